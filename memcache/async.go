@@ -0,0 +1,268 @@
+package memcache
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// pipelineDepth is the number of requests that can be queued on a
+	// server's pipeline, and awaiting responses, before callers block.
+	pipelineDepth = 1024
+
+	// pipelineIdleTimeout is how long an unused pipeline keeps its
+	// connection and goroutines.
+	pipelineIdleTimeout = 30 * time.Second
+)
+
+// Future is the pending result of an asynchronous operation such as
+// GetAsync.
+type Future struct {
+	done   chan struct{}
+	item   *Item
+	err    error
+	once   sync.Once
+	finish func(*Item) error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) resolve(item *Item, err error) {
+	f.item, f.err = item, err
+	close(f.done)
+}
+
+// Done returns a channel that is closed when the result is available.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the result of the operation and returns it: the item
+// for GetAsync, or nil for SetAsync, and the error the operation would
+// have returned if run synchronously.
+func (f *Future) Wait() (*Item, error) {
+	<-f.done
+	f.once.Do(func() {
+		if f.err == nil && f.finish != nil {
+			if err := f.finish(f.item); err != nil {
+				f.item, f.err = nil, err
+			}
+		}
+	})
+	return f.item, f.err
+}
+
+// GetAsync starts getting the item for key and returns a Future for it.
+// Requests to a server are pipelined on a connection dedicated to
+// asynchronous operations, written back to back and matched to their
+// responses in order, so that many operations can be in flight without
+// a goroutine per call. The value is decoded by the client's
+// Transcoders in Wait. Asynchronous operations use the key's primary
+// server only, and are not seen by Interceptors.
+func (c *Client) GetAsync(key string) *Future {
+	f := newFuture()
+	orig := key
+	f.finish = func(it *Item) error {
+		c.restoreItem(it, orig)
+		return c.finishRead(it)
+	}
+	key = c.storageKey(key)
+	lim := c.limits()
+	c.submit(key, f, func(w *bufio.Writer) error {
+		_, err := w.WriteString("gets " + key + "\r\n")
+		return err
+	}, func(r *bufio.Reader) (item *Item, err error) {
+		err = parseGetResponse(r, lim, []string{key}, c.StrictResponses, func(it *Item) { item = it })
+		if err == nil && item == nil {
+			err = ErrCacheMiss
+		}
+		return item, err
+	})
+	return f
+}
+
+// SetAsync starts writing item, unconditionally, as Set does, and
+// returns a Future for the result. item is encoded, and chunked if
+// needed, before SetAsync returns. See GetAsync for how requests are
+// sent.
+func (c *Client) SetAsync(item *Item) *Future {
+	f := newFuture()
+	if c.ReadOnly {
+		f.resolve(nil, ErrReadOnly)
+		return f
+	}
+	if c.mapsKeys() {
+		pi := *item
+		pi.Key = c.storageKey(pi.Key)
+		item = &pi
+	}
+	it, err := c.prepareStore(item)
+	if err != nil {
+		f.resolve(nil, err)
+		return f
+	}
+	c.submit(it.Key, f, func(w *bufio.Writer) error {
+		return writeStore(w, "set", it)
+	}, func(r *bufio.Reader) (*Item, error) {
+		err := readStoreLine(r, "set")
+		c.L1.remove(it.Key)
+		return nil, err
+	})
+	return f
+}
+
+// pipeReq is a request queued on a pipeline.
+type pipeReq struct {
+	write func(w *bufio.Writer) error
+	read  func(r *bufio.Reader) (*Item, error)
+	f     *Future
+	keys  []string
+}
+
+// submit queues a request for key on the pipeline of its server.
+func (c *Client) submit(key string, f *Future, write func(w *bufio.Writer) error, read func(r *bufio.Reader) (*Item, error)) {
+	keys := []string{key}
+	if !legalKey(key) {
+		f.resolve(nil, c.withKeys(keys, ErrMalformedKey))
+		return
+	}
+	addr, err := c.pickServer(key)
+	if err != nil {
+		f.resolve(nil, err)
+		return
+	}
+	c.state.pipes.get(c, addr).submit(&pipeReq{write: write, read: read, f: f, keys: keys})
+}
+
+// pipelines holds a client's pipelines, by server address.
+type pipelines struct {
+	mu sync.Mutex
+	m  map[string]*pipeline
+}
+
+func (ps *pipelines) get(c *Client, addr net.Addr) *pipeline {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	p, ok := ps.m[addr.String()]
+	if !ok {
+		if ps.m == nil {
+			ps.m = make(map[string]*pipeline)
+		}
+		p = &pipeline{c: c, addr: addr, reqs: make(chan *pipeReq, pipelineDepth)}
+		ps.m[addr.String()] = p
+	}
+	return p
+}
+
+// pipeline sends asynchronous requests to one server. Its writer
+// goroutine, started on demand and stopped when idle, writes queued
+// requests on a dedicated connection, flushing whenever the queue is
+// empty, and passes them on to a reader goroutine that reads their
+// responses in order.
+type pipeline struct {
+	c    *Client
+	addr net.Addr
+	reqs chan *pipeReq
+
+	mu      sync.Mutex
+	running bool
+}
+
+func (p *pipeline) submit(req *pipeReq) {
+	// Sending under mu keeps the writer from stopping with a request
+	// left in reqs.
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.running {
+		p.running = true
+		go p.write()
+	}
+	p.reqs <- req
+}
+
+func (p *pipeline) write() {
+	var cn *conn
+	var pending chan *pipeReq
+	closeConn := func() {
+		if cn != nil {
+			cn.nc.Close()
+			close(pending)
+			cn, pending = nil, nil
+		}
+	}
+	idle := time.NewTimer(pipelineIdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case req := <-p.reqs:
+			if cn == nil {
+				var err error
+				if cn, err = p.c.getConn(p.addr, opWrite); err != nil {
+					cn = nil
+					req.f.resolve(nil, p.c.withKeys(req.keys, err))
+					break
+				}
+				pending = make(chan *pipeReq, pipelineDepth)
+				go p.c.readPipeline(cn, pending)
+			}
+			cn.nc.SetWriteDeadline(time.Now().Add(p.c.opTimeout(opWrite)))
+			if err := req.write(cn.rw.Writer); err != nil {
+				req.f.resolve(nil, p.c.withKeys(req.keys, err))
+				closeConn()
+				break
+			}
+			// Once queued, the reader resolves the request, failing it
+			// if the connection breaks.
+			pending <- req
+			if len(p.reqs) == 0 {
+				if err := cn.rw.Flush(); err != nil {
+					closeConn()
+				}
+			}
+		case <-idle.C:
+			p.mu.Lock()
+			if len(p.reqs) > 0 {
+				p.mu.Unlock()
+				break
+			}
+			p.running = false
+			p.mu.Unlock()
+			closeConn()
+			return
+		}
+		if !idle.Stop() {
+			select {
+			case <-idle.C:
+			default:
+			}
+		}
+		idle.Reset(pipelineIdleTimeout)
+	}
+}
+
+// readPipeline reads the responses to the requests passed on pending,
+// in order. After a connection error, it fails the remaining requests.
+func (c *Client) readPipeline(cn *conn, pending chan *pipeReq) {
+	var cerr error
+	for req := range pending {
+		if cerr != nil {
+			req.f.resolve(nil, c.withKeys(req.keys, cerr))
+			continue
+		}
+		cn.nc.SetReadDeadline(time.Now().Add(c.opTimeout(opRead)))
+		item, err := req.read(cn.rw.Reader)
+		if err != nil && !resumableError(err) {
+			cerr = err
+			cn.nc.Close()
+		}
+		if err != nil {
+			req.f.resolve(nil, c.withKeys(req.keys, err))
+			continue
+		}
+		req.f.resolve(item, nil)
+	}
+}
@@ -0,0 +1,82 @@
+package memcache
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestAsync(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	var sets []*Future
+	for i := 0; i < 100; i++ {
+		sets = append(sets, c.SetAsync(&Item{Key: fmt.Sprintf("k%d", i), Value: []byte(fmt.Sprint(i))}))
+	}
+	for i, f := range sets {
+		if it, err := f.Wait(); err != nil || it != nil {
+			t.Fatalf("SetAsync %d = %v, %v", i, it, err)
+		}
+	}
+
+	gets := make([]*Future, 101)
+	for i := range gets {
+		gets[i] = c.GetAsync(fmt.Sprintf("k%d", i))
+	}
+	for i, f := range gets[:100] {
+		<-f.Done()
+		it, err := f.Wait()
+		if err != nil || string(it.Value) != fmt.Sprint(i) {
+			t.Errorf("GetAsync(k%d) = %v, %v", i, it, err)
+		}
+	}
+	if _, err := gets[100].Wait(); err != ErrCacheMiss {
+		t.Errorf("GetAsync of missing key = %v, want ErrCacheMiss", err)
+	}
+	if _, err := c.GetAsync("bad key").Wait(); err != ErrMalformedKey {
+		t.Errorf("GetAsync of bad key = %v, want ErrMalformedKey", err)
+	}
+	// All requests shared the pipeline's connection.
+	if st := c.ConnStats()[s.Addr()]; st.Dials != 1 {
+		t.Errorf("dials = %d, want 1", st.Dials)
+	}
+
+	// Values are decoded in Wait.
+	c.Transcoders = []Transcoder{&CompressionTranscoder{MinSize: 1}}
+	if _, err := c.SetAsync(&Item{Key: "z", Value: []byte("zzzzzzzz")}).Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if it, err := c.GetAsync("z").Wait(); err != nil || string(it.Value) != "zzzzzzzz" {
+		t.Errorf("GetAsync with transcoder = %v, %v", it, err)
+	}
+}
+
+func TestAsyncConnectionFailure(t *testing.T) {
+	// The server hangs up on every connection.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			nc.Close()
+		}
+	}()
+	c := New(ln.Addr().String())
+	for i := 0; i < 2; i++ {
+		f1, f2 := c.GetAsync("a"), c.SetAsync(&Item{Key: "b"})
+		if _, err := f1.Wait(); err == nil || err == ErrCacheMiss {
+			t.Errorf("GetAsync on broken connection = %v, want connection error", err)
+		}
+		if _, err := f2.Wait(); err == nil {
+			t.Error("SetAsync on broken connection succeeded")
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+)
+
+// BatchResult reports the outcome of each key of a batch operation such
+// as SetMulti. A key maps to nil if the operation succeeded for it, or
+// to the error it failed with: a cache-level error such as ErrNotStored
+// or ErrCacheMiss, ErrMalformedKey, or the error of the server the key
+// was sent to.
+type BatchResult map[string]error
+
+// Failed returns the keys that failed, sorted.
+func (r BatchResult) Failed() []string {
+	var keys []string
+	for key, err := range r {
+		if err != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Err returns nil if every key succeeded, or an error wrapping the
+// error of the first failed key in sorted order. The error does not
+// name the key, which may hold user data; Failed lists the keys.
+func (r BatchResult) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("memcache: %d of %d keys failed: %w", len(failed), len(r), r[failed[0]])
+}
+
+// SetMulti writes the given items, unconditionally. If several items
+// have the same key, the last one's result is reported.
+func (c *Client) SetMulti(items []*Item) BatchResult {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	return c.interceptBatch(&Op{Name: "set_multi", Keys: keys, Batch: items}, func(op *Op) BatchResult {
+		return c.setMulti(op.Batch)
+	})
+}
+
+func (c *Client) setMulti(items []*Item) BatchResult {
+	var keys []string
+	var encoded []*Item
+	encErrs := make(BatchResult)
+	for _, item := range items {
+		it, err := c.prepareStore(item)
+		if err != nil {
+			encErrs[item.Key] = err
+			continue
+		}
+		keys = append(keys, it.Key)
+		encoded = append(encoded, it)
+	}
+	res := c.batch("set", keys, func(w *bufio.Writer, i int) error {
+		return writeStore(w, "set", encoded[i])
+	}, func(r *bufio.Reader, i int) error {
+		return readStoreLine(r, "set")
+	})
+	for key, err := range encErrs {
+		res[key] = err
+	}
+	return res
+}
+
+// DeleteMulti deletes the items with the provided keys. A key that
+// didn't exist in the cache is reported as ErrCacheMiss.
+func (c *Client) DeleteMulti(keys []string) BatchResult {
+	if len(c.Interceptors) > 0 {
+		// Interceptors may rewrite op.Keys in place.
+		keys = append([]string(nil), keys...)
+	}
+	return c.interceptBatch(&Op{Name: "delete_multi", Keys: keys}, func(op *Op) BatchResult {
+		keys := op.Keys
+		res := c.batch("delete", keys, func(w *bufio.Writer, i int) error {
+			_, err := fmt.Fprintf(w, "delete %s\r\n", keys[i])
+			return err
+		}, func(r *bufio.Reader, i int) error {
+			return readExpect(r, resultDeleted)
+		})
+		for key, err := range res {
+			if err == nil || errors.Is(err, ErrCacheMiss) {
+				c.publishInvalidation(InvalidationEvent{Key: key})
+			}
+		}
+		return res
+	})
+}
+
+// TouchMulti updates the expiry of the items with the provided keys, as
+// Touch does. A key that isn't in the cache is reported as
+// ErrCacheMiss.
+func (c *Client) TouchMulti(keys []string, seconds int32) BatchResult {
+	if len(c.Interceptors) > 0 {
+		// Interceptors may rewrite op.Keys in place.
+		keys = append([]string(nil), keys...)
+	}
+	return c.interceptBatch(&Op{Name: "touch_multi", Keys: keys}, func(op *Op) BatchResult {
+		keys := op.Keys
+		return c.batch("touch", keys, func(w *bufio.Writer, i int) error {
+			_, err := fmt.Fprintf(w, "touch %s %d\r\n", keys[i], seconds)
+			return err
+		}, func(r *bufio.Reader, i int) error {
+			return readExpect(r, resultTouched)
+		})
+	})
+}
+
+// interceptBatch runs the batch operation fn through the client's
+// interceptors, which see the error of its result, and returns the
+// result. If an interceptor fails the operation without running it,
+// every key is reported with the interceptor's error.
+func (c *Client) interceptBatch(op *Op, fn func(op *Op) BatchResult) BatchResult {
+	err := c.intercept(op, func(ctx context.Context, op *Op) error {
+		op.Result = fn(op)
+		return op.Result.Err()
+	})
+	if op.Result == nil && err != nil {
+		op.Result = make(BatchResult, len(op.Keys))
+		for _, key := range op.Keys {
+			op.Result[key] = err
+		}
+	}
+	return op.Result
+}
+
+// batchWindow is the number of commands a batch writes before reading
+// their responses. Bounding it keeps the responses of a large batch
+// from filling the socket buffers while the client is still writing,
+// which would stall both ends.
+const batchWindow = 256
+
+// batch runs a command for each of keys: write writes the command for
+// the key at index i and read reads its response. Keys are grouped by
+// server and each group is pipelined on a single connection, writing a
+// window of commands with one flush before reading their responses.
+// Groups run concurrently, at most BatchParallelism at a time. A server
+// error fails the key it occurred on and every key after it in the
+// group. When replication is enabled, each key is written to its
+// replicas separately.
+func (c *Client) batch(op string, keys []string, write func(w *bufio.Writer, i int) error, read func(r *bufio.Reader, i int) error) BatchResult {
+	res := make(BatchResult, len(keys))
+	if c.Replicas > 1 {
+		for i, key := range keys {
+			if !legalKey(key) {
+				res[key] = ErrMalformedKey
+				continue
+			}
+			res[key] = c.withKeyWriteRw(key, op, func(rw *bufio.ReadWriter) error {
+				if err := write(rw.Writer, i); err != nil {
+					return err
+				}
+				if err := rw.Flush(); err != nil {
+					return err
+				}
+				return read(rw.Reader, i)
+			})
+		}
+		return res
+	}
+	var addrs []net.Addr
+	groups := make(map[string][]int)
+	for i, key := range keys {
+		if !legalKey(key) {
+			res[key] = ErrMalformedKey
+			continue
+		}
+		addr, err := c.pickServer(key)
+		if err != nil {
+			res[key] = err
+			continue
+		}
+		if _, ok := groups[addr.String()]; !ok {
+			addrs = append(addrs, addr)
+		}
+		groups[addr.String()] = append(groups[addr.String()], i)
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var sem chan struct{}
+	if c.BatchParallelism > 0 {
+		sem = make(chan struct{}, c.BatchParallelism)
+	}
+	for _, addr := range addrs {
+		wg.Add(1)
+		if sem != nil {
+			sem <- struct{}{}
+		}
+		go func(addr net.Addr, idx []int) {
+			defer wg.Done()
+			gres := c.batchGroup(addr, op, keys, idx, write, read)
+			mu.Lock()
+			for key, err := range gres {
+				res[key] = err
+			}
+			mu.Unlock()
+			if sem != nil {
+				<-sem
+			}
+		}(addr, groups[addr.String()])
+	}
+	wg.Wait()
+	return res
+}
+
+// batchGroup runs the commands for the keys at indexes idx, which all
+// map to addr, on one connection.
+func (c *Client) batchGroup(addr net.Addr, op string, keys []string, idx []int, write func(w *bufio.Writer, i int) error, read func(r *bufio.Reader, i int) error) BatchResult {
+	res := make(BatchResult, len(idx))
+	gkeys := make([]string, len(idx))
+	for j, i := range idx {
+		gkeys[j] = keys[i]
+	}
+	done := 0
+	err := c.withAddrRw(addr, op, gkeys, func(rw *bufio.ReadWriter) error {
+		for done < len(idx) {
+			window := idx[done:]
+			if len(window) > batchWindow {
+				window = window[:batchWindow]
+			}
+			for _, i := range window {
+				if err := write(rw.Writer, i); err != nil {
+					return err
+				}
+			}
+			if err := rw.Flush(); err != nil {
+				return err
+			}
+			for _, i := range window {
+				err := read(rw.Reader, i)
+				if err != nil && !resumableError(err) {
+					return err
+				}
+				res[keys[i]] = err
+				done++
+			}
+		}
+		return nil
+	})
+	for _, i := range idx[done:] {
+		res[keys[i]] = err
+	}
+	return res
+}
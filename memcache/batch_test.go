@@ -0,0 +1,137 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBatchResults(t *testing.T) {
+	s1, s2 := newFakeServer(t), newFakeServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	c := New(s1.Addr(), s2.Addr())
+
+	var items []*Item
+	keys := []string{"a", "b", "c", "d", "e", "f"}
+	for _, key := range keys {
+		items = append(items, &Item{Key: key, Value: []byte("v-" + key)})
+	}
+	items = append(items, &Item{Key: "bad key", Value: []byte("x")})
+	res := c.SetMulti(items)
+	if got := res.Failed(); !reflect.DeepEqual(got, []string{"bad key"}) {
+		t.Fatalf("SetMulti failed keys = %v, want [bad key]", got)
+	}
+	if !errors.Is(res.Err(), ErrMalformedKey) {
+		t.Errorf("SetMulti Err() = %v, want ErrMalformedKey", res.Err())
+	}
+	for _, key := range keys {
+		if it, err := c.Get(key); err != nil || string(it.Value) != "v-"+key {
+			t.Errorf("Get(%q) after SetMulti = %v, %v", key, it, err)
+		}
+	}
+
+	res = c.TouchMulti([]string{"a", "missing"}, 60)
+	if res["a"] != nil || res["missing"] != ErrCacheMiss {
+		t.Errorf("TouchMulti = %v, want a ok and missing ErrCacheMiss", res)
+	}
+
+	res = c.DeleteMulti(append(keys, "missing"))
+	if got := res.Failed(); !reflect.DeepEqual(got, []string{"missing"}) {
+		t.Errorf("DeleteMulti failed keys = %v, want [missing]", got)
+	}
+	for _, key := range keys {
+		if _, ok := s1.get(key); ok {
+			t.Errorf("%q still on server 1 after DeleteMulti", key)
+		}
+		if _, ok := s2.get(key); ok {
+			t.Errorf("%q still on server 2 after DeleteMulti", key)
+		}
+	}
+}
+
+func TestBatchServerFailure(t *testing.T) {
+	s := newFakeServer(t)
+	s.Close()
+	c := New(s.Addr())
+	res := c.DeleteMulti([]string{"a", "b"})
+	for _, key := range []string{"a", "b"} {
+		if !errors.Is(res[key], ErrConnFailed) {
+			t.Errorf("DeleteMulti[%q] = %v, want ErrConnFailed", key, res[key])
+		}
+	}
+}
+
+func TestTouch(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	s.put("foo", []byte("bar"), 0)
+	if err := c.Touch("foo", 10); err != nil {
+		t.Errorf("Touch(foo) = %v", err)
+	}
+	if err := c.Touch("missing", 10); err != ErrCacheMiss {
+		t.Errorf("Touch(missing) = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestBatchPipelined(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	// More keys than a window, with every third one missing.
+	var items []*Item
+	var keys []string
+	for i := 0; i < 2*batchWindow+10; i++ {
+		key := fmt.Sprintf("k%d", i)
+		keys = append(keys, key)
+		if i%3 != 0 {
+			items = append(items, &Item{Key: key, Value: []byte(key)})
+		}
+	}
+	if err := c.SetMulti(items).Err(); err != nil {
+		t.Fatalf("SetMulti: %v", err)
+	}
+	res := c.DeleteMulti(keys)
+	for i, key := range keys {
+		want := error(nil)
+		if i%3 == 0 {
+			want = ErrCacheMiss
+		}
+		if res[key] != want {
+			t.Errorf("DeleteMulti result for %s = %v, want %v", key, res[key], want)
+		}
+	}
+	if st := c.ConnStats()[s.Addr()]; st.Dials != 1 {
+		t.Errorf("dials = %d, want 1", st.Dials)
+	}
+}
+
+func TestBatchParallelism(t *testing.T) {
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		s := newFakeServer(t)
+		defer s.Close()
+		addrs = append(addrs, s.Addr())
+	}
+	for _, par := range []int{0, 1, 2} {
+		c := New(addrs...)
+		c.BatchParallelism = par
+		var items []*Item
+		var keys []string
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("p%d", i)
+			keys = append(keys, key)
+			items = append(items, &Item{Key: key, Value: []byte(key)})
+		}
+		if err := c.SetMulti(items).Err(); err != nil {
+			t.Fatalf("parallelism %d: SetMulti: %v", par, err)
+		}
+		res := c.DeleteMulti(append(keys, "never-set"))
+		if failed := res.Failed(); !reflect.DeepEqual(failed, []string{"never-set"}) || len(res) != 51 {
+			t.Errorf("parallelism %d: DeleteMulti failed keys = %v of %d", par, failed, len(res))
+		}
+	}
+}
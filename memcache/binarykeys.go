@@ -0,0 +1,150 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+)
+
+// binaryKey reports whether key is sent base64-encoded, with the b flag
+// of the meta commands: BinaryKeys and MetaProtocol are set, and key,
+// which isn't a legal text key, fits the length limit.
+func (c *Client) binaryKey(key string) bool {
+	return c.BinaryKeys && c.MetaProtocol && len(key) > 0 && len(key) <= maxKeyLength && !legalKey(key)
+}
+
+// validKey reports whether key can be sent to the servers.
+func (c *Client) validKey(key string) bool {
+	return legalKey(key) || c.binaryKey(key)
+}
+
+// metaKey returns key as written in meta commands taking flags right
+// after the key: as is, or base64-encoded and followed by the b flag.
+func (c *Client) metaKey(key string) string {
+	if c.binaryKey(key) {
+		return base64.StdEncoding.EncodeToString([]byte(key)) + " b"
+	}
+	return key
+}
+
+// metaModes are the ms modes of the storage commands.
+var metaModes = map[string]string{"set": "S", "add": "E", "replace": "R", "cas": "S"}
+
+// metaStore writes item with "ms", as the storage command verb does.
+func (c *Client) metaStore(rw *bufio.ReadWriter, verb string, item *Item) error {
+	key, b := item.Key, ""
+	if c.binaryKey(key) {
+		key, b = base64.StdEncoding.EncodeToString([]byte(key)), " b"
+	}
+	_, err := fmt.Fprintf(rw, "ms %s %d F%d T%d M%s%s", key, len(item.Value), item.Flags, item.Expiration, metaModes[verb], b)
+	if err == nil && verb == "cas" {
+		_, err = fmt.Fprintf(rw, " C%d", item.casid)
+	}
+	if err == nil && c.returnsCAS() {
+		_, err = rw.WriteString(" c")
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := rw.Write(crlf); err != nil {
+		return err
+	}
+	if _, err := rw.Write(item.Value); err != nil {
+		return err
+	}
+	if _, err := rw.Write(crlf); err != nil {
+		return err
+	}
+	if c.returnsCAS() {
+		return c.readStoredCAS(rw, item)
+	}
+	return readMetaStatus(rw, "ms")
+}
+
+// metaDelete deletes key with "md".
+func (c *Client) metaDelete(rw *bufio.ReadWriter, key string) error {
+	if _, err := fmt.Fprintf(rw, "md %s\r\n", c.metaKey(key)); err != nil {
+		return err
+	}
+	return readMetaStatus(rw, "md")
+}
+
+// metaTouch sets the expiration of key with "mg" and the T flag.
+func (c *Client) metaTouch(rw *bufio.ReadWriter, key string, seconds int32) error {
+	if _, err := fmt.Fprintf(rw, "mg %s T%d\r\n", c.metaKey(key), seconds); err != nil {
+		return err
+	}
+	return readMetaStatus(rw, "mg")
+}
+
+// metaIncrDecr applies verb, "incr" or "decr", with delta to key with
+// "ma", as the incr and decr commands do.
+func (c *Client) metaIncrDecr(rw *bufio.ReadWriter, verb, key string, delta uint64) (uint64, error) {
+	mode := "I"
+	if verb == "decr" {
+		mode = "D"
+	}
+	if _, err := fmt.Fprintf(rw, "ma %s D%d M%s v\r\n", c.metaKey(key), delta, mode); err != nil {
+		return 0, err
+	}
+	if err := rw.Flush(); err != nil {
+		return 0, err
+	}
+	mr, err := readMetaResponse(rw.Reader, c.limits(), c.StrictResponses)
+	if err != nil {
+		return 0, err
+	}
+	switch mr.status {
+	case "VA":
+	case "NF":
+		return 0, ErrCacheMiss
+	default:
+		return 0, fmt.Errorf("%w: unexpected %s response to ma", ErrProtocol, mr.status)
+	}
+	return strconv.ParseUint(string(mr.value), 10, 64)
+}
+
+// readMetaStatus flushes a meta command without a value in its response
+// and maps the status it answers with to an error.
+func readMetaStatus(rw *bufio.ReadWriter, cmd string) error {
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	line, err := rw.ReadSlice('\n')
+	if err != nil {
+		return err
+	}
+	status := line
+	if i := bytes.IndexAny(line, " \r"); i >= 0 {
+		status = line[:i]
+	}
+	switch string(status) {
+	case "HD":
+		return nil
+	case "ERROR":
+		return ErrMetaUnsupported
+	}
+	if err := metaStatusError(string(status)); err != nil {
+		return err
+	}
+	if err := checkServerError(line); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: unexpected response %q to %s", ErrProtocol, line, cmd)
+}
+
+// metaStatusError maps the status of a failed meta command to an error,
+// or returns nil for an unknown status.
+func metaStatusError(status string) error {
+	switch status {
+	case "NS":
+		return ErrNotStored
+	case "EX":
+		return ErrCASConflict
+	case "NF", "EN":
+		return ErrCacheMiss
+	}
+	return nil
+}
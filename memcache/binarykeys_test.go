@@ -0,0 +1,72 @@
+package memcache
+
+import (
+	"crypto/sha1"
+	"testing"
+)
+
+func TestBinaryKeys(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.MetaProtocol = true
+	sum := sha1.Sum([]byte("struct digest"))
+	key := string(sum[:]) + " \r\n"
+
+	if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != ErrMalformedKey {
+		t.Fatalf("Set of binary key without BinaryKeys = %v, want ErrMalformedKey", err)
+	}
+	c.BinaryKeys = true
+
+	mustSet(t, c, &Item{Key: key, Value: []byte("v"), Flags: 7})
+	if it, ok := s.get(key); !ok || string(it.value) != "v" || it.flags != 7 {
+		t.Fatalf("stored item = %+v, %v", it, ok)
+	}
+	it, err := c.Get(key)
+	if err != nil || it.Key != key || string(it.Value) != "v" || it.Flags != 7 {
+		t.Fatalf("Get = %+v, %v", it, err)
+	}
+	items, err := c.GetMulti([]string{key, "text"})
+	if err != nil || len(items) != 1 || items[key] == nil {
+		t.Fatalf("GetMulti = %v, %v", items, err)
+	}
+
+	it.Value = []byte("w")
+	if err := c.CompareAndSwap(it); err != nil {
+		t.Fatalf("CompareAndSwap = %v", err)
+	}
+	if err := c.CompareAndSwap(it); err != ErrCASConflict {
+		t.Errorf("stale CompareAndSwap = %v, want ErrCASConflict", err)
+	}
+	if err := c.Add(&Item{Key: key, Value: []byte("x")}); err != ErrNotStored {
+		t.Errorf("Add of existing key = %v, want ErrNotStored", err)
+	}
+	if err := c.Touch(key, 60); err != nil {
+		t.Errorf("Touch = %v", err)
+	}
+	if it, _ := s.get(key); it.exptime != "60" {
+		t.Errorf("exptime after Touch = %q", it.exptime)
+	}
+
+	counter := key + "n"
+	mustSet(t, c, &Item{Key: counter, Value: []byte("5")})
+	if n, err := c.Increment(counter, 2); err != nil || n != 7 {
+		t.Errorf("Increment = %d, %v", n, err)
+	}
+	if n, err := c.Decrement(counter, 10); err != nil || n != 0 {
+		t.Errorf("Decrement = %d, %v", n, err)
+	}
+
+	if err := c.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(key); err != ErrCacheMiss {
+		t.Errorf("second Delete = %v, want ErrCacheMiss", err)
+	}
+	if _, err := c.Get(key); err != ErrCacheMiss {
+		t.Errorf("Get after Delete = %v, want ErrCacheMiss", err)
+	}
+	if res := c.DeleteMulti([]string{counter}); res[counter] != ErrMalformedKey {
+		t.Errorf("DeleteMulti of binary key = %v, want ErrMalformedKey", res)
+	}
+}
@@ -0,0 +1,30 @@
+package memcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBufferSizes(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.ReadBufferSize = 64 << 10
+	c.WriteBufferSize = 16
+
+	val := strings.Repeat("v", 100000)
+	mustSet(t, c, &Item{Key: "big", Value: []byte(val)})
+	it, err := c.Get("big")
+	if err != nil || string(it.Value) != val {
+		t.Fatalf("Get = %d bytes, %v", len(it.Value), err)
+	}
+	addr, _ := c.selector.PickServer("big")
+	cn, err := c.getConn(addr, opRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cn.release()
+	if r, w := cn.rw.Reader.Size(), cn.rw.Writer.Size(); r != 64<<10 || w != 16 {
+		t.Errorf("buffer sizes = %d, %d; want %d, 16", r, w, 64<<10)
+	}
+}
@@ -0,0 +1,139 @@
+package memcache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+// InvalidationEvent is the invalidation of a key or a tag, carried by
+// an InvalidationBus.
+type InvalidationEvent struct {
+	// Key is the deleted key, as stored on the servers, including the
+	// publishing client's KeyPrefix, or "" for a tag invalidation.
+	Key string
+
+	// Tag is the invalidated tag, or "" for a key invalidation. Tags,
+	// like keys passed to the client, are subject to the KeyPrefix of
+	// the client applying the event.
+	Tag string
+
+	// Origin identifies the client that published the event, so that
+	// clients ignore their own events.
+	Origin string
+}
+
+// InvalidationBus carries invalidation events between clients, such as
+// those of the clusters of several regions, so that a key deleted or a
+// tag invalidated in one cluster is in the others too, along with the
+// L1 caches of their clients. ChannelBus is an in-process
+// implementation; adapters to message brokers implement the interface
+// in the same way.
+type InvalidationBus interface {
+	// Publish sends ev to the bus's subscribers.
+	Publish(ev InvalidationEvent) error
+
+	// Subscribe calls fn for every event published from now on, until
+	// the returned function is called.
+	Subscribe(fn func(InvalidationEvent)) (unsubscribe func())
+}
+
+// origin returns the ID the client publishes invalidation events with.
+// Clients sharing state, such as those returned by OnServer, share it.
+func (c *Client) origin() string {
+	c.state.originOnce.Do(func() {
+		var b [8]byte
+		rand.Read(b[:])
+		c.state.origin = hex.EncodeToString(b[:])
+	})
+	return c.state.origin
+}
+
+// publishInvalidation publishes ev to the client's Invalidations bus,
+// if any. Failures are logged.
+func (c *Client) publishInvalidation(ev InvalidationEvent) {
+	if c.Invalidations == nil {
+		return
+	}
+	ev.Origin = c.origin()
+	if err := c.Invalidations.Publish(ev); err != nil {
+		c.logWarn("memcache: failed to publish invalidation", "key", ev.Key, "tag", ev.Tag, "err", err)
+	}
+}
+
+// ConsumeInvalidations subscribes to bus and applies the events other
+// clients publish to it: keys are deleted, and tags invalidated, in the
+// client's servers and L1 cache, without publishing them again. Call
+// the returned function to stop.
+func (c *Client) ConsumeInvalidations(bus InvalidationBus) (stop func()) {
+	origin := c.origin()
+	return bus.Subscribe(func(ev InvalidationEvent) {
+		if ev.Origin == origin {
+			return
+		}
+		var err error
+		switch {
+		case ev.Key != "":
+			c.L1.remove(ev.Key)
+			if err = c.delete(ev.Key); errors.Is(err, ErrCacheMiss) {
+				err = nil
+			}
+		case ev.Tag != "":
+			err = c.invalidateTag(ev.Tag)
+		}
+		if err != nil {
+			c.logWarn("memcache: failed to apply invalidation", "key", ev.Key, "tag", ev.Tag, "err", err)
+		}
+	})
+}
+
+// ChannelBus is an in-process InvalidationBus delivering each event to
+// every subscriber through a buffered channel and a goroutine per
+// subscriber, in publication order. Publish blocks while a
+// subscriber's buffer is full.
+type ChannelBus struct {
+	mu   sync.RWMutex
+	subs map[*chan InvalidationEvent]struct{}
+}
+
+// channelBusBuffer is the size of each ChannelBus subscriber's buffer.
+const channelBusBuffer = 64
+
+// NewChannelBus returns an empty ChannelBus.
+func NewChannelBus() *ChannelBus {
+	return &ChannelBus{subs: make(map[*chan InvalidationEvent]struct{})}
+}
+
+// Publish delivers ev to the current subscribers.
+func (b *ChannelBus) Publish(ev InvalidationEvent) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs {
+		*ch <- ev
+	}
+	return nil
+}
+
+// Subscribe calls fn, from a goroutine of its own, for every event
+// published until the returned function is called.
+func (b *ChannelBus) Subscribe(fn func(InvalidationEvent)) (unsubscribe func()) {
+	ch := make(chan InvalidationEvent, channelBusBuffer)
+	b.mu.Lock()
+	b.subs[&ch] = struct{}{}
+	b.mu.Unlock()
+	go func() {
+		for ev := range ch {
+			fn(ev)
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, &ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
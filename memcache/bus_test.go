@@ -0,0 +1,103 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInvalidationBus(t *testing.T) {
+	bus := NewChannelBus()
+	s1, s2 := newFakeServer(t), newFakeServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	c1, c2 := New(s1.Addr()), New(s2.Addr())
+	c1.Invalidations = bus
+	c2.Invalidations = bus
+	c2.L1 = NewL1Cache(10, 0, time.Minute)
+	defer c1.ConsumeInvalidations(bus)()
+	defer c2.ConsumeInvalidations(bus)()
+
+	for _, c := range []*Client{c1, c2} {
+		mustSet(t, c, &Item{Key: "a", Value: []byte("1")})
+		mustSet(t, c, &Item{Key: "b", Value: []byte("2")})
+	}
+	if _, err := c2.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A delete in one cluster propagates to the other and its L1.
+	if err := c1.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "delete of a", func() bool {
+		_, ok := s2.get("a")
+		return !ok
+	})
+	if _, err := c2.Get("a"); err != ErrCacheMiss {
+		t.Errorf("Get after remote delete = %v, want ErrCacheMiss", err)
+	}
+
+	res := c2.DeleteMulti([]string{"b"})
+	if err := res.Err(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "delete of b", func() bool {
+		_, ok := s1.get("b")
+		return !ok
+	})
+
+	// Tag invalidations propagate too.
+	for _, c := range []*Client{c1, c2} {
+		if err := c.SetTagged(&Item{Key: "t", Value: []byte("3")}, "list"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c1.InvalidateTag("list"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "tag invalidation", func() bool {
+		_, err := c2.GetTagged("t", "list")
+		return err == ErrCacheMiss
+	})
+}
+
+func TestChannelBusIgnoresOwnEvents(t *testing.T) {
+	bus := NewChannelBus()
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.Invalidations = bus
+	defer c.ConsumeInvalidations(bus)()
+
+	got := make(chan InvalidationEvent, 1)
+	defer bus.Subscribe(func(ev InvalidationEvent) { got <- ev })()
+
+	mustSet(t, c, &Item{Key: "a", Value: []byte("1")})
+	if err := c.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	ev := <-got
+	if ev.Key != "a" || ev.Tag != "" || ev.Origin != c.origin() {
+		t.Errorf("event = %+v", ev)
+	}
+
+	// The client doesn't apply its own event: a value set after the
+	// delete survives.
+	mustSet(t, c, &Item{Key: "a", Value: []byte("2")})
+	time.Sleep(10 * time.Millisecond)
+	if it, err := c.Get("a"); err != nil || string(it.Value) != "2" {
+		t.Errorf("Get = %+v, %v", it, err)
+	}
+}
@@ -0,0 +1,64 @@
+package memcache
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// DefaultChecksumFlag is the Item.Flags bit marking values checksummed
+// by a ChecksumTranscoder with no Flag set.
+const DefaultChecksumFlag uint32 = 1 << 26
+
+// ErrChecksum is returned when a value read from a server does not
+// match its checksum, which indicates corruption in memory or a
+// desynchronized connection.
+var ErrChecksum = errors.New("memcache: value checksum mismatch")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumTranscoder is a Transcoder appending a CRC-32C checksum to
+// values and marking them with the Flag bit. On read, marked values are
+// verified and ErrChecksum is returned on mismatch; unmarked values are
+// passed through unchecked, so checksums can be enabled on a live
+// cluster.
+//
+// To also cover compressed or encrypted bytes, list ChecksumTranscoder
+// after the transcoders producing them.
+type ChecksumTranscoder struct {
+	// Flag is the Item.Flags bit marking checksummed values. If zero,
+	// DefaultChecksumFlag is used.
+	Flag uint32
+}
+
+func (t *ChecksumTranscoder) flag() uint32 {
+	if t.Flag != 0 {
+		return t.Flag
+	}
+	return DefaultChecksumFlag
+}
+
+func (t *ChecksumTranscoder) Encode(item *Item) error {
+	if item.Flags&t.flag() != 0 {
+		return ErrFlagInUse
+	}
+	v := make([]byte, len(item.Value)+4)
+	copy(v, item.Value)
+	binary.BigEndian.PutUint32(v[len(item.Value):], crc32.Checksum(item.Value, castagnoli))
+	item.Value = v
+	item.Flags |= t.flag()
+	return nil
+}
+
+func (t *ChecksumTranscoder) Decode(item *Item) error {
+	if item.Flags&t.flag() == 0 {
+		return nil
+	}
+	n := len(item.Value) - 4
+	if n < 0 || crc32.Checksum(item.Value[:n], castagnoli) != binary.BigEndian.Uint32(item.Value[n:]) {
+		return ErrChecksum
+	}
+	item.Value = item.Value[:n]
+	item.Flags &^= t.flag()
+	return nil
+}
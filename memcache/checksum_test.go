@@ -0,0 +1,33 @@
+package memcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChecksumTranscoder(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.Transcoders = []Transcoder{&CompressionTranscoder{MinSize: 10}, &ChecksumTranscoder{}}
+
+	val := strings.Repeat("checked ", 20)
+	mustSet(t, c, &Item{Key: "foo", Value: []byte(val)})
+	it, err := c.Get("foo")
+	if err != nil || string(it.Value) != val || it.Flags != 0 {
+		t.Fatalf("Get(foo) = %+v, %v; want original value", it, err)
+	}
+
+	raw, _ := s.get("foo")
+	corrupt := append([]byte(nil), raw.value...)
+	corrupt[0] ^= 0xff
+	s.put("foo", corrupt, raw.flags)
+	if _, err := c.Get("foo"); err != ErrChecksum {
+		t.Errorf("Get of corrupted value = %v, want ErrChecksum", err)
+	}
+
+	s.put("legacy", []byte("unchecked"), 0)
+	if it, err := c.Get("legacy"); err != nil || string(it.Value) != "unchecked" {
+		t.Errorf("Get of unchecksummed value = %v, %v", it, err)
+	}
+}
@@ -0,0 +1,106 @@
+package memcache
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChunkedFlag is the Item.Flags bit marking the manifest of a value
+// split into chunks because it exceeded the client's ChunkSize. Clients
+// only interpret it if their ChunkSize is positive.
+const ChunkedFlag uint32 = 1 << 27
+
+// chunk writes item's value as chunks of at most ChunkSize bytes under
+// fresh chunk keys, and returns the manifest item to store in its
+// place. The manifest names the chunks as "chunks <id> <n> <size>".
+func (c *Client) chunk(item *Item) (*Item, error) {
+	if item.Flags&ChunkedFlag != 0 {
+		return nil, ErrFlagInUse
+	}
+	var idb [8]byte
+	if _, err := rand.Read(idb[:]); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(idb[:])
+	n := (len(item.Value) + c.ChunkSize - 1) / c.ChunkSize
+	chunks := make([]*Item, n)
+	keys := make([]string, n)
+	for i := range chunks {
+		end := (i + 1) * c.ChunkSize
+		if end > len(item.Value) {
+			end = len(item.Value)
+		}
+		keys[i] = chunkKey(id, i)
+		chunks[i] = &Item{Key: keys[i], Value: item.Value[i*c.ChunkSize : end], Expiration: item.Expiration}
+	}
+	res := c.batch("set", keys, func(w *bufio.Writer, i int) error {
+		return writeStore(w, "set", chunks[i])
+	}, func(r *bufio.Reader, i int) error {
+		return readStoreLine(r, "set")
+	})
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+	m := *item
+	m.Value = []byte(fmt.Sprintf("chunks %s %d %d", id, n, len(item.Value)))
+	m.Flags |= ChunkedFlag
+	return &m, nil
+}
+
+func chunkKey(id string, i int) string {
+	return "chunk:" + id + ":" + strconv.Itoa(i)
+}
+
+// unchunk replaces the value of a chunk manifest read from a server by
+// the reassembled value. Items that are not manifests are left as is.
+// A missing chunk is reported as ErrCacheMiss.
+func (c *Client) unchunk(item *Item) error {
+	if !c.chunked(item) {
+		return nil
+	}
+	f := strings.Fields(string(item.Value))
+	var n, size int
+	var err error
+	if len(f) == 4 && f[0] == "chunks" {
+		if n, err = strconv.Atoi(f[2]); err == nil {
+			size, err = strconv.Atoi(f[3])
+		}
+	}
+	if len(f) != 4 || f[0] != "chunks" || err != nil || n <= 0 || size < 0 {
+		return errors.New("memcache: malformed chunk manifest")
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = chunkKey(f[1], i)
+	}
+	chunks, err := c.getMulti(keys)
+	if err != nil {
+		return err
+	}
+	value := make([]byte, 0, size)
+	for _, key := range keys {
+		ch, ok := chunks[key]
+		if !ok {
+			return ErrCacheMiss
+		}
+		value = append(value, ch.value()...)
+		ch.Release()
+	}
+	if len(value) != size {
+		return fmt.Errorf("memcache: chunks total %d bytes, manifest announced %d", len(value), size)
+	}
+	item.Value = value
+	item.Flags &^= ChunkedFlag
+	return nil
+}
+
+// chunked reports whether item is a chunk manifest the client
+// reassembles.
+func (c *Client) chunked(item *Item) bool {
+	return c.ChunkSize > 0 && item.Flags&ChunkedFlag != 0
+}
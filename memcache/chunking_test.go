@@ -0,0 +1,71 @@
+package memcache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestChunking(t *testing.T) {
+	s1, s2 := newFakeServer(t), newFakeServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	c := New(s1.Addr(), s2.Addr())
+	c.ChunkSize = 100
+
+	big := []byte(strings.Repeat("0123456789", 25))
+	mustSet(t, c, &Item{Key: "big", Value: big, Flags: 5})
+	mustSet(t, c, &Item{Key: "small", Value: []byte("tiny")})
+
+	it, err := c.Get("big")
+	if err != nil {
+		t.Fatalf("Get(big): %v", err)
+	}
+	if !bytes.Equal(it.Value, big) || it.Flags != 5 {
+		t.Errorf("Get(big) = %d bytes, flags %d; want original", len(it.Value), it.Flags)
+	}
+
+	// A reader with a larger ChunkSize still reassembles.
+	r := New(s1.Addr(), s2.Addr())
+	r.ChunkSize = 1 << 20
+	items, err := r.GetMulti([]string{"big", "small"})
+	if err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if !bytes.Equal(items["big"].Value, big) || string(items["small"].Value) != "tiny" {
+		t.Errorf("GetMulti returned unassembled values")
+	}
+
+	// Losing a chunk turns the item into a miss.
+	for _, s := range []*fakeServer{s1, s2} {
+		s.mu.Lock()
+		for key := range s.items {
+			if strings.HasPrefix(key, "chunk:") && strings.HasSuffix(key, ":1") {
+				delete(s.items, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+	if _, err := c.Get("big"); err != ErrCacheMiss {
+		t.Errorf("Get with a missing chunk = %v, want ErrCacheMiss", err)
+	}
+	items, err = c.GetMulti([]string{"big", "small"})
+	if err != nil || len(items) != 1 {
+		t.Errorf("GetMulti with a missing chunk = %v, %v; want only small", items, err)
+	}
+}
+
+func TestChunkingDisabled(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	// Without ChunkSize, the flag bit belongs to the application.
+	mustSet(t, c, &Item{Key: "k", Value: []byte("v"), Flags: ChunkedFlag})
+	if it, err := c.Get("k"); err != nil || string(it.Value) != "v" || it.Flags != ChunkedFlag {
+		t.Errorf("Get = %+v, %v; want the item", it, err)
+	}
+	if m, err := c.GetMulti([]string{"k"}); err != nil || len(m) != 1 {
+		t.Errorf("GetMulti = %v, %v; want the item", m, err)
+	}
+}
@@ -0,0 +1,82 @@
+package memcache
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCoalesced is the number of distinct keys after which coalesced
+// Gets are sent without waiting for the end of the window.
+const maxCoalesced = 500
+
+// coalescer collects the Gets of a client during a CoalesceWindow.
+type coalescer struct {
+	mu      sync.Mutex
+	waiters map[string][]chan coalesced
+}
+
+type coalesced struct {
+	item *Item
+	err  error
+}
+
+// coalescedGet gets key as part of the next coalesced GetMulti.
+func (c *Client) coalescedGet(key string) (*Item, error) {
+	if !c.validKey(key) {
+		return nil, ErrMalformedKey
+	}
+	co := &c.state.coalesce
+	ch := make(chan coalesced, 1)
+	co.mu.Lock()
+	if co.waiters == nil {
+		co.waiters = make(map[string][]chan coalesced)
+		time.AfterFunc(c.CoalesceWindow, c.flushCoalesced)
+	}
+	co.waiters[key] = append(co.waiters[key], ch)
+	full := len(co.waiters) >= maxCoalesced
+	co.mu.Unlock()
+	if full {
+		c.flushCoalesced()
+	}
+	r := <-ch
+	return r.item, r.err
+}
+
+// flushCoalesced sends the collected Gets and delivers their results.
+// A key missing from the results gets the GetMulti error, if any, and
+// ErrCacheMiss otherwise. Each waiter for a key gets its own Item.
+func (c *Client) flushCoalesced() {
+	co := &c.state.coalesce
+	co.mu.Lock()
+	waiters := co.waiters
+	co.waiters = nil
+	co.mu.Unlock()
+	if len(waiters) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(waiters))
+	for key := range waiters {
+		keys = append(keys, key)
+	}
+	items, err := c.getMulti(keys)
+	for key, chs := range waiters {
+		it, ok := items[key]
+		if !ok {
+			r := coalesced{err: err}
+			if err == nil {
+				r.err = ErrCacheMiss
+			}
+			for _, ch := range chs {
+				ch <- r
+			}
+			continue
+		}
+		// Copy the item for the other waiters before handing it out, as
+		// callers decode items in place.
+		it.Bytes()
+		for _, ch := range chs[1:] {
+			ch <- coalesced{item: it.clone()}
+		}
+		chs[0] <- coalesced{item: it}
+	}
+}
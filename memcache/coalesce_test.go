@@ -0,0 +1,53 @@
+package memcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoalesceWindow(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.CoalesceWindow = 20 * time.Millisecond
+	rec := &recordingMetrics{}
+	c.Metrics = rec
+	for i := 0; i < 10; i++ {
+		s.put(fmt.Sprintf("k%d", i), []byte(fmt.Sprint(i)), 0)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("k%d", i%12)
+			it, err := c.Get(key)
+			switch {
+			case i%12 >= 10:
+				if err != ErrCacheMiss {
+					errs <- fmt.Errorf("Get(%s) = %v, want ErrCacheMiss", key, err)
+				}
+			case err != nil || string(it.Value) != fmt.Sprint(i%12):
+				errs <- fmt.Errorf("Get(%s) = %v, %v", key, it, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.ends) >= 30 {
+		t.Errorf("30 Gets made %d requests, want them coalesced", len(rec.ends))
+	}
+
+	if _, err := c.Get("bad key"); err != ErrMalformedKey {
+		t.Errorf("Get(bad key) = %v, want ErrMalformedKey", err)
+	}
+}
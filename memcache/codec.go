@@ -0,0 +1,105 @@
+package memcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+)
+
+// Codec serializes Go values to and from item values. Values stored
+// with a codec are marked with its Flags bits, so that a value written
+// by a different codec, or as raw bytes, is detected when read.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+
+	// Flags returns the Item.Flags bits identifying the codec.
+	Flags() uint32
+}
+
+const (
+	// JSONCodecFlag is the Item.Flags bit marking values encoded by
+	// JSON.
+	JSONCodecFlag uint32 = 1 << 29
+
+	// GobCodecFlag is the Item.Flags bit marking values encoded by Gob.
+	GobCodecFlag uint32 = 1 << 28
+)
+
+var (
+	// JSON is a Codec using encoding/json.
+	JSON Codec = jsonCodec{}
+
+	// Gob is a Codec using encoding/gob. Each value is encoded as a
+	// self-contained gob stream.
+	Gob Codec = gobCodec{}
+)
+
+// ErrCodecMismatch is returned by GetObject when the item's flags show
+// it was not encoded by the client's codec.
+var ErrCodecMismatch = errors.New("memcache: item was not encoded by this codec")
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Flags() uint32                              { return JSONCodecFlag }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (gobCodec) Flags() uint32 { return GobCodecFlag }
+
+func (c *Client) codec() Codec {
+	if c.Codec != nil {
+		return c.Codec
+	}
+	return JSON
+}
+
+// SetObject marshals item.Object with the client's Codec and writes the
+// result as item's value, unconditionally. item itself is not modified.
+func (c *Client) SetObject(item *Item) error {
+	codec := c.codec()
+	data, err := codec.Marshal(item.Object)
+	if err != nil {
+		return err
+	}
+	it := *item
+	it.Value = data
+	it.Flags |= codec.Flags()
+	return c.Set(&it)
+}
+
+// GetObject gets the item for key and unmarshals its value into v with
+// the client's Codec. The returned item has Object set to v and the
+// codec's flags cleared. ErrCodecMismatch is returned if the item was
+// not stored with the codec.
+func (c *Client) GetObject(key string, v interface{}) (*Item, error) {
+	it, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	codec := c.codec()
+	if it.Flags&codec.Flags() != codec.Flags() {
+		return nil, ErrCodecMismatch
+	}
+	if err := codec.Unmarshal(it.Value, v); err != nil {
+		return nil, err
+	}
+	it.Flags &^= codec.Flags()
+	it.Object = v
+	return it, nil
+}
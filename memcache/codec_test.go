@@ -0,0 +1,42 @@
+package memcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+type codecTestUser struct {
+	Name string
+	Age  int
+}
+
+func TestCodecs(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	for _, codec := range []Codec{JSON, Gob} {
+		c := New(s.Addr())
+		c.Codec = codec
+		want := codecTestUser{Name: "ann", Age: 42}
+		if err := c.SetObject(&Item{Key: "user", Object: want, Flags: 1}); err != nil {
+			t.Fatalf("SetObject: %v", err)
+		}
+		raw, _ := s.get("user")
+		if raw.flags != 1|codec.Flags() {
+			t.Errorf("stored flags = %#x, want %#x", raw.flags, 1|codec.Flags())
+		}
+		var got codecTestUser
+		it, err := c.GetObject("user", &got)
+		if err != nil {
+			t.Fatalf("GetObject: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) || it.Flags != 1 || it.Object != &got {
+			t.Errorf("GetObject = %+v, item %+v; want %+v", got, it, want)
+		}
+	}
+
+	s.put("raw", []byte("{}"), 0)
+	var u codecTestUser
+	if _, err := New(s.Addr()).GetObject("raw", &u); err != ErrCodecMismatch {
+		t.Errorf("GetObject of raw value = %v, want ErrCodecMismatch", err)
+	}
+}
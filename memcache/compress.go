@@ -0,0 +1,142 @@
+package memcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	// DefaultCompressionFlag is the Item.Flags bit marking values
+	// compressed by a CompressionTranscoder with no Flag set.
+	DefaultCompressionFlag uint32 = 1 << 31
+
+	// DefaultCompressionMinSize is the size below which a
+	// CompressionTranscoder with no MinSize set stores values as is.
+	DefaultCompressionMinSize = 1024
+)
+
+// Compressor is a compression algorithm used by a
+// CompressionTranscoder. Only gzip is built in, as package memcache
+// depends on nothing outside the standard library; snappy or zstd can
+// be plugged in by wrapping their packages, for example:
+//
+//	type zstdCompressor struct {
+//		enc *zstd.Encoder
+//		dec *zstd.Decoder
+//	}
+//
+//	func (z zstdCompressor) Compress(p []byte) ([]byte, error) {
+//		return z.enc.EncodeAll(p, nil), nil
+//	}
+//
+//	func (z zstdCompressor) Decompress(p []byte) ([]byte, error) {
+//		return z.dec.DecodeAll(p, nil)
+//	}
+type Compressor interface {
+	Compress(p []byte) ([]byte, error)
+	Decompress(p []byte) ([]byte, error)
+}
+
+// Gzip is a Compressor using gzip at the default compression level.
+var Gzip Compressor = GzipCompressor{Level: gzip.DefaultCompression}
+
+// GzipCompressor is a Compressor using gzip at the given level.
+type GzipCompressor struct {
+	Level int
+}
+
+func (g GzipCompressor) Compress(p []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, g.Level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(p); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g GzipCompressor) Decompress(p []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(p))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// ErrFlagInUse is returned by a Transcoder asked to encode an item that
+// already has the Item.Flags bit the Transcoder uses.
+var ErrFlagInUse = errors.New("memcache: item flag bit reserved by a transcoder is already set")
+
+// CompressionTranscoder is a Transcoder compressing values of at least
+// MinSize bytes, and marking them with the Flag bit. Values that do not
+// shrink are stored as is.
+type CompressionTranscoder struct {
+	// Compressor is the compression algorithm. If nil, Gzip is used.
+	Compressor Compressor
+
+	// MinSize is the size below which values are stored uncompressed.
+	// If zero, DefaultCompressionMinSize is used.
+	MinSize int
+
+	// Flag is the Item.Flags bit marking compressed values. If zero,
+	// DefaultCompressionFlag is used.
+	Flag uint32
+}
+
+func (t *CompressionTranscoder) compressor() Compressor {
+	if t.Compressor != nil {
+		return t.Compressor
+	}
+	return Gzip
+}
+
+func (t *CompressionTranscoder) flag() uint32 {
+	if t.Flag != 0 {
+		return t.Flag
+	}
+	return DefaultCompressionFlag
+}
+
+func (t *CompressionTranscoder) Encode(item *Item) error {
+	if item.Flags&t.flag() != 0 {
+		return ErrFlagInUse
+	}
+	minSize := t.MinSize
+	if minSize == 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	if len(item.Value) < minSize {
+		return nil
+	}
+	z, err := t.compressor().Compress(item.Value)
+	if err != nil {
+		return err
+	}
+	if len(z) < len(item.Value) {
+		item.Value = z
+		item.Flags |= t.flag()
+	}
+	return nil
+}
+
+func (t *CompressionTranscoder) Decode(item *Item) error {
+	if item.Flags&t.flag() == 0 {
+		return nil
+	}
+	v, err := t.compressor().Decompress(item.Value)
+	if err != nil {
+		return fmt.Errorf("memcache: decompressing: %w", err)
+	}
+	item.Value = v
+	item.Flags &^= t.flag()
+	return nil
+}
@@ -0,0 +1,51 @@
+package memcache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompressionTranscoder(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.Transcoders = []Transcoder{&CompressionTranscoder{MinSize: 100}}
+
+	big := []byte(strings.Repeat("compressible ", 100))
+	mustSet(t, c, &Item{Key: "big", Value: big, Flags: 3})
+	mustSet(t, c, &Item{Key: "small", Value: []byte("tiny"), Flags: 3})
+
+	raw, _ := s.get("big")
+	if len(raw.value) >= len(big) || raw.flags != 3|DefaultCompressionFlag {
+		t.Errorf("stored big value is %d bytes with flags %#x, want compressed", len(raw.value), raw.flags)
+	}
+	raw, _ = s.get("small")
+	if string(raw.value) != "tiny" || raw.flags != 3 {
+		t.Errorf("stored small value = %q, flags %#x; want uncompressed", raw.value, raw.flags)
+	}
+
+	it, err := c.Get("big")
+	if err != nil {
+		t.Fatalf("Get(big): %v", err)
+	}
+	if !bytes.Equal(it.Value, big) || it.Flags != 3 {
+		t.Errorf("Get(big) = %d bytes, flags %#x; want original", len(it.Value), it.Flags)
+	}
+	items, err := c.GetMulti([]string{"big", "small"})
+	if err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if !bytes.Equal(items["big"].Value, big) || string(items["small"].Value) != "tiny" {
+		t.Errorf("GetMulti returned undecoded values")
+	}
+
+	if err := c.Set(&Item{Key: "x", Value: big, Flags: DefaultCompressionFlag}); err != ErrFlagInUse {
+		t.Errorf("Set with the compression flag = %v, want ErrFlagInUse", err)
+	}
+
+	s.put("corrupt", []byte("not gzip"), DefaultCompressionFlag)
+	if _, err := c.Get("corrupt"); err == nil {
+		t.Error("Get of corrupt compressed value succeeded")
+	}
+}
@@ -0,0 +1,99 @@
+package memcache
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ConnEvent is a connection lifecycle event.
+type ConnEvent string
+
+const (
+	// ConnDialed means a new connection was established.
+	ConnDialed ConnEvent = "dial"
+
+	// ConnDialFailed means a connection could not be established.
+	ConnDialFailed ConnEvent = "dial_failure"
+
+	// ConnClosedUnexpectedly means a connection was lost while in use,
+	// for example because the server closed or reset it.
+	ConnClosedUnexpectedly ConnEvent = "unexpected_close"
+
+	// ConnProtocolError means a server sent a response the client could
+	// not understand, desynchronizing the connection.
+	ConnProtocolError ConnEvent = "protocol_error"
+
+	// ConnDiscarded means a connection was closed instead of being
+	// returned to the pool because an operation on it failed.
+	ConnDiscarded ConnEvent = "discard"
+)
+
+// ConnEventRecorder may be implemented by a MetricsRecorder to also
+// receive connection lifecycle events, for surfacing flapping servers
+// and keepalive problems. A connection that fails with an unexpected
+// close or protocol error is reported once for that and once as
+// ConnDiscarded.
+type ConnEventRecorder interface {
+	ConnEvent(addr net.Addr, ev ConnEvent)
+}
+
+// ConnStats counts connection lifecycle events for one server.
+type ConnStats struct {
+	Dials, DialFailures, UnexpectedCloses, ProtocolErrors, Discarded uint64
+}
+
+type connCounters struct {
+	dials, dialFailures, unexpectedCloses, protoErrs, discarded uint64
+}
+
+// connTracker holds connCounters per server address.
+type connTracker struct {
+	m sync.Map // string -> *connCounters
+}
+
+func (ct *connTracker) record(addr net.Addr, ev ConnEvent) {
+	key := addr.String()
+	v, ok := ct.m.Load(key)
+	if !ok {
+		v, _ = ct.m.LoadOrStore(key, new(connCounters))
+	}
+	cc := v.(*connCounters)
+	switch ev {
+	case ConnDialed:
+		atomic.AddUint64(&cc.dials, 1)
+	case ConnDialFailed:
+		atomic.AddUint64(&cc.dialFailures, 1)
+	case ConnClosedUnexpectedly:
+		atomic.AddUint64(&cc.unexpectedCloses, 1)
+	case ConnProtocolError:
+		atomic.AddUint64(&cc.protoErrs, 1)
+	case ConnDiscarded:
+		atomic.AddUint64(&cc.discarded, 1)
+	}
+}
+
+func (c *Client) connEvent(addr net.Addr, ev ConnEvent) {
+	c.state.conns.record(addr, ev)
+	if r, ok := c.Metrics.(ConnEventRecorder); ok {
+		r.ConnEvent(addr, ev)
+	}
+}
+
+// ConnStats returns the connection counters of each server the client
+// has connected to, keyed by server address.
+func (c *Client) ConnStats() map[string]ConnStats {
+	stats := make(map[string]ConnStats)
+	c.state.conns.m.Range(func(k, v interface{}) bool {
+		cc := v.(*connCounters)
+		stats[k.(string)] = ConnStats{
+			Dials:            atomic.LoadUint64(&cc.dials),
+			DialFailures:     atomic.LoadUint64(&cc.dialFailures),
+			UnexpectedCloses: atomic.LoadUint64(&cc.unexpectedCloses),
+			ProtocolErrors:   atomic.LoadUint64(&cc.protoErrs),
+			Discarded:        atomic.LoadUint64(&cc.discarded),
+		}
+		return true
+	})
+	return stats
+}
@@ -0,0 +1,159 @@
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+)
+
+// maxReportedMismatches bounds the number of keys kept in a
+// ConsistencyReport.
+const maxReportedMismatches = 100
+
+// ConsistencyReport summarizes a comparison of sampled keys between two
+// sources, such as two clusters or a primary and its replica.
+type ConsistencyReport struct {
+	// Checked is the number of keys compared.
+	Checked int
+	// Matched is the number of keys present in both sources with equal
+	// values and flags.
+	Matched int
+	// Mismatched is the number of keys present in both sources with
+	// different values or flags.
+	Mismatched int
+	// MissingA and MissingB count keys present in only one source.
+	MissingA, MissingB int
+	// BothMissing counts keys present in neither source.
+	BothMissing int
+	// Errors is the number of keys that could not be compared because a
+	// read failed.
+	Errors int
+	// MismatchedKeys holds up to 100 of the keys that differed or were
+	// missing from exactly one source.
+	MismatchedKeys []string
+}
+
+// MismatchRate returns the fraction of compared keys that differed or
+// were missing from exactly one source.
+func (r *ConsistencyReport) MismatchRate() float64 {
+	if r.Checked == 0 {
+		return 0
+	}
+	return float64(r.Mismatched+r.MissingA+r.MissingB) / float64(r.Checked)
+}
+
+// MissRate returns the fraction of compared keys that were missing from
+// at least one source.
+func (r *ConsistencyReport) MissRate() float64 {
+	if r.Checked == 0 {
+		return 0
+	}
+	return float64(r.MissingA+r.MissingB+r.BothMissing) / float64(r.Checked)
+}
+
+func (r *ConsistencyReport) add(key string, a, b *Item) {
+	r.Checked++
+	switch {
+	case a == nil && b == nil:
+		r.BothMissing++
+		return
+	case a == nil:
+		r.MissingA++
+	case b == nil:
+		r.MissingB++
+	case a.Flags == b.Flags && bytes.Equal(a.Bytes(), b.Bytes()):
+		r.Matched++
+		return
+	default:
+		r.Mismatched++
+	}
+	if len(r.MismatchedKeys) < maxReportedMismatches {
+		r.MismatchedKeys = append(r.MismatchedKeys, key)
+	}
+}
+
+// SampleKeys returns a random subset of keys, each kept with
+// probability fraction.
+func SampleKeys(keys []string, fraction float64) []string {
+	var sample []string
+	for _, key := range keys {
+		if rand.Float64() < fraction {
+			sample = append(sample, key)
+		}
+	}
+	return sample
+}
+
+// CheckConsistency reads keys from clients a and b and reports how they
+// differ. Keys that fail to be read from either source are counted in
+// Errors; the last such error is also returned. When a GetMulti fails,
+// the keys it did not return are read again one at a time, so that
+// only the keys that really can't be read count as errors.
+func CheckConsistency(a, b MemcacheClient, keys []string) (*ConsistencyReport, error) {
+	r := new(ConsistencyReport)
+	ia, erra := a.GetMulti(keys)
+	ib, errb := b.GetMulti(keys)
+	var lastErr error
+	for _, key := range keys {
+		ita, err := lookupKey(a, ia, erra, key)
+		var itb *Item
+		if err == nil {
+			itb, err = lookupKey(b, ib, errb, key)
+		}
+		if err != nil {
+			r.Errors++
+			lastErr = err
+			continue
+		}
+		r.add(key, ita, itb)
+	}
+	return r, lastErr
+}
+
+// lookupKey returns the item for key from items, the result of a
+// GetMulti on c that failed with err, reading it again with Get if the
+// GetMulti failed without returning it. It returns nil for a miss.
+func lookupKey(c MemcacheClient, items map[string]*Item, err error, key string) (*Item, error) {
+	if it, ok := items[key]; ok || err == nil {
+		return it, nil
+	}
+	it, err := c.Get(key)
+	if errors.Is(err, ErrCacheMiss) {
+		return nil, nil
+	}
+	return it, err
+}
+
+// CheckReplicas compares each key's replicas against its primary and
+// reports how they differ, with the primary as source A. Each replica
+// compared counts once in Checked. Replication must be enabled on c.
+func (c *Client) CheckReplicas(keys []string) (*ConsistencyReport, error) {
+	r := new(ConsistencyReport)
+	var lastErr error
+	for _, key := range keys {
+		pk := c.storageKey(key)
+		if !legalKey(pk) {
+			return r, ErrMalformedKey
+		}
+		addrs, err := c.pickReplicas(pk)
+		if err != nil {
+			return r, err
+		}
+		items := make([]*Item, len(addrs))
+		for i, addr := range addrs {
+			err = c.getFromAddr(addr, []string{pk}, func(it *Item) { items[i] = it })
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			r.Errors++
+			lastErr = err
+			continue
+		}
+		for _, it := range items[1:] {
+			r.add(key, items[0], it)
+		}
+	}
+	return r, lastErr
+}
@@ -0,0 +1,115 @@
+package memcache
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	sa, sb := newFakeServer(t), newFakeServer(t)
+	defer sa.Close()
+	defer sb.Close()
+	sa.put("same", []byte("x"), 0)
+	sb.put("same", []byte("x"), 0)
+	sa.put("diff", []byte("x"), 0)
+	sb.put("diff", []byte("y"), 0)
+	sa.put("onlya", []byte("x"), 0)
+	sb.put("onlyb", []byte("x"), 0)
+
+	for _, lazy := range []bool{false, true} {
+		a, b := New(sa.Addr()), New(sb.Addr())
+		a.LazyValues, b.LazyValues = lazy, lazy
+		r, err := CheckConsistency(a, b, []string{"same", "diff", "onlya", "onlyb", "none"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Checked != 5 || r.Matched != 1 || r.Mismatched != 1 || r.MissingA != 1 || r.MissingB != 1 || r.BothMissing != 1 {
+			t.Errorf("lazy=%v: report = %+v", lazy, r)
+		}
+		if g, e := r.MismatchRate(), 3.0/5; g != e {
+			t.Errorf("lazy=%v: MismatchRate = %v, want %v", lazy, g, e)
+		}
+		if g, e := len(r.MismatchedKeys), 3; g != e {
+			t.Errorf("lazy=%v: len(MismatchedKeys) = %d, want %d", lazy, g, e)
+		}
+	}
+}
+
+func TestCheckConsistencyErrors(t *testing.T) {
+	sa, sb := newFakeServer(t), newFakeServer(t)
+	defer sa.Close()
+	defer sb.Close()
+	sa.put("same", []byte("x"), 0)
+	sb.put("same", []byte("x"), 0)
+	sa.put("bad", []byte("x"), 0)
+	sb.put("bad", []byte("x"), 0)
+
+	failure := errors.New("injected")
+	b := NewFaultInjector(New(sb.Addr()),
+		Fault{Ops: []string{"getmulti"}, Probability: 1, Err: failure},
+		Fault{Ops: []string{"get"}, KeyPattern: regexp.MustCompile("^bad$"), Probability: 1, Err: failure},
+	)
+	r, err := CheckConsistency(New(sa.Addr()), b, []string{"same", "bad", "none"})
+	if err != failure {
+		t.Errorf("err = %v, want the injected error", err)
+	}
+	if r.Checked != 2 || r.Matched != 1 || r.BothMissing != 1 || r.Errors != 1 {
+		t.Errorf("report = %+v", r)
+	}
+}
+
+func TestCheckReplicas(t *testing.T) {
+	s1, s2 := newFakeServer(t), newFakeServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	c := New(s1.Addr(), s2.Addr())
+	c.Replicas = 2
+	mustSet(t, c, &Item{Key: "same", Value: []byte("x")})
+	mustSet(t, c, &Item{Key: "diff", Value: []byte("x")})
+	mustSet(t, c, &Item{Key: "gone", Value: []byte("x")})
+
+	byAddr := map[string]*fakeServer{s1.Addr(): s1, s2.Addr(): s2}
+	replica := func(key string) *fakeServer {
+		addrs, err := c.pickReplicas(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return byAddr[addrs[1].String()]
+	}
+	replica("diff").put("diff", []byte("y"), 0)
+	replica("gone").del("gone")
+
+	r, err := c.CheckReplicas([]string{"same", "diff", "gone", "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Checked != 4 || r.Matched != 1 || r.Mismatched != 1 || r.MissingB != 1 || r.BothMissing != 1 {
+		t.Errorf("report = %+v", r)
+	}
+}
+
+func TestSampleKeys(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	if got := SampleKeys(keys, 0); len(got) != 0 {
+		t.Errorf("SampleKeys with fraction 0 kept %d keys", len(got))
+	}
+	if got := SampleKeys(keys, 1); len(got) != len(keys) {
+		t.Errorf("SampleKeys with fraction 1 kept %d of %d keys", len(got), len(keys))
+	}
+	got := SampleKeys(keys, 0.5)
+	if len(got) < 350 || len(got) > 650 {
+		t.Errorf("SampleKeys with fraction 0.5 kept %d of %d keys", len(got), len(keys))
+	}
+	seen := make(map[string]bool)
+	for _, key := range got {
+		if seen[key] {
+			t.Fatalf("SampleKeys returned %q twice", key)
+		}
+		seen[key] = true
+	}
+}
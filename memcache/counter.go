@@ -0,0 +1,157 @@
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Counter is a numeric counter stored under a key, created on first use
+// with a time to live. Unlike Increment and Decrement, which fail with
+// ErrCacheMiss on a missing key, its methods create the counter
+// atomically: with the meta protocol's auto-vivifying arithmetic when
+// the client uses it, or with Add otherwise.
+type Counter struct {
+	c          *Client
+	key        string
+	expiration int32
+}
+
+// NewCounter returns the counter stored under key, which is created
+// with a time to live of ttl, or no expiration if ttl is zero. The time
+// to live is not extended by later updates.
+func (c *Client) NewCounter(key string, ttl time.Duration) *Counter {
+	return &Counter{c: c, key: key, expiration: expirationFor(ttl)}
+}
+
+// IncrBy adds delta to the counter, creating it with the value delta
+// if it is missing, and returns the new value.
+func (k *Counter) IncrBy(delta uint64) (uint64, error) {
+	return k.update("incr", delta, delta)
+}
+
+// DecrBy subtracts delta from the counter, stopping at zero, and
+// creating it with the value zero if it is missing, and returns the
+// new value.
+func (k *Counter) DecrBy(delta uint64) (uint64, error) {
+	return k.update("decr", delta, 0)
+}
+
+// Value returns the value of the counter, zero if it is missing.
+func (k *Counter) Value() (uint64, error) {
+	it, err := k.c.getRaw(k.key)
+	if errors.Is(err, ErrCacheMiss) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(string(it.Bytes()), 10, 64)
+	if err != nil {
+		return 0, k.c.withKeys([]string{k.key}, errors.New("memcache: counter holds a non-numeric value"))
+	}
+	return n, nil
+}
+
+// Reset sets the counter to zero, restarting its time to live.
+func (k *Counter) Reset() error {
+	return k.c.storeRaw("set", &Item{Key: k.key, Value: []byte("0"), Expiration: k.expiration})
+}
+
+// update applies verb with delta to the counter, creating it with the
+// value initial if it is missing.
+func (k *Counter) update(verb string, delta, initial uint64) (uint64, error) {
+	return k.c.arithWithInit(k.key, verb, delta, initial, k.expiration)
+}
+
+// IncrementWithInit atomically increments key by delta, or creates it
+// with the value initial and a time to live of ttl, or no expiration if
+// ttl is zero, if it is missing. It returns the new value: initial if
+// the key was created. The time to live is not extended when key
+// exists.
+//
+// With MetaProtocol, the key is created by the server's auto-vivifying
+// arithmetic in one round trip. Otherwise, or if ErrorOnOverflow is
+// set, a miss is followed by an Add of initial, and if another client
+// created the key first, by another increment. ErrorOnOverflow and
+// ErrorOnUnderflow apply as they do to Increment and Decrement.
+func (c *Client) IncrementWithInit(key string, delta, initial uint64, ttl time.Duration) (uint64, error) {
+	return c.arithWithInit(key, "incr", delta, initial, expirationFor(ttl))
+}
+
+// arithWithInit applies verb, "incr" or "decr", with delta to key,
+// creating it with the value initial and expiration exp if it is
+// missing.
+func (c *Client) arithWithInit(key, verb string, delta, initial uint64, exp int32) (uint64, error) {
+	// ma can't tell a created counter from a wrapped one, nor fail a
+	// decrement below zero, so the checks take the generic path.
+	checked := verb == "incr" && c.ErrorOnOverflow || verb == "decr" && c.ErrorOnUnderflow
+	if c.MetaProtocol && !checked {
+		return c.metaArith(key, verb, delta, initial, exp)
+	}
+	for {
+		var n uint64
+		var err error
+		if verb == "incr" {
+			n, err = c.Increment(key, delta)
+		} else {
+			n, err = c.Decrement(key, delta)
+		}
+		if !errors.Is(err, ErrCacheMiss) {
+			return n, err
+		}
+		err = c.storeRaw("add", &Item{Key: key, Value: strconv.AppendUint(nil, initial, 10), Expiration: exp})
+		if err == nil {
+			return initial, nil
+		}
+		if !errors.Is(err, ErrNotStored) {
+			return 0, err
+		}
+		// Created by another client meanwhile: apply delta to it.
+	}
+}
+
+// metaArith applies verb, "incr" or "decr", with delta to the counter
+// under key with the "ma" command, which creates a missing counter with
+// the value initial and expiration exp.
+func (c *Client) metaArith(key, verb string, delta, initial uint64, exp int32) (uint64, error) {
+	mode := "I"
+	if verb == "decr" {
+		mode = "D"
+	}
+	var (
+		mu  sync.Mutex
+		val uint64
+		got bool
+	)
+	key = c.storageKey(key)
+	err := c.withKeyWriteRw(key, "ma", func(rw *bufio.ReadWriter) error {
+		if _, err := fmt.Fprintf(rw, "ma %s N%d J%d D%d M%s v\r\n", c.metaKey(key), exp, initial, delta, mode); err != nil {
+			return err
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		mr, err := readMetaResponse(rw.Reader, c.limits(), c.StrictResponses)
+		if err != nil {
+			return err
+		}
+		if mr.status != "VA" {
+			return fmt.Errorf("%w: unexpected %s response to ma", ErrProtocol, mr.status)
+		}
+		v, ok := parseUint(mr.value, 64)
+		if !ok {
+			return fmt.Errorf("%w: malformed ma value %q", ErrProtocol, mr.value)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !got {
+			val, got = v, true
+		}
+		return nil
+	})
+	return val, err
+}
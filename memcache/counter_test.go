@@ -0,0 +1,127 @@
+package memcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	for _, meta := range []bool{false, true} {
+		s := newFakeServer(t)
+		defer s.Close()
+		c := New(s.Addr())
+		c.MetaProtocol = meta
+
+		k := c.NewCounter("n", time.Minute)
+		if v, err := k.Value(); v != 0 || err != nil {
+			t.Errorf("meta=%v: Value of missing counter = %d, %v", meta, v, err)
+		}
+		if v, err := k.IncrBy(5); v != 5 || err != nil {
+			t.Errorf("meta=%v: IncrBy(5) of missing counter = %d, %v", meta, v, err)
+		}
+		if v, err := k.IncrBy(2); v != 7 || err != nil {
+			t.Errorf("meta=%v: IncrBy(2) = %d, %v", meta, v, err)
+		}
+		if v, err := k.DecrBy(10); v != 0 || err != nil {
+			t.Errorf("meta=%v: DecrBy(10) = %d, %v", meta, v, err)
+		}
+		if err := k.Reset(); err != nil {
+			t.Errorf("meta=%v: Reset = %v", meta, err)
+		}
+		if v, err := k.Value(); v != 0 || err != nil {
+			t.Errorf("meta=%v: Value after Reset = %d, %v", meta, v, err)
+		}
+
+		d := c.NewCounter("d", 0)
+		if v, err := d.DecrBy(3); v != 0 || err != nil {
+			t.Errorf("meta=%v: DecrBy(3) of missing counter = %d, %v", meta, v, err)
+		}
+
+		// Concurrent first increments all count.
+		var wg sync.WaitGroup
+		f := c.NewCounter("f", time.Minute)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := f.IncrBy(1); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		if v, err := f.Value(); v != 10 || err != nil {
+			t.Errorf("meta=%v: after concurrent increments, Value = %d, %v", meta, v, err)
+		}
+	}
+}
+
+func TestIncrementWithInit(t *testing.T) {
+	for _, meta := range []bool{false, true} {
+		s := newFakeServer(t)
+		defer s.Close()
+		c := New(s.Addr())
+		c.MetaProtocol = meta
+
+		if v, err := c.IncrementWithInit("n", 1, 100, time.Minute); v != 100 || err != nil {
+			t.Errorf("meta=%v: IncrementWithInit of missing key = %d, %v; want 100", meta, v, err)
+		}
+		if it, ok := s.get("n"); !ok || it.exptime != "60" {
+			t.Errorf("meta=%v: created item = %+v, want expiration 60", meta, it)
+		}
+		if v, err := c.IncrementWithInit("n", 5, 100, time.Minute); v != 105 || err != nil {
+			t.Errorf("meta=%v: IncrementWithInit of existing key = %d, %v; want 105", meta, v, err)
+		}
+	}
+}
+
+func TestCountersWithTranscoder(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.Transcoders = []Transcoder{new(ChecksumTranscoder)}
+
+	k := c.NewCounter("n", time.Minute)
+	for want := uint64(1); want <= 2; want++ {
+		if v, err := k.IncrBy(1); v != want || err != nil {
+			t.Errorf("IncrBy(1) = %d, %v; want %d", v, err, want)
+		}
+	}
+	if err := k.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := k.IncrBy(3); v != 3 || err != nil {
+		t.Errorf("IncrBy(3) after Reset = %d, %v", v, err)
+	}
+
+	l := c.NewLock("lock", time.Minute)
+	for i := 0; i < 2; i++ {
+		if ok, err := l.TryLock(); !ok || err != nil {
+			t.Fatalf("TryLock %d = %v, %v", i, ok, err)
+		}
+		if err := l.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.SetTagged(&Item{Key: "tagged", Value: []byte("v")}, "t"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InvalidateTag("t"); err != nil {
+		t.Errorf("InvalidateTag = %v", err)
+	}
+	if _, err := c.GetTagged("tagged", "t"); err != ErrCacheMiss {
+		t.Errorf("GetTagged after InvalidateTag = %v, want ErrCacheMiss", err)
+	}
+	ns := c.Namespace("ns")
+	if err := ns.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Flush(); err != nil {
+		t.Errorf("Namespace.Flush = %v", err)
+	}
+	if _, err := ns.Get("k"); err != ErrCacheMiss {
+		t.Errorf("Namespace.Get after Flush = %v, want ErrCacheMiss", err)
+	}
+}
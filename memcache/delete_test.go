@@ -0,0 +1,22 @@
+package memcache
+
+import (
+	"testing"
+)
+
+func TestDeleteExisting(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.ErrorKeys = KeysPlain
+	s.put("foo", []byte("bar"), 0)
+
+	existed, err := c.DeleteExisting("foo")
+	if err != nil || !existed {
+		t.Errorf("DeleteExisting(foo) = %v, %v; want true, nil", existed, err)
+	}
+	existed, err = c.DeleteExisting("foo")
+	if err != nil || existed {
+		t.Errorf("second DeleteExisting(foo) = %v, %v; want false, nil", existed, err)
+	}
+}
@@ -0,0 +1,117 @@
+package memcache
+
+import (
+	"net"
+)
+
+// ServerShare is the part of a key distribution on one server.
+type ServerShare struct {
+	Addr net.Addr
+
+	// Keys and Bytes are the number of keys on the server and their
+	// total size. Sizes are only known from a dump.
+	Keys  int
+	Bytes int64
+
+	// Misplaced counts the keys dumped from the server that the
+	// selector routes to another one, such as keys left behind by a
+	// change of servers, which are no longer read.
+	Misplaced int
+}
+
+// Distribution reports how keys are spread across a client's servers.
+type Distribution struct {
+	// Servers holds a share per server, in the client's order.
+	Servers []ServerShare
+
+	// Keys and Bytes are the totals of the shares.
+	Keys  int
+	Bytes int64
+}
+
+// KeyImbalance returns the ratio of the largest number of keys on a
+// server to the mean: 1 when keys are spread evenly, and the number of
+// servers when they are all on one. It returns 0 for no keys.
+func (d *Distribution) KeyImbalance() float64 {
+	max := 0
+	for _, s := range d.Servers {
+		if s.Keys > max {
+			max = s.Keys
+		}
+	}
+	if d.Keys == 0 {
+		return 0
+	}
+	return float64(max) * float64(len(d.Servers)) / float64(d.Keys)
+}
+
+// ByteImbalance is like KeyImbalance for the bytes on each server.
+func (d *Distribution) ByteImbalance() float64 {
+	var max int64
+	for _, s := range d.Servers {
+		if s.Bytes > max {
+			max = s.Bytes
+		}
+	}
+	if d.Bytes == 0 {
+		return 0
+	}
+	return float64(max) * float64(len(d.Servers)) / float64(d.Bytes)
+}
+
+// newDistribution returns an empty distribution over addrs, and a map
+// from their names to their indexes in it.
+func newDistribution(addrs []net.Addr) (*Distribution, map[string]int) {
+	d := &Distribution{Servers: make([]ServerShare, len(addrs))}
+	idx := make(map[string]int, len(addrs))
+	for i, addr := range addrs {
+		d.Servers[i].Addr = addr
+		idx[addr.String()] = i
+	}
+	return d, idx
+}
+
+// KeyDistribution routes keys, such as a sample of the application's
+// keys, with the client's selector, as the client would store them, and
+// reports how many go to each server, so that imbalance can be found
+// before a server runs out of memory. No request is sent. Like Stats,
+// it requires the selector to be a *ServerList.
+func (c *Client) KeyDistribution(keys []string) (*Distribution, error) {
+	d, idx := newDistribution(c.servers())
+	for _, key := range keys {
+		addr, err := c.pickServer(c.storageKey(key))
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := idx[addr.String()]; ok {
+			d.Servers[i].Keys++
+			d.Keys++
+		}
+	}
+	return d, nil
+}
+
+// DumpDistribution lists the keys on each server with MetaDump and
+// reports the number of keys and bytes each holds, along with the keys
+// the selector routes elsewhere. Like Stats, it requires the selector to
+// be a *ServerList.
+func (c *Client) DumpDistribution() (*Distribution, error) {
+	d, _ := newDistribution(c.servers())
+	for i, s := range d.Servers {
+		share := &d.Servers[i]
+		err := c.MetaDump(s.Addr, func(ki KeyInfo) error {
+			share.Keys++
+			share.Bytes += int64(ki.Size)
+			if addr, err := c.pickServer(ki.Key); err == nil && addr.String() != s.Addr.String() {
+				share.Misplaced++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		d.Keys += share.Keys
+		d.Bytes += share.Bytes
+	}
+	return d, nil
+}
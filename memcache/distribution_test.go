@@ -0,0 +1,79 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestKeyDistribution(t *testing.T) {
+	s1, s2 := newFakeServer(t), newFakeServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	c := New(s1.Addr(), s2.Addr())
+
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, fmt.Sprintf("key:%d", i))
+	}
+	d, err := c.KeyDistribution(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Servers) != 2 || d.Keys != 1000 || d.Servers[0].Keys+d.Servers[1].Keys != 1000 {
+		t.Fatalf("distribution = %+v", d)
+	}
+	if im := d.KeyImbalance(); im < 1 || im > 1.2 {
+		t.Errorf("KeyImbalance = %v for evenly hashed keys", im)
+	}
+
+	// Keys sharing a routing key all go to one server.
+	c.RoutingKey = HashTag
+	for i := range keys {
+		keys[i] = "{user}:" + keys[i]
+	}
+	if d, err = c.KeyDistribution(keys); err != nil {
+		t.Fatal(err)
+	}
+	if im := d.KeyImbalance(); im != 2 {
+		t.Errorf("KeyImbalance = %v, want 2", im)
+	}
+}
+
+func TestDumpDistribution(t *testing.T) {
+	s1, s2 := newFakeServer(t), newFakeServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	c := New(s1.Addr(), s2.Addr())
+
+	for i := 0; i < 20; i++ {
+		mustSet(t, c, &Item{Key: fmt.Sprintf("k%d", i), Value: []byte("0123456789")})
+	}
+	// A key on a server the selector doesn't route it to.
+	misplaced := "k0"
+	addr, _ := c.pickServer(misplaced)
+	other := s1
+	if addr.String() == s1.Addr() {
+		other = s2
+	}
+	other.put(misplaced, []byte("0123456789"), 0)
+
+	d, err := c.DumpDistribution()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Keys != 21 || d.Bytes != 210 {
+		t.Errorf("totals = %d keys, %d bytes, want 21, 210", d.Keys, d.Bytes)
+	}
+	for _, sh := range d.Servers {
+		want := 0
+		if sh.Addr.String() == other.Addr() {
+			want = 1
+		}
+		if sh.Misplaced != want {
+			t.Errorf("%v: Misplaced = %d, want %d", sh.Addr, sh.Misplaced, want)
+		}
+	}
+	if im := d.ByteImbalance(); im < 1 {
+		t.Errorf("ByteImbalance = %v", im)
+	}
+}
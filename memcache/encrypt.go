@@ -0,0 +1,137 @@
+package memcache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// DefaultEncryptionFlag is the Item.Flags bit marking values encrypted
+// by an EncryptionTranscoder with no Flag set.
+const DefaultEncryptionFlag uint32 = 1 << 25
+
+// ErrDecrypt is returned when an encrypted value cannot be decrypted,
+// because it was tampered with, is stored under a different key, or
+// was encrypted with an unknown encryption key.
+var ErrDecrypt = errors.New("memcache: value decryption failed")
+
+// KeyProvider supplies AES keys to an EncryptionTranscoder. Keys are
+// identified by an ID stored with each value, so that keys can be
+// rotated while values encrypted with older keys are still readable.
+//
+// Implementations must be safe for concurrent use.
+type KeyProvider interface {
+	// CurrentKey returns the ID and the key used to encrypt new values.
+	CurrentKey() (id uint32, key []byte, err error)
+
+	// Key returns the key with the given ID, for decrypting values.
+	Key(id uint32) ([]byte, error)
+}
+
+// StaticKey is a KeyProvider with a single AES-128, AES-192 or AES-256
+// key, which has ID 0.
+type StaticKey []byte
+
+func (k StaticKey) CurrentKey() (uint32, []byte, error) { return 0, k, nil }
+
+func (k StaticKey) Key(id uint32) ([]byte, error) {
+	if id != 0 {
+		return nil, fmt.Errorf("memcache: unknown encryption key ID %d", id)
+	}
+	return k, nil
+}
+
+// EncryptionTranscoder is a Transcoder encrypting values with AES-GCM
+// and marking them with the Flag bit. The item's key is authenticated
+// along with the value, so a value copied to another key fails to
+// decrypt. Encrypted values are 32 bytes larger than their plaintext.
+//
+// Compression, if used, must be listed before encryption, since
+// ciphertext does not compress.
+type EncryptionTranscoder struct {
+	Keys KeyProvider
+
+	// Flag is the Item.Flags bit marking encrypted values. If zero,
+	// DefaultEncryptionFlag is used.
+	Flag uint32
+
+	// RequireEncrypted makes Decode fail with ErrDecrypt on values
+	// without the Flag bit, so that a plaintext value planted in the
+	// cache is not trusted. Counters, which the servers store in the
+	// clear, then cannot be read with Get.
+	RequireEncrypted bool
+}
+
+func (t *EncryptionTranscoder) flag() uint32 {
+	if t.Flag != 0 {
+		return t.Flag
+	}
+	return DefaultEncryptionFlag
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encode replaces item's value by the key ID, a random nonce, and the
+// sealed value.
+func (t *EncryptionTranscoder) Encode(item *Item) error {
+	if item.Flags&t.flag() != 0 {
+		return ErrFlagInUse
+	}
+	id, key, err := t.Keys.CurrentKey()
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	v := make([]byte, 4+gcm.NonceSize(), 4+gcm.NonceSize()+len(item.Value)+gcm.Overhead())
+	binary.BigEndian.PutUint32(v, id)
+	nonce := v[4:]
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	item.Value = gcm.Seal(v, nonce, item.Value, []byte(item.Key))
+	item.Flags |= t.flag()
+	return nil
+}
+
+func (t *EncryptionTranscoder) Decode(item *Item) error {
+	if item.Flags&t.flag() == 0 {
+		if t.RequireEncrypted {
+			return ErrDecrypt
+		}
+		return nil
+	}
+	if len(item.Value) < 4 {
+		return ErrDecrypt
+	}
+	key, err := t.Keys.Key(binary.BigEndian.Uint32(item.Value))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	v := item.Value[4:]
+	if len(v) < gcm.NonceSize() {
+		return ErrDecrypt
+	}
+	plain, err := gcm.Open(nil, v[:gcm.NonceSize()], v[gcm.NonceSize():], []byte(item.Key))
+	if err != nil {
+		return ErrDecrypt
+	}
+	item.Value = plain
+	item.Flags &^= t.flag()
+	return nil
+}
@@ -0,0 +1,95 @@
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+type rotatingKeys struct {
+	current uint32
+	keys    map[uint32][]byte
+}
+
+func (r *rotatingKeys) CurrentKey() (uint32, []byte, error) { return r.current, r.keys[r.current], nil }
+
+func (r *rotatingKeys) Key(id uint32) ([]byte, error) {
+	if k, ok := r.keys[id]; ok {
+		return k, nil
+	}
+	return nil, errors.New("no such key")
+}
+
+func TestEncryptionTranscoder(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	keys := &rotatingKeys{keys: map[uint32][]byte{
+		0: bytes.Repeat([]byte{1}, 32),
+		1: bytes.Repeat([]byte{2}, 32),
+	}}
+	c := New(s.Addr())
+	c.Transcoders = []Transcoder{&EncryptionTranscoder{Keys: keys}}
+
+	mustSet(t, c, &Item{Key: "secret", Value: []byte("hunter2")})
+	raw, _ := s.get("secret")
+	if bytes.Contains(raw.value, []byte("hunter2")) || raw.flags != DefaultEncryptionFlag {
+		t.Errorf("stored value %q with flags %#x is not encrypted", raw.value, raw.flags)
+	}
+
+	keys.current = 1
+	if it, err := c.Get("secret"); err != nil || string(it.Value) != "hunter2" || it.Flags != 0 {
+		t.Errorf("Get after key rotation = %+v, %v", it, err)
+	}
+
+	// A value moved to another key must not decrypt.
+	s.put("other", raw.value, raw.flags)
+	if _, err := c.Get("other"); err != ErrDecrypt {
+		t.Errorf("Get of value moved to another key = %v, want ErrDecrypt", err)
+	}
+
+	c2 := New(s.Addr())
+	c2.Transcoders = []Transcoder{&EncryptionTranscoder{Keys: StaticKey(bytes.Repeat([]byte{3}, 16))}}
+	if _, err := c2.Get("secret"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Get with the wrong key = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestRequireEncrypted(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.Transcoders = []Transcoder{&EncryptionTranscoder{Keys: StaticKey(bytes.Repeat([]byte{1}, 16)), RequireEncrypted: true}}
+
+	s.put("planted", []byte("evil"), 0)
+	if _, err := c.Get("planted"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Get of plaintext value = %v, want ErrDecrypt", err)
+	}
+	mustSet(t, c, &Item{Key: "secret", Value: []byte("hunter2")})
+	if it, err := c.Get("secret"); err != nil || string(it.Value) != "hunter2" {
+		t.Errorf("Get of encrypted value = %+v, %v", it, err)
+	}
+
+	// Tag versions, namespace epochs and counters are stored in the
+	// clear by the client itself, and must remain readable.
+	if err := c.SetTagged(&Item{Key: "tagged", Value: []byte("v")}, "t"); err != nil {
+		t.Fatal(err)
+	}
+	if it, err := c.GetTagged("tagged", "t"); err != nil || string(it.Value) != "v" {
+		t.Errorf("GetTagged = %+v, %v", it, err)
+	}
+	ns := c.Namespace("ns")
+	if err := ns.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if it, err := ns.Get("k"); err != nil || string(it.Value) != "v" {
+		t.Errorf("Namespace.Get = %+v, %v", it, err)
+	}
+	k := c.NewCounter("hits", time.Minute)
+	if _, err := k.IncrBy(1); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := k.Value(); err != nil || n != 1 {
+		t.Errorf("Counter.Value = %d, %v, want 1", n, err)
+	}
+}
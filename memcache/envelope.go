@@ -0,0 +1,163 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultEnvelopeFlag is the Item.Flags bit marking values wrapped by
+// an EnvelopeTranscoder with no Flag set.
+const DefaultEnvelopeFlag uint32 = 1 << 24
+
+// envelopeVersion is the version of the envelope format written.
+const envelopeVersion = 1
+
+// envelopeMagic starts every envelope.
+var envelopeMagic = [2]byte{'M', 'E'}
+
+// envelopeHeaderLen is the length of the envelope header: the magic,
+// the format version, the codec ID and the compression ID.
+const envelopeHeaderLen = 5
+
+var (
+	// DefaultEnvelopeCompressors are the compression IDs understood by
+	// an EnvelopeTranscoder with no Compressors set.
+	DefaultEnvelopeCompressors = map[byte]Compressor{1: Gzip}
+
+	// DefaultEnvelopeCodecs are the codec IDs understood by an
+	// EnvelopeTranscoder with no Codecs set.
+	DefaultEnvelopeCodecs = map[byte]Codec{1: JSON, 2: Gob}
+)
+
+// ErrEnvelope is returned when a value's envelope is malformed or names
+// a version, codec or compression algorithm the reader does not know.
+var ErrEnvelope = errors.New("memcache: unsupported value envelope")
+
+// EnvelopeTranscoder is a Transcoder prefixing values with a small
+// header recording the envelope format version and the IDs of the
+// codec and compression algorithm used, and marking them with the Flag
+// bit. Readers decode each value according to its own header, not
+// their current settings, so the compression and codec used for new
+// values can change during a rolling deploy without breaking entries
+// already cached, as long as every reader knows all IDs in use.
+//
+// The codec ID is taken from the Codec Flags bits of the item, which
+// the envelope stores in their place and restores on read.
+type EnvelopeTranscoder struct {
+	// Compression is the ID, in Compressors, of the algorithm used for
+	// new values of at least MinSize bytes. Zero leaves values
+	// uncompressed.
+	Compression byte
+
+	// MinSize is the size below which values are stored uncompressed.
+	// If zero, DefaultCompressionMinSize is used.
+	MinSize int
+
+	// Compressors maps compression IDs to algorithms. If nil,
+	// DefaultEnvelopeCompressors is used.
+	Compressors map[byte]Compressor
+
+	// Codecs maps codec IDs to codecs. If nil, DefaultEnvelopeCodecs is
+	// used.
+	Codecs map[byte]Codec
+
+	// Flag is the Item.Flags bit marking enveloped values. If zero,
+	// DefaultEnvelopeFlag is used.
+	Flag uint32
+}
+
+func (t *EnvelopeTranscoder) flag() uint32 {
+	if t.Flag != 0 {
+		return t.Flag
+	}
+	return DefaultEnvelopeFlag
+}
+
+func (t *EnvelopeTranscoder) compressors() map[byte]Compressor {
+	if t.Compressors != nil {
+		return t.Compressors
+	}
+	return DefaultEnvelopeCompressors
+}
+
+func (t *EnvelopeTranscoder) codecs() map[byte]Codec {
+	if t.Codecs != nil {
+		return t.Codecs
+	}
+	return DefaultEnvelopeCodecs
+}
+
+func (t *EnvelopeTranscoder) Encode(item *Item) error {
+	if item.Flags&t.flag() != 0 {
+		return ErrFlagInUse
+	}
+	var codecID byte
+	for id, codec := range t.codecs() {
+		if f := codec.Flags(); f != 0 && item.Flags&f == f {
+			codecID = id
+			item.Flags &^= f
+			break
+		}
+	}
+	value := item.Value
+	var compressionID byte
+	minSize := t.MinSize
+	if minSize == 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	if t.Compression != 0 && len(value) >= minSize {
+		comp, ok := t.compressors()[t.Compression]
+		if !ok {
+			return fmt.Errorf("memcache: unknown envelope compression ID %d", t.Compression)
+		}
+		z, err := comp.Compress(value)
+		if err != nil {
+			return err
+		}
+		if len(z) < len(value) {
+			value, compressionID = z, t.Compression
+		}
+	}
+	v := make([]byte, envelopeHeaderLen+len(value))
+	copy(v, envelopeMagic[:])
+	v[2], v[3], v[4] = envelopeVersion, codecID, compressionID
+	copy(v[envelopeHeaderLen:], value)
+	item.Value = v
+	item.Flags |= t.flag()
+	return nil
+}
+
+func (t *EnvelopeTranscoder) Decode(item *Item) error {
+	if item.Flags&t.flag() == 0 {
+		return nil
+	}
+	v := item.Value
+	if len(v) < envelopeHeaderLen || v[0] != envelopeMagic[0] || v[1] != envelopeMagic[1] {
+		return ErrEnvelope
+	}
+	if v[2] != envelopeVersion {
+		return fmt.Errorf("%w: version %d", ErrEnvelope, v[2])
+	}
+	var codecFlags uint32
+	if id := v[3]; id != 0 {
+		codec, ok := t.codecs()[id]
+		if !ok {
+			return fmt.Errorf("%w: codec ID %d", ErrEnvelope, id)
+		}
+		codecFlags = codec.Flags()
+	}
+	value := v[envelopeHeaderLen:]
+	if id := v[4]; id != 0 {
+		comp, ok := t.compressors()[id]
+		if !ok {
+			return fmt.Errorf("%w: compression ID %d", ErrEnvelope, id)
+		}
+		var err error
+		if value, err = comp.Decompress(value); err != nil {
+			return err
+		}
+	}
+	item.Value = value
+	item.Flags = item.Flags&^t.flag() | codecFlags
+	return nil
+}
@@ -0,0 +1,48 @@
+package memcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvelopeTranscoder(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+
+	// An old deployment writes uncompressed JSON.
+	old := New(s.Addr())
+	old.Transcoders = []Transcoder{&EnvelopeTranscoder{}}
+	want := codecTestUser{Name: strings.Repeat("x", 2000), Age: 1}
+	if err := old.SetObject(&Item{Key: "old", Object: want}); err != nil {
+		t.Fatalf("SetObject: %v", err)
+	}
+	raw, _ := s.get("old")
+	if raw.flags != DefaultEnvelopeFlag || raw.value[3] != 1 || raw.value[4] != 0 {
+		t.Errorf("old envelope: flags %#x header %v", raw.flags, raw.value[:envelopeHeaderLen])
+	}
+
+	// A new deployment writes gzip-compressed gob and reads both.
+	cur := New(s.Addr())
+	cur.Codec = Gob
+	cur.Transcoders = []Transcoder{&EnvelopeTranscoder{Compression: 1}}
+	if err := cur.SetObject(&Item{Key: "new", Object: want}); err != nil {
+		t.Fatalf("SetObject: %v", err)
+	}
+	raw, _ = s.get("new")
+	if raw.value[3] != 2 || raw.value[4] != 1 {
+		t.Errorf("new envelope header = %v, want gob and gzip", raw.value[:envelopeHeaderLen])
+	}
+	var got codecTestUser
+	if _, err := cur.GetObject("new", &got); err != nil || got != want {
+		t.Errorf("GetObject(new) = %v", err)
+	}
+	it, err := cur.Get("old")
+	if err != nil || it.Flags != JSONCodecFlag {
+		t.Errorf("Get(old) by new deployment = %+v, %v; want JSON flags restored", it, err)
+	}
+
+	s.put("future", []byte("ME\x09\x00\x00x"), DefaultEnvelopeFlag)
+	if _, err := cur.Get("future"); err == nil {
+		t.Error("Get of unknown envelope version succeeded")
+	}
+}
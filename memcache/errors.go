@@ -0,0 +1,214 @@
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// OpError is the error returned when an operation fails against a
+// server for a reason other than a cache-level condition such as
+// ErrCacheMiss or ErrNotStored, which are returned unwrapped.
+//
+// errors.Is reports whether an OpError is of a given Kind, and
+// errors.As reaches the underlying error:
+//
+//	if errors.Is(err, memcache.ErrTimeout) { ... }
+//	var oe *memcache.OpError
+//	if errors.As(err, &oe) { log.Print(oe.Addr) }
+type OpError struct {
+	// Op is the protocol command that failed, such as "gets" or "set".
+	Op string
+
+	// Addr is the server the operation was sent to.
+	Addr net.Addr
+
+	// Kind is one of ErrTimeout, ErrConnFailed, ErrServerError or
+	// ErrProtocol.
+	Kind error
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *OpError) Error() string {
+	return fmt.Sprintf("memcache: %s %s: %v", e.Op, e.Addr, e.Err)
+}
+
+func (e *OpError) Unwrap() error { return e.Err }
+
+// Is reports whether target is e's Kind.
+func (e *OpError) Is(target error) bool { return target == e.Kind }
+
+// Timeout reports whether the operation timed out, so that OpError
+// satisfies the Timeout method of net.Error.
+func (e *OpError) Timeout() bool { return e.Kind == ErrTimeout }
+
+// wrapOpError wraps err in an OpError unless it is nil, a cache-level
+// error, or already an OpError.
+func wrapOpError(op string, addr net.Addr, class ErrorClass, err error) error {
+	if class == ErrClassNone {
+		return err
+	}
+	var oe *OpError
+	if errors.As(err, &oe) {
+		return err
+	}
+	var kind error
+	switch class {
+	case ErrClassTimeout:
+		kind = ErrTimeout
+	case ErrClassNetwork:
+		kind = ErrConnFailed
+	case ErrClassServer:
+		kind = ErrServerError
+	default:
+		kind = ErrProtocol
+	}
+	return &OpError{Op: op, Addr: addr, Kind: kind, Err: err}
+}
+
+// KeyMode controls whether the keys of an operation are attached to
+// its errors and reports.
+type KeyMode int
+
+const (
+	// KeysOmitted attaches no keys. It is the default, so that keys,
+	// which may contain user data, do not leak into errors and metrics.
+	KeysOmitted KeyMode = iota
+
+	// KeysHashed attaches the HashKey of each key.
+	KeysHashed
+
+	// KeysPlain attaches the keys themselves.
+	KeysPlain
+)
+
+// reportedKeys returns keys as they should be attached to errors and
+// reports under the client's ErrorKeys mode.
+func (c *Client) reportedKeys(keys []string) []string {
+	switch c.ErrorKeys {
+	case KeysHashed:
+		hashed := make([]string, len(keys))
+		for i, key := range keys {
+			hashed[i] = HashKey(key)
+		}
+		return hashed
+	case KeysPlain:
+		return keys
+	}
+	return nil
+}
+
+// reportedKey is reportedKeys for a single key, returning "" if keys
+// are omitted.
+func (c *Client) reportedKey(key string) string {
+	if keys := c.reportedKeys([]string{key}); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// KeyError annotates an error returned by a Client with the keys of the
+// failed operation. It is only returned when the client's ErrorKeys is
+// set, and wraps cache-level errors too, so callers must test for them
+// with errors.Is rather than ==.
+type KeyError struct {
+	// Keys are the operation's keys, or their hashes under KeysHashed.
+	Keys []string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("%v (keys %s)", e.Err, strings.Join(e.Keys, ","))
+}
+
+func (e *KeyError) Unwrap() error { return e.Err }
+
+// withKeys wraps err in a KeyError if the client's ErrorKeys is set.
+func (c *Client) withKeys(keys []string, err error) error {
+	if err == nil || c.ErrorKeys == KeysOmitted {
+		return err
+	}
+	return &KeyError{Keys: c.reportedKeys(keys), Err: err}
+}
+
+// ServerErrorCode classifies the reason given in a SERVER_ERROR
+// response.
+type ServerErrorCode int
+
+const (
+	// ServerErrorUnknown is any reason not covered by another code.
+	ServerErrorUnknown ServerErrorCode = iota
+
+	// ServerErrorOutOfMemory means the server could not allocate memory
+	// for the item, for example "out of memory storing object".
+	ServerErrorOutOfMemory
+
+	// ServerErrorTooLarge means the item exceeds the server's maximum
+	// item size: "object too large for cache".
+	ServerErrorTooLarge
+
+	// ServerErrorBusy means the server is temporarily unable to serve
+	// the request, for example while the LRU crawler is busy.
+	ServerErrorBusy
+)
+
+func (code ServerErrorCode) String() string {
+	switch code {
+	case ServerErrorOutOfMemory:
+		return "out of memory"
+	case ServerErrorTooLarge:
+		return "too large"
+	case ServerErrorBusy:
+		return "busy"
+	}
+	return "unknown"
+}
+
+// ServerError is a parsed SERVER_ERROR response. It matches
+// ErrServerError with errors.Is, and is reachable from an OpError with
+// errors.As:
+//
+//	var se *memcache.ServerError
+//	if errors.As(err, &se) && se.Code == memcache.ServerErrorTooLarge { ... }
+type ServerError struct {
+	// Code classifies Message.
+	Code ServerErrorCode
+
+	// Message is the reason sent by the server.
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return "memcache: server error: " + e.Message
+}
+
+// Is reports whether target is ErrServerError.
+func (e *ServerError) Is(target error) bool { return target == ErrServerError }
+
+func parseServerErrorCode(msg string) ServerErrorCode {
+	switch {
+	case strings.Contains(msg, "too large"):
+		return ServerErrorTooLarge
+	case strings.Contains(msg, "out of memory"):
+		return ServerErrorOutOfMemory
+	case strings.Contains(msg, "busy"):
+		return ServerErrorBusy
+	}
+	return ServerErrorUnknown
+}
+
+// checkServerError returns a *ServerError if line is a SERVER_ERROR
+// response.
+func checkServerError(line []byte) error {
+	if !bytes.HasPrefix(line, resultServerErrorPrefix) {
+		return nil
+	}
+	msg := string(bytes.TrimSpace(line[len(resultServerErrorPrefix):]))
+	return &ServerError{Code: parseServerErrorCode(msg), Message: msg}
+}
@@ -0,0 +1,194 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpErrorTaxonomy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = New(addr).Get("foo")
+	if !errors.Is(err, ErrConnFailed) {
+		t.Fatalf("Get from closed server: got %v, want ErrConnFailed", err)
+	}
+	var oe *OpError
+	if !errors.As(err, &oe) || oe.Op != "gets" || oe.Addr.String() != addr {
+		t.Errorf("OpError = %+v, want gets on %s", oe, addr)
+	}
+
+	if err := checkServerError([]byte("SERVER_ERROR out of memory storing object\r\n")); !errors.Is(err, ErrServerError) ||
+		!strings.Contains(err.Error(), "out of memory") {
+		t.Errorf("checkServerError = %v", err)
+	}
+	if got := classifyError(wrapOpError("set", oe.Addr, ErrClassTimeout, errors.New("x"))); got != ErrClassTimeout {
+		t.Errorf("classifyError(wrapped timeout) = %q", got)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	tests := []struct {
+		line string
+		code ServerErrorCode
+	}{
+		{"SERVER_ERROR out of memory storing object\r\n", ServerErrorOutOfMemory},
+		{"SERVER_ERROR object too large for cache\r\n", ServerErrorTooLarge},
+		{"SERVER_ERROR busy\r\n", ServerErrorBusy},
+		{"SERVER_ERROR something else\r\n", ServerErrorUnknown},
+	}
+	for _, tt := range tests {
+		err := wrapOpError("set", nil, ErrClassServer, checkServerError([]byte(tt.line)))
+		var se *ServerError
+		if !errors.As(err, &se) {
+			t.Errorf("%q: %v is not a *ServerError", tt.line, err)
+			continue
+		}
+		if se.Code != tt.code {
+			t.Errorf("%q: Code = %v, want %v", tt.line, se.Code, tt.code)
+		}
+		if !errors.Is(err, ErrServerError) {
+			t.Errorf("%q: errors.Is(ErrServerError) = false", tt.line)
+		}
+	}
+	if err := checkServerError([]byte("STORED\r\n")); err != nil {
+		t.Errorf("checkServerError(STORED) = %v", err)
+	}
+}
+
+func TestErrorKeys(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+
+	c := New(s.Addr())
+	if _, err := c.Get("missing"); err != ErrCacheMiss {
+		t.Fatalf("Get with keys omitted = %v, want bare ErrCacheMiss", err)
+	}
+
+	c.ErrorKeys = KeysPlain
+	_, err := c.Get("missing")
+	var ke *KeyError
+	if !errors.As(err, &ke) || len(ke.Keys) != 1 || ke.Keys[0] != "missing" {
+		t.Fatalf("Get with plain keys = %v, want KeyError for missing", err)
+	}
+	if !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("errors.Is(%v, ErrCacheMiss) = false", err)
+	}
+
+	c.ErrorKeys = KeysHashed
+	rec := new(recordingMetrics)
+	c.Metrics = rec
+	err = c.Delete("missing")
+	if !errors.As(err, &ke) || ke.Keys[0] != HashKey("missing") {
+		t.Fatalf("Delete with hashed keys = %v, want KeyError with hashed key", err)
+	}
+	if strings.Contains(err.Error(), "missing") {
+		t.Errorf("error %q contains the plain key", err)
+	}
+	ends := rec.ends
+	if len(ends) != 1 || len(ends[0].Keys) != 1 || ends[0].Keys[0] != HashKey("missing") {
+		t.Errorf("OpMetrics = %+v, want hashed key", ends)
+	}
+}
+
+func TestErrorKeysHelpers(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.ErrorKeys = KeysPlain
+	ctx := context.Background()
+
+	v, err := c.GetOrSet(ctx, "loaded", time.Minute, func() ([]byte, error) { return []byte("v"), nil })
+	if err != nil || string(v) != "v" {
+		t.Errorf("GetOrSet = %q, %v, want v", v, err)
+	}
+	err = c.Update(ctx, "updated", 0, func(old []byte) ([]byte, error) { return append(old, 'x'), nil })
+	if err != nil {
+		t.Errorf("Update on a new key = %v", err)
+	}
+	if it, err := c.Get("updated"); err != nil || string(it.Value) != "x" {
+		t.Errorf("Get(updated) = %v, %v, want x", it, err)
+	}
+	ctr := c.NewCounter("ctr", time.Minute)
+	if n, err := ctr.IncrBy(3); err != nil || n != 3 {
+		t.Errorf("IncrBy on a new counter = %d, %v, want 3", n, err)
+	}
+	if n, err := ctr.Value(); err != nil || n != 3 {
+		t.Errorf("Value = %d, %v, want 3", n, err)
+	}
+}
+
+func TestErrorsOmitKeys(t *testing.T) {
+	const secret = "secret-key"
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.Transcoders = []Transcoder{&CompressionTranscoder{}}
+	c.ChunkSize = 1 << 20
+
+	check := func(name string, err error) {
+		t.Helper()
+		if err == nil {
+			t.Errorf("%s: no error", name)
+			return
+		}
+		if strings.Contains(err.Error(), secret) {
+			t.Errorf("%s: error %q carries the key", name, err)
+		}
+	}
+
+	s.put(secret+"-gzip", []byte("not gzip"), DefaultCompressionFlag)
+	_, err := c.Get(secret + "-gzip")
+	check("decompression", err)
+
+	s.put(secret+"-manifest", []byte("garbage"), ChunkedFlag)
+	_, err = c.Get(secret + "-manifest")
+	check("malformed manifest", err)
+
+	s.put(secret+"-short", []byte("chunks abc 1 5"), ChunkedFlag)
+	s.put(chunkKey("abc", 0), []byte("x"), 0)
+	_, err = c.Get(secret + "-short")
+	check("short chunks", err)
+
+	s.put(secret+"-counter", []byte("not a number"), 0)
+	_, err = c.NewCounter(secret+"-counter", time.Minute).Value()
+	check("non-numeric counter", err)
+
+	_, lease, err := c.GetLease(context.Background(), secret+"-lease", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("lease misuse", lease.Set(&Item{Key: secret + "-other"}))
+
+	r := bufio.NewReader(strings.NewReader("VALUE " + secret + "-b 0 1\r\nx\r\nEND\r\n"))
+	check("unexpected key", parseGetResponse(r, c.limits(), []string{secret + "-a"}, true, func(*Item) {}))
+
+	check("batch", BatchResult{secret: ErrNotStored}.Err())
+
+	var dead []string
+	for i := 0; i < 3; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dead = append(dead, ln.Addr().String())
+		ln.Close()
+	}
+	rc := New(dead...)
+	rc.Replicas = 3
+	_, err = rc.GetQuorum(secret)
+	var re *ReplicaError
+	if !errors.As(err, &re) || re.Key != "" {
+		t.Errorf("GetQuorum from dead replicas = %v, want a ReplicaError without key", err)
+	}
+	check("replicas", err)
+}
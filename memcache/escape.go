@@ -0,0 +1,54 @@
+package memcache
+
+import (
+	"strings"
+)
+
+// EscapeKey returns key with '%' and the bytes not allowed in keys,
+// such as spaces, newlines and other control characters, and non-ASCII
+// bytes, percent-escaped as in URLs, so that keys derived from user
+// input can't corrupt the text protocol and distinct inputs give
+// distinct keys. The result is legal if it fits memcached's limit of
+// 250 bytes. UnescapeKey reverses it.
+func EscapeKey(key string) string {
+	if legalKey(key) && strings.IndexByte(key, '%') < 0 {
+		return key
+	}
+	var sb strings.Builder
+	sb.Grow(len(key) + 8)
+	writeEscaped(&sb, key, "%")
+	return sb.String()
+}
+
+// UnescapeKey returns the key EscapeKey escaped to s. It returns
+// ErrMalformedKey if s holds a '%' not followed by two hexadecimal
+// digits.
+func UnescapeKey(s string) (string, error) {
+	i := strings.IndexByte(s, '%')
+	if i < 0 {
+		return s, nil
+	}
+	b := make([]byte, 0, len(s))
+	for ; i >= 0; i = strings.IndexByte(s, '%') {
+		if i+2 >= len(s) || unhex(s[i+1]) < 0 || unhex(s[i+2]) < 0 {
+			return "", ErrMalformedKey
+		}
+		b = append(b, s[:i]...)
+		b = append(b, byte(unhex(s[i+1])<<4|unhex(s[i+2])))
+		s = s[i+3:]
+	}
+	return string(append(b, s...)), nil
+}
+
+// unhex returns the value of the hexadecimal digit c, or -1.
+func unhex(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
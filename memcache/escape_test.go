@@ -0,0 +1,59 @@
+package memcache
+
+import (
+	"testing"
+)
+
+func TestEscapeKey(t *testing.T) {
+	for key, want := range map[string]string{
+		"plain":        "plain",
+		"a b":          "a%20b",
+		"line\r\nnext": "line%0D%0Anext",
+		"100%":         "100%25",
+		"café":         "caf%C3%A9",
+		"":             "",
+	} {
+		got := EscapeKey(key)
+		if got != want {
+			t.Errorf("EscapeKey(%q) = %q, want %q", key, got, want)
+		}
+		if !legalKey(got) {
+			t.Errorf("EscapeKey(%q) = %q is not a legal key", key, got)
+		}
+		if back, err := UnescapeKey(got); err != nil || back != key {
+			t.Errorf("UnescapeKey(%q) = %q, %v, want %q", got, back, err, key)
+		}
+	}
+	for _, s := range []string{"%", "%2", "%zz", "a%2g"} {
+		if _, err := UnescapeKey(s); err != ErrMalformedKey {
+			t.Errorf("UnescapeKey(%q) = %v, want ErrMalformedKey", s, err)
+		}
+	}
+}
+
+func TestEscapeKeys(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	key := "user input\nwith spaces"
+	if err := c.Set(&Item{Key: key, Value: []byte("v")}); err != ErrMalformedKey {
+		t.Fatalf("Set without EscapeKeys = %v, want ErrMalformedKey", err)
+	}
+
+	c.EscapeKeys = true
+	mustSet(t, c, &Item{Key: key, Value: []byte("v")})
+	if _, ok := s.get("user%20input%0Awith%20spaces"); !ok {
+		t.Fatal("item not stored under the escaped key")
+	}
+	it, err := c.Get(key)
+	if err != nil || it.Key != key {
+		t.Fatalf("Get = %+v, %v", it, err)
+	}
+	items, err := c.GetMulti([]string{key, "100%"})
+	if err != nil || len(items) != 1 || items[key] == nil {
+		t.Errorf("GetMulti = %v, %v", items, err)
+	}
+	if err := c.Delete(key); err != nil {
+		t.Errorf("Delete = %v", err)
+	}
+}
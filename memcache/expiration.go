@@ -0,0 +1,65 @@
+package memcache
+
+import (
+	"math"
+	"time"
+)
+
+// MaxRelativeExpiration is the largest Item.Expiration memcached treats
+// as relative to the current time; larger values are Unix times.
+const MaxRelativeExpiration = 60 * 60 * 24 * 30
+
+// expiredExpiration is an Item.Expiration in the past, which memcached
+// stores as already expired.
+const expiredExpiration = -1
+
+// ExpiresIn returns the Item.Expiration for an item that expires after
+// d. Durations beyond memcached's 30 day limit on relative expirations
+// are converted to an absolute time, rather than being taken for a
+// Unix time in 1970 and expiring at once. A positive d below one second
+// is rounded up. Zero means no expiration, and a negative d an item
+// that is already expired.
+func ExpiresIn(d time.Duration) int32 {
+	if d < 0 {
+		return expiredExpiration
+	}
+	return expirationFor(d)
+}
+
+// ExpiresAt returns the Item.Expiration for an item that expires at t.
+// Times within 30 days are converted to a relative expiration, so that
+// clock skew between the client and the servers doesn't matter; later
+// times are passed as Unix times, capped at the largest time an int32
+// holds. The zero Time means no expiration, and a time in the past an
+// item that is already expired.
+func ExpiresAt(t time.Time) int32 {
+	if t.IsZero() {
+		return 0
+	}
+	d := time.Until(t)
+	if d <= 0 {
+		return expiredExpiration
+	}
+	return expirationFor(d)
+}
+
+// expirationFor converts ttl to an Item.Expiration. A positive ttl
+// below one second is rounded up, and a ttl beyond the server's 30 day
+// limit on relative expirations is converted to an absolute time.
+func expirationFor(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+	secs := int64(ttl / time.Second)
+	if ttl%time.Second != 0 {
+		secs++
+	}
+	if secs <= MaxRelativeExpiration {
+		return int32(secs)
+	}
+	abs := time.Now().Unix() + secs
+	if secs > math.MaxInt32 || abs > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(abs)
+}
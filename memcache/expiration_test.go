@@ -0,0 +1,48 @@
+package memcache
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestExpiresIn(t *testing.T) {
+	for _, tt := range []struct {
+		d    time.Duration
+		want int32
+	}{
+		{0, 0},
+		{-time.Second, -1},
+		{time.Millisecond, 1},
+		{30 * 24 * time.Hour, MaxRelativeExpiration},
+		{math.MaxInt64, math.MaxInt32},
+	} {
+		if got := ExpiresIn(tt.d); got != tt.want {
+			t.Errorf("ExpiresIn(%v) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+	d := 30*24*time.Hour + time.Second
+	want := time.Now().Add(d).Unix()
+	if got := int64(ExpiresIn(d)); got < want-1 || got > want+1 {
+		t.Errorf("ExpiresIn(%v) = %d, want Unix time %d", d, got, want)
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	if got := ExpiresAt(time.Time{}); got != 0 {
+		t.Errorf("ExpiresAt(zero) = %d, want 0", got)
+	}
+	if got := ExpiresAt(time.Now().Add(-time.Minute)); got != -1 {
+		t.Errorf("ExpiresAt(past) = %d, want -1", got)
+	}
+	if got := ExpiresAt(time.Now().Add(time.Hour)); got < 3599 || got > 3600 {
+		t.Errorf("ExpiresAt(in an hour) = %d, want 3600", got)
+	}
+	far := time.Now().Add(60 * 24 * time.Hour)
+	if got := int64(ExpiresAt(far)); got < far.Unix()-1 || got > far.Unix()+1 {
+		t.Errorf("ExpiresAt(%v) = %d, want Unix time %d", far, got, far.Unix())
+	}
+	if got := ExpiresAt(time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)); got != math.MaxInt32 {
+		t.Errorf("ExpiresAt(2200) = %d, want MaxInt32", got)
+	}
+}
@@ -0,0 +1,74 @@
+package memcache
+
+import (
+	"expvar"
+	"net"
+)
+
+// MultiMetrics returns a MetricsRecorder that forwards every callback
+// to each of recorders in turn, including connection events to those
+// implementing ConnEventRecorder.
+func MultiMetrics(recorders ...MetricsRecorder) MetricsRecorder {
+	return multiMetrics(recorders)
+}
+
+type multiMetrics []MetricsRecorder
+
+func (mm multiMetrics) OpStart(op string, addr net.Addr) {
+	for _, r := range mm {
+		r.OpStart(op, addr)
+	}
+}
+
+func (mm multiMetrics) OpEnd(m OpMetrics) {
+	for _, r := range mm {
+		r.OpEnd(m)
+	}
+}
+
+func (mm multiMetrics) ConnEvent(addr net.Addr, ev ConnEvent) {
+	for _, r := range mm {
+		if cr, ok := r.(ConnEventRecorder); ok {
+			cr.ConnEvent(addr, ev)
+		}
+	}
+}
+
+// expvarMetrics is a MetricsRecorder counting into an expvar.Map.
+type expvarMetrics struct {
+	m *expvar.Map
+}
+
+// PublishExpvar publishes counters for c under the expvar name prefix,
+// for services that already expose /debug/vars. The published map holds
+// "ops", "hits", "misses", "errors" (by ErrorClass), "bytes_in",
+// "bytes_out", and "idle_conns" and "conns" (ConnStats) by server. The
+// recorder is added to any MetricsRecorder already set on c.
+//
+// Like expvar.Publish, PublishExpvar panics if prefix is already in use.
+func PublishExpvar(c *Client, prefix string) *expvar.Map {
+	m := expvar.NewMap(prefix)
+	m.Set("errors", new(expvar.Map))
+	m.Set("idle_conns", expvar.Func(func() interface{} { return c.IdleConns() }))
+	m.Set("conns", expvar.Func(func() interface{} { return c.ConnStats() }))
+	rec := &expvarMetrics{m: m}
+	if c.Metrics != nil {
+		c.Metrics = MultiMetrics(c.Metrics, rec)
+	} else {
+		c.Metrics = rec
+	}
+	return m
+}
+
+func (e *expvarMetrics) OpStart(op string, addr net.Addr) {}
+
+func (e *expvarMetrics) OpEnd(m OpMetrics) {
+	e.m.Add("ops", 1)
+	e.m.Add("hits", int64(m.Hits))
+	e.m.Add("misses", int64(m.Misses))
+	e.m.Add("bytes_in", m.BytesIn)
+	e.m.Add("bytes_out", m.BytesOut)
+	if m.ErrClass != ErrClassNone {
+		e.m.Get("errors").(*expvar.Map).Add(string(m.ErrClass), 1)
+	}
+}
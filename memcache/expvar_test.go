@@ -0,0 +1,38 @@
+package memcache
+
+import (
+	"expvar"
+	"strconv"
+	"testing"
+)
+
+// expvarTestRuns makes the published names unique across runs of
+// TestPublishExpvar, as with -count, since expvar names can't be reused.
+var expvarTestRuns int
+
+func TestPublishExpvar(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	rec := new(recordingMetrics)
+	c.Metrics = rec
+	expvarTestRuns++
+	name := "test_memcache_" + strconv.Itoa(expvarTestRuns)
+	m := PublishExpvar(c, name)
+
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("fooval")})
+	c.Get("foo")
+	c.Get("bar")
+
+	for name, want := range map[string]int64{"ops": 3, "hits": 1, "misses": 1} {
+		if got := m.Get(name).(*expvar.Int).Value(); got != want {
+			t.Errorf("%s = %d, want %d", name, got, want)
+		}
+	}
+	if len(rec.ends) != 3 {
+		t.Errorf("existing recorder got %d ops, want 3", len(rec.ends))
+	}
+	if got := expvar.Get(name); got != m {
+		t.Errorf("map not published under prefix")
+	}
+}
@@ -0,0 +1,479 @@
+package memcache
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is a minimal in-memory memcached speaking enough of the
+// ASCII protocol to exercise the client without a memcached binary.
+type fakeServer struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	items map[string]*fakeItem
+	cas   uint64
+
+	// noGat makes the server answer gat and gats with ERROR, as
+	// memcached before 1.5.3 does.
+	noGat bool
+
+	// noMeta makes the server answer mg and md with ERROR, as
+	// memcached before 1.6 does.
+	noMeta bool
+}
+
+type fakeItem struct {
+	value []byte
+	flags uint32
+	cas   uint64
+
+	// stale is set by markStale, and won once a client has been sent
+	// the win flag for the stale item.
+	stale, won bool
+
+	// vivified is set for placeholders created by mg with the N flag.
+	vivified bool
+
+	// exptime is the expiration last set or touched, unenforced.
+	exptime string
+
+	// fetched and accessed record the last mg of the item.
+	fetched  bool
+	accessed time.Time
+}
+
+func newFakeServer(t testing.TB) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("fake server listen: %v", err)
+	}
+	s := &fakeServer{ln: ln, items: make(map[string]*fakeItem)}
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) Addr() string { return s.ln.Addr().String() }
+
+func (s *fakeServer) Close() { s.ln.Close() }
+
+func (s *fakeServer) get(key string) (*fakeItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[key]
+	return it, ok
+}
+
+func (s *fakeServer) put(key string, value []byte, flags uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cas++
+	s.items[key] = &fakeItem{value: value, flags: flags, cas: s.cas}
+}
+
+func (s *fakeServer) del(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+}
+
+// markStale marks key stale, as a meta delete with the I flag would.
+func (s *fakeServer) markStale(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if it, ok := s.items[key]; ok {
+		it.stale, it.won = true, false
+	}
+}
+
+func (s *fakeServer) serve() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(c)
+	}
+}
+
+func (s *fakeServer) handle(c net.Conn) {
+	defer c.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if !s.dispatch(rw, f) {
+			return
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) dispatch(rw *bufio.ReadWriter, f []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch f[0] {
+	case "get", "gets", "gat", "gats":
+		keys := f[1:]
+		if f[0] == "gat" || f[0] == "gats" {
+			if s.noGat {
+				rw.WriteString("ERROR\r\n")
+				return true
+			}
+			keys = f[2:]
+			for _, key := range keys {
+				if it, ok := s.items[key]; ok {
+					it.exptime = f[1]
+				}
+			}
+		}
+		for _, key := range keys {
+			it, ok := s.items[key]
+			if !ok {
+				continue
+			}
+			if f[0] == "gets" || f[0] == "gats" {
+				fmt.Fprintf(rw, "VALUE %s %d %d %d\r\n", key, it.flags, len(it.value), it.cas)
+			} else {
+				fmt.Fprintf(rw, "VALUE %s %d %d\r\n", key, it.flags, len(it.value))
+			}
+			rw.Write(it.value)
+			rw.WriteString("\r\n")
+		}
+		rw.WriteString("END\r\n")
+	case "set", "add", "replace", "cas":
+		flags, _ := strconv.ParseUint(f[2], 10, 32)
+		size, _ := strconv.Atoi(f[4])
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rw, buf); err != nil {
+			return false
+		}
+		it, exists := s.items[f[1]]
+		switch {
+		case f[0] == "add" && exists, f[0] == "replace" && !exists:
+			rw.WriteString("NOT_STORED\r\n")
+			return true
+		case f[0] == "cas" && !exists:
+			rw.WriteString("NOT_FOUND\r\n")
+			return true
+		case f[0] == "cas" && f[5] != strconv.FormatUint(it.cas, 10):
+			rw.WriteString("EXISTS\r\n")
+			return true
+		}
+		s.cas++
+		s.items[f[1]] = &fakeItem{value: buf[:size], flags: uint32(flags), cas: s.cas, exptime: f[3]}
+		if strings.HasPrefix(f[3], "-") {
+			// A negative expiration expires the item at once.
+			delete(s.items, f[1])
+		}
+		rw.WriteString("STORED\r\n")
+	case "delete":
+		if _, ok := s.items[f[1]]; !ok {
+			rw.WriteString("NOT_FOUND\r\n")
+			return true
+		}
+		delete(s.items, f[1])
+		rw.WriteString("DELETED\r\n")
+	case "incr", "decr":
+		it, ok := s.items[f[1]]
+		if !ok {
+			rw.WriteString("NOT_FOUND\r\n")
+			return true
+		}
+		n, err := strconv.ParseUint(string(it.value), 10, 64)
+		if err != nil {
+			rw.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return true
+		}
+		delta, _ := strconv.ParseUint(f[2], 10, 64)
+		if f[0] == "incr" {
+			n += delta
+		} else if delta > n {
+			n = 0
+		} else {
+			n -= delta
+		}
+		s.cas++
+		it.value, it.cas = []byte(strconv.FormatUint(n, 10)), s.cas
+		fmt.Fprintf(rw, "%d\r\n", n)
+	case "touch":
+		it, ok := s.items[f[1]]
+		if !ok {
+			rw.WriteString("NOT_FOUND\r\n")
+			return true
+		}
+		it.exptime = f[2]
+		rw.WriteString("TOUCHED\r\n")
+	case "flush_all":
+		s.items = make(map[string]*fakeItem)
+		rw.WriteString("OK\r\n")
+	case "lru_crawler":
+		for key, it := range s.items {
+			fmt.Fprintf(rw, "key=%s exp=-1 la=0 cas=%d fetch=no cls=1 size=%d\r\n", url.QueryEscape(key), it.cas, len(it.value))
+		}
+		rw.WriteString("END\r\n")
+	case "mg":
+		if s.noMeta {
+			rw.WriteString("ERROR\r\n")
+			return true
+		}
+		s.metaGet(rw, metaKeyArg(f[1], f[2:]), f[2:])
+	case "ma":
+		s.metaArith(rw, metaKeyArg(f[1], f[2:]), f[2:])
+	case "ms":
+		size, _ := strconv.Atoi(f[2])
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rw, buf); err != nil {
+			return false
+		}
+		s.metaSet(rw, metaKeyArg(f[1], f[3:]), buf[:size], f[3:])
+	case "mn":
+		rw.WriteString("MN\r\n")
+	case "md":
+		if s.noMeta {
+			rw.WriteString("ERROR\r\n")
+			return true
+		}
+		key := metaKeyArg(f[1], f[2:])
+		it, ok := s.items[key]
+		if !ok {
+			rw.WriteString("NF\r\n")
+			return true
+		}
+		if hasMetaFlag(f[2:], 'I') {
+			s.cas++
+			it.stale, it.won, it.cas = true, false, s.cas
+			rw.WriteString("HD\r\n")
+			return true
+		}
+		delete(s.items, key)
+		rw.WriteString("HD\r\n")
+	case "stats":
+		fmt.Fprintf(rw, "STAT curr_items %d\r\nEND\r\n", len(s.items))
+	default:
+		rw.WriteString("ERROR\r\n")
+	}
+	return true
+}
+
+// metaKeyArg returns the key of a meta command, decoding it if flags
+// hold the b flag.
+func metaKeyArg(key string, flags []string) string {
+	if !hasMetaFlag(flags, 'b') {
+		return key
+	}
+	b, _ := base64.StdEncoding.DecodeString(key)
+	return string(b)
+}
+
+// metaSet answers "ms key size flags...", supporting the F, T, M, C
+// and c flags.
+func (s *fakeServer) metaSet(rw *bufio.ReadWriter, key string, value []byte, flags []string) {
+	var itemFlags uint64
+	exptime, mode, cas := "0", "S", ""
+	for _, fl := range flags {
+		switch fl[0] {
+		case 'F':
+			itemFlags, _ = strconv.ParseUint(fl[1:], 10, 32)
+		case 'T':
+			exptime = fl[1:]
+		case 'M':
+			mode = fl[1:]
+		case 'C':
+			cas = fl[1:]
+		}
+	}
+	it, exists := s.items[key]
+	switch {
+	case mode == "E" && exists, mode == "R" && !exists:
+		rw.WriteString("NS\r\n")
+		return
+	case cas != "" && !exists:
+		rw.WriteString("NF\r\n")
+		return
+	case cas != "" && cas != strconv.FormatUint(it.cas, 10):
+		rw.WriteString("EX\r\n")
+		return
+	}
+	s.cas++
+	s.items[key] = &fakeItem{value: value, flags: uint32(itemFlags), cas: s.cas, exptime: exptime}
+	if hasMetaFlag(flags, 'c') {
+		fmt.Fprintf(rw, "HD c%d\r\n", s.cas)
+		return
+	}
+	rw.WriteString("HD\r\n")
+}
+
+// metaGet answers "mg key flags...", supporting the v, f, c, k, s, t,
+// l, h, T, N, O, q and b flags and reporting the W, X and Z flags of stale
+// and vivified items.
+func (s *fakeServer) metaGet(rw *bufio.ReadWriter, key string, flags []string) {
+	it, ok := s.items[key]
+	won := false
+	if !ok {
+		if !hasMetaFlag(flags, 'N') {
+			if !hasMetaFlag(flags, 'q') {
+				rw.WriteString("EN" + metaOpaque(flags) + "\r\n")
+			}
+			return
+		}
+		// Vivify: create an empty placeholder won by this client.
+		s.cas++
+		it = &fakeItem{cas: s.cas, vivified: true}
+		s.items[key] = it
+		won = true
+	}
+	var ret []string
+	value := false
+	for _, fl := range flags {
+		switch fl[0] {
+		case 'v':
+			value = true
+		case 'f':
+			ret = append(ret, fmt.Sprintf("f%d", it.flags))
+		case 'c':
+			ret = append(ret, fmt.Sprintf("c%d", it.cas))
+		case 'k':
+			if hasMetaFlag(flags, 'b') {
+				ret = append(ret, "k"+base64.StdEncoding.EncodeToString([]byte(key)), "b")
+			} else {
+				ret = append(ret, "k"+key)
+			}
+		case 's':
+			ret = append(ret, fmt.Sprintf("s%d", len(it.value)))
+		case 't':
+			if n, err := strconv.Atoi(it.exptime); err == nil && n > 0 {
+				ret = append(ret, fmt.Sprintf("t%d", n))
+			} else {
+				ret = append(ret, "t-1")
+			}
+		case 'l':
+			idle := 0
+			if !it.accessed.IsZero() {
+				idle = int(time.Since(it.accessed).Seconds())
+			}
+			ret = append(ret, fmt.Sprintf("l%d", idle))
+		case 'h':
+			if it.fetched {
+				ret = append(ret, "h1")
+			} else {
+				ret = append(ret, "h0")
+			}
+		case 'T':
+			it.exptime = fl[1:]
+		case 'O':
+			ret = append(ret, fl)
+		}
+	}
+	it.fetched, it.accessed = true, time.Now()
+	if it.stale {
+		if !it.won {
+			it.won = true
+			ret = append(ret, "W")
+		} else {
+			ret = append(ret, "Z")
+		}
+		ret = append(ret, "X")
+	} else if won {
+		ret = append(ret, "W")
+	} else if it.vivified {
+		ret = append(ret, "Z")
+	}
+	if !value {
+		fmt.Fprintf(rw, "HD %s\r\n", strings.Join(ret, " "))
+		return
+	}
+	fmt.Fprintf(rw, "VA %d %s\r\n", len(it.value), strings.Join(ret, " "))
+	rw.Write(it.value)
+	rw.WriteString("\r\n")
+}
+
+// metaOpaque returns the O flag of a meta command, with a leading
+// space, as echoed by responses without other flags, or "" if it has
+// none.
+func metaOpaque(flags []string) string {
+	for _, fl := range flags {
+		if fl[0] == 'O' {
+			return " " + fl
+		}
+	}
+	return ""
+}
+
+func hasMetaFlag(flags []string, f byte) bool {
+	for _, fl := range flags {
+		if fl[0] == f {
+			return true
+		}
+	}
+	return false
+}
+
+// metaArith answers "ma key flags...", supporting the N, J, D, M and v
+// flags.
+func (s *fakeServer) metaArith(rw *bufio.ReadWriter, key string, flags []string) {
+	var vivify bool
+	var initial, delta uint64 = 0, 1
+	decr := false
+	exptime := "0"
+	for _, fl := range flags {
+		switch fl[0] {
+		case 'N':
+			vivify, exptime = true, fl[1:]
+		case 'J':
+			initial, _ = strconv.ParseUint(fl[1:], 10, 64)
+		case 'D':
+			delta, _ = strconv.ParseUint(fl[1:], 10, 64)
+		case 'M':
+			decr = fl[1:] == "D" || fl[1:] == "-"
+		}
+	}
+	it, ok := s.items[key]
+	var n uint64
+	switch {
+	case !ok && !vivify:
+		rw.WriteString("NF\r\n")
+		return
+	case !ok:
+		n = initial
+	default:
+		var err error
+		if n, err = strconv.ParseUint(string(it.value), 10, 64); err != nil {
+			rw.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return
+		}
+		if !decr {
+			n += delta
+		} else if delta > n {
+			n = 0
+		} else {
+			n -= delta
+		}
+		exptime = it.exptime
+	}
+	s.cas++
+	v := strconv.FormatUint(n, 10)
+	s.items[key] = &fakeItem{value: []byte(v), cas: s.cas, exptime: exptime}
+	if !hasMetaFlag(flags, 'v') {
+		rw.WriteString("HD\r\n")
+		return
+	}
+	fmt.Fprintf(rw, "VA %d\r\n%s\r\n", len(v), v)
+}
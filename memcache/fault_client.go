@@ -0,0 +1,191 @@
+package memcache
+
+import (
+	"math/rand"
+	"net"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Fault describes a failure injected by a FaultClient. A fault applies
+// to an operation if the operation is listed in Ops, one of its keys
+// matches KeyPattern, and a random draw falls below Probability.
+type Fault struct {
+	// Ops restricts the fault to the named operations: "get",
+	// "getmulti", "set", "add", "cas", "delete", "incr", "decr" or
+	// "stats". If empty, the fault applies to all of them.
+	Ops []string
+
+	// KeyPattern, if non-nil, restricts the fault to operations with a
+	// key it matches.
+	KeyPattern *regexp.Regexp
+
+	// Probability is the chance, between 0 and 1, that the fault
+	// applies to a matching operation.
+	Probability float64
+
+	// Latency is added before the operation is performed or failed.
+	Latency time.Duration
+
+	// Timeout makes the operation fail with an OpError of kind
+	// ErrTimeout, as if the server had not responded in time.
+	Timeout bool
+
+	// Err, if non-nil, is returned instead of performing the operation.
+	Err error
+
+	// PartialFraction is the fraction of items, between 0 and 1, removed
+	// from a GetMulti result, restricted to keys matching KeyPattern.
+	// A fault with PartialFraction set only removes items; its other
+	// fields except KeyPattern are ignored.
+	PartialFraction float64
+}
+
+func (f *Fault) applies(op string, keys []string) bool {
+	if len(f.Ops) > 0 {
+		found := false
+		for _, o := range f.Ops {
+			found = found || o == op
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.KeyPattern != nil {
+		found := false
+		for _, key := range keys {
+			found = found || f.KeyPattern.MatchString(key)
+		}
+		if !found {
+			return false
+		}
+	}
+	return rand.Float64() < f.Probability
+}
+
+// FaultClient wraps a MemcacheClient and injects latency, timeouts,
+// errors and partial GetMulti results, for chaos testing the fallback
+// paths of applications. It must not be used in production.
+type FaultClient struct {
+	c MemcacheClient
+
+	// Faults are the faults to inject. They are evaluated in order and
+	// the latencies of all applying faults add up; the first applying
+	// fault with Timeout or Err set decides the error.
+	Faults []Fault
+}
+
+// NewFaultInjector returns a FaultClient injecting faults into
+// operations on c.
+func NewFaultInjector(c MemcacheClient, faults ...Fault) *FaultClient {
+	return &FaultClient{c: c, Faults: faults}
+}
+
+// inject applies the faults for op and returns the error, if any, that
+// the operation should fail with instead of being performed.
+func (f *FaultClient) inject(op string, keys ...string) error {
+	var err error
+	for i := range f.Faults {
+		fault := &f.Faults[i]
+		if fault.PartialFraction > 0 || !fault.applies(op, keys) {
+			continue
+		}
+		time.Sleep(fault.Latency)
+		if err != nil {
+			continue
+		}
+		switch {
+		case fault.Timeout:
+			err = &OpError{Op: op, Kind: ErrTimeout, Err: os.ErrDeadlineExceeded}
+		case fault.Err != nil:
+			err = fault.Err
+		}
+	}
+	return err
+}
+
+// dropItems applies the partial-result faults to a GetMulti result.
+func (f *FaultClient) dropItems(items map[string]*Item) {
+	for i := range f.Faults {
+		fault := &f.Faults[i]
+		if fault.PartialFraction <= 0 {
+			continue
+		}
+		for key := range items {
+			if fault.KeyPattern != nil && !fault.KeyPattern.MatchString(key) {
+				continue
+			}
+			if rand.Float64() < fault.PartialFraction {
+				delete(items, key)
+			}
+		}
+	}
+}
+
+func (f *FaultClient) Get(key string) (*Item, error) {
+	if err := f.inject("get", key); err != nil {
+		return nil, err
+	}
+	return f.c.Get(key)
+}
+
+func (f *FaultClient) GetMulti(keys []string) (map[string]*Item, error) {
+	if err := f.inject("getmulti", keys...); err != nil {
+		return nil, err
+	}
+	items, err := f.c.GetMulti(keys)
+	if err == nil {
+		f.dropItems(items)
+	}
+	return items, err
+}
+
+func (f *FaultClient) Stats() (map[net.Addr]map[string]string, error) {
+	if err := f.inject("stats"); err != nil {
+		return nil, err
+	}
+	return f.c.Stats()
+}
+
+func (f *FaultClient) Set(item *Item) error {
+	if err := f.inject("set", item.Key); err != nil {
+		return err
+	}
+	return f.c.Set(item)
+}
+
+func (f *FaultClient) Add(item *Item) error {
+	if err := f.inject("add", item.Key); err != nil {
+		return err
+	}
+	return f.c.Add(item)
+}
+
+func (f *FaultClient) CompareAndSwap(item *Item) error {
+	if err := f.inject("cas", item.Key); err != nil {
+		return err
+	}
+	return f.c.CompareAndSwap(item)
+}
+
+func (f *FaultClient) Delete(key string) error {
+	if err := f.inject("delete", key); err != nil {
+		return err
+	}
+	return f.c.Delete(key)
+}
+
+func (f *FaultClient) Increment(key string, delta uint64) (uint64, error) {
+	if err := f.inject("incr", key); err != nil {
+		return 0, err
+	}
+	return f.c.Increment(key, delta)
+}
+
+func (f *FaultClient) Decrement(key string, delta uint64) (uint64, error) {
+	if err := f.inject("decr", key); err != nil {
+		return 0, err
+	}
+	return f.c.Decrement(key, delta)
+}
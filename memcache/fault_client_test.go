@@ -0,0 +1,50 @@
+package memcache
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFaultClient(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	s.put("user:1", []byte("a"), 0)
+	s.put("user:2", []byte("b"), 0)
+	s.put("other", []byte("c"), 0)
+
+	errBoom := errors.New("boom")
+	f := NewFaultInjector(New(s.Addr()),
+		Fault{Ops: []string{"get"}, KeyPattern: regexp.MustCompile(`^user:`), Probability: 1, Err: errBoom},
+		Fault{Ops: []string{"set"}, Probability: 1, Latency: 20 * time.Millisecond, Timeout: true},
+		Fault{KeyPattern: regexp.MustCompile(`^user:`), PartialFraction: 1},
+	)
+
+	if _, err := f.Get("user:1"); err != errBoom {
+		t.Errorf("Get(user:1) = %v, want injected error", err)
+	}
+	if it, err := f.Get("other"); err != nil || string(it.Value) != "c" {
+		t.Errorf("Get(other) = %v, %v; want pass-through", it, err)
+	}
+
+	start := time.Now()
+	err := f.Set(&Item{Key: "x", Value: []byte("y")})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Set = %v, want ErrTimeout", err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("Set returned after %v, want injected latency", d)
+	}
+	if _, ok := s.get("x"); ok {
+		t.Error("Set reached the server despite an injected timeout")
+	}
+
+	items, err := f.GetMulti([]string{"user:1", "user:2", "other"})
+	if err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if len(items) != 1 || items["other"] == nil {
+		t.Errorf("GetMulti = %v, want only other", items)
+	}
+}
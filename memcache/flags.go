@@ -0,0 +1,102 @@
+package memcache
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// FlagReserver is implemented by Transcoders that reserve bits of
+// Item.Flags.
+type FlagReserver interface {
+	// ReservedFlags returns the Item.Flags bits the Transcoder uses.
+	ReservedFlags() uint32
+}
+
+func (t *CompressionTranscoder) ReservedFlags() uint32 { return t.flag() }
+func (t *ChecksumTranscoder) ReservedFlags() uint32    { return t.flag() }
+func (t *EncryptionTranscoder) ReservedFlags() uint32  { return t.flag() }
+func (t *EnvelopeTranscoder) ReservedFlags() uint32    { return t.flag() }
+
+// FlagConflictError reports a bit of Item.Flags reserved twice.
+type FlagConflictError struct {
+	// Bit is the conflicting bit, from 0 to 31.
+	Bit int
+
+	// Owner and Other are the users of the bit.
+	Owner, Other string
+}
+
+func (e *FlagConflictError) Error() string {
+	return fmt.Sprintf("memcache: item flag bit %d reserved by both %s and %s", e.Bit, e.Owner, e.Other)
+}
+
+// FlagRegistry records which user owns each bit of Item.Flags. The zero
+// value is an empty registry.
+type FlagRegistry struct {
+	owners [32]string
+}
+
+// Reserve reserves flags for owner, returning a *FlagConflictError
+// without reserving anything if any of the bits is already reserved.
+func (r *FlagRegistry) Reserve(owner string, flags uint32) error {
+	for f := flags; f != 0; f &= f - 1 {
+		bit := bits.TrailingZeros32(f)
+		if r.owners[bit] != "" {
+			return &FlagConflictError{Bit: bit, Owner: r.owners[bit], Other: owner}
+		}
+	}
+	for f := flags; f != 0; f &= f - 1 {
+		r.owners[bits.TrailingZeros32(f)] = owner
+	}
+	return nil
+}
+
+// Owner returns the owner of bit, or "" if it is free.
+func (r *FlagRegistry) Owner(bit int) string {
+	return r.owners[bit]
+}
+
+// CheckFlags returns a registry of the Item.Flags bits used by the client:
+// the application's AppFlags, the flags of the client's own features
+// such as ChunkedFlag, the Codec's flags, and those of each Transcoder
+// implementing FlagReserver. It returns a *FlagConflictError if two of
+// them overlap, as happens when stacked transcoders are left on their
+// default flag or an application flag collides with one. Call it once
+// the client is configured, at startup, to catch such conflicts before
+// they corrupt values.
+func (c *Client) CheckFlags() (*FlagRegistry, error) {
+	r := new(FlagRegistry)
+	if err := r.Reserve("application", c.AppFlags); err != nil {
+		return nil, err
+	}
+	if c.ChunkSize > 0 {
+		if err := r.Reserve("chunking", ChunkedFlag); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.Reserve("soft TTL", SoftTTLFlag); err != nil {
+		return nil, err
+	}
+	if err := r.Reserve("negative caching", NegativeFlag); err != nil {
+		return nil, err
+	}
+	if err := r.Reserve("leases", LeaseFlag); err != nil {
+		return nil, err
+	}
+	if c.Tombstones {
+		if err := r.Reserve("tombstones", TombstoneFlag); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.Reserve(fmt.Sprintf("codec %T", c.codec()), c.codec().Flags()); err != nil {
+		return nil, err
+	}
+	for i, t := range c.Transcoders {
+		if fr, ok := t.(FlagReserver); ok {
+			if err := r.Reserve(fmt.Sprintf("transcoder %d (%T)", i, t), fr.ReservedFlags()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,37 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFlagRegistry(t *testing.T) {
+	c := New()
+	c.AppFlags = 0xff
+	c.Transcoders = []Transcoder{&CompressionTranscoder{}, &ChecksumTranscoder{}, &EncryptionTranscoder{}}
+	r, err := c.CheckFlags()
+	if err != nil {
+		t.Fatalf("CheckFlags: %v", err)
+	}
+	if got := r.Owner(31); got != "transcoder 0 (*memcache.CompressionTranscoder)" {
+		t.Errorf("owner of bit 31 = %q", got)
+	}
+	if got := r.Owner(3); got != "application" {
+		t.Errorf("owner of bit 3 = %q", got)
+	}
+	if got := r.Owner(10); got != "" {
+		t.Errorf("owner of bit 10 = %q, want free", got)
+	}
+
+	c.Transcoders = append(c.Transcoders, &CompressionTranscoder{Flag: 1 << 2})
+	_, err = c.CheckFlags()
+	var fce *FlagConflictError
+	if !errors.As(err, &fce) || fce.Bit != 2 || fce.Owner != "application" {
+		t.Errorf("CheckFlags with overlapping transcoder = %v, want conflict on bit 2", err)
+	}
+
+	c.Transcoders = []Transcoder{&ChecksumTranscoder{}, &ChecksumTranscoder{}}
+	if _, err := c.CheckFlags(); !errors.As(err, &fce) {
+		t.Errorf("CheckFlags with two transcoders on the default flag = %v, want conflict", err)
+	}
+}
@@ -0,0 +1,86 @@
+package memcache
+
+import "sync"
+
+// flightGroup deduplicates concurrent reads of the same key, in the
+// manner of golang.org/x/sync/singleflight.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// flight is a read in progress.
+type flight struct {
+	wg   sync.WaitGroup
+	dups int
+
+	// item and err are the result, set before wg is done. item is a
+	// copy kept for the waiters to copy in turn.
+	item *Item
+	err  error
+}
+
+// do calls fn for key unless a call for key is already in flight, in
+// which case it waits for that call and returns a copy of its result.
+func (g *flightGroup) do(key string, fn func() (*Item, error)) (*Item, error) {
+	g.mu.Lock()
+	if f, ok := g.calls[key]; ok {
+		f.dups++
+		g.mu.Unlock()
+		f.wg.Wait()
+		if f.err != nil {
+			return nil, f.err
+		}
+		return f.item.clone(), nil
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	f := new(flight)
+	f.wg.Add(1)
+	g.calls[key] = f
+	g.mu.Unlock()
+
+	item, err := fn()
+	g.land(key, f, item, err)
+	return item, err
+}
+
+// goOnce calls fn for key in a new goroutine, unless a call for key is
+// already in flight, and reports whether it did. Calls of do for key
+// made meanwhile wait for fn.
+func (g *flightGroup) goOnce(key string, fn func() (*Item, error)) bool {
+	g.mu.Lock()
+	if _, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		return false
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	f := new(flight)
+	f.wg.Add(1)
+	g.calls[key] = f
+	g.mu.Unlock()
+
+	go func() {
+		item, err := fn()
+		g.land(key, f, item, err)
+	}()
+	return true
+}
+
+// land ends the flight f for key with the result of its call.
+func (g *flightGroup) land(key string, f *flight, item *Item, err error) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	dups := f.dups
+	g.mu.Unlock()
+	if dups > 0 {
+		f.err = err
+		if err == nil {
+			f.item = item.clone()
+		}
+	}
+	f.wg.Done()
+}
@@ -0,0 +1,78 @@
+package memcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFlightGroup(t *testing.T) {
+	var g flightGroup
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (*Item, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &Item{Key: "k", Value: []byte("v")}, nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	items := make([]*Item, n)
+	started := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			started <- struct{}{}
+			it, err := g.do("k", fn)
+			if err != nil {
+				t.Error(err)
+			}
+			items[i] = it
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		<-started
+	}
+	// Let the waiters join the flight before it lands.
+	for {
+		g.mu.Lock()
+		f := g.calls["k"]
+		joined := f != nil && f.dups == n-1
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("fn called %d times, want 1", calls)
+	}
+	seen := make(map[*Item]bool)
+	for _, it := range items {
+		if string(it.Value) != "v" || seen[it] {
+			t.Errorf("item %p = %q, shared %v", it, it.Value, seen[it])
+		}
+		seen[it] = true
+	}
+	if _, ok := g.calls["k"]; ok {
+		t.Error("finished flight still registered")
+	}
+}
+
+func TestDedupGets(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.DedupGets = true
+	s.put("a", []byte("alpha"), 0)
+	if it, err := c.Get("a"); err != nil || string(it.Value) != "alpha" {
+		t.Errorf("Get = %v, %v", it, err)
+	}
+	if _, err := c.Get("missing"); err != ErrCacheMiss {
+		t.Errorf("Get(missing) = %v, want ErrCacheMiss", err)
+	}
+}
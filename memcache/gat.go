@@ -0,0 +1,82 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// errGatUnsupported is returned by a server lacking the gats command.
+var errGatUnsupported = errors.New("memcache: server does not support gats")
+
+// GetAndTouch gets the item for key, like Get, and resets its
+// expiration to seconds, like Touch, in one round trip with the gats
+// command. With replication, or once a server has answered that it
+// lacks the command, as memcached before 1.5.3 does, the client issues
+// a Get and a Touch instead.
+func (c *Client) GetAndTouch(key string, seconds int32) (item *Item, err error) {
+	op := &Op{Name: "gat", Keys: []string{key}}
+	err = c.intercept(op, func(ctx context.Context, op *Op) (err error) {
+		op.Item, err = c.getAndTouch(op.Keys[0], seconds)
+		if err == nil {
+			err = c.finishRead(op.Item)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return op.Item, nil
+}
+
+func (c *Client) getAndTouch(key string, seconds int32) (item *Item, err error) {
+	if c.Replicas <= 1 && atomic.LoadInt32(&c.state.noGat) == 0 && !c.binaryKey(key) {
+		err = c.withKeyAddr(key, func(addr net.Addr) error {
+			return c.withAddrConn(addr, "gats", []string{key}, func(cn *conn, m *OpMetrics) error {
+				return c.gatsRw(cn.rw, key, seconds, func(it *Item) { item = it })
+			})
+		})
+		if !errors.Is(err, errGatUnsupported) {
+			if err == nil && item == nil {
+				err = ErrCacheMiss
+			}
+			return item, err
+		}
+		atomic.StoreInt32(&c.state.noGat, 1)
+	}
+	if item, err = c.get(key); err != nil {
+		return nil, err
+	}
+	err = c.withKeyWriteRw(key, "touch", func(rw *bufio.ReadWriter) error {
+		return c.touchRw(rw, key, seconds)
+	})
+	if errors.Is(err, ErrCacheMiss) {
+		// Deleted or expired since the get: the value read is still
+		// the one the caller would have got a moment earlier.
+		err = nil
+	}
+	return item, err
+}
+
+// gatsRw sends a gats command for key and reads its response.
+func (c *Client) gatsRw(rw *bufio.ReadWriter, key string, seconds int32, cb func(*Item)) error {
+	if _, err := fmt.Fprintf(rw, "gats %d %s\r\n", seconds, key); err != nil {
+		return err
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	// "ERROR" and "END", the shortest valid response, are both at
+	// least five bytes long.
+	if b, err := rw.Peek(5); err == nil && bytes.Equal(b, []byte("ERROR")) {
+		if _, err := c.limits().readLine(rw.Reader); err != nil {
+			return err
+		}
+		return errGatUnsupported
+	}
+	return parseGetResponse(rw.Reader, c.limits(), []string{key}, c.StrictResponses, cb)
+}
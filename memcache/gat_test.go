@@ -0,0 +1,34 @@
+package memcache
+
+import "testing"
+
+func TestGetAndTouch(t *testing.T) {
+	for _, noGat := range []bool{false, true} {
+		s := newFakeServer(t)
+		defer s.Close()
+		s.mu.Lock()
+		s.noGat = noGat
+		s.mu.Unlock()
+		c := New(s.Addr())
+
+		mustSet(t, c, &Item{Key: "k", Value: []byte("v"), Expiration: 10})
+		for i := 0; i < 2; i++ {
+			it, err := c.GetAndTouch("k", 60)
+			if err != nil || string(it.Value) != "v" {
+				t.Fatalf("noGat=%v: GetAndTouch = %+v, %v", noGat, it, err)
+			}
+			if fi, _ := s.get("k"); fi.exptime != "60" {
+				t.Errorf("noGat=%v: expiration after GetAndTouch = %s, want 60", noGat, fi.exptime)
+			}
+		}
+		// The item keeps its CAS ID.
+		it, _ := c.GetAndTouch("k", 60)
+		it.Value = []byte("w")
+		if err := c.CompareAndSwap(it); err != nil {
+			t.Errorf("noGat=%v: CompareAndSwap after GetAndTouch = %v", noGat, err)
+		}
+		if _, err := c.GetAndTouch("missing", 60); err != ErrCacheMiss {
+			t.Errorf("noGat=%v: GetAndTouch of missing key = %v, want ErrCacheMiss", noGat, err)
+		}
+	}
+}
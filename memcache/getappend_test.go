@@ -0,0 +1,47 @@
+package memcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetAppend(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	s.put("foo", []byte("bar"), 5)
+
+	buf := []byte("prefix:")
+	buf, it, err := c.GetAppend("foo", buf)
+	if err != nil {
+		t.Fatalf("GetAppend: %v", err)
+	}
+	if string(buf) != "prefix:bar" || string(it.Value) != "bar" || it.Flags != 5 || it.Key != "foo" {
+		t.Errorf("GetAppend = %q, %+v", buf, it)
+	}
+	it.Value = []byte("baz")
+	if err := c.CompareAndSwap(&it); err != nil {
+		t.Errorf("CompareAndSwap of GetAppend item: %v", err)
+	}
+
+	buf, _, err = c.GetAppend("missing", buf[:0])
+	if err != ErrCacheMiss || len(buf) != 0 {
+		t.Errorf("GetAppend of missing key = %q, %v; want ErrCacheMiss", buf, err)
+	}
+
+	// A large enough buffer is reused.
+	buf = make([]byte, 0, 64)
+	out, _, err := c.GetAppend("foo", buf)
+	if err != nil || string(out) != "baz" || &out[0] != &buf[:1][0] {
+		t.Errorf("GetAppend into spare capacity = %q, %v; want reuse of buf", out, err)
+	}
+
+	c.Transcoders = []Transcoder{&CompressionTranscoder{MinSize: 1}}
+	if err := c.Set(&Item{Key: "z", Value: []byte(strings.Repeat("z", 100))}); err != nil {
+		t.Fatal(err)
+	}
+	buf, it, err = c.GetAppend("z", nil)
+	if err != nil || string(buf) != strings.Repeat("z", 100) || string(it.Value) != string(buf) {
+		t.Errorf("GetAppend with transcoder = %q, %v", buf, err)
+	}
+}
@@ -0,0 +1,51 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGetMultiFanOut(t *testing.T) {
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		s := newFakeServer(t)
+		defer s.Close()
+		addrs = append(addrs, s.Addr())
+	}
+	var keys []string
+	for i := 0; i < 40; i++ {
+		keys = append(keys, fmt.Sprintf("f%d", i))
+	}
+	for _, tt := range []struct{ par, perGet int }{{0, 0}, {1, 0}, {2, 3}, {0, 1}} {
+		c := New(addrs...)
+		c.GetMultiParallelism, c.MaxKeysPerGet = tt.par, tt.perGet
+		rec := &recordingMetrics{}
+		c.Metrics = rec
+		for _, key := range keys[:30] {
+			mustSet(t, c, &Item{Key: key, Value: []byte(key)})
+		}
+		rec.mu.Lock()
+		rec.ends = nil
+		rec.mu.Unlock()
+		m, err := c.GetMulti(keys)
+		if err != nil || len(m) != 30 {
+			t.Errorf("%+v: GetMulti = %d items, %v", tt, len(m), err)
+			continue
+		}
+		for key, it := range m {
+			if string(it.Value) != key {
+				t.Errorf("%+v: %s = %q", tt, key, it.Value)
+			}
+		}
+		rec.mu.Lock()
+		for _, e := range rec.ends {
+			if tt.perGet > 0 && len(e.Keys) > tt.perGet {
+				t.Errorf("%+v: request with %d keys", tt, len(e.Keys))
+			}
+		}
+		if tt.perGet == 1 && len(rec.ends) != len(keys) {
+			t.Errorf("%+v: %d requests, want %d", tt, len(rec.ends), len(keys))
+		}
+		rec.mu.Unlock()
+	}
+}
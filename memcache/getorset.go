@@ -0,0 +1,93 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// GetOrSet returns the value of key, or on a cache miss calls loader,
+// adds the value it returns under key for ttl and returns it. A ttl
+// of zero stores the value without expiration. The value is stored
+// with Add, so that it doesn't replace a value written or a tombstone
+// left by DeleteSoft while loader ran; a key with a tombstone is
+// loaded on every call until the tombstone expires.
+//
+// Concurrent calls for the same key share one read and at most one
+// call of loader. A failure to store the loaded value is logged and
+// otherwise ignored; errors reading the key other than ErrCacheMiss
+// and errors from loader are returned, and cached if NegativeTTL is
+// set. GetOrSet returns ctx.Err() without contacting the server if ctx
+// is already done.
+func (c *Client) GetOrSet(ctx context.Context, key string, ttl time.Duration, loader func() ([]byte, error)) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !c.acceptsKey(key) {
+		return nil, ErrMalformedKey
+	}
+	it, err := c.state.loads.do(key, func() (*Item, error) {
+		it, err := c.Get(key)
+		if err == nil {
+			if nerr := negativeHit(it); nerr != nil {
+				return nil, nerr
+			}
+			value, expiry, delta := unwrapSoftTTL(it)
+			if !c.refreshDue(expiry, delta) {
+				return &Item{Key: key, Value: value}, nil
+			}
+			// Refresh early, serving the cached value if that fails.
+			fresh, err := c.load(key, ttl, loader, c.Set)
+			if err != nil {
+				c.logDebug("memcache: GetOrSet early refresh failed", "key", key, "err", err)
+				return &Item{Key: key, Value: value}, nil
+			}
+			return fresh, nil
+		}
+		if errors.Is(err, ErrTombstoned) {
+			// Serve the fresh value, but leave the tombstone in place.
+			value, err := loader()
+			if err != nil {
+				return nil, err
+			}
+			return &Item{Key: key, Value: value}, nil
+		}
+		if !errors.Is(err, ErrCacheMiss) {
+			return nil, err
+		}
+		it, err = c.load(key, ttl, loader, c.Add)
+		if err != nil {
+			c.cacheLoadError(key, err)
+		}
+		return it, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return it.Bytes(), nil
+}
+
+// load calls loader and stores the value it returns under key for ttl
+// with store, returning an item holding the value. With
+// EarlyRefreshBeta, the value is stored with its expiration time and
+// load time, for refreshDue.
+func (c *Client) load(key string, ttl time.Duration, loader func() ([]byte, error), store func(*Item) error) (*Item, error) {
+	start := time.Now()
+	value, err := loader()
+	if err != nil {
+		return nil, err
+	}
+	it := &Item{Key: key, Value: value, Expiration: expirationFor(ttl)}
+	if c.EarlyRefreshBeta > 0 {
+		var expiry time.Time
+		if ttl > 0 {
+			expiry = start.Add(ttl)
+		}
+		it.Value = wrapSoftTTL(value, expiry, time.Since(start))
+		it.Flags = SoftTTLFlag
+	}
+	if err := store(it); err != nil && !errors.Is(err, ErrNotStored) {
+		c.logDebug("memcache: failed to store loaded value", "key", key, "err", err)
+	}
+	return &Item{Key: key, Value: value}, nil
+}
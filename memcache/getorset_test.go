@@ -0,0 +1,195 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrSet(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	ctx := context.Background()
+
+	var loads int32
+	release := make(chan struct{})
+	loader := func() ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []byte("loaded"), nil
+	}
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrSet(ctx, "k", time.Minute, loader)
+			if err != nil || string(v) != "loaded" {
+				t.Errorf("GetOrSet = %q, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := atomic.LoadInt32(&loads); got != 1 {
+		t.Errorf("loader called %d times, want 1", got)
+	}
+	if it, ok := s.get("k"); !ok || string(it.value) != "loaded" {
+		t.Errorf("stored item = %+v, %v", it, ok)
+	}
+
+	// A hit doesn't call the loader.
+	v, err := c.GetOrSet(ctx, "k", time.Minute, func() ([]byte, error) {
+		t.Error("loader called on a hit")
+		return nil, nil
+	})
+	if err != nil || string(v) != "loaded" {
+		t.Errorf("GetOrSet hit = %q, %v", v, err)
+	}
+
+	errLoad := errors.New("load failed")
+	if _, err := c.GetOrSet(ctx, "other", 0, func() ([]byte, error) { return nil, errLoad }); err != errLoad {
+		t.Errorf("loader error = %v, want %v", err, errLoad)
+	}
+	if _, ok := s.get("other"); ok {
+		t.Error("failed load was stored")
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.GetOrSet(cctx, "k", 0, loader); err != context.Canceled {
+		t.Errorf("canceled GetOrSet = %v", err)
+	}
+}
+
+func TestExpirationFor(t *testing.T) {
+	for _, tt := range []struct {
+		ttl  time.Duration
+		want int32
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Millisecond, 1},
+		{90 * time.Second, 90},
+		{1500 * time.Millisecond, 2},
+	} {
+		if got := expirationFor(tt.ttl); got != tt.want {
+			t.Errorf("expirationFor(%v) = %d, want %d", tt.ttl, got, tt.want)
+		}
+	}
+	long := 60 * 24 * time.Hour
+	if got := int64(expirationFor(long)); got < time.Now().Add(long).Unix()-1 {
+		t.Errorf("expirationFor(%v) = %d, want absolute time", long, got)
+	}
+}
+
+func TestNegativeCaching(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.NegativeTTL = time.Minute
+	ctx := context.Background()
+
+	var loads int32
+	errNotFound := errors.New("no such row")
+	loader := func() ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		return nil, errNotFound
+	}
+	if _, err := c.GetOrSet(ctx, "missing", time.Minute, loader); err != errNotFound {
+		t.Fatalf("first GetOrSet = %v, want %v", err, errNotFound)
+	}
+	it, ok := s.get("missing")
+	if !ok || it.flags&NegativeFlag == 0 {
+		t.Fatalf("negative entry = %+v, %v", it, ok)
+	}
+	for _, get := range []func() error{
+		func() error { _, err := c.GetOrSet(ctx, "missing", time.Minute, loader); return err },
+		func() error {
+			_, err := c.GetOrRevalidate(ctx, "missing", time.Minute, time.Minute, loader)
+			return err
+		},
+	} {
+		var nerr *NegativeCacheError
+		if err := get(); !errors.As(err, &nerr) || nerr.Message != errNotFound.Error() {
+			t.Errorf("cached failure = %v, want NegativeCacheError", err)
+		}
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+
+	// Without NegativeTTL, failures are not cached.
+	c.NegativeTTL = 0
+	if _, err := c.GetOrSet(ctx, "other", time.Minute, loader); err != errNotFound {
+		t.Errorf("GetOrSet = %v, want %v", err, errNotFound)
+	}
+	if _, ok := s.get("other"); ok {
+		t.Error("failure cached without NegativeTTL")
+	}
+}
+
+func TestEarlyRefresh(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	ctx := context.Background()
+
+	var loads int32
+	loader := func() ([]byte, error) {
+		n := atomic.AddInt32(&loads, 1)
+		time.Sleep(time.Millisecond)
+		return []byte{'v', '0' + byte(n)}, nil
+	}
+	get := func(want string) {
+		t.Helper()
+		if v, err := c.GetOrSet(ctx, "k", time.Hour, loader); err != nil || string(v) != want {
+			t.Fatalf("GetOrSet = %q, %v; want %q", v, err, want)
+		}
+	}
+
+	// A small beta leaves an hour-long value alone.
+	c.EarlyRefreshBeta = 1e-6
+	get("v1")
+	if it, ok := s.get("k"); !ok || it.flags&SoftTTLFlag == 0 {
+		t.Fatalf("stored item = %+v, %v; want SoftTTLFlag", it, ok)
+	}
+	get("v1")
+
+	// A huge one makes every read reload it.
+	c.EarlyRefreshBeta = 1e12
+	get("v2")
+	get("v3")
+	if n := atomic.LoadInt32(&loads); n != 3 {
+		t.Errorf("loader called %d times, want 3", n)
+	}
+}
+
+func TestRefreshDue(t *testing.T) {
+	c := New()
+	now := time.Now()
+	for _, tt := range []struct {
+		beta   float64
+		expiry time.Time
+		delta  time.Duration
+		want   bool
+	}{
+		{0, time.Time{}, time.Second, false},
+		{0, now.Add(time.Hour), time.Second, false},
+		{0, now.Add(-time.Second), 0, true},
+		{1, now.Add(time.Hour), 0, false},
+		{1e12, now.Add(time.Hour), time.Second, true},
+		{1e12, time.Time{}, time.Second, false},
+	} {
+		c.EarlyRefreshBeta = tt.beta
+		if got := c.refreshDue(tt.expiry, tt.delta); got != tt.want {
+			t.Errorf("refreshDue(%v, %v) with beta %v = %v, want %v", tt.expiry, tt.delta, tt.beta, got, tt.want)
+		}
+	}
+}
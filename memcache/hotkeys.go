@@ -0,0 +1,178 @@
+package memcache
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHotKeyInterval is the default length of the intervals hot
+	// keys are reported for.
+	DefaultHotKeyInterval = time.Minute
+
+	// DefaultHotKeyTopK is the default number of hot keys reported per
+	// interval.
+	DefaultHotKeyTopK = 10
+)
+
+// hotKeySlack is the number of counters the sketch keeps per reported
+// key. Extra counters make the counts of the top keys more accurate
+// when many keys have similar frequencies.
+const hotKeySlack = 8
+
+// HotKey is a frequently used key, as estimated from sampled operations.
+type HotKey struct {
+	Key string
+
+	// Count is the estimated number of operations on Key during the
+	// interval, scaled up by the sample rate.
+	Count uint64
+
+	// Error bounds the overestimation of Count: the true count lies
+	// between Count-Error and Count, up to sampling noise.
+	Error uint64
+}
+
+// HotKeyRecorder may be implemented by a client's MetricsRecorder to
+// receive the hottest keys at the end of each interval.
+type HotKeyRecorder interface {
+	// HotKeys is called with the hottest keys of the interval that
+	// started at start, hottest first.
+	HotKeys(start time.Time, keys []HotKey)
+}
+
+// hotKeyTracker estimates the most frequent keys of each interval with
+// a Space-Saving sketch of bounded size: when the sketch is full, a new
+// key replaces the least frequent one and inherits its count as error.
+type hotKeyTracker struct {
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]*hotKeyCounter
+	last   []HotKey
+}
+
+type hotKeyCounter struct {
+	count, err uint64
+}
+
+// sampleHotKeys counts keys in the hot key sketch with probability
+// HotKeySampleRate each, and reports the previous interval if it ended.
+func (c *Client) sampleHotKeys(keys []string) {
+	rate := c.HotKeySampleRate
+	if rate <= 0 {
+		return
+	}
+	now := time.Now()
+	t := &c.state.hotKeys
+	t.mu.Lock()
+	report, from := t.rotate(c, now)
+	for _, key := range keys {
+		if rate < 1 && rand.Float64() >= rate {
+			continue
+		}
+		t.add(key, c.hotKeyTopK()*hotKeySlack)
+	}
+	t.mu.Unlock()
+	if report != nil {
+		if r, ok := c.Metrics.(HotKeyRecorder); ok {
+			r.HotKeys(from, report)
+		}
+	}
+}
+
+// HotKeys returns the hottest keys of the last completed interval,
+// hottest first, as estimated from the keys sampled at
+// HotKeySampleRate. Keys are reported as sent to the servers, with any
+// KeyPrefix. It returns nil if sampling is disabled or no interval has
+// completed yet.
+func (c *Client) HotKeys() []HotKey {
+	t := &c.state.hotKeys
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rotate(c, time.Now())
+	return append([]HotKey(nil), t.last...)
+}
+
+func (c *Client) hotKeyInterval() time.Duration {
+	if c.HotKeyInterval > 0 {
+		return c.HotKeyInterval
+	}
+	return DefaultHotKeyInterval
+}
+
+func (c *Client) hotKeyTopK() int {
+	if c.HotKeyTopK > 0 {
+		return c.HotKeyTopK
+	}
+	return DefaultHotKeyTopK
+}
+
+// rotate ends the current interval if it is over, returning its top
+// keys and start time. t.mu must be held.
+func (t *hotKeyTracker) rotate(c *Client, now time.Time) ([]HotKey, time.Time) {
+	if t.start.IsZero() {
+		t.start = now
+		return nil, time.Time{}
+	}
+	if now.Sub(t.start) < c.hotKeyInterval() {
+		return nil, time.Time{}
+	}
+	top := t.top(c.hotKeyTopK(), c.HotKeySampleRate)
+	from := t.start
+	t.last = top
+	t.counts = nil
+	t.start = now
+	return top, from
+}
+
+// add counts key, evicting the least frequent key if the sketch already
+// holds size keys. t.mu must be held.
+func (t *hotKeyTracker) add(key string, size int) {
+	if hc, ok := t.counts[key]; ok {
+		hc.count++
+		return
+	}
+	if t.counts == nil {
+		t.counts = make(map[string]*hotKeyCounter, size)
+	}
+	if len(t.counts) < size {
+		t.counts[key] = &hotKeyCounter{count: 1}
+		return
+	}
+	var minKey string
+	var min *hotKeyCounter
+	for k, hc := range t.counts {
+		if min == nil || hc.count < min.count {
+			minKey, min = k, hc
+		}
+	}
+	delete(t.counts, minKey)
+	t.counts[key] = &hotKeyCounter{count: min.count + 1, err: min.count}
+}
+
+// top returns the k most frequent keys, with counts scaled by the
+// inverse of the sample rate. t.mu must be held.
+func (t *hotKeyTracker) top(k int, rate float64) []HotKey {
+	keys := make([]HotKey, 0, len(t.counts))
+	for key, hc := range t.counts {
+		keys = append(keys, HotKey{Key: key, Count: hc.count, Error: hc.err})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if len(keys) > k {
+		keys = keys[:k]
+	}
+	if rate > 0 && rate < 1 {
+		for i := range keys {
+			keys[i].Count = uint64(float64(keys[i].Count) / rate)
+			keys[i].Error = uint64(float64(keys[i].Error) / rate)
+		}
+	}
+	return keys
+}
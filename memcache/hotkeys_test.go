@@ -0,0 +1,69 @@
+package memcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type hotKeyMetrics struct {
+	recordingMetrics
+	mu      sync.Mutex
+	reports [][]HotKey
+}
+
+func (r *hotKeyMetrics) HotKeys(start time.Time, keys []HotKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, keys)
+}
+
+func TestHotKeys(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	rec := new(hotKeyMetrics)
+	c := New(s.Addr())
+	c.Metrics = rec
+	c.HotKeySampleRate = 1
+	c.HotKeyInterval = 50 * time.Millisecond
+	c.HotKeyTopK = 2
+
+	mustSet(t, c, &Item{Key: "hot", Value: []byte("v")})
+	for i := 0; i < 5; i++ {
+		c.Get("hot")
+		c.Get("warm")
+	}
+	c.Get("cold")
+	if got := c.HotKeys(); got != nil {
+		t.Errorf("HotKeys before the interval ended = %v, want nil", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	c.Get("next")
+	want := []HotKey{{Key: "hot", Count: 6}, {Key: "warm", Count: 5}}
+	got := c.HotKeys()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("HotKeys = %v, want %v", got, want)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.reports) != 1 || fmt.Sprint(rec.reports[0]) != fmt.Sprint(want) {
+		t.Errorf("HotKeyRecorder reports = %v, want [%v]", rec.reports, want)
+	}
+}
+
+func TestHotKeySketch(t *testing.T) {
+	var tr hotKeyTracker
+	for i := 0; i < 100; i++ {
+		tr.add("hot", 4)
+		tr.add(fmt.Sprint("cold", i), 4)
+	}
+	top := tr.top(1, 0.5)
+	if len(top) != 1 || top[0].Key != "hot" || top[0].Count != 200 || top[0].Error != 0 {
+		t.Errorf("top = %v, want hot with count 200", top)
+	}
+	if len(tr.counts) != 4 {
+		t.Errorf("sketch holds %d keys, want 4", len(tr.counts))
+	}
+}
@@ -0,0 +1,421 @@
+//go:build go1.18
+
+// Package httpcache provides an http.RoundTripper caching responses in
+// memcache, following the rules of RFC 7234 for a shared cache:
+// freshness from s-maxage, max-age or Expires, Vary, revalidation with
+// ETag and Last-Modified, and the stale-if-error extension of RFC 5861.
+//
+// Typical use:
+//
+//	mc := memcache.New("10.0.0.1:11211", "10.0.0.2:11211")
+//	client := &http.Client{Transport: httpcache.New(mc)}
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultKeyPrefix is the default Transport.KeyPrefix.
+const DefaultKeyPrefix = "httpcache:"
+
+// DefaultMaxBodySize is the default Transport.MaxBodySize, leaving room
+// below memcached's default 1MB item size for the headers.
+const DefaultMaxBodySize = 900 << 10
+
+// XFromCache is the header set on responses served from the cache.
+const XFromCache = "X-From-Cache"
+
+// Transport is an http.RoundTripper caching the responses to GET
+// requests in memcache. As memcache is shared, responses marked private,
+// and responses to requests with credentials not marked public, aren't
+// cached, and neither are Set-Cookie and hop-by-hop headers. Other
+// methods invalidate the cached response for their URL when they
+// succeed.
+type Transport struct {
+	// Client is the memcache client responses are cached in.
+	Client memcache.MemcacheClient
+
+	// Transport makes the requests the cache can't answer. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// KeyPrefix is prepended to the cache keys, which are hashes of the
+	// request URLs. If empty, DefaultKeyPrefix is used.
+	KeyPrefix string
+
+	// StaleIfError is how long after it goes stale a response may still
+	// be served when the origin fails or answers with a 5xx status, for
+	// responses not setting the stale-if-error directive themselves.
+	StaleIfError time.Duration
+
+	// MaxBodySize is the size of the largest body cached. If zero,
+	// DefaultMaxBodySize is used.
+	MaxBodySize int64
+
+	now func() time.Time // for tests; time.Now if nil
+}
+
+// New returns a Transport caching responses in c.
+func New(c memcache.MemcacheClient) *Transport {
+	return &Transport{Client: c}
+}
+
+// entry is a cached response.
+type entry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// Vary holds the values of the request headers named by the
+	// response's Vary header.
+	Vary http.Header
+
+	// Date is when the response was generated, accounting for its Age.
+	Date time.Time
+
+	Lifetime     time.Duration
+	StaleIfError time.Duration
+}
+
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// hopByHopHeaders are the headers that only apply to a single
+// connection, and must not be cached, along with those named by the
+// Connection header.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+var safeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		resp, err := t.transport().RoundTrip(req)
+		if err == nil && !safeMethods[req.Method] && resp.StatusCode < 400 {
+			t.Client.Delete(t.key(req))
+		}
+		return resp, err
+	}
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return t.transport().RoundTrip(req)
+	}
+
+	key := t.key(req)
+	now := t.clock()
+	var cached *entry
+	if _, ok := reqCC["no-cache"]; !ok {
+		cached = t.lookup(key, req)
+	}
+	if cached != nil && now.Sub(cached.Date) < cached.Lifetime {
+		return cached.response(req, now), nil
+	}
+
+	outReq := req
+	if cached != nil {
+		outReq = cached.conditional(req)
+	}
+	resp, err := t.transport().RoundTrip(outReq)
+	if cached != nil && (err != nil || resp.StatusCode >= 500) &&
+		now.Sub(cached.Date) < cached.Lifetime+cached.StaleIfError {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return cached.response(req, now), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && outReq != req && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		for name, vals := range storedHeader(resp.Header) {
+			cached.Header[name] = vals
+		}
+		if !cacheable(req, &http.Response{StatusCode: cached.StatusCode, Header: cached.Header}) {
+			t.Client.Delete(key)
+			return cached.response(req, now), nil
+		}
+		cached.Date, cached.Lifetime, cached.StaleIfError = t.freshness(cached.Header, now)
+		t.store(key, cached, now)
+		return cached.response(req, now), nil
+	}
+	return t.storeResponse(key, req, resp, now)
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+func (t *Transport) key(req *http.Request) string {
+	prefix := t.KeyPrefix
+	if prefix == "" {
+		prefix = DefaultKeyPrefix
+	}
+	sum := sha1.Sum([]byte(req.URL.String()))
+	return prefix + hex.EncodeToString(sum[:])
+}
+
+// lookup returns the entry cached under key if it matches req's
+// headers, or nil.
+func (t *Transport) lookup(key string, req *http.Request) *entry {
+	it, err := t.Client.Get(key)
+	if err != nil {
+		return nil
+	}
+	e := new(entry)
+	if err := gob.NewDecoder(bytes.NewReader(it.Value)).Decode(e); err != nil {
+		return nil
+	}
+	for name, vals := range e.Vary {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(vals, ",") {
+			return nil
+		}
+	}
+	return e
+}
+
+// storeResponse caches resp if it may be, and returns it with its body
+// buffered.
+func (t *Transport) storeResponse(key string, req *http.Request, resp *http.Response, now time.Time) (*http.Response, error) {
+	if !cacheable(req, resp) {
+		return resp, nil
+	}
+	date, lifetime, staleIfError := t.freshness(resp.Header, now)
+	if lifetime+staleIfError <= 0 && resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return resp, nil
+	}
+	max := t.MaxBodySize
+	if max == 0 {
+		max = DefaultMaxBodySize
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > max {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	e := &entry{
+		StatusCode:   resp.StatusCode,
+		Header:       storedHeader(resp.Header),
+		Body:         body,
+		Vary:         make(http.Header),
+		Date:         date,
+		Lifetime:     lifetime,
+		StaleIfError: staleIfError,
+	}
+	for _, name := range headerTokens(resp.Header, "Vary") {
+		name = http.CanonicalHeaderKey(name)
+		e.Vary[name] = req.Header.Values(name)
+	}
+	t.store(key, e, now)
+	return resp, nil
+}
+
+// store caches e under key until it can no longer be served. Entries
+// with validators but no freshness are kept for a minute, so that they
+// can be revalidated.
+func (t *Transport) store(key string, e *entry, now time.Time) {
+	keep := e.Lifetime + e.StaleIfError - now.Sub(e.Date)
+	if keep < time.Minute && (e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != "") {
+		keep = time.Minute
+	}
+	if keep <= 0 {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return
+	}
+	t.Client.Set(&memcache.Item{Key: key, Value: buf.Bytes(), Expiration: memcache.ExpiresIn(keep)})
+}
+
+// storedHeader returns a copy of h without the headers a shared cache
+// must not store: Set-Cookie and the hop-by-hop headers.
+func storedHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range headerTokens(h, "Connection") {
+		h.Del(name)
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+	h.Del("Set-Cookie")
+	return h
+}
+
+// cacheable reports whether a shared cache may store resp.
+func cacheable(req *http.Request, resp *http.Response) bool {
+	if !cacheableStatus[resp.StatusCode] {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if _, ok := cc["private"]; ok {
+		return false
+	}
+	for _, name := range headerTokens(resp.Header, "Vary") {
+		if name == "*" {
+			return false
+		}
+	}
+	if req.Header.Get("Authorization") != "" {
+		_, public := cc["public"]
+		_, smaxage := cc["s-maxage"]
+		_, mustRevalidate := cc["must-revalidate"]
+		return public || smaxage || mustRevalidate
+	}
+	return true
+}
+
+// freshness returns when a response with header h was generated, how
+// long it is fresh for and how long after that it may be served if the
+// origin fails.
+func (t *Transport) freshness(h http.Header, now time.Time) (date time.Time, lifetime, staleIfError time.Duration) {
+	date = now
+	if age, err := strconv.Atoi(h.Get("Age")); err == nil && age > 0 {
+		date = now.Add(-time.Duration(age) * time.Second)
+	}
+	cc := parseCacheControl(h)
+	staleIfError = t.StaleIfError
+	if v, ok := cc["stale-if-error"]; ok {
+		staleIfError = seconds(v)
+	}
+	if _, ok := cc["no-cache"]; ok {
+		return date, 0, staleIfError
+	}
+	if v, ok := cc["s-maxage"]; ok {
+		return date, seconds(v), staleIfError
+	}
+	if v, ok := cc["max-age"]; ok {
+		return date, seconds(v), staleIfError
+	}
+	if exp, err := http.ParseTime(h.Get("Expires")); err == nil {
+		origin, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			origin = now
+		}
+		return date, exp.Sub(origin), staleIfError
+	}
+	return date, 0, staleIfError
+}
+
+// conditional returns a copy of req asking the origin to validate e, or
+// req itself if e has no validators or req makes its own conditions.
+func (e *entry) conditional(req *http.Request) *http.Request {
+	etag, modified := e.Header.Get("ETag"), e.Header.Get("Last-Modified")
+	if etag == "" && modified == "" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return req
+	}
+	req = req.Clone(req.Context())
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if modified != "" {
+		req.Header.Set("If-Modified-Since", modified)
+	}
+	return req
+}
+
+// response returns e as the response to req.
+func (e *entry) response(req *http.Request, now time.Time) *http.Response {
+	h := e.Header.Clone()
+	h.Set("Age", strconv.Itoa(int(now.Sub(e.Date)/time.Second)))
+	h.Set(XFromCache, "1")
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// parseCacheControl returns the directives of h's Cache-Control header,
+// mapped to their arguments.
+func parseCacheControl(h http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, d := range headerTokens(h, "Cache-Control") {
+		name, arg, _ := strings.Cut(d, "=")
+		cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return cc
+}
+
+// headerTokens returns the comma-separated elements of h's header name.
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for _, tok := range strings.Split(v, ",") {
+			if tok = strings.TrimSpace(tok); tok != "" {
+				tokens = append(tokens, tok)
+			}
+		}
+	}
+	return tokens
+}
+
+// seconds parses a delta-seconds directive argument.
+func seconds(v string) time.Duration {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
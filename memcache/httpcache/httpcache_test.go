@@ -0,0 +1,324 @@
+//go:build go1.18
+
+package httpcache
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// mapClient is a memcache.MemcacheClient backed by a map, ignoring
+// expirations.
+type mapClient struct {
+	mu    sync.Mutex
+	items map[string][]byte
+}
+
+func newMapClient() *mapClient { return &mapClient{items: make(map[string][]byte)} }
+
+func (c *mapClient) Get(key string) (*memcache.Item, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	return &memcache.Item{Key: key, Value: v}, nil
+}
+
+func (c *mapClient) Set(it *memcache.Item) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[it.Key] = it.Value
+	return nil
+}
+
+func (c *mapClient) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+	return nil
+}
+
+func (c *mapClient) Stats() (map[net.Addr]map[string]string, error) { return nil, nil }
+func (c *mapClient) Add(it *memcache.Item) error                    { return c.Set(it) }
+func (c *mapClient) CompareAndSwap(it *memcache.Item) error         { return c.Set(it) }
+func (c *mapClient) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	return nil, errors.New("not implemented")
+}
+func (c *mapClient) Increment(key string, delta uint64) (uint64, error) {
+	return 0, errors.New("not implemented")
+}
+func (c *mapClient) Decrement(key string, delta uint64) (uint64, error) {
+	return 0, errors.New("not implemented")
+}
+
+// origin is a test server counting its requests and answering with the
+// headers and status set by the test.
+type origin struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	hits   int
+	status int
+	header http.Header
+	body   string
+	last   *http.Request
+}
+
+func newOrigin(t *testing.T, cacheControl string) *origin {
+	o := &origin{status: http.StatusOK, header: http.Header{"Cache-Control": {cacheControl}}, body: "hello"}
+	o.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.hits++
+		o.last = r
+		for k, v := range o.header {
+			w.Header()[k] = v
+		}
+		if etag := o.header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(o.status)
+		io.WriteString(w, o.body)
+	}))
+	t.Cleanup(o.Close)
+	return o
+}
+
+func (o *origin) requests() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.hits
+}
+
+// fakeClock is a settable Transport clock.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTransport() (*Transport, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	tr := New(newMapClient())
+	tr.now = clock.now
+	return tr, clock
+}
+
+func get(t *testing.T, c *http.Client, url string, header ...string) (body string, fromCache bool, resp *http.Response) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), resp.Header.Get(XFromCache) == "1", resp
+}
+
+func TestMaxAge(t *testing.T) {
+	o := newOrigin(t, "max-age=60")
+	tr, clock := newTransport()
+	c := &http.Client{Transport: tr}
+
+	if body, cached, _ := get(t, c, o.URL); body != "hello" || cached {
+		t.Fatalf("first get = %q, cached %v", body, cached)
+	}
+	clock.t = clock.t.Add(30 * time.Second)
+	body, cached, resp := get(t, c, o.URL)
+	if body != "hello" || !cached {
+		t.Fatalf("second get = %q, cached %v", body, cached)
+	}
+	if age := resp.Header.Get("Age"); age != "30" {
+		t.Errorf("Age = %q, want 30", age)
+	}
+	if n := o.requests(); n != 1 {
+		t.Errorf("origin requests = %d, want 1", n)
+	}
+
+	clock.t = clock.t.Add(time.Minute)
+	if _, cached, _ := get(t, c, o.URL); cached {
+		t.Error("stale response served from cache")
+	}
+	if n := o.requests(); n != 2 {
+		t.Errorf("origin requests = %d, want 2", n)
+	}
+
+	// Requests with no-cache go to the origin.
+	if _, cached, _ := get(t, c, o.URL, "Cache-Control", "no-cache"); cached {
+		t.Error("no-cache request served from cache")
+	}
+}
+
+func TestNotCacheable(t *testing.T) {
+	for _, tc := range []struct {
+		name, cacheControl string
+		header             []string
+	}{
+		{"no-store", "no-store, max-age=60", nil},
+		{"private", "private, max-age=60", nil},
+		{"no freshness", "", nil},
+		{"authorization", "max-age=60", []string{"Authorization", "Bearer x"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newOrigin(t, tc.cacheControl)
+			tr, _ := newTransport()
+			c := &http.Client{Transport: tr}
+			get(t, c, o.URL, tc.header...)
+			if _, cached, _ := get(t, c, o.URL, tc.header...); cached {
+				t.Error("served from cache")
+			}
+		})
+	}
+}
+
+func TestVary(t *testing.T) {
+	o := newOrigin(t, "max-age=60")
+	o.header.Set("Vary", "Accept-Language")
+	tr, _ := newTransport()
+	c := &http.Client{Transport: tr}
+
+	get(t, c, o.URL, "Accept-Language", "en")
+	if _, cached, _ := get(t, c, o.URL, "Accept-Language", "en"); !cached {
+		t.Error("same variant not served from cache")
+	}
+	if _, cached, _ := get(t, c, o.URL, "Accept-Language", "fr"); cached {
+		t.Error("other variant served from cache")
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	o := newOrigin(t, "max-age=60, stale-if-error=300")
+	tr, clock := newTransport()
+	c := &http.Client{Transport: tr}
+
+	get(t, c, o.URL)
+	o.mu.Lock()
+	o.status = http.StatusServiceUnavailable
+	o.body = "down"
+	o.mu.Unlock()
+
+	clock.t = clock.t.Add(2 * time.Minute)
+	if body, cached, _ := get(t, c, o.URL); body != "hello" || !cached {
+		t.Errorf("within stale-if-error = %q, cached %v", body, cached)
+	}
+	clock.t = clock.t.Add(5 * time.Minute)
+	if body, cached, resp := get(t, c, o.URL); body != "down" || cached || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("past stale-if-error = %q, cached %v", body, cached)
+	}
+}
+
+func TestRevalidate(t *testing.T) {
+	o := newOrigin(t, "max-age=60")
+	o.header.Set("ETag", `"v1"`)
+	tr, clock := newTransport()
+	c := &http.Client{Transport: tr}
+
+	get(t, c, o.URL)
+	clock.t = clock.t.Add(2 * time.Minute)
+	body, cached, resp := get(t, c, o.URL)
+	if body != "hello" || !cached || resp.StatusCode != http.StatusOK {
+		t.Fatalf("revalidated get = %d %q, cached %v", resp.StatusCode, body, cached)
+	}
+	if inm := o.last.Header.Get("If-None-Match"); inm != `"v1"` {
+		t.Errorf("If-None-Match = %q", inm)
+	}
+
+	// The 304 made the response fresh again.
+	if _, cached, _ := get(t, c, o.URL); !cached {
+		t.Error("not served from cache after revalidation")
+	}
+	if n := o.requests(); n != 2 {
+		t.Errorf("origin requests = %d, want 2", n)
+	}
+}
+
+func TestRevalidatePrivate(t *testing.T) {
+	o := newOrigin(t, "max-age=60")
+	o.header.Set("ETag", `"v1"`)
+	tr, clock := newTransport()
+	c := &http.Client{Transport: tr}
+
+	get(t, c, o.URL)
+	o.mu.Lock()
+	o.header.Set("Cache-Control", "private, max-age=60")
+	o.mu.Unlock()
+	clock.t = clock.t.Add(2 * time.Minute)
+	if body, cached, _ := get(t, c, o.URL); body != "hello" || !cached {
+		t.Fatalf("revalidated get = %q, cached %v", body, cached)
+	}
+
+	// The 304 made the response private: it must no longer be cached.
+	if _, cached, _ := get(t, c, o.URL); cached {
+		t.Error("private response served from cache after revalidation")
+	}
+}
+
+func TestStoredHeaders(t *testing.T) {
+	o := newOrigin(t, "max-age=60")
+	o.header.Set("Set-Cookie", "session=secret")
+	o.header.Set("Connection", "X-Hop")
+	o.header.Set("X-Hop", "1")
+	o.header.Set("X-Kept", "1")
+	tr, _ := newTransport()
+	c := &http.Client{Transport: tr}
+
+	get(t, c, o.URL)
+	_, cached, resp := get(t, c, o.URL)
+	if !cached {
+		t.Fatal("not served from cache")
+	}
+	for _, name := range []string{"Set-Cookie", "Connection", "X-Hop"} {
+		if v := resp.Header.Get(name); v != "" {
+			t.Errorf("cached response has %s: %q", name, v)
+		}
+	}
+	if resp.Header.Get("X-Kept") != "1" {
+		t.Error("cached response lost X-Kept")
+	}
+}
+
+func TestUnsafeMethodInvalidates(t *testing.T) {
+	o := newOrigin(t, "max-age=60")
+	tr, _ := newTransport()
+	c := &http.Client{Transport: tr}
+
+	get(t, c, o.URL)
+	resp, err := c.Post(o.URL, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, cached, _ := get(t, c, o.URL); cached {
+		t.Error("served from cache after POST")
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	o := newOrigin(t, "max-age=60")
+	tr, _ := newTransport()
+	tr.MaxBodySize = 3
+	c := &http.Client{Transport: tr}
+
+	if body, _, _ := get(t, c, o.URL); body != "hello" {
+		t.Fatalf("body = %q", body)
+	}
+	if _, cached, _ := get(t, c, o.URL); cached {
+		t.Error("oversized body served from cache")
+	}
+}
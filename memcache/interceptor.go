@@ -0,0 +1,74 @@
+package memcache
+
+import (
+	"context"
+)
+
+// Op describes a client operation passing through the interceptor
+// chain. Interceptors may modify its inputs before calling next, for
+// example to rewrite keys, and inspect or modify its results after.
+type Op struct {
+	// Name is the operation: "get", "getmulti", "set", "add", "cas",
+	// "delete", "touch", "incr", "decr", "getappend", "gat",
+	// "getmeta", "getquorum", "ttl", "invalidate", "setreader",
+	// "getreader", "set_multi", "delete_multi" or "touch_multi".
+	// The streaming setreader and getreader operations carry no Item.
+	Name string
+
+	// Keys are the keys the operation acts on, without the client's
+	// KeyPrefix.
+	Keys []string
+
+	// Item is the item to store for set, add and cas, and the item
+	// returned by get, getappend, gat, getmeta and getquorum. The key
+	// stored by set, add and cas is Item.Key: rewriting Keys alone has
+	// no effect on them.
+	Item *Item
+
+	// Items is the result of getmulti.
+	Items map[string]*Item
+
+	// Batch is the items to store for set_multi. As with Item, the keys
+	// stored are those of the items.
+	Batch []*Item
+
+	// Result is the outcome of set_multi, delete_multi and touch_multi
+	// for each key. The error the operation returns is Result.Err().
+	Result BatchResult
+
+	// Delta is the amount to increment or decrement by, and Value the
+	// resulting value.
+	Delta, Value uint64
+}
+
+// OpFunc performs an operation.
+type OpFunc func(ctx context.Context, op *Op) error
+
+// Interceptor wraps an operation. It must call next to perform the
+// operation, and may do so any number of times, for instance to retry.
+// As the client's methods take no context, ctx is context.Background()
+// unless an interceptor passes next a context of its own; the client
+// itself does not watch it.
+type Interceptor func(ctx context.Context, op *Op, next OpFunc) error
+
+// intercept runs fn through the client's interceptors.
+func (c *Client) intercept(op *Op, fn OpFunc) error {
+	ctx := context.Background()
+	if c.mapsKeys() {
+		fn = c.withStorageKeys(fn)
+	}
+	if len(c.Interceptors) == 0 {
+		return c.withKeys(op.Keys, fn(ctx, op))
+	}
+	return c.withKeys(op.Keys, chainInterceptors(c.Interceptors, fn)(ctx, op))
+}
+
+func chainInterceptors(ics []Interceptor, fn OpFunc) OpFunc {
+	for i := len(ics) - 1; i >= 0; i-- {
+		ic, next := ics[i], fn
+		fn = func(ctx context.Context, op *Op) error {
+			return ic(ctx, op, next)
+		}
+	}
+	return fn
+}
@@ -0,0 +1,124 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	var order []string
+	prefix := func(ctx context.Context, op *Op, next OpFunc) error {
+		order = append(order, "prefix")
+		for i, key := range op.Keys {
+			op.Keys[i] = "app:" + key
+		}
+		if op.Item != nil {
+			it := *op.Item
+			it.Key = "app:" + it.Key
+			op.Item = &it
+		}
+		err := next(ctx, op)
+		if op.Item != nil {
+			op.Item.Key = strings.TrimPrefix(op.Item.Key, "app:")
+		}
+		return err
+	}
+	logging := func(ctx context.Context, op *Op, next OpFunc) error {
+		order = append(order, "log:"+op.Name)
+		return next(ctx, op)
+	}
+	c.Interceptors = []Interceptor{prefix, logging}
+
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("fooval")})
+	if _, ok := s.get("app:foo"); !ok {
+		t.Fatalf("interceptor did not rewrite key on Set")
+	}
+	it, err := c.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.Key != "foo" {
+		t.Errorf("Get key = %q, want foo", it.Key)
+	}
+	if got, want := strings.Join(order, ","), "prefix,log:set,prefix,log:get"; got != want {
+		t.Errorf("interceptor order = %s, want %s", got, want)
+	}
+}
+
+func TestInterceptorKeepsCallerKeys(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.Interceptors = []Interceptor{func(ctx context.Context, op *Op, next OpFunc) error {
+		for i, key := range op.Keys {
+			op.Keys[i] = "app:" + key
+		}
+		return next(ctx, op)
+	}}
+	s.put("app:a", []byte("alpha"), 0)
+
+	keys := []string{"a"}
+	if _, err := c.GetMulti(keys); err != nil {
+		t.Fatal(err)
+	}
+	if keys[0] != "a" {
+		t.Errorf("GetMulti rewrote the caller's keys to %q", keys)
+	}
+}
+
+func TestInterceptorsSeeBatches(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.KeyPrefix = "app:"
+
+	var names []string
+	c.Interceptors = []Interceptor{func(ctx context.Context, op *Op, next OpFunc) error {
+		names = append(names, op.Name)
+		if op.Name == "set_multi" && len(op.Batch) != len(op.Keys) {
+			t.Errorf("set_multi has %d items for %d keys", len(op.Batch), len(op.Keys))
+		}
+		err := next(ctx, op)
+		if (err == nil) != (op.Result.Err() == nil) {
+			t.Errorf("%s returned %v, want %v", op.Name, err, op.Result.Err())
+		}
+		for key := range op.Result {
+			if strings.HasPrefix(key, "app:") {
+				t.Errorf("%s result has storage key %q", op.Name, key)
+			}
+		}
+		return err
+	}}
+
+	if err := c.SetMulti([]*Item{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.get("app:a"); !ok {
+		t.Errorf("SetMulti did not store app:a")
+	}
+	if err := c.TouchMulti([]string{"a", "b"}, 60).Err(); err != nil {
+		t.Fatal(err)
+	}
+	res := c.DeleteMulti([]string{"a", "missing"})
+	if res["a"] != nil || !errors.Is(res["missing"], ErrCacheMiss) {
+		t.Errorf("DeleteMulti = %v", res)
+	}
+	if got, want := strings.Join(names, ","), "set_multi,touch_multi,delete_multi"; got != want {
+		t.Errorf("ops = %s, want %s", got, want)
+	}
+
+	// An interceptor failing the operation fails every key.
+	c.Interceptors = []Interceptor{func(ctx context.Context, op *Op, next OpFunc) error {
+		return ErrServerError
+	}}
+	res = c.DeleteMulti([]string{"a", "b"})
+	if len(res) != 2 || !errors.Is(res["a"], ErrServerError) || !errors.Is(res["b"], ErrServerError) {
+		t.Errorf("DeleteMulti with failing interceptor = %v", res)
+	}
+}
@@ -0,0 +1,33 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Invalidate marks the item for key stale with the invalidate flag of a
+// meta delete, instead of removing it. Readers using MetaProtocol keep
+// getting the old value, with Stale set, and the first of them also
+// gets Win, telling it to recompute and store a new value, as
+// GetOrRevalidate does; the others are served the stale value in the
+// meantime. ErrCacheMiss is returned if the key is not in the cache.
+//
+// Invalidate uses the meta protocol whether or not MetaProtocol is set,
+// and returns ErrMetaUnsupported from servers that lack it.
+func (c *Client) Invalidate(key string) error {
+	return c.intercept(&Op{Name: "invalidate", Keys: []string{key}}, func(ctx context.Context, op *Op) error {
+		key := op.Keys[0]
+		err := c.withKeyWriteRw(key, "md", func(rw *bufio.ReadWriter) error {
+			if _, err := fmt.Fprintf(rw, "md %s I\r\n", c.metaKey(key)); err != nil {
+				return err
+			}
+			return readMetaStatus(rw, "md")
+		})
+		if err == nil || errors.Is(err, ErrCacheMiss) {
+			c.publishInvalidation(InvalidationEvent{Key: key})
+		}
+		return err
+	})
+}
@@ -0,0 +1,34 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInvalidate(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.MetaProtocol = true
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("old")})
+
+	if err := c.Invalidate("foo"); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+	it, err := c.Get("foo")
+	if err != nil || string(it.Value) != "old" || !it.Stale || !it.Win {
+		t.Fatalf("first Get after Invalidate = %+v, %v; want stale old value with Win", it, err)
+	}
+	it, err = c.Get("foo")
+	if err != nil || string(it.Value) != "old" || !it.Stale || it.Win {
+		t.Errorf("second Get after Invalidate = %+v, %v; want stale old value without Win", it, err)
+	}
+	if err := c.Invalidate("missing"); err != ErrCacheMiss {
+		t.Errorf("Invalidate(missing) = %v, want ErrCacheMiss", err)
+	}
+
+	s.noMeta = true
+	if err := c.Invalidate("foo"); !errors.Is(err, ErrMetaUnsupported) {
+		t.Errorf("Invalidate on an old server = %v, want ErrMetaUnsupported", err)
+	}
+}
@@ -0,0 +1,158 @@
+package memcache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// NoExpiration is the ItemMeta.TTL of an item without expiration.
+const NoExpiration time.Duration = -1
+
+// FetchOptions selects the metadata GetWithOptions requests along with
+// an item's value.
+type FetchOptions struct {
+	// TTL requests the time remaining until the item expires.
+	TTL bool
+
+	// LastAccess requests the time since the item was last accessed.
+	LastAccess bool
+
+	// Fetched requests whether the item was fetched before.
+	Fetched bool
+
+	// Size requests the size of the item's value on the server.
+	Size bool
+}
+
+// flags returns the mg flags requesting the selected metadata.
+func (o FetchOptions) flags() string {
+	var b []byte
+	for _, f := range []struct {
+		on   bool
+		flag byte
+	}{{o.TTL, 't'}, {o.LastAccess, 'l'}, {o.Fetched, 'h'}, {o.Size, 's'}} {
+		if f.on {
+			b = append(b, ' ', f.flag)
+		}
+	}
+	return string(b)
+}
+
+// ItemMeta is the metadata of an item reported by the server. Only the
+// fields requested in FetchOptions are set.
+type ItemMeta struct {
+	// TTL is the time remaining until the item expires, in whole
+	// seconds, or NoExpiration.
+	TTL time.Duration
+
+	// LastAccess is the time since the item was last accessed, in
+	// whole seconds, before this fetch.
+	LastAccess time.Duration
+
+	// Fetched reports whether the item was fetched before this fetch.
+	Fetched bool
+
+	// Size is the size of the item's value on the server, which may
+	// differ from len(Value) for compressed, encrypted or chunked
+	// items.
+	Size int
+}
+
+// itemMeta parses the metadata flags of an mg response, returning nil
+// if there are none.
+func (mr *metaResponse) itemMeta() (*ItemMeta, error) {
+	var meta *ItemMeta
+	for _, fl := range mr.flags {
+		switch fl[0] {
+		case 't', 'l', 'h', 's':
+		default:
+			continue
+		}
+		if meta == nil {
+			meta = new(ItemMeta)
+		}
+		n, err := strconv.ParseInt(fl[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed %q flag in meta response", ErrProtocol, fl)
+		}
+		switch fl[0] {
+		case 't':
+			meta.TTL = time.Duration(n) * time.Second
+			if n < 0 {
+				meta.TTL = NoExpiration
+			}
+		case 'l':
+			meta.LastAccess = time.Duration(n) * time.Second
+		case 'h':
+			meta.Fetched = n != 0
+		case 's':
+			meta.Size = int(n)
+		}
+	}
+	return meta, nil
+}
+
+// GetWithOptions gets the item for key like Get, along with the
+// metadata selected by opts in the item's Meta field, for cache
+// efficiency analysis and refresh decisions. It uses the meta
+// protocol whether or not MetaProtocol is set, and returns
+// ErrMetaUnsupported from servers that lack it. The L1 cache is
+// bypassed.
+func (c *Client) GetWithOptions(key string, opts FetchOptions) (item *Item, err error) {
+	op := &Op{Name: "getmeta", Keys: []string{key}}
+	err = c.intercept(op, func(ctx context.Context, op *Op) (err error) {
+		op.Item, err = c.metaFetch(op.Keys[0], "v f c k"+opts.flags())
+		if err == nil {
+			err = c.finishRead(op.Item)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if op.Item.Meta == nil {
+		op.Item.Meta = new(ItemMeta)
+	}
+	return op.Item, nil
+}
+
+// metaFetch reads key from its server with an mg command with flags.
+func (c *Client) metaFetch(key, flags string) (item *Item, err error) {
+	err = c.withKeyAddr(key, func(addr net.Addr) error {
+		return c.metaGetFromAddr(addr, []string{key}, flags, func(it *Item) { item = it })
+	})
+	if err == nil && item == nil {
+		err = ErrCacheMiss
+	}
+	return item, err
+}
+
+// GetTTL returns the time remaining until the item for key expires, in
+// whole seconds, or NoExpiration, without reading its value. It
+// returns ErrCacheMiss if there is no such item, and ErrTombstoned for
+// a tombstone left by DeleteSoft if Tombstones is set.
+//
+// GetTTL uses the meta protocol's mg command whether or not
+// MetaProtocol is set. Servers without the meta protocol, such as
+// memcached before 1.6, make it return ErrMetaUnsupported; there is no
+// other way to read an item's expiration from them.
+func (c *Client) GetTTL(key string) (ttl time.Duration, err error) {
+	op := &Op{Name: "ttl", Keys: []string{key}}
+	err = c.intercept(op, func(ctx context.Context, op *Op) (err error) {
+		op.Item, err = c.metaFetch(op.Keys[0], "f t")
+		if err == nil && c.tombstone(op.Item) {
+			err = ErrTombstoned
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if op.Item.Meta == nil {
+		return 0, fmt.Errorf("%w: no TTL in mg response", ErrProtocol)
+	}
+	return op.Item.Meta.TTL, nil
+}
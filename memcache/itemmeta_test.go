@@ -0,0 +1,85 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetWithOptions(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("fooval"), Expiration: 100})
+	mustSet(t, c, &Item{Key: "forever", Value: []byte("v")})
+
+	all := FetchOptions{TTL: true, LastAccess: true, Fetched: true, Size: true}
+	it, err := c.GetWithOptions("foo", all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ItemMeta{TTL: 100 * time.Second, Size: 6}
+	if string(it.Value) != "fooval" || *it.Meta != want {
+		t.Errorf("first GetWithOptions = %q, %+v; want fooval, %+v", it.Value, *it.Meta, want)
+	}
+	it, err = c.GetWithOptions("foo", FetchOptions{Fetched: true})
+	if err != nil || !it.Meta.Fetched || it.Meta.TTL != 0 {
+		t.Errorf("second GetWithOptions = %+v, %v; want only Fetched set", it.Meta, err)
+	}
+	it, err = c.GetWithOptions("forever", FetchOptions{TTL: true})
+	if err != nil || it.Meta.TTL != NoExpiration {
+		t.Errorf("GetWithOptions without expiration = %+v, %v; want NoExpiration", it.Meta, err)
+	}
+	it, err = c.GetWithOptions("foo", FetchOptions{})
+	if err != nil || it.Meta == nil || *it.Meta != (ItemMeta{}) {
+		t.Errorf("GetWithOptions without options = %+v, %v; want empty Meta", it, err)
+	}
+	if _, err := c.GetWithOptions("missing", all); err != ErrCacheMiss {
+		t.Errorf("GetWithOptions(missing) = %v, want ErrCacheMiss", err)
+	}
+	if it, err := c.Get("foo"); err != nil || it.Meta != nil {
+		t.Errorf("Get = %+v, %v; want no Meta", it, err)
+	}
+}
+
+func TestGetWithOptionsOldServer(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	s.noMeta = true
+	c := New(s.Addr())
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("fooval")})
+	_, err := c.GetWithOptions("foo", FetchOptions{TTL: true})
+	if !errors.Is(err, ErrMetaUnsupported) || !errors.Is(err, ErrProtocol) {
+		t.Errorf("GetWithOptions on an old server = %v, want ErrMetaUnsupported", err)
+	}
+}
+
+func TestGetTTL(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("fooval"), Expiration: 90})
+	mustSet(t, c, &Item{Key: "forever", Value: []byte("v")})
+
+	if ttl, err := c.GetTTL("foo"); err != nil || ttl != 90*time.Second {
+		t.Errorf("GetTTL(foo) = %v, %v; want 1m30s", ttl, err)
+	}
+	if ttl, err := c.GetTTL("forever"); err != nil || ttl != NoExpiration {
+		t.Errorf("GetTTL(forever) = %v, %v; want NoExpiration", ttl, err)
+	}
+	if _, err := c.GetTTL("missing"); err != ErrCacheMiss {
+		t.Errorf("GetTTL(missing) = %v, want ErrCacheMiss", err)
+	}
+	c.Tombstones = true
+	if err := c.DeleteSoft("foo", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTTL("foo"); err != ErrTombstoned {
+		t.Errorf("GetTTL(tombstoned) = %v, want ErrTombstoned", err)
+	}
+
+	s.noMeta = true
+	if _, err := c.GetTTL("forever"); !errors.Is(err, ErrMetaUnsupported) {
+		t.Errorf("GetTTL on an old server = %v, want ErrMetaUnsupported", err)
+	}
+}
@@ -0,0 +1,32 @@
+package memcache
+
+import (
+	"math/rand"
+	"time"
+)
+
+// jitterExpiration returns exp, an Item.Expiration, moved at random by
+// up to the fraction ExpirationJitter of the time left until it. Zero
+// stays zero, and the result is kept in the same form, relative or
+// absolute, at least one second away.
+func (c *Client) jitterExpiration(exp int32) int32 {
+	if c.ExpirationJitter <= 0 || exp <= 0 {
+		return exp
+	}
+	left, now := int64(exp), int64(0)
+	if exp > MaxRelativeExpiration {
+		now = time.Now().Unix()
+		left -= now
+		if left <= 0 {
+			return exp
+		}
+	}
+	left += int64(float64(left) * c.ExpirationJitter * (2*rand.Float64() - 1))
+	if left < 1 {
+		left = 1
+	}
+	if now == 0 && left > MaxRelativeExpiration {
+		left = MaxRelativeExpiration
+	}
+	return int32(now + left)
+}
@@ -0,0 +1,71 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitterExpiration(t *testing.T) {
+	c := New()
+	if got := c.jitterExpiration(100); got != 100 {
+		t.Errorf("without jitter, jitterExpiration(100) = %d", got)
+	}
+	c.ExpirationJitter = 0.1
+	seen := make(map[int32]bool)
+	for i := 0; i < 1000; i++ {
+		got := c.jitterExpiration(1000)
+		if got < 900 || got > 1100 {
+			t.Fatalf("jitterExpiration(1000) = %d, want within 10%%", got)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 10 {
+		t.Errorf("jitterExpiration(1000) took only %d values", len(seen))
+	}
+	if got := c.jitterExpiration(0); got != 0 {
+		t.Errorf("jitterExpiration(0) = %d", got)
+	}
+
+	now := time.Now().Unix()
+	abs := int32(now + 2*MaxRelativeExpiration)
+	for i := 0; i < 100; i++ {
+		got := int64(c.jitterExpiration(abs))
+		if left := got - now; left < 2*MaxRelativeExpiration*9/10-1 || left > 2*MaxRelativeExpiration*11/10+1 {
+			t.Fatalf("jitterExpiration(%d) = %d, want within 10%% of the time left", abs, got)
+		}
+	}
+
+	c.ExpirationJitter = 1
+	for i := 0; i < 100; i++ {
+		if got := c.jitterExpiration(MaxRelativeExpiration); got < 1 || got > MaxRelativeExpiration {
+			t.Fatalf("jitterExpiration(%d) = %d, want a relative expiration", MaxRelativeExpiration, got)
+		}
+	}
+}
+
+func TestExpirationJitterOnStore(t *testing.T) {
+	c := New()
+	c.ExpirationJitter = 0.5
+	item := &Item{Key: "k", Value: []byte("v"), Expiration: 1000}
+	var got []int32
+	for i := 0; i < 20; i++ {
+		it, err := c.prepareStore(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, it.Expiration)
+	}
+	if item.Expiration != 1000 {
+		t.Errorf("caller's item expiration changed to %d", item.Expiration)
+	}
+	differ := false
+	for _, exp := range got {
+		if exp < 500 || exp > 1500 {
+			t.Errorf("stored expiration %d, want within 50%% of 1000", exp)
+		}
+		differ = differ || exp != got[0]
+	}
+	if !differ {
+		t.Errorf("stored expirations all %d", got[0])
+	}
+}
@@ -0,0 +1,166 @@
+package memcache
+
+import (
+	"bufio"
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultJournalSize is the number of keys a WriteJournal created with
+// a non-positive size holds.
+const DefaultJournalSize = 10000
+
+// WriteJournal records Sets and Deletes that failed because their
+// servers could not be reached, and replays them once the servers
+// answer again, so that invalidations issued during an outage are not
+// lost. Only the last write of each key is kept, and when the journal
+// is full the oldest key is dropped. The journal lives in memory only.
+//
+// A client with a Journal replays it in the background after any
+// successful request; ReplayJournal replays it on demand.
+type WriteJournal struct {
+	max int
+
+	mu      sync.Mutex
+	order   *list.List // of *journalEntry, oldest first
+	entries map[string]*list.Element
+
+	dropped   uint64
+	replaying int32
+}
+
+// journalEntry is a journaled write: a Set of item, or a Delete of key
+// if item is nil.
+type journalEntry struct {
+	key  string
+	item *Item
+}
+
+// NewWriteJournal returns a journal holding at most size keys.
+func NewWriteJournal(size int) *WriteJournal {
+	if size <= 0 {
+		size = DefaultJournalSize
+	}
+	return &WriteJournal{max: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Len returns the number of keys with a journaled write.
+func (j *WriteJournal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.order.Len()
+}
+
+// Dropped returns the number of journaled writes discarded because the
+// journal was full.
+func (j *WriteJournal) Dropped() uint64 {
+	return atomic.LoadUint64(&j.dropped)
+}
+
+func (j *WriteJournal) record(e *journalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if el, ok := j.entries[e.key]; ok {
+		j.order.Remove(el)
+	}
+	j.entries[e.key] = j.order.PushBack(e)
+	for j.order.Len() > j.max {
+		old := j.order.Remove(j.order.Front()).(*journalEntry)
+		delete(j.entries, old.key)
+		atomic.AddUint64(&j.dropped, 1)
+	}
+}
+
+// forget removes the journaled write of key, superseded by one that
+// reached a server.
+func (j *WriteJournal) forget(key string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if el, ok := j.entries[key]; ok {
+		j.order.Remove(el)
+		delete(j.entries, key)
+	}
+}
+
+// take removes and returns all journaled writes.
+func (j *WriteJournal) take() []*journalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries := make([]*journalEntry, 0, j.order.Len())
+	for el := j.order.Front(); el != nil; el = el.Next() {
+		entries = append(entries, el.Value.(*journalEntry))
+	}
+	j.order.Init()
+	j.entries = make(map[string]*list.Element)
+	return entries
+}
+
+// restore puts back e after a failed replay, unless key has since been
+// written again.
+func (j *WriteJournal) restore(e *journalEntry) {
+	j.mu.Lock()
+	_, newer := j.entries[e.key]
+	j.mu.Unlock()
+	if !newer {
+		j.record(e)
+	}
+}
+
+// journal records the outcome of the unconditional write of key, a Set
+// of item or a Delete if item is nil, in the client's Journal.
+func (c *Client) journal(key string, item *Item, err error) {
+	if c.Journal == nil {
+		return
+	}
+	switch classifyError(err) {
+	case ErrClassNetwork, ErrClassTimeout:
+		c.Journal.record(&journalEntry{key: key, item: item})
+	case ErrClassNone:
+		c.Journal.forget(key)
+	}
+}
+
+// maybeReplayJournal starts replaying the journal in the background if
+// it has entries and isn't being replayed already.
+func (c *Client) maybeReplayJournal() {
+	j := c.Journal
+	if j == nil || j.Len() == 0 || !atomic.CompareAndSwapInt32(&j.replaying, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&j.replaying, 0)
+		c.replay(j)
+	}()
+}
+
+// ReplayJournal replays the writes in the client's Journal, returning
+// the number that reached their servers. Writes that fail because their
+// servers are still unreachable stay in the journal.
+func (c *Client) ReplayJournal() int {
+	if c.Journal == nil {
+		return 0
+	}
+	return c.replay(c.Journal)
+}
+
+func (c *Client) replay(j *WriteJournal) int {
+	n := 0
+	for _, e := range j.take() {
+		var err error
+		if e.item != nil {
+			err = c.onItem("set", e.item, (*Client).set)
+		} else {
+			err = c.withKeyWriteRw(e.key, "delete", func(rw *bufio.ReadWriter) error {
+				return c.deleteRw(rw, e.key)
+			})
+		}
+		switch classifyError(err) {
+		case ErrClassNetwork, ErrClassTimeout:
+			j.restore(e)
+		default:
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,74 @@
+package memcache
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriteJournal(t *testing.T) {
+	// Reserve an address with nothing listening on it.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := New(addr)
+	c.Journal = NewWriteJournal(2)
+	if err := c.Set(&Item{Key: "a", Value: []byte("old")}); err == nil {
+		t.Fatal("Set with server down succeeded")
+	}
+	c.Set(&Item{Key: "a", Value: []byte("new")})
+	c.Delete("b")
+	c.Set(&Item{Key: "c", Value: []byte("c")})
+	if n, d := c.Journal.Len(), c.Journal.Dropped(); n != 2 || d != 1 {
+		t.Errorf("journal has %d keys, %d dropped; want 2, 1", n, d)
+	}
+	if n := c.ReplayJournal(); n != 0 || c.Journal.Len() != 2 {
+		t.Errorf("replay with server down = %d, journal %d", n, c.Journal.Len())
+	}
+
+	// Bring the server up at the same address.
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen again on %s: %v", addr, err)
+	}
+	s := &fakeServer{ln: ln, items: make(map[string]*fakeItem)}
+	go s.serve()
+	defer s.Close()
+	s.put("b", []byte("stale"), 0)
+
+	// Any successful request triggers the replay.
+	if _, err := c.Get("x"); err != ErrCacheMiss {
+		t.Fatalf("Get = %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for c.Journal.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, ok := s.get("b"); ok {
+		t.Error("journaled delete of b not replayed")
+	}
+	if it, ok := s.get("c"); !ok || string(it.value) != "c" {
+		t.Error("journaled set of c not replayed")
+	}
+	// a was dropped from the full journal.
+	if _, ok := s.get("a"); ok {
+		t.Error("dropped write of a was replayed")
+	}
+
+	// A write reaching the server supersedes a journaled one.
+	// Hold off background replays while checking this.
+	for !atomic.CompareAndSwapInt32(&c.Journal.replaying, 0, 1) {
+		time.Sleep(time.Millisecond)
+	}
+	c.Journal.record(&journalEntry{key: "d", item: &Item{Key: "d", Value: []byte("old")}})
+	mustSet(t, c, &Item{Key: "d", Value: []byte("new")})
+	if c.Journal.Len() != 0 {
+		t.Error("successful Set left journaled write")
+	}
+}
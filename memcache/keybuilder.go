@@ -0,0 +1,68 @@
+package memcache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// maxKeyLength is the longest key memcached accepts.
+const maxKeyLength = 250
+
+// KeyBuilder builds keys from a prefix, a schema version and
+// parameters, such as "user:v3:42". Bumping Version when the format of
+// the cached values changes moves all their keys, so that new code
+// never reads values in the old format and the cluster needn't be
+// flushed; the old values are left to expire or be evicted.
+type KeyBuilder struct {
+	// Prefix names the kind of value, such as "user".
+	Prefix string
+
+	// Version is the schema version of the values.
+	Version int
+}
+
+// Key returns the key for params: the prefix, "v" and the version, and
+// the params, separated by ':'. Bytes of params that are not allowed in
+// keys, and ':' and '%', are percent-escaped, so that distinct params
+// give distinct keys. A key longer than memcached's limit of 250 bytes
+// is truncated and suffixed with '#' and the SHA-1 of the full key.
+func (b KeyBuilder) Key(params ...string) string {
+	var sb strings.Builder
+	escapeKeyPart(&sb, b.Prefix)
+	sb.WriteString(":v")
+	sb.WriteString(strconv.Itoa(b.Version))
+	for _, p := range params {
+		sb.WriteByte(':')
+		escapeKeyPart(&sb, p)
+	}
+	key := sb.String()
+	if len(key) <= maxKeyLength {
+		return key
+	}
+	sum := sha1.Sum([]byte(key))
+	return key[:maxKeyLength-1-2*len(sum)] + "#" + hex.EncodeToString(sum[:])
+}
+
+// escapeKeyPart writes s to sb, percent-escaping the bytes not allowed
+// in keys, ':' and '%'.
+func escapeKeyPart(sb *strings.Builder, s string) {
+	writeEscaped(sb, s, ":%")
+}
+
+// writeEscaped writes s to sb, percent-escaping the bytes not allowed
+// in keys and those in also.
+func writeEscaped(sb *strings.Builder, s string, also string) {
+	const hexDigits = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c > 0x7e || strings.IndexByte(also, c) >= 0 {
+			sb.WriteByte('%')
+			sb.WriteByte(hexDigits[c>>4])
+			sb.WriteByte(hexDigits[c&0xf])
+			continue
+		}
+		sb.WriteByte(c)
+	}
+}
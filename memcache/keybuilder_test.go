@@ -0,0 +1,42 @@
+package memcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	b := KeyBuilder{Prefix: "user", Version: 3}
+	for _, tt := range []struct {
+		params []string
+		want   string
+	}{
+		{nil, "user:v3"},
+		{[]string{"42"}, "user:v3:42"},
+		{[]string{"a b", "x:y", "100%"}, "user:v3:a%20b:x%3Ay:100%25"},
+		{[]string{"é\n"}, "user:v3:%C3%A9%0A"},
+		{[]string{""}, "user:v3:"},
+	} {
+		got := b.Key(tt.params...)
+		if got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.params, got, tt.want)
+		}
+		if !legalKey(got) {
+			t.Errorf("Key(%q) = %q is not a legal key", tt.params, got)
+		}
+	}
+	if b.Key("a:b") == b.Key("a", "b") {
+		t.Error("distinct params gave the same key")
+	}
+	if (KeyBuilder{Prefix: "user", Version: 4}).Key("42") == b.Key("42") {
+		t.Error("version not part of the key")
+	}
+
+	long1, long2 := b.Key(strings.Repeat("x", 300)), b.Key(strings.Repeat("x", 301))
+	if len(long1) != maxKeyLength || !legalKey(long1) {
+		t.Errorf("long key %q has length %d", long1, len(long1))
+	}
+	if long1 == long2 {
+		t.Error("long keys with the same prefix collide")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build go1.20
+
+package memcache
+
+import (
+	"hash/crc32"
+	"unsafe"
+)
+
+// keyHash returns the CRC-32 (IEEE) checksum of key, as
+// crc32.ChecksumIEEE([]byte(key)) does, without copying key.
+func keyHash(key string) uint32 {
+	return crc32.ChecksumIEEE(unsafe.Slice(unsafe.StringData(key), len(key)))
+}
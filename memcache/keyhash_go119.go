@@ -0,0 +1,10 @@
+//go:build !go1.20
+
+package memcache
+
+import "hash/crc32"
+
+// keyHash returns the CRC-32 (IEEE) checksum of key.
+func keyHash(key string) uint32 {
+	return crc32.ChecksumIEEE([]byte(key))
+}
@@ -0,0 +1,200 @@
+package memcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// L1Cache is an in-process LRU cache in front of the servers, set as a
+// client's L1 to serve ultra-hot keys without a network round trip.
+// Get and GetMulti look keys up in it first and add the items they
+// read; Sets write through to it, and every other write through the
+// client, such as Delete or Increment, removes its keys. Writes by
+// other clients are not seen until entries expire, after at most the
+// cache's maximum TTL, which bounds how stale a value can be.
+//
+// An L1Cache may be shared by several clients. It is safe for
+// concurrent use.
+type L1Cache struct {
+	maxEntries int
+	maxBytes   int
+	maxTTL     time.Duration
+
+	mu      sync.Mutex
+	ll      *list.List // of *l1Entry, most recently used first
+	entries map[string]*list.Element
+	bytes   int
+
+	// gen counts removals, so that reads racing with a write don't add
+	// the value they read from before the write.
+	gen uint64
+}
+
+type l1Entry struct {
+	item    *Item
+	size    int
+	expires time.Time
+}
+
+// NewL1Cache returns an L1Cache holding at most maxEntries items and
+// maxBytes bytes of keys and values, for at most maxTTL each. A
+// maxEntries or maxBytes of zero means no limit.
+func NewL1Cache(maxEntries, maxBytes int, maxTTL time.Duration) *L1Cache {
+	return &L1Cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		maxTTL:     maxTTL,
+		ll:         list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Len returns the number of items in the cache.
+func (l *L1Cache) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+// Purge removes all items from the cache.
+func (l *L1Cache) Purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ll.Init()
+	l.entries = make(map[string]*list.Element)
+	l.bytes = 0
+	l.gen++
+}
+
+// generation returns the current generation, to pass to add. It is
+// zero for a nil cache.
+func (l *L1Cache) generation() uint64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.gen
+}
+
+// get returns a copy of the item cached for key.
+func (l *L1Cache) get(key string) (*Item, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*l1Entry)
+	if time.Now().After(e.expires) {
+		l.removeElement(el)
+		return nil, false
+	}
+	l.ll.MoveToFront(el)
+	return e.item.clone(), true
+}
+
+// getMulti returns copies of the items cached for keys, and the keys
+// not found.
+func (l *L1Cache) getMulti(keys []string) (map[string]*Item, []string) {
+	if l == nil {
+		return nil, keys
+	}
+	var found map[string]*Item
+	var missing []string
+	for _, key := range keys {
+		it, ok := l.get(key)
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		if found == nil {
+			found = make(map[string]*Item)
+		}
+		found[key] = it
+	}
+	return found, missing
+}
+
+// add caches a copy of it, read from a server, unless a write removed
+// any key since generation gen.
+func (l *L1Cache) add(it *Item, gen uint64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.gen != gen {
+		return
+	}
+	l.put(it, l.maxTTL)
+}
+
+// store caches a copy of it, just written to a server, for the lesser
+// of the cache's maximum TTL and the item's relative expiration.
+func (l *L1Cache) store(it *Item) {
+	if l == nil {
+		return
+	}
+	ttl := l.maxTTL
+	if it.Expiration > 0 && it.Expiration <= MaxRelativeExpiration {
+		if d := time.Duration(it.Expiration) * time.Second; d < ttl {
+			ttl = d
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.put(it, ttl)
+}
+
+// put caches a copy of it for ttl, evicting the least recently used
+// items as needed. l.mu must be held.
+func (l *L1Cache) put(it *Item, ttl time.Duration) {
+	if el, ok := l.entries[it.Key]; ok {
+		l.removeElement(el)
+	}
+	e := &l1Entry{item: it.clone(), expires: time.Now().Add(ttl)}
+	e.item.Object = nil
+	e.size = len(it.Key) + len(e.item.Value)
+	if l.maxBytes > 0 && e.size > l.maxBytes {
+		return
+	}
+	l.entries[it.Key] = l.ll.PushFront(e)
+	l.bytes += e.size
+	for l.maxEntries > 0 && l.ll.Len() > l.maxEntries || l.maxBytes > 0 && l.bytes > l.maxBytes {
+		l.removeElement(l.ll.Back())
+	}
+}
+
+// remove removes keys from the cache.
+func (l *L1Cache) remove(keys ...string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.gen++
+	for _, key := range keys {
+		if el, ok := l.entries[key]; ok {
+			l.removeElement(el)
+		}
+	}
+}
+
+func (l *L1Cache) removeElement(el *list.Element) {
+	e := l.ll.Remove(el).(*l1Entry)
+	delete(l.entries, e.item.Key)
+	l.bytes -= e.size
+}
+
+// l1WriteOps are the commands after which the keys they wrote are
+// removed from the client's L1 cache.
+var l1WriteOps = map[string]bool{
+	"set": true, "add": true, "replace": true, "cas": true,
+	"append": true, "prepend": true, "delete": true,
+	"incr": true, "decr": true, "ma": true, "md": true,
+}
@@ -0,0 +1,113 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestL1Cache(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.L1 = NewL1Cache(100, 0, time.Minute)
+	rec := &recordingMetrics{}
+	c.Metrics = rec
+	gets := func() int {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		n := 0
+		for _, e := range rec.ends {
+			if e.Op == "gets" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Set writes through, so the first Get is served locally.
+	mustSet(t, c, &Item{Key: "a", Value: []byte("1")})
+	it, err := c.Get("a")
+	if err != nil || string(it.Value) != "1" || gets() != 0 {
+		t.Fatalf("Get after Set = %+v, %v after %d server reads", it, err, gets())
+	}
+	// Returned items are copies.
+	it.Value[0] = 'x'
+	if it, _ := c.Get("a"); string(it.Value) != "1" {
+		t.Errorf("cached value changed to %q through a returned item", it.Value)
+	}
+
+	// Items read from the server are cached.
+	s.put("b", []byte("2"), 0)
+	for i := 0; i < 3; i++ {
+		if it, err := c.Get("b"); err != nil || string(it.Value) != "2" {
+			t.Fatalf("Get(b) = %+v, %v", it, err)
+		}
+	}
+	if n := gets(); n != 1 {
+		t.Errorf("%d server reads of b, want 1", n)
+	}
+
+	// Other writes invalidate.
+	if _, err := c.Increment("c", 1); err != ErrCacheMiss {
+		t.Fatal(err)
+	}
+	if err := c.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("b"); err != ErrCacheMiss {
+		t.Errorf("Get after Delete = %v, want ErrCacheMiss", err)
+	}
+
+	// GetMulti mixes cached and fetched items.
+	s.put("d", []byte("4"), 0)
+	m, err := c.GetMulti([]string{"a", "d", "missing"})
+	if err != nil || len(m) != 2 || string(m["a"].Value) != "1" || string(m["d"].Value) != "4" {
+		t.Fatalf("GetMulti = %v, %v", m, err)
+	}
+	before := gets()
+	if _, err := c.GetMulti([]string{"a", "d"}); err != nil || gets() != before {
+		t.Errorf("cached GetMulti sent %d server reads", gets()-before)
+	}
+}
+
+func TestL1CacheBounds(t *testing.T) {
+	l := NewL1Cache(3, 0, time.Minute)
+	for i := 0; i < 5; i++ {
+		l.store(&Item{Key: fmt.Sprint(i), Value: []byte("v")})
+	}
+	if l.Len() != 3 {
+		t.Errorf("Len = %d, want 3", l.Len())
+	}
+	if _, ok := l.get("0"); ok {
+		t.Error("least recently used item not evicted")
+	}
+
+	l = NewL1Cache(0, 10, time.Minute)
+	l.store(&Item{Key: "a", Value: []byte("12345")})
+	l.store(&Item{Key: "b", Value: []byte("12345")})
+	if _, ok := l.get("a"); ok || l.Len() != 1 {
+		t.Errorf("byte bound not enforced: Len = %d", l.Len())
+	}
+	l.store(&Item{Key: "big", Value: make([]byte, 20)})
+	if _, ok := l.get("big"); ok {
+		t.Error("item larger than the cache was stored")
+	}
+
+	// TTLs are capped by the item's expiration and the cache's maximum.
+	l = NewL1Cache(0, 0, time.Millisecond)
+	l.store(&Item{Key: "a", Value: []byte("1"), Expiration: 60})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := l.get("a"); ok {
+		t.Error("item outlived the maximum TTL")
+	}
+
+	// A read racing with a write is not cached.
+	l = NewL1Cache(0, 0, time.Minute)
+	gen := l.generation()
+	l.remove("a")
+	l.add(&Item{Key: "a", Value: []byte("old")}, gen)
+	if _, ok := l.get("a"); ok {
+		t.Error("item read before a write was cached")
+	}
+}
@@ -0,0 +1,137 @@
+package memcache
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultLatencyBuckets are the upper bounds of the buckets of the
+// per-server latency histograms of clients with no LatencyBuckets.
+// Operations slower than the last bound are counted in an overflow
+// bucket.
+var defaultLatencyBuckets = []time.Duration{
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	1 * time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+}
+
+// LatencyHistogram is a snapshot of the latency distribution of the
+// operations sent to one server.
+type LatencyHistogram struct {
+	// Bounds are the bucket upper bounds, from the client's
+	// LatencyBuckets.
+	Bounds []time.Duration
+
+	// Counts holds the number of operations in each bucket. It has one
+	// more element than Bounds, for operations slower than the last
+	// bound.
+	Counts []uint64
+
+	// Count and Sum are the total number and duration of operations.
+	Count uint64
+	Sum   time.Duration
+}
+
+// Mean returns the mean operation latency.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the upper bound of the bucket containing the q-th
+// quantile, for 0 <= q <= 1. If the quantile falls in the overflow
+// bucket, the last bound is returned.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 || len(h.Bounds) == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(h.Count))
+	var n uint64
+	for i, bound := range h.Bounds {
+		n += h.Counts[i]
+		if n > rank {
+			return bound
+		}
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+type latencyHistogram struct {
+	bounds []time.Duration
+	counts []uint64 // accessed atomically
+	count  uint64
+	sum    int64
+}
+
+// newLatencyHistogram returns a histogram with a copy of bounds, or of
+// defaultLatencyBuckets if bounds is nil.
+func newLatencyHistogram(bounds []time.Duration) *latencyHistogram {
+	if bounds == nil {
+		bounds = defaultLatencyBuckets
+	}
+	return &latencyHistogram{
+		bounds: append([]time.Duration(nil), bounds...),
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// latencyTracker holds a latencyHistogram per server address.
+type latencyTracker struct {
+	m sync.Map // string -> *latencyHistogram
+}
+
+// observe records d for addr, creating its histogram with bounds if it
+// has none yet.
+func (lt *latencyTracker) observe(addr net.Addr, d time.Duration, bounds []time.Duration) {
+	key := addr.String()
+	v, ok := lt.m.Load(key)
+	if !ok {
+		v, _ = lt.m.LoadOrStore(key, newLatencyHistogram(bounds))
+	}
+	v.(*latencyHistogram).observe(d)
+}
+
+// Latencies returns the latency histogram of each server the client has
+// sent operations to, keyed by server address, so that a single
+// degraded node can be identified from client-side data.
+func (c *Client) Latencies() map[string]LatencyHistogram {
+	hs := make(map[string]LatencyHistogram)
+	c.state.latency.m.Range(func(k, v interface{}) bool {
+		h := v.(*latencyHistogram)
+		snap := LatencyHistogram{
+			Bounds: append([]time.Duration(nil), h.bounds...),
+			Counts: make([]uint64, len(h.counts)),
+			Count:  atomic.LoadUint64(&h.count),
+			Sum:    time.Duration(atomic.LoadInt64(&h.sum)),
+		}
+		for i := range h.counts {
+			snap.Counts[i] = atomic.LoadUint64(&h.counts[i])
+		}
+		hs[k.(string)] = snap
+		return true
+	})
+	return hs
+}
@@ -0,0 +1,52 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram(nil)
+	for i := 0; i < 90; i++ {
+		h.observe(200 * time.Microsecond)
+	}
+	for i := 0; i < 10; i++ {
+		h.observe(40 * time.Millisecond)
+	}
+	c := &Client{state: new(clientState)}
+	c.state.latency.m.Store("server", h)
+	snap := c.Latencies()["server"]
+	if snap.Count != 100 {
+		t.Fatalf("Count = %d, want 100", snap.Count)
+	}
+	if g, e := snap.Quantile(0.5), 250*time.Microsecond; g != e {
+		t.Errorf("p50 = %v, want %v", g, e)
+	}
+	if g, e := snap.Quantile(0.95), 50*time.Millisecond; g != e {
+		t.Errorf("p95 = %v, want %v", g, e)
+	}
+	if g, e := snap.Mean(), (90*200*time.Microsecond+10*40*time.Millisecond)/100; g != e {
+		t.Errorf("Mean = %v, want %v", g, e)
+	}
+}
+
+func TestLatencyBuckets(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	bounds := []time.Duration{time.Hour}
+	c.LatencyBuckets = bounds
+	c.Get("foo")
+	bounds[0] = time.Nanosecond
+
+	snap, ok := c.Latencies()[s.Addr()]
+	if !ok {
+		t.Fatalf("no histogram for %s in %v", s.Addr(), c.Latencies())
+	}
+	if len(snap.Bounds) != 1 || snap.Bounds[0] != time.Hour {
+		t.Errorf("Bounds = %v, want [1h]", snap.Bounds)
+	}
+	if snap.Count != 1 || snap.Counts[0] != 1 {
+		t.Errorf("Counts = %v, want the operation in the first bucket", snap.Counts)
+	}
+}
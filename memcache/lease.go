@@ -0,0 +1,185 @@
+package memcache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// LeaseFlag is the Item.Flags bit marking the placeholder items with
+// which clients not using the meta protocol hold leases. Their value is
+// the lease's random token.
+const LeaseFlag uint32 = 1 << 22
+
+// Lease is the right, won on a cache miss by GetLease, to compute the
+// value of a key and store it. Other clients reading the key meanwhile
+// wait for the value instead of computing it too.
+type Lease struct {
+	c     *Client
+	key   string
+	casid uint64
+}
+
+// GetLease gets the item for key, like Get, but protects the key from
+// thundering herds on a miss: the first client to miss wins a lease,
+// which it should use to compute the value and store it with
+// Lease.Set, while other clients wait for that value.
+//
+// On a hit, GetLease returns the item and no lease. On a miss, it
+// returns a lease and no item if this client won the lease, or else
+// polls the key, with a randomized backoff, until its value is stored,
+// its lease expires and this client wins the next, or ctx is done, in
+// which case it returns ctx.Err(). A lease expires after ttl if it
+// isn't used.
+//
+// With MetaProtocol, leases are the win flags of the server, and items
+// marked stale are leased the same way: the client that wins a stale
+// item gets both the item and a lease, and the others get the stale
+// item, with Stale set, at once. Otherwise leases are placeholder items
+// created with Add and marked with LeaseFlag, which clients reading the
+// key with Get see as empty values.
+func (c *Client) GetLease(ctx context.Context, key string, ttl time.Duration) (*Item, *Lease, error) {
+	backoff := minRetryBackoff
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		it, lease, err := c.tryLease(key, ttl)
+		if it != nil || lease != nil || err != nil {
+			return it, lease, err
+		}
+		if err := waitBackoff(ctx, &backoff); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// tryLease reads key once, returning the item on a hit or a lease if
+// this client won it, and neither while another client holds the
+// lease.
+func (c *Client) tryLease(key string, ttl time.Duration) (*Item, *Lease, error) {
+	if c.MetaProtocol {
+		it, pending, err := c.metaLeaseGet(key, ttl)
+		if err != nil || it == nil {
+			return nil, nil, err
+		}
+		var lease *Lease
+		if it.Win {
+			lease = &Lease{c: c, key: key, casid: it.casid}
+		}
+		if !it.Stale && (it.Win || pending) {
+			// An empty placeholder created for the lease.
+			return nil, lease, nil
+		}
+		if err := c.finishRead(it); err != nil {
+			return nil, nil, err
+		}
+		return it, lease, nil
+	}
+
+	it, err := c.Get(key)
+	switch {
+	case err == nil && it.Flags&LeaseFlag == 0:
+		return it, nil, nil
+	case err == nil:
+		return nil, nil, nil
+	case !errors.Is(err, ErrCacheMiss):
+		return nil, nil, err
+	}
+	var tb [8]byte
+	if _, err := rand.Read(tb[:]); err != nil {
+		return nil, nil, err
+	}
+	token := hex.EncodeToString(tb[:])
+	err = c.Add(&Item{Key: key, Value: []byte(token), Flags: LeaseFlag, Expiration: expirationFor(ttl)})
+	if errors.Is(err, ErrNotStored) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// Read the placeholder back for its CAS ID, which guards Lease.Set.
+	it, err = c.Get(key)
+	switch {
+	case errors.Is(err, ErrCacheMiss):
+		return nil, nil, nil
+	case err != nil:
+		return nil, nil, err
+	case it.Flags&LeaseFlag == 0:
+		return it, nil, nil
+	case string(it.Bytes()) != token:
+		return nil, nil, nil
+	}
+	return nil, &Lease{c: c, key: key, casid: it.casid}, nil
+}
+
+// metaLeaseGet reads key with "mg" and the vivify flag, so that a miss
+// creates an empty placeholder expiring after ttl, reported with the
+// win flag to this client only. pending reports the Z flag: another
+// client won the item.
+func (c *Client) metaLeaseGet(key string, ttl time.Duration) (it *Item, pending bool, err error) {
+	if c.ReadOnly {
+		// The vivify flag makes this read a write.
+		return nil, false, ErrReadOnly
+	}
+	orig := key
+	key = c.storageKey(key)
+	defer func() { c.restoreItem(it, orig) }()
+	err = c.withKeyAddr(key, func(addr net.Addr) error {
+		return c.withAddrConn(addr, "mg", []string{key}, func(cn *conn, m *OpMetrics) error {
+			rw := cn.rw
+			if _, err := fmt.Fprintf(rw, "mg %s v f c k N%d\r\n", c.metaKey(key), expirationFor(ttl)); err != nil {
+				return err
+			}
+			if err := rw.Flush(); err != nil {
+				return err
+			}
+			mr, err := readMetaResponse(rw.Reader, c.limits(), c.StrictResponses)
+			if err != nil {
+				return err
+			}
+			switch mr.status {
+			case "EN":
+				m.Misses++
+				return nil
+			case "VA":
+			default:
+				return fmt.Errorf("%w: unexpected %s response to mg", ErrProtocol, mr.status)
+			}
+			if it, err = mr.item(key); err != nil {
+				return err
+			}
+			_, pending = mr.flag('Z')
+			m.Hits++
+			return nil
+		})
+	})
+	return it, pending, err
+}
+
+// Set stores item, whose Key must be the lease's key, unless the lease
+// expired or the key was written by another client since the lease was
+// won, in which case it returns ErrCASConflict or ErrNotStored.
+func (l *Lease) Set(item *Item) error {
+	if item.Key != l.key {
+		return l.c.withKeys([]string{l.key, item.Key}, errors.New("memcache: lease used to set another key"))
+	}
+	it := *item
+	it.casid = l.casid
+	return l.c.CompareAndSwap(&it)
+}
+
+// Release gives up the lease without storing a value, so that another
+// client can win it without waiting for it to expire. The leased item
+// is expired, whether a placeholder or a stale value.
+func (l *Lease) Release() error {
+	err := l.c.CompareAndSwap(&Item{Key: l.key, Expiration: -1, casid: l.casid})
+	if errors.Is(err, ErrCASConflict) || errors.Is(err, ErrNotStored) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetLease(t *testing.T) {
+	for _, meta := range []bool{false, true} {
+		s := newFakeServer(t)
+		defer s.Close()
+		c := New(s.Addr())
+		c.MetaProtocol = meta
+		ctx := context.Background()
+
+		it, lease, err := c.GetLease(ctx, "k", time.Minute)
+		if it != nil || lease == nil || err != nil {
+			t.Fatalf("meta=%v: first miss = %+v, %v, %v; want a lease", meta, it, lease, err)
+		}
+
+		// Other readers wait for the lease holder's value.
+		short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		if _, _, err := c.GetLease(short, "k", time.Minute); err != context.DeadlineExceeded {
+			t.Errorf("meta=%v: GetLease of leased key = %v, want deadline exceeded", meta, err)
+		}
+		cancel()
+		got := make(chan *Item)
+		go func() {
+			it, lease, err := c.GetLease(ctx, "k", time.Minute)
+			if lease != nil || err != nil {
+				t.Errorf("meta=%v: waiting GetLease = %v, %v", meta, lease, err)
+			}
+			got <- it
+		}()
+		time.Sleep(10 * time.Millisecond)
+		if err := lease.Set(&Item{Key: "k", Value: []byte("v")}); err != nil {
+			t.Fatalf("meta=%v: Lease.Set = %v", meta, err)
+		}
+		if it := <-got; it == nil || string(it.Value) != "v" {
+			t.Errorf("meta=%v: waiter got %+v", meta, it)
+		}
+
+		// A hit returns no lease, and a used lease can't set again.
+		if it, lease, err := c.GetLease(ctx, "k", time.Minute); err != nil || lease != nil || string(it.Value) != "v" {
+			t.Errorf("meta=%v: hit = %+v, %v, %v", meta, it, lease, err)
+		}
+		if err := lease.Set(&Item{Key: "k", Value: []byte("again")}); err != ErrCASConflict {
+			t.Errorf("meta=%v: reused Lease.Set = %v, want ErrCASConflict", meta, err)
+		}
+		if err := lease.Set(&Item{Key: "other", Value: []byte("v")}); err == nil {
+			t.Errorf("meta=%v: Lease.Set of another key succeeded", meta)
+		}
+
+		// A released lease can be won by the next reader.
+		_, lease, _ = c.GetLease(ctx, "r", time.Minute)
+		if err := lease.Release(); err != nil {
+			t.Fatalf("meta=%v: Release = %v", meta, err)
+		}
+		if _, lease, err := c.GetLease(ctx, "r", time.Minute); lease == nil || err != nil {
+			t.Errorf("meta=%v: GetLease after Release = %v, %v; want a lease", meta, lease, err)
+		}
+	}
+}
+
+func TestGetLeaseStale(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.MetaProtocol = true
+	ctx := context.Background()
+
+	mustSet(t, c, &Item{Key: "k", Value: []byte("old")})
+	s.markStale("k")
+	it, lease, err := c.GetLease(ctx, "k", time.Minute)
+	if err != nil || lease == nil || it == nil || !it.Stale || string(it.Value) != "old" {
+		t.Fatalf("winner = %+v, %v, %v; want the stale item and a lease", it, lease, err)
+	}
+	it, lease2, err := c.GetLease(ctx, "k", time.Minute)
+	if err != nil || lease2 != nil || it == nil || string(it.Value) != "old" {
+		t.Errorf("other reader = %+v, %v, %v; want the stale item", it, lease2, err)
+	}
+	if err := lease.Set(&Item{Key: "k", Value: []byte("new")}); err != nil {
+		t.Fatalf("Lease.Set = %v", err)
+	}
+	if it, err := c.Get("k"); err != nil || string(it.Value) != "new" || it.Stale {
+		t.Errorf("after Set, Get = %+v, %v", it, err)
+	}
+}
@@ -0,0 +1,63 @@
+package memcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// inflightLimiter bounds the requests a client has in progress.
+type inflightLimiter struct {
+	mu sync.Mutex
+	n  int
+
+	// released is closed, and cleared, when a slot is released, to
+	// wake the requests waiting for one.
+	released chan struct{}
+}
+
+// acquire takes one of limit slots, waiting up to wait for one to be
+// released, or returns ErrTooManyInFlight. It returns ctx's error if
+// ctx is done first.
+func (l *inflightLimiter) acquire(ctx context.Context, limit int, wait time.Duration) error {
+	var timeout <-chan time.Time
+	for {
+		l.mu.Lock()
+		if l.n < limit {
+			l.n++
+			l.mu.Unlock()
+			return nil
+		}
+		if l.released == nil {
+			l.released = make(chan struct{})
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		if wait <= 0 {
+			return ErrTooManyInFlight
+		}
+		if timeout == nil {
+			t := time.NewTimer(wait)
+			defer t.Stop()
+			timeout = t.C
+		}
+		select {
+		case <-released:
+		case <-timeout:
+			return ErrTooManyInFlight
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (l *inflightLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.n--
+	if l.released != nil {
+		close(l.released)
+		l.released = nil
+	}
+}
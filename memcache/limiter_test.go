@@ -0,0 +1,75 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInflightLimiter(t *testing.T) {
+	var l inflightLimiter
+	if err := l.acquire(context.Background(), 1, 0); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	if err := l.acquire(context.Background(), 1, 0); err != ErrTooManyInFlight {
+		t.Errorf("acquire over the limit = %v, want ErrTooManyInFlight", err)
+	}
+	if err := l.acquire(context.Background(), 1, 10*time.Millisecond); err != ErrTooManyInFlight {
+		t.Errorf("acquire with expired wait = %v, want ErrTooManyInFlight", err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.release()
+	}()
+	if err := l.acquire(context.Background(), 1, time.Second); err != nil {
+		t.Errorf("acquire waiting for release = %v", err)
+	}
+}
+
+func TestInflightLimiterCancel(t *testing.T) {
+	var l inflightLimiter
+	if err := l.acquire(context.Background(), 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := l.acquire(ctx, 1, time.Minute); err != context.Canceled {
+		t.Errorf("acquire with canceled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestInflightLimiterRaisedLimit(t *testing.T) {
+	var l inflightLimiter
+	if err := l.acquire(context.Background(), 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.acquire(context.Background(), 2, 0); err != nil {
+		t.Errorf("acquire after raising the limit = %v", err)
+	}
+	if err := l.acquire(context.Background(), 2, 0); err != ErrTooManyInFlight {
+		t.Errorf("acquire over the raised limit = %v, want ErrTooManyInFlight", err)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.MaxInFlight = 1
+	s.put("a", []byte("alpha"), 0)
+
+	// Occupy the only slot.
+	if err := c.state.inflight.acquire(context.Background(), c.MaxInFlight, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("a"); err != ErrTooManyInFlight {
+		t.Errorf("Get over the limit = %v, want ErrTooManyInFlight", err)
+	}
+	c.state.inflight.release()
+	if it, err := c.Get("a"); err != nil || string(it.Value) != "alpha" {
+		t.Errorf("Get = %v, %v", it, err)
+	}
+}
@@ -0,0 +1,83 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestResponseLimits(t *testing.T) {
+	lim := responseLimits{maxLine: 64, maxValue: 10}
+	tests := []struct {
+		name, resp string
+		ok         bool
+	}{
+		{"within limits", "VALUE a 0 10\r\n0123456789\r\nEND\r\n", true},
+		{"value too large", "VALUE a 0 11\r\n01234567890\r\nEND\r\n", false},
+		{"line too long", "VALUE " + strings.Repeat("k", 100) + " 0 1\r\nx\r\nEND\r\n", false},
+	}
+	for _, tt := range tests {
+		// A small buffer makes long lines arrive in several fragments.
+		r := bufio.NewReaderSize(strings.NewReader(tt.resp), 16)
+		err := parseGetResponse(r, lim, nil, false, func(*Item) {})
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrProtocol) {
+			t.Errorf("%s: err = %v, want ErrProtocol", tt.name, err)
+		}
+	}
+}
+
+func TestValueGrowsAsRead(t *testing.T) {
+	// A truncated response announcing a huge value must fail without
+	// allocating the announced size.
+	r := bufio.NewReader(strings.NewReader("VALUE a 0 1073741824\r\nabc"))
+	if err := parseGetResponse(r, New().limits(), []string{"a"}, true, func(*Item) {}); !errors.Is(err, ErrProtocol) {
+		t.Errorf("truncated huge value: err = %v, want ErrProtocol", err)
+	}
+
+	want := bytes.Repeat([]byte("0123456789"), 3*maxValueGrowth/10)
+	r = bufio.NewReader(strings.NewReader(fmt.Sprintf("VALUE a 0 %d\r\n%s\r\nEND\r\n", len(want), want)))
+	var got []byte
+	if err := parseGetResponse(r, New().limits(), []string{"a"}, true, func(it *Item) { got = it.Value }); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("value of %d bytes read as %d bytes", len(want), len(got))
+	}
+}
+
+func TestDrainOversizedValues(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.MaxValueSize = 10
+	c.DrainOversizedValues = true
+	s.put("small", []byte("ok"), 0)
+	s.put("big", []byte(strings.Repeat("x", 100)), 0)
+
+	for _, meta := range []bool{false, true} {
+		c.MetaProtocol = meta
+		if _, err := c.Get("big"); err != ErrValueTooLarge {
+			t.Errorf("meta=%v: Get(big) = %v, want ErrValueTooLarge", meta, err)
+		}
+		if _, _, err := c.GetAppend("big", nil); err != ErrValueTooLarge {
+			t.Errorf("meta=%v: GetAppend(big) = %v, want ErrValueTooLarge", meta, err)
+		}
+		m, err := c.GetMulti([]string{"big", "small"})
+		if err != ErrValueTooLarge || len(m) != 1 || string(m["small"].Value) != "ok" {
+			t.Errorf("meta=%v: GetMulti = %v, %v; want small only and ErrValueTooLarge", meta, m, err)
+		}
+		if it, err := c.Get("small"); err != nil || string(it.Value) != "ok" {
+			t.Errorf("meta=%v: Get(small) = %v, %v", meta, it, err)
+		}
+	}
+	// The connection survives draining.
+	if st := c.ConnStats()[s.Addr()]; st.Dials != 1 {
+		t.Errorf("dials = %d, want 1", st.Dials)
+	}
+}
@@ -0,0 +1,206 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrLockNotHeld is returned by Lock.Extend and Lock.Unlock when the
+// lock isn't held, or was lost because it expired and was taken by
+// another holder.
+var ErrLockNotHeld = errors.New("memcache: lock not held")
+
+// Lock is a lock held in memcached under a key, for short critical
+// sections such as letting only one worker refresh a value. The lock
+// item is created with Add and expires after the lock's TTL unless
+// extended, so that a crashed holder doesn't keep it forever; its
+// value is a fencing token, which is how Extend and Unlock tell their
+// own hold from another holder's.
+//
+// Memcached may evict or lose the lock item, so a Lock only reduces
+// duplicated work: it does not guarantee mutual exclusion. Resources
+// that must not be written by two holders should reject writes with a
+// fencing token lower than one they have seen.
+//
+// A Lock is safe for concurrent use, but holds the lock for the whole
+// process: it is not reentrant.
+type Lock struct {
+	// AutoExtend makes a held lock extend itself every third of its TTL
+	// until it is unlocked or found lost. Set it before taking the lock.
+	AutoExtend bool
+
+	c   *Client
+	key string
+	ttl time.Duration
+
+	mu    sync.Mutex
+	token uint64
+	stop  chan struct{}
+}
+
+// NewLock returns a lock held under key, which expires ttl after it is
+// taken or last extended. The fencing tokens are kept under key
+// followed by ":fence", which is never expired.
+func (c *Client) NewLock(key string, ttl time.Duration) *Lock {
+	return &Lock{c: c, key: key, ttl: ttl}
+}
+
+// Token returns the fencing token of the current hold of the lock, or
+// zero if it isn't held. Each hold of a lock gets a larger token than
+// the previous one, unless the token counter is evicted.
+func (l *Lock) Token() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+// nextToken returns a new fencing token, creating the counter if
+// needed.
+func (l *Lock) nextToken() (uint64, error) {
+	key := l.key + ":fence"
+	for {
+		n, err := l.c.Increment(key, 1)
+		if !errors.Is(err, ErrCacheMiss) {
+			return n, err
+		}
+		if err := l.c.storeRaw("add", &Item{Key: key, Value: []byte("0")}); err != nil && !errors.Is(err, ErrNotStored) {
+			return 0, err
+		}
+	}
+}
+
+// TryLock takes the lock if it is free, reporting whether it did. It
+// returns false without error if another holder has it.
+func (l *Lock) TryLock() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.token != 0 {
+		return false, nil
+	}
+	token, err := l.nextToken()
+	if err != nil {
+		return false, err
+	}
+	err = l.c.Add(&Item{Key: l.key, Value: strconv.AppendUint(nil, token, 10), Expiration: expirationFor(l.ttl)})
+	if errors.Is(err, ErrNotStored) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	l.token = token
+	if l.AutoExtend && l.ttl > 0 {
+		l.stop = make(chan struct{})
+		go l.extendLoop(token, l.stop)
+	}
+	return true, nil
+}
+
+// Lock takes the lock, retrying with a randomized backoff while
+// another holder has it, until ctx is done.
+func (l *Lock) Lock(ctx context.Context) error {
+	backoff := minRetryBackoff
+	for {
+		ok, err := l.TryLock()
+		if ok || err != nil {
+			return err
+		}
+		if err := waitBackoff(ctx, &backoff); err != nil {
+			return err
+		}
+	}
+}
+
+// replace replaces the lock item with expiration exp if it still holds
+// token, returning ErrLockNotHeld if it doesn't.
+func (l *Lock) replace(token uint64, exp int32) error {
+	if token == 0 {
+		return ErrLockNotHeld
+	}
+	it, err := l.c.Get(l.key)
+	if errors.Is(err, ErrCacheMiss) {
+		return ErrLockNotHeld
+	}
+	if err != nil {
+		return err
+	}
+	if string(it.Bytes()) != strconv.FormatUint(token, 10) {
+		return ErrLockNotHeld
+	}
+	it.Expiration = exp
+	err = l.c.CompareAndSwap(it)
+	if errors.Is(err, ErrCASConflict) || errors.Is(err, ErrNotStored) {
+		return ErrLockNotHeld
+	}
+	return err
+}
+
+// Extend resets the expiration of the held lock to its TTL from now.
+// It returns ErrLockNotHeld if the lock was lost.
+func (l *Lock) Extend() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.replace(l.token, expirationFor(l.ttl))
+	if err == ErrLockNotHeld {
+		l.release()
+	}
+	return err
+}
+
+// Unlock releases the held lock. It returns ErrLockNotHeld if the lock
+// wasn't held or was lost, in which case it is left to its new holder.
+func (l *Lock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// A negative expiration makes the server expire the item at once,
+	// which unlike delete can be made conditional on the CAS ID.
+	err := l.replace(l.token, -1)
+	if err == nil || err == ErrLockNotHeld {
+		l.release()
+	}
+	return err
+}
+
+// release forgets the current hold, stopping its extension. l.mu must
+// be held.
+func (l *Lock) release() {
+	l.token = 0
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+}
+
+// extendLoop extends the hold with token every third of the TTL until
+// stop is closed or the lock is found lost. Other errors are logged and
+// retried at the next tick.
+func (l *Lock) extendLoop(token uint64, stop chan struct{}) {
+	t := time.NewTicker(l.ttl / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		l.mu.Lock()
+		if l.token != token {
+			l.mu.Unlock()
+			return
+		}
+		err := l.replace(token, expirationFor(l.ttl))
+		if err == ErrLockNotHeld {
+			l.release()
+		}
+		l.mu.Unlock()
+		if err == ErrLockNotHeld {
+			return
+		}
+		if err != nil {
+			l.c.logDebug("memcache: failed to extend lock", "key", l.key, "err", err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	a, b := c.NewLock("l", time.Minute), c.NewLock("l", time.Minute)
+	if ok, err := a.TryLock(); !ok || err != nil {
+		t.Fatalf("a.TryLock = %v, %v", ok, err)
+	}
+	if ok, err := b.TryLock(); ok || err != nil {
+		t.Fatalf("b.TryLock of held lock = %v, %v", ok, err)
+	}
+	first := a.Token()
+	if first == 0 || b.Token() != 0 {
+		t.Fatalf("tokens = %d, %d", first, b.Token())
+	}
+	if err := a.Extend(); err != nil {
+		t.Errorf("a.Extend = %v", err)
+	}
+
+	locked := make(chan error)
+	go func() { locked <- b.Lock(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	if err := a.Unlock(); err != nil {
+		t.Fatalf("a.Unlock = %v", err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatalf("b.Lock = %v", err)
+	}
+	if b.Token() <= first {
+		t.Errorf("second token %d not above first %d", b.Token(), first)
+	}
+	if err := a.Unlock(); err != ErrLockNotHeld {
+		t.Errorf("unlocking a released lock = %v, want ErrLockNotHeld", err)
+	}
+
+	// A lock taken over by another holder is lost, and left alone.
+	mustSet(t, c, &Item{Key: "l", Value: []byte("999")})
+	if err := b.Extend(); err != ErrLockNotHeld {
+		t.Errorf("extending a lost lock = %v, want ErrLockNotHeld", err)
+	}
+	if err := b.Unlock(); err != ErrLockNotHeld {
+		t.Errorf("unlocking a lost lock = %v, want ErrLockNotHeld", err)
+	}
+	if it, ok := s.get("l"); !ok || string(it.value) != "999" {
+		t.Errorf("new holder's lock item = %+v, %v", it, ok)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := a.Lock(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Lock of held lock = %v, want deadline exceeded", err)
+	}
+}
+
+func TestLockAutoExtend(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	l := c.NewLock("l", 30*time.Millisecond)
+	l.AutoExtend = true
+	if ok, err := l.TryLock(); !ok || err != nil {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+	it, _ := s.get("l")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if now, _ := s.get("l"); now != it {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("lock never extended")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatalf("Unlock = %v", err)
+	}
+	if _, ok := s.get("l"); ok {
+		t.Error("lock item left after Unlock")
+	}
+}
@@ -0,0 +1,28 @@
+package memcache
+
+// Logger is the interface used by Client to report diagnostic events.
+// Its methods take a message followed by alternating keys and values,
+// so a *slog.Logger satisfies it.
+//
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	// Warn reports failures the client recovered from or worked around,
+	// such as a failed dial or a failed replica write.
+	Warn(msg string, keysAndValues ...interface{})
+
+	// Debug reports routine events, such as a connection being
+	// discarded or a read falling back to another replica.
+	Debug(msg string, keysAndValues ...interface{})
+}
+
+func (c *Client) logWarn(msg string, keysAndValues ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Warn(msg, keysAndValues...)
+	}
+}
+
+func (c *Client) logDebug(msg string, keysAndValues ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Debug(msg, keysAndValues...)
+	}
+}
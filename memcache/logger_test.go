@@ -0,0 +1,28 @@
+package memcache
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var buf bytes.Buffer
+	c := New(addr)
+	c.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if _, err := c.Get("foo"); err == nil {
+		t.Fatal("Get from closed server: want error")
+	}
+	if out := buf.String(); !strings.Contains(out, "connect failed") || !strings.Contains(out, addr) {
+		t.Errorf("log output = %q, want connect failure for %s", out, addr)
+	}
+}
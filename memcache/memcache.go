@@ -20,12 +20,12 @@ package memcache
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
+	"math/rand"
 	"net"
-
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -49,9 +49,29 @@ var (
 	// CompareAndSwap) failed because the condition was not satisfied.
 	ErrNotStored = errors.New("memcache: item not stored")
 
-	// ErrServer means that a server error occurred.
+	// ErrServerError means that the server responded with SERVER_ERROR.
 	ErrServerError = errors.New("memcache: server error")
 
+	// ErrTimeout means that connecting to, writing to or reading from a
+	// server timed out.
+	ErrTimeout = errors.New("memcache: timeout")
+
+	// ErrConnFailed means that a connection to a server could not be
+	// established or was lost.
+	ErrConnFailed = errors.New("memcache: connection failed")
+
+	// ErrProtocol means that a server sent an error or a response the
+	// client could not understand.
+	ErrProtocol = errors.New("memcache: protocol error")
+
+	// ErrValueTooLarge means that a value was larger than the client's
+	// MaxValueSize and was skipped, as DrainOversizedValues requests.
+	ErrValueTooLarge = errors.New("memcache: value larger than MaxValueSize")
+
+	// ErrTooManyInFlight means that a request was shed because the
+	// client already had MaxInFlight requests in progress.
+	ErrTooManyInFlight = errors.New("memcache: too many requests in flight")
+
 	// ErrNoStats means that no statistics were available.
 	ErrNoStats = errors.New("memcache: no statistics available")
 
@@ -62,15 +82,41 @@ var (
 
 	// ErrNoServers is returned when no servers are configured or available.
 	ErrNoServers = errors.New("memcache: no servers configured or available")
+
+	// ErrMetaUnsupported means that the server answered a meta command
+	// with ERROR, as memcached before 1.6 does. It wraps ErrProtocol.
+	ErrMetaUnsupported = fmt.Errorf("%w: server does not support the meta protocol", ErrProtocol)
+
+	// ErrReplicatedCAS is returned by CompareAndSwap, and the operations
+	// built on it, when Replicas is greater than one: each replica
+	// assigns its own CAS IDs, so no single CAS ID matches them all.
+	ErrReplicatedCAS = errors.New("memcache: CompareAndSwap is not supported with Replicas")
+
+	// ErrReturnCASWithoutMeta is returned by the storage operations when
+	// ReturnCAS is set without MetaProtocol.
+	ErrReturnCASWithoutMeta = errors.New("memcache: ReturnCAS requires MetaProtocol")
 )
 
 const (
 	// DefaultTimeout is the default socket read/write timeout.
 	DefaultTimeout = 100 * time.Millisecond
 
+	// DefaultAdminTimeout is the default socket read/write timeout for
+	// administrative commands such as stats, used when neither
+	// AdminTimeout nor Timeout is set.
+	DefaultAdminTimeout = 1 * time.Second
+
 	// DefaultMaxIdleConns is the default maximum number of idle connections
 	// kept for any single address.
 	DefaultMaxIdleConns = 2
+
+	// DefaultMaxLineLength is the default maximum length of a response
+	// line.
+	DefaultMaxLineLength = 4096
+
+	// DefaultBufferSize is the default size of the read and write
+	// buffers of each connection.
+	DefaultBufferSize = 4096
 )
 
 const buffered = 8 // arbitrary buffered channel size, for readability
@@ -80,15 +126,18 @@ const buffered = 8 // arbitrary buffered channel size, for readability
 // be re-used or not. If an error occurs, by default we don't reuse the
 // connection, unless it was just a cache error.
 func resumableError(err error) bool {
+	if ke, ok := err.(*KeyError); ok {
+		err = ke.Err
+	}
 	switch err {
-	case ErrCacheMiss, ErrCASConflict, ErrNotStored, ErrMalformedKey:
+	case ErrCacheMiss, ErrCASConflict, ErrNotStored, ErrMalformedKey, ErrValueTooLarge:
 		return true
 	}
 	return false
 }
 
 func legalKey(key string) bool {
-	if len(key) > 250 {
+	if len(key) > maxKeyLength {
 		return false
 	}
 	for i := 0; i < len(key); i++ {
@@ -107,9 +156,12 @@ var (
 	resultExists    = []byte("EXISTS\r\n")
 	resultNotFound  = []byte("NOT_FOUND\r\n")
 	resultDeleted   = []byte("DELETED\r\n")
+	resultTouched   = []byte("TOUCHED\r\n")
 	resultEnd       = []byte("END\r\n")
+	valuePrefix     = []byte("VALUE ")
 
 	resultClientErrorPrefix = []byte("CLIENT_ERROR ")
+	resultServerErrorPrefix = []byte("SERVER_ERROR ")
 )
 
 // New returns a memcache client using the provided server(s)
@@ -123,7 +175,7 @@ func New(server ...string) *Client {
 
 // NewFromSelector returns a new Client using the provided ServerSelector.
 func NewFromSelector(ss ServerSelector) *Client {
-	return &Client{selector: ss}
+	return &Client{selector: ss, state: new(clientState)}
 }
 
 type MemcacheClient interface {
@@ -152,6 +204,16 @@ type Client struct {
 	// If zero, DefaultTimeout is used.
 	Timeout time.Duration
 
+	// ReadTimeout, WriteTimeout and AdminTimeout override Timeout for
+	// read operations (Get, GetMulti), write operations (Set, Add,
+	// CompareAndSwap, Delete, Increment, Decrement) and administrative
+	// commands (Stats) respectively. If zero, Timeout is used, except
+	// that AdminTimeout falls back to DefaultAdminTimeout when Timeout
+	// is also zero.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	AdminTimeout time.Duration
+
 	// MaxIdleConns specifies the maximum number of idle connections that will
 	// be maintained per address. If less than one, DefaultMaxIdleConns will be
 	// used.
@@ -160,8 +222,388 @@ type Client struct {
 	// be set to a number higher than your peak parallel requests.
 	MaxIdleConns int
 
+	// Replicas is the number of servers each key is written to. If
+	// greater than one, writes go to that many distinct servers chosen
+	// by the selector, which must implement ReplicaSelector, and
+	// ReplicaPolicy decides whether a write succeeded. CompareAndSwap,
+	// and the operations built on it such as Update, locks and leases,
+	// then fail with ErrReplicatedCAS.
+	Replicas int
+
+	// ReplicaPolicy decides how many replicas must accept a write for it
+	// to be considered successful. The zero value is WriteAll.
+	ReplicaPolicy ReplicaPolicy
+
+	// ReadFallbackOnMiss makes Get try the next replica on a cache miss,
+	// not only on a server error, when replication is enabled.
+	ReadFallbackOnMiss bool
+
+	// ReadRepairExpiration is the expiration, in seconds, used when an
+	// item found on a fallback replica is written back to the replicas
+	// that failed to return it. If zero, DefaultReadRepairExpiration is
+	// used. If negative, read repair is disabled.
+	ReadRepairExpiration int32
+
+	// LocalZone is the zone the client runs in. When replication is
+	// enabled and the selector implements ZoneSelector, Get tries
+	// replicas in LocalZone before falling back to other zones.
+	LocalZone string
+
+	// Logger, if non-nil, receives diagnostic messages about connection
+	// failures, replica fallbacks and similar events. A *slog.Logger
+	// may be used directly.
+	Logger Logger
+
+	// SlowOpThreshold, if positive, is the duration above which an
+	// operation is reported as slow to SlowOpHook and Logger, along
+	// with its keys, server and op type.
+	SlowOpThreshold time.Duration
+
+	// HashSlowOpKeys makes slow operation reports carry a hash of each
+	// key instead of the key itself.
+	HashSlowOpKeys bool
+
+	// MetaProtocol makes the client use the meta commands of memcached
+	// 1.6 and later where it supports them. Get and GetMulti are sent
+	// as "mg", which reports the Stale and Win flags of items.
+	MetaProtocol bool
+
+	// ErrorOnUnderflow makes Decrement fail with ErrUnderflow, leaving
+	// the value unchanged, when delta exceeds it, instead of stopping
+	// at zero as memcached does. The decrement is then a read followed
+	// by a compare and swap, retried if the value changes in between.
+	// The read uses the meta protocol's mg command, to keep the
+	// remaining time to live, whether or not MetaProtocol is set, so
+	// Decrement fails with ErrMetaUnsupported on servers without it,
+	// and with ErrReplicatedCAS if Replicas is greater than one.
+	ErrorOnUnderflow bool
+
+	// ErrorOnOverflow makes Increment return ErrOverflow, along with
+	// the new value, when the increment wrapped around past the largest
+	// 64-bit value.
+	ErrorOnOverflow bool
+
+	// ReturnCAS makes Set, Add and CompareAndSwap set the CAS ID of the
+	// value they stored on the item passed to them, so that a later
+	// CompareAndSwap needs no read. The CAS ID is returned by the "ms"
+	// command storing the item, so ReturnCAS requires MetaProtocol:
+	// without it, storage operations fail with ErrReturnCASWithoutMeta.
+	// ReturnCAS has no effect with Replicas, as the replicas assign CAS
+	// IDs independently.
+	ReturnCAS bool
+
+	// MaxLineLength is the maximum length, including the terminator, of
+	// a response line the client will read. A longer line fails the
+	// operation with ErrProtocol. If zero, DefaultMaxLineLength is used.
+	MaxLineLength int
+
+	// MaxValueSize, if positive, is the largest value the client will
+	// read. A get response announcing a larger value fails the
+	// operation with ErrProtocol instead of allocating the value.
+	MaxValueSize int
+
+	// DrainOversizedValues makes the client skip values larger than
+	// MaxValueSize rather than fail on them: the value is read and
+	// discarded, without being allocated, and the connection is kept.
+	// Get then returns ErrValueTooLarge, and GetMulti omits the item and
+	// returns ErrValueTooLarge along with the other items.
+	DrainOversizedValues bool
+
+	// PoolValues makes the client read item values into buffers from a
+	// shared pool. Callers must call Release on each item returned by
+	// Get and GetMulti once they are done with its value, which is then
+	// reused for later reads. Values larger than 1MB are not pooled.
+	PoolValues bool
+
+	// LazyValues makes GetMulti read the values of each response into
+	// shared buffers and copy them out only when Item.Bytes is called,
+	// saving the copy for items the caller doesn't use. The Value of
+	// such items is nil until Bytes is called. Values that must be
+	// decoded by Transcoders or reassembled from chunks are copied
+	// immediately. LazyValues has no effect on single-key reads, with
+	// PoolValues, or with the meta protocol.
+	LazyValues bool
+
+	// CoalesceWindow, if positive, makes Get wait up to this long for
+	// Gets from other goroutines and send them all as a single GetMulti,
+	// one request per server. This trades up to CoalesceWindow of added
+	// latency for fewer round trips when many goroutines read at once.
+	// Concurrent Gets for the same key share one request. Coalescing is
+	// not used when replication is enabled.
+	CoalesceWindow time.Duration
+
+	// DedupGets makes concurrent Gets for the same key share a single
+	// request: while one is in flight, further Gets for its key wait
+	// for it and get a copy of its result.
+	DedupGets bool
+
+	// ReadOnly makes the client fail every operation that would modify
+	// the cache with ErrReadOnly, without sending it: stores, deletes,
+	// touches and arithmetic, including those of SetMulti, SetAsync and
+	// the lease, lock, counter and tombstone helpers. Reads and Stats
+	// are unaffected. NewReadOnly does the same for any MemcacheClient.
+	ReadOnly bool
+
+	// MaxInFlight, if positive, limits the number of requests the client
+	// has in progress on its connections at once, across all servers.
+	// A request over the limit waits up to InFlightWait for another to
+	// finish, and then fails with ErrTooManyInFlight; with InFlightWait
+	// zero, it fails at once. Requests of GetAsync and SetAsync are not
+	// counted.
+	MaxInFlight  int
+	InFlightWait time.Duration
+
+	// BatchParallelism, if positive, is the number of servers that
+	// SetMulti, DeleteMulti and TouchMulti send to at once. If zero,
+	// all servers of a batch are sent to concurrently, as GetMulti does.
+	BatchParallelism int
+
+	// GetMultiParallelism, if positive, is the number of requests a
+	// GetMulti has in progress at once. If zero, GetMulti sends all its
+	// requests concurrently, one per server.
+	GetMultiParallelism int
+
+	// MaxKeysPerGet, if positive, splits the keys a GetMulti sends to
+	// one server into requests of at most this many keys, which run
+	// concurrently, subject to GetMultiParallelism.
+	MaxKeysPerGet int
+
+	// Journal, if non-nil, records Sets and Deletes that fail because
+	// their servers are unreachable, to replay them when the servers
+	// recover. See WriteJournal.
+	Journal *WriteJournal
+
+	// NegativeTTL, if positive, makes GetOrSet and GetOrRevalidate cache
+	// the failures of their loaders for this long, so that a key whose
+	// load fails, such as one naming a row that doesn't exist, isn't
+	// reloaded on every read. See NegativeCacheError.
+	NegativeTTL time.Duration
+
+	// L1, if non-nil, is an in-process cache consulted by Get and
+	// GetMulti before the servers. See L1Cache.
+	L1 *L1Cache
+
+	// KeyPrefix is prepended to every key the client sends, and
+	// removed from the keys of the items it returns, so that several
+	// applications or environments can share a cluster without their
+	// keys colliding. Keys must fit memcached's limit of 250 bytes with
+	// the prefix. MetaDump reports keys as stored, prefix included.
+	KeyPrefix string
+
+	// HashLongKeys makes the client store keys that are too long, or
+	// contain spaces or control characters, and so would fail with
+	// ErrMalformedKey, under a key made of KeyPrefix, '#' and the
+	// base64 SHA-1 of the key instead, so that keys generated from URLs
+	// and other input needn't be checked by the caller.
+	HashLongKeys bool
+
+	// KeyMapper, if non-nil, maps every key the client sends, before
+	// EscapeKeys, KeyPrefix and HashLongKeys apply, so that a policy
+	// such as a tenant prefix or lowercasing is enforced in one place.
+	// Items are returned with the keys they were asked for, so the
+	// mapping needn't be reversible; keys it maps together share an
+	// item.
+	KeyMapper func(key string) string
+
+	// EscapeKeys makes the client escape keys with EscapeKey, so that
+	// keys with spaces, control characters or non-ASCII bytes are
+	// stored rather than rejected with ErrMalformedKey. Items are
+	// returned with their keys unescaped. Escaping comes before
+	// HashLongKeys and makes BinaryKeys moot.
+	EscapeKeys bool
+
+	// RoutingKey, if non-nil, returns the key to pick the server for a
+	// key by, in place of the key itself, so that related keys, such as
+	// those of a user, are stored on one server and fetched by GetMulti
+	// in one round trip. It is called with keys as stored, KeyPrefix
+	// included, and an empty result routes by the key. HashTag is a
+	// RoutingKey function; WithRoutingKey routes a set of operations
+	// instead.
+	RoutingKey func(key string) string
+
+	// BinaryKeys, with MetaProtocol, lets keys hold any bytes, such as
+	// raw digests: keys that aren't legal in the text protocol are sent
+	// base64-encoded with the meta commands' b flag, and returned
+	// decoded. Get, GetMulti, Set, Add, CompareAndSwap, Delete, Touch,
+	// Increment and Decrement, and the operations built on them,
+	// support binary keys; the batch, asynchronous and streaming
+	// operations reject them with ErrMalformedKey.
+	BinaryKeys bool
+
+	// Tombstones makes reads honour TombstoneFlag, so that the
+	// tombstones left by DeleteSoft read as ErrTombstoned. It must be
+	// set on every client reading keys deleted with DeleteSoft, and
+	// DeleteSoft fails without it. Otherwise the flag bit is left to
+	// the application.
+	Tombstones bool
+
+	// Invalidations, if non-nil, is the bus the client publishes the
+	// keys it deletes with Delete, DeleteMulti and DeleteSoft, and the
+	// tags it invalidates, to. Peer clients apply them with
+	// ConsumeInvalidations.
+	Invalidations InvalidationBus
+
+	// EarlyRefreshBeta, if positive, makes GetOrSet and GetOrRevalidate
+	// reload values at random shortly before they expire, so that a
+	// popular key is refreshed by a single caller rather than by all of
+	// its readers at once when it expires. Values are reloaded earlier
+	// the longer their load took and the larger EarlyRefreshBeta; 1 is a
+	// good default, as in the XFetch algorithm this implements.
+	EarlyRefreshBeta float64
+
+	// DefaultExpiration and DefaultFlags, if non-zero, are the
+	// Expiration and Flags of the items the client stores that leave
+	// them zero, so that the same constants aren't repeated at every
+	// call site. With a DefaultExpiration, an item can't be stored
+	// without expiration. Neither applies to the keys the client keeps
+	// for its own features, such as the fencing token counters of
+	// locks, tag versions and namespace epochs, which must not expire.
+	DefaultExpiration int32
+	DefaultFlags      uint32
+
+	// ExpirationJitter, if positive, moves the expiration of each item
+	// stored at random by up to this fraction of its time to live, such
+	// as 0.1 for ±10%, so that items written together don't all expire
+	// at once.
+	ExpirationJitter float64
+
+	// UpdateAttempts is the number of times Update tries to apply its
+	// function before giving up on conflicting writes from other
+	// clients. If zero, DefaultUpdateAttempts is used.
+	UpdateAttempts int
+
+	// StrictResponses makes the client validate get responses against
+	// their requests: items must be for requested keys, in request
+	// order, with values of exactly the announced length, and lines must
+	// end in CRLF. A response failing validation fails the operation
+	// with ErrProtocol and its connection is discarded, so that a
+	// desynchronized connection cannot return one key's value for
+	// another.
+	StrictResponses bool
+
+	// ReadBufferSize and WriteBufferSize are the sizes of the read and
+	// write buffers of each connection. Larger buffers mean fewer
+	// syscalls for large responses and batches, smaller ones less memory
+	// per idle connection. If zero, DefaultBufferSize is used. Changes
+	// apply to new connections.
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// ErrorKeys controls whether errors returned by the client, and the
+	// OpMetrics and WireTrace passed to hooks, carry the keys of the
+	// operation. The default, KeysOmitted, attaches none.
+	//
+	// ErrorKeys does not cover everything that may expose keys: the
+	// messages passed to Logger carry plain keys, slow operations are
+	// reported with plain keys unless HashSlowOpKeys is set, and the
+	// protocol lines of a WireTrace contain the keys as sent.
+	ErrorKeys KeyMode
+
+	// SlowOpHook, if non-nil, is called for every slow operation.
+	SlowOpHook func(SlowOp)
+
+	// WireTraceHook, if non-nil, receives the protocol lines exchanged
+	// by a sampled fraction of operations, with value bodies elided,
+	// for debugging protocol issues in production.
+	WireTraceHook func(WireTrace)
+
+	// WireTraceFraction is the fraction of operations, between 0 and 1,
+	// passed to WireTraceHook.
+	WireTraceFraction float64
+
+	// HotKeySampleRate, if positive, is the fraction of operation keys,
+	// between 0 and 1, counted to estimate the hottest keys of each
+	// HotKeyInterval, reported by HotKeys and to a Metrics recorder
+	// implementing HotKeyRecorder.
+	HotKeySampleRate float64
+
+	// HotKeyInterval is the length of the intervals hot keys are
+	// reported for. If zero, DefaultHotKeyInterval is used.
+	HotKeyInterval time.Duration
+
+	// HotKeyTopK is the number of hot keys reported per interval. If
+	// zero, DefaultHotKeyTopK is used.
+	HotKeyTopK int
+
+	// AppFlags are the bits of Item.Flags used by the application,
+	// which CheckFlags checks no codec or transcoder reserves.
+	AppFlags uint32
+
+	// Codec serializes the objects stored by SetObject and read by
+	// GetObject. If nil, JSON is used.
+	Codec Codec
+
+	// Transcoders transform values written by Set, Add, CompareAndSwap
+	// and SetMulti, in order, and values read by Get and GetMulti, in
+	// reverse order. An item that fails to decode is reported as an
+	// error; GetMulti omits it from its result.
+	Transcoders []Transcoder
+
+	// ChunkSize, if positive, makes Set, Add, CompareAndSwap and
+	// SetMulti split values larger than ChunkSize bytes, after
+	// transcoding, into chunks stored under separate keys, with a small
+	// manifest marked by ChunkedFlag stored under the item's key. Get
+	// and GetMulti reassemble chunked values of any size if ChunkSize
+	// is positive, so every client reading them must set it; clients
+	// without it leave the flag bit to the application.
+	//
+	// Chunked values are not atomic: a reader racing a writer may see
+	// the chunks of the previous value disappear and get ErrCacheMiss,
+	// and chunks outlive a deleted or overwritten manifest until they
+	// expire or are evicted. Eviction of any chunk also reads as a
+	// miss. ChunkSize should leave room for item overhead below the
+	// server's maximum item size.
+	ChunkSize int
+
+	// Interceptors wrap every Get, GetMulti, Set, Add, CompareAndSwap,
+	// Delete, Touch, Increment and Decrement call, outermost first.
+	Interceptors []Interceptor
+
+	// Metrics, if non-nil, is called for every operation sent to a
+	// server.
+	Metrics MetricsRecorder
+
+	// LatencyBuckets are the increasing upper bounds of the buckets of
+	// the per-server histograms returned by Latencies. If nil, bounds
+	// from 250µs to 1s are used. They are copied when the histogram of
+	// a server is created, on its first operation.
+	LatencyBuckets []time.Duration
+
+	// ItemVersion, if non-nil, extracts an application-defined version
+	// from an item. GetQuorum uses it to choose between replicas.
+	ItemVersion func(*Item) uint64
+
 	selector ServerSelector
 
+	// state is shared between a client and the views returned by
+	// OnServer and WithRoutingKey.
+	state *clientState
+}
+
+// clientState is the mutable state of a client.
+type clientState struct {
+	pool     connPool
+	counters counters
+	latency  latencyTracker
+	conns    connTracker
+	pipes    pipelines
+	coalesce coalescer
+	flights  flightGroup
+	loads    flightGroup
+	refresh  flightGroup
+	inflight inflightLimiter
+	hotKeys  hotKeyTracker
+
+	// noGat is set once a server has answered that it lacks gats.
+	noGat int32
+
+	// origin identifies the client in invalidation events.
+	originOnce sync.Once
+	origin     string
+}
+
+// connPool holds a client's idle connections.
+type connPool struct {
 	lk       sync.Mutex
 	freeconn map[string][]*conn
 }
@@ -183,19 +625,59 @@ type Item struct {
 
 	// Expiration is the cache expiration time, in seconds: either a relative
 	// time from now (up to 1 month), or an absolute Unix epoch time.
-	// Zero means the Item has no expiration time.
+	// Zero means the Item has no expiration time. ExpiresIn and
+	// ExpiresAt compute it from a time.Duration or time.Time.
 	Expiration int32
 
+	// Stale reports that the item has been marked stale on the server,
+	// for instance by a meta delete with the invalidate flag. It is only
+	// set when the client uses the meta protocol.
+	Stale bool
+
+	// Win reports that this client won the right to recompute and
+	// re-cache a stale item; other clients fetching it see Stale
+	// without Win until it is replaced. It is only set when the client
+	// uses the meta protocol.
+	Win bool
+
+	// Meta holds the metadata requested with GetWithOptions, and is
+	// nil for items read otherwise.
+	Meta *ItemMeta
+
 	// Compare and swap ID.
 	casid uint64
+
+	// buf is the pooled buffer holding Value, if any.
+	buf *[]byte
+
+	// lazy is the not yet copied value of an item read with LazyValues.
+	lazy []byte
+}
+
+// CasID returns the compare and swap ID of an item read by Get,
+// GetMulti or a similar call, or zero for an item that wasn't read. It
+// may be kept and set on a new item with SetCasID to make a later
+// CompareAndSwap conditional on the item read.
+func (it *Item) CasID() uint64 {
+	return it.casid
+}
+
+// SetCasID sets the compare and swap ID CompareAndSwap checks.
+func (it *Item) SetCasID(id uint64) {
+	it.casid = id
 }
 
 // conn is a connection to a server.
 type conn struct {
 	nc   net.Conn
+	cc   *countingConn
 	rw   *bufio.ReadWriter
 	addr net.Addr
 	c    *Client
+
+	// detached is set when an operation hands the connection over to
+	// its caller, who becomes responsible for releasing it.
+	detached bool
 }
 
 // release returns this connection back to the client's free pool
@@ -203,8 +685,8 @@ func (cn *conn) release() {
 	cn.c.putFreeConn(cn.addr, cn)
 }
 
-func (cn *conn) extendDeadline() {
-	cn.nc.SetDeadline(time.Now().Add(cn.c.netTimeout()))
+func (cn *conn) extendDeadline(class opClass) {
+	cn.nc.SetDeadline(time.Now().Add(cn.c.opTimeout(class)))
 }
 
 // condRelease releases this connection if the error pointed to by err
@@ -212,42 +694,68 @@ func (cn *conn) extendDeadline() {
 // cache miss).  The purpose is to not recycle TCP connections that
 // are bad.
 func (cn *conn) condRelease(err *error) {
+	if cn.detached {
+		return
+	}
 	if *err == nil || resumableError(*err) {
 		cn.release()
 	} else {
+		cn.c.logDebug("memcache: discarding connection after error", "addr", cn.addr, "err", *err)
+		switch classifyError(*err) {
+		case ErrClassNetwork:
+			cn.c.connEvent(cn.addr, ConnClosedUnexpectedly)
+		case ErrClassProtocol:
+			cn.c.connEvent(cn.addr, ConnProtocolError)
+		}
+		cn.c.connEvent(cn.addr, ConnDiscarded)
 		cn.nc.Close()
 	}
 }
 
 func (c *Client) putFreeConn(addr net.Addr, cn *conn) {
-	c.lk.Lock()
-	defer c.lk.Unlock()
-	if c.freeconn == nil {
-		c.freeconn = make(map[string][]*conn)
+	p := &c.state.pool
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.freeconn == nil {
+		p.freeconn = make(map[string][]*conn)
 	}
-	freelist := c.freeconn[addr.String()]
+	freelist := p.freeconn[addr.String()]
 	if len(freelist) >= c.maxIdleConns() {
 		cn.nc.Close()
 		return
 	}
-	c.freeconn[addr.String()] = append(freelist, cn)
+	p.freeconn[addr.String()] = append(freelist, cn)
 }
 
 func (c *Client) getFreeConn(addr net.Addr) (cn *conn, ok bool) {
-	c.lk.Lock()
-	defer c.lk.Unlock()
-	if c.freeconn == nil {
+	p := &c.state.pool
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.freeconn == nil {
 		return nil, false
 	}
-	freelist, ok := c.freeconn[addr.String()]
+	freelist, ok := p.freeconn[addr.String()]
 	if !ok || len(freelist) == 0 {
 		return nil, false
 	}
 	cn = freelist[len(freelist)-1]
-	c.freeconn[addr.String()] = freelist[:len(freelist)-1]
+	p.freeconn[addr.String()] = freelist[:len(freelist)-1]
 	return cn, true
 }
 
+// IdleConns returns the number of idle pooled connections to each
+// server, keyed by server address.
+func (c *Client) IdleConns() map[string]int {
+	p := &c.state.pool
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	idle := make(map[string]int, len(p.freeconn))
+	for addr, freelist := range p.freeconn {
+		idle[addr] = len(freelist)
+	}
+	return idle
+}
+
 func (c *Client) netTimeout() time.Duration {
 	if c.Timeout != 0 {
 		return c.Timeout
@@ -255,6 +763,45 @@ func (c *Client) netTimeout() time.Duration {
 	return DefaultTimeout
 }
 
+// opClass groups operations that share a default timeout.
+type opClass int
+
+const (
+	opRead opClass = iota
+	opWrite
+	opAdmin
+)
+
+// classOf returns the class of the operation named op.
+func classOf(op string) opClass {
+	switch op {
+	case "get", "gets", "mg":
+		return opRead
+	case "stats", "metadump":
+		return opAdmin
+	}
+	return opWrite
+}
+
+func (c *Client) opTimeout(class opClass) time.Duration {
+	var t time.Duration
+	switch class {
+	case opRead:
+		t = c.ReadTimeout
+	case opWrite:
+		t = c.WriteTimeout
+	case opAdmin:
+		t = c.AdminTimeout
+		if t == 0 && c.Timeout == 0 {
+			return DefaultAdminTimeout
+		}
+	}
+	if t != 0 {
+		return t
+	}
+	return c.netTimeout()
+}
+
 func (c *Client) maxIdleConns() int {
 	if c.MaxIdleConns > 0 {
 		return c.MaxIdleConns
@@ -291,45 +838,281 @@ func (c *Client) dial(addr net.Addr) (net.Conn, error) {
 	return nil, err
 }
 
-func (c *Client) getConn(addr net.Addr) (*conn, error) {
+func (c *Client) getConn(addr net.Addr, class opClass) (*conn, error) {
 	cn, ok := c.getFreeConn(addr)
 	if ok {
-		cn.extendDeadline()
+		cn.extendDeadline(class)
 		return cn, nil
 	}
 	nc, err := c.dial(addr)
 	if err != nil {
+		c.connEvent(addr, ConnDialFailed)
 		return nil, err
 	}
+	c.connEvent(addr, ConnDialed)
+	cc := &countingConn{Conn: nc}
 	cn = &conn{
-		nc:   nc,
+		nc:   cc,
+		cc:   cc,
 		addr: addr,
-		rw:   bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc)),
+		rw:   bufio.NewReadWriter(bufio.NewReaderSize(cc, bufferSize(c.ReadBufferSize)), bufio.NewWriterSize(cc, bufferSize(c.WriteBufferSize))),
 		c:    c,
 	}
-	cn.extendDeadline()
+	cn.extendDeadline(class)
 	return cn, nil
 }
 
-func (c *Client) onItem(item *Item, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
-	addr, err := c.selector.PickServer(item.Key)
+func bufferSize(n int) int {
+	if n <= 0 {
+		return DefaultBufferSize
+	}
+	return n
+}
+
+// store encodes item with the client's Transcoders, chunking it if
+// needed, and writes it with fn.
+func (c *Client) store(op string, item *Item, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
+	if op == "cas" && c.Replicas > 1 {
+		return ErrReplicatedCAS
+	}
+	if c.ReturnCAS && !c.MetaProtocol {
+		return ErrReturnCASWithoutMeta
+	}
+	it, err := c.prepareStore(item)
 	if err != nil {
 		return err
 	}
-	cn, err := c.getConn(addr)
+	err = c.onItem(op, it, fn)
+	if err == nil && it != item && c.returnsCAS() {
+		item.casid = it.casid
+	}
+	if op == "set" {
+		c.journal(it.Key, it, err)
+		switch {
+		case err != nil:
+		case c.tombstone(item):
+			c.L1.remove(item.Key)
+		default:
+			c.L1.store(item)
+		}
+	}
+	return err
+}
+
+// storeRaw writes item with verb, "set" or "add", as is: without the
+// defaults, Transcoders, jitter and chunking applied by store. It is
+// used for numeric counters, which the servers must be able to
+// increment, and for the client's own metadata.
+func (c *Client) storeRaw(verb string, item *Item) error {
+	it := *item
+	it.Key = c.storageKey(item.Key)
+	return c.storeAsStored(verb, &it)
+}
+
+// storeAsStored writes item with verb, "set" or "add", keeping its key,
+// flags, expiration and value exactly as given: item is as stored on
+// the servers, with its storage key and encoded value.
+func (c *Client) storeAsStored(verb string, item *Item) error {
+	fn := (*Client).set
+	if verb == "add" {
+		fn = (*Client).add
+	}
+	err := c.onItem(verb, item, fn)
+	if err == nil {
+		c.L1.remove(item.Key)
+	}
+	return err
+}
+
+// getRaw reads the item for key like Get, but without decoding it with
+// the client's Transcoders or consulting its L1 cache, for values the
+// client itself writes with storeRaw or the arithmetic commands.
+func (c *Client) getRaw(key string) (*Item, error) {
+	op := &Op{Name: "get", Keys: []string{key}}
+	err := c.intercept(op, func(ctx context.Context, op *Op) (err error) {
+		op.Item, err = c.get(op.Keys[0])
+		return err
+	})
 	if err != nil {
+		return nil, err
+	}
+	return op.Item, nil
+}
+
+// getMultiRaw is the batch version of getRaw.
+func (c *Client) getMultiRaw(keys []string) (map[string]*Item, error) {
+	op := &Op{Name: "getmulti", Keys: keys}
+	err := c.intercept(op, func(ctx context.Context, op *Op) (err error) {
+		op.Items, err = c.getMulti(op.Keys)
 		return err
+	})
+	return op.Items, err
+}
+
+func (c *Client) onItem(op string, item *Item, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
+	if c.Replicas > 1 {
+		return c.onReplicas(item.Key, func(addr net.Addr) error {
+			return c.onItemAtAddr(addr, op, item, fn)
+		})
 	}
-	defer cn.condRelease(&err)
-	if err = fn(c, cn.rw, item); err != nil {
+	addr, err := c.pickServer(item.Key)
+	if err != nil {
 		return err
 	}
-	return nil
+	return c.onItemAtAddr(addr, op, item, fn)
+}
+
+func (c *Client) onItemAtAddr(addr net.Addr, op string, item *Item, fn func(*Client, *bufio.ReadWriter, *Item) error) error {
+	return c.withAddrRw(addr, op, []string{item.Key}, func(rw *bufio.ReadWriter) error {
+		return fn(c, rw, item)
+	})
 }
 
 // Get gets the item for the given key. ErrCacheMiss is returned for a
 // memcache cache miss. The key must be at most 250 bytes in length.
+//
+// When replication is enabled, Get falls back to the key's other
+// replicas if the primary fails, and asynchronously repairs the
+// replicas that could not return the item.
 func (c *Client) Get(key string) (item *Item, err error) {
+	op := &Op{Name: "get", Keys: []string{key}}
+	err = c.intercept(op, func(ctx context.Context, op *Op) (err error) {
+		if it, ok := c.L1.get(op.Keys[0]); ok {
+			op.Item = it
+			return nil
+		}
+		gen := c.L1.generation()
+		op.Item, err = c.get(op.Keys[0])
+		if err == nil {
+			err = c.finishRead(op.Item)
+		}
+		if err == nil {
+			c.L1.add(op.Item, gen)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return op.Item, nil
+}
+
+// GetAppend gets the item for key like Get, but appends its value to
+// dst and returns the extended buffer, so that callers reusing dst avoid
+// allocating a value per call. The returned item's Value aliases the
+// appended part of the buffer. When the client has Transcoders or
+// replicas, or uses the meta protocol, the value is read as Get does and
+// then copied.
+func (c *Client) GetAppend(key string, dst []byte) ([]byte, Item, error) {
+	op := &Op{Name: "getappend", Keys: []string{key}}
+	err := c.intercept(op, func(ctx context.Context, op *Op) (err error) {
+		if len(c.Transcoders) > 0 || c.Replicas > 1 || c.MetaProtocol {
+			op.Item, err = c.get(op.Keys[0])
+			if err == nil {
+				err = c.finishRead(op.Item)
+			}
+			if err == nil {
+				start := len(dst)
+				dst = append(dst, op.Item.Value...)
+				op.Item.Value = dst[start:]
+			}
+			return err
+		}
+		op.Item, dst, err = c.getAppend(op.Keys[0], dst)
+		return err
+	})
+	if err != nil {
+		return dst, Item{}, err
+	}
+	return dst, *op.Item, nil
+}
+
+// GetInto gets the item for key into it, as Get does, reusing the
+// capacity of it.Value. Together with Item.Reset, it lets loops reuse a
+// single Item and value buffer. On error, the fields of it are left
+// unchanged, though the contents of its Value may have been overwritten.
+func (c *Client) GetInto(key string, it *Item) error {
+	buf, got, err := c.GetAppend(key, it.Value[:0])
+	if err != nil {
+		return err
+	}
+	got.Value = buf
+	if it.buf != nil && sameArray(buf, *it.buf) {
+		got.buf = it.buf
+	}
+	*it = got
+	return nil
+}
+
+// sameArray reports whether a and b share their underlying array.
+func sameArray(a, b []byte) bool {
+	return cap(a) > 0 && cap(b) > 0 && &a[:1][0] == &b[:1][0]
+}
+
+func (c *Client) getAppend(key string, dst []byte) (*Item, []byte, error) {
+	it := new(Item)
+	start := len(dst)
+	err := c.withKeyAddr(key, func(addr net.Addr) error {
+		return c.withAddrConn(addr, "get", []string{key}, func(cn *conn, m *OpMetrics) error {
+			lim := c.limits()
+			size, err := streamGet(cn, key, lim, it)
+			if err != nil {
+				return err
+			}
+			buf := dst
+			err = lim.checkSize(cn.rw.Reader, size)
+			if err == nil {
+				buf, err = appendValue(cn.rw.Reader, dst, size, c.StrictResponses)
+			}
+			if err != nil && err != ErrValueTooLarge {
+				return err
+			}
+			tooLarge := err
+			line, err := lim.readLine(cn.rw.Reader)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(line, resultEnd) {
+				return fmt.Errorf("%w: unexpected line after get value: %q", ErrProtocol, line)
+			}
+			if tooLarge != nil {
+				return tooLarge
+			}
+			m.Hits = 1
+			dst = buf
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, dst[:start], err
+	}
+	it.Key = key
+	it.Value = dst[start:]
+	if c.chunked(it) {
+		if err := c.unchunk(it); err != nil {
+			return nil, dst[:start], err
+		}
+		dst = append(dst[:start], it.Value...)
+		it.Value = dst[start:]
+	}
+	return it, dst, nil
+}
+
+func (c *Client) get(key string) (*Item, error) {
+	if c.DedupGets {
+		return c.state.flights.do(key, func() (*Item, error) { return c.fetch(key) })
+	}
+	return c.fetch(key)
+}
+
+// fetch reads the item for key from the servers.
+func (c *Client) fetch(key string) (item *Item, err error) {
+	if c.Replicas > 1 {
+		return c.getReplicated(key)
+	}
+	if c.CoalesceWindow > 0 {
+		return c.coalescedGet(key)
+	}
 	err = c.withKeyAddr(key, func(addr net.Addr) error {
 		return c.getFromAddr(addr, []string{key}, func(it *Item) { item = it })
 	})
@@ -344,10 +1127,7 @@ func (c *Client) Stats() (map[net.Addr]map[string]string, error) {
 	stats := make(map[net.Addr]map[string]string)
 	ch := make(chan error, buffered)
 	sn := 0
-	ss := c.selector.(*ServerList)
-	ss.lk.RLock()
-	defer ss.lk.RUnlock()
-	for _, addr := range ss.addrs {
+	for _, addr := range c.servers() {
 		sn += 1
 		go func(addr net.Addr) {
 			ch <- c.statsFromAddr(addr, func(stat map[string]string) {
@@ -367,11 +1147,24 @@ func (c *Client) Stats() (map[net.Addr]map[string]string, error) {
 	return stats, err
 }
 
+// servers returns a copy of the client's server addresses. It requires
+// the selector to be a *ServerList.
+func (c *Client) servers() []net.Addr {
+	sel := c.selector
+	if rs, ok := sel.(*routeSelector); ok {
+		sel = rs.base
+	}
+	ss := sel.(*ServerList)
+	ss.lk.RLock()
+	defer ss.lk.RUnlock()
+	return append([]net.Addr(nil), ss.addrs...)
+}
+
 func (c *Client) withKeyAddr(key string, fn func(net.Addr) error) (err error) {
-	if !legalKey(key) {
+	if !c.validKey(key) {
 		return ErrMalformedKey
 	}
-	addr, err := c.selector.PickServer(key)
+	addr, err := c.pickServer(key)
 	if err != nil {
 		return err
 	}
@@ -379,14 +1172,15 @@ func (c *Client) withKeyAddr(key string, fn func(net.Addr) error) (err error) {
 }
 
 func (c *Client) statsFromAddr(addr net.Addr, cb func(map[string]string)) error {
-	return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+	return c.withAddrRw(addr, "stats", nil, func(rw *bufio.ReadWriter) error {
 		if _, err := fmt.Fprintf(rw, "stats\r\n"); err != nil {
 			return err
 		}
 		if err := rw.Flush(); err != nil {
 			return err
 		}
-		line, err := rw.ReadString('\n')
+		lim := c.limits()
+		line, err := lim.readString(rw.Reader)
 		if err != nil {
 			return err
 		}
@@ -399,7 +1193,7 @@ func (c *Client) statsFromAddr(addr net.Addr, cb func(map[string]string)) error
 			if len(s) == 3 && s[0] == "STAT" {
 				stats[s[1]] = strings.TrimSpace(s[2])
 			}
-			line, err = rw.ReadString('\n')
+			line, err = lim.readString(rw.Reader)
 		}
 		if err != nil {
 			return err
@@ -409,33 +1203,199 @@ func (c *Client) statsFromAddr(addr net.Addr, cb func(map[string]string)) error
 	})
 }
 
-func (c *Client) withAddrRw(addr net.Addr, fn func(*bufio.ReadWriter) error) (err error) {
-	cn, err := c.getConn(addr)
+// KeyInfo describes a key as reported by "lru_crawler metadump".
+type KeyInfo struct {
+	// Key is the item's key.
+	Key string
+	// Expiration is the absolute Unix time at which the item expires,
+	// or -1 if it never expires.
+	Expiration int64
+	// LastAccess is the Unix time at which the item was last accessed.
+	LastAccess int64
+	// Size is the total size of the item in bytes, including overhead.
+	Size int
+}
+
+// MetaDump lists the keys stored on the server at addr using
+// "lru_crawler metadump all", calling fn for each. Iteration stops at
+// the first error returned by fn. The server must be memcached 1.4.31
+// or later with the LRU crawler enabled.
+func (c *Client) MetaDump(addr net.Addr, fn func(KeyInfo) error) error {
+	return c.withAddrConn(addr, "metadump", nil, func(cn *conn, _ *OpMetrics) error {
+		return c.metaDump(cn, fn)
+	})
+}
+
+func (c *Client) metaDump(cn *conn, fn func(KeyInfo) error) (err error) {
+	if _, err = fmt.Fprintf(cn.rw, "lru_crawler metadump all\r\n"); err != nil {
+		return err
+	}
+	if err = cn.rw.Flush(); err != nil {
+		return err
+	}
+	lim := c.limits()
+	for {
+		var line string
+		line, err = lim.readString(cn.rw.Reader)
+		if err != nil {
+			return err
+		}
+		if line == "END\r\n" {
+			return nil
+		}
+		if strings.HasPrefix(line, "ERROR") || strings.HasPrefix(line, "BUSY") ||
+			strings.HasPrefix(line, "CLIENT_ERROR") || strings.HasPrefix(line, "SERVER_ERROR") {
+			return fmt.Errorf("memcache: metadump error: %s", strings.TrimSpace(line))
+		}
+		ki, perr := parseMetaDumpLine(line)
+		if perr != nil {
+			// The connection is still in sync; skip unparseable lines.
+			continue
+		}
+		if ferr := fn(ki); ferr != nil {
+			// Drain the rest of the dump so the connection can be reused.
+			for line != "END\r\n" && err == nil {
+				line, err = lim.readString(cn.rw.Reader)
+				cn.extendDeadline(opAdmin)
+			}
+			if err != nil {
+				return err
+			}
+			return ferr
+		}
+		cn.extendDeadline(opAdmin)
+	}
+}
+
+func parseMetaDumpLine(line string) (KeyInfo, error) {
+	var ki KeyInfo
+	for _, field := range strings.Fields(line) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		var err error
+		switch kv[0] {
+		case "key":
+			ki.Key, err = url.QueryUnescape(kv[1])
+		case "exp":
+			ki.Expiration, err = strconv.ParseInt(kv[1], 10, 64)
+		case "la":
+			ki.LastAccess, err = strconv.ParseInt(kv[1], 10, 64)
+		case "size":
+			ki.Size, err = strconv.Atoi(kv[1])
+		}
+		if err != nil {
+			return ki, err
+		}
+	}
+	if ki.Key == "" {
+		return ki, fmt.Errorf("memcache: unexpected metadump line: %q", line)
+	}
+	return ki, nil
+}
+
+// withAddrConn runs the operation op against the server at addr on a
+// pooled connection, counting it in the client's counters and reporting
+// it to the client's MetricsRecorder. fn may fill in the hit and miss
+// counts of the OpMetrics it is passed.
+func (c *Client) withAddrConn(addr net.Addr, op string, keys []string, fn func(*conn, *OpMetrics) error) (err error) {
+	if c.ReadOnly && classOf(op) == opWrite {
+		return ErrReadOnly
+	}
+	if c.MaxInFlight > 0 {
+		if err := c.state.inflight.acquire(context.Background(), c.MaxInFlight, c.InFlightWait); err != nil {
+			c.logDebug("memcache: request shed", "addr", addr, "op", op)
+			return err
+		}
+		defer c.state.inflight.release()
+	}
+	m := OpMetrics{Op: op, Addr: addr, Keys: c.reportedKeys(keys)}
+	if c.Metrics != nil {
+		c.Metrics.OpStart(op, addr)
+	}
+	start := time.Now()
+	defer func() {
+		m.Duration = time.Since(start)
+		if errors.Is(err, ErrCacheMiss) && m.Hits+m.Misses == 0 {
+			m.Misses = 1
+		}
+		m.ErrClass = classifyError(err)
+		err = wrapOpError(op, addr, m.ErrClass, err)
+		m.Err = err
+		c.state.counters.record(&m)
+		c.state.latency.observe(addr, m.Duration, c.LatencyBuckets)
+		if c.SlowOpThreshold > 0 && m.Duration >= c.SlowOpThreshold {
+			c.reportSlowOp(&m, keys)
+		}
+		c.sampleHotKeys(keys)
+		if c.Metrics != nil {
+			c.Metrics.OpEnd(m)
+		}
+		if err == nil {
+			c.maybeReplayJournal()
+		}
+		if c.L1 != nil && l1WriteOps[op] {
+			c.L1.remove(keys...)
+		}
+	}()
+	cn, err := c.getConn(addr, classOf(op))
 	if err != nil {
+		c.logWarn("memcache: connect failed", "addr", addr, "op", op, "err", err)
 		return err
 	}
 	defer cn.condRelease(&err)
-	return fn(cn.rw)
+	if c.WireTraceHook != nil && c.WireTraceFraction > 0 && rand.Float64() < c.WireTraceFraction {
+		cn.cc.startTrace()
+		defer func() { c.WireTraceHook(cn.cc.stopTrace(op, addr, m.Keys, err)) }()
+	}
+	nr, nw := cn.cc.nr, cn.cc.nw
+	err = fn(cn, &m)
+	m.BytesIn, m.BytesOut = cn.cc.nr-nr, cn.cc.nw-nw
+	return err
+}
+
+func (c *Client) withAddrRw(addr net.Addr, op string, keys []string, fn func(*bufio.ReadWriter) error) error {
+	return c.withAddrConn(addr, op, keys, func(cn *conn, _ *OpMetrics) error {
+		return fn(cn.rw)
+	})
 }
 
-func (c *Client) withKeyRw(key string, fn func(*bufio.ReadWriter) error) error {
+func (c *Client) withKeyRw(key string, op string, fn func(*bufio.ReadWriter) error) error {
 	return c.withKeyAddr(key, func(addr net.Addr) error {
-		return c.withAddrRw(addr, fn)
+		return c.withAddrRw(addr, op, []string{key}, fn)
 	})
 }
 
 func (c *Client) getFromAddr(addr net.Addr, keys []string, cb func(*Item)) error {
-	return c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
-		if _, err := fmt.Fprintf(rw, "gets %s\r\n", strings.Join(keys, " ")); err != nil {
-			return err
+	if c.MetaProtocol {
+		return c.metaGetFromAddr(addr, keys, "v f c k", cb)
+	}
+	return c.withAddrConn(addr, "gets", keys, func(cn *conn, m *OpMetrics) error {
+		rw := cn.rw
+		bp := getScratch()
+		b := append(*bp, "gets"...)
+		for _, key := range keys {
+			b = append(b, ' ')
+			b = append(b, key...)
 		}
-		if err := rw.Flush(); err != nil {
+		b = append(b, crlf...)
+		_, err := rw.Write(b)
+		putScratch(bp, b)
+		if err != nil {
 			return err
 		}
-		if err := parseGetResponse(rw.Reader, cb); err != nil {
+		if err := rw.Flush(); err != nil {
 			return err
 		}
-		return nil
+		lim := c.limits()
+		lim.lazy = c.LazyValues && !c.PoolValues && len(keys) > 1
+		err = parseGetResponse(rw.Reader, lim, keys, c.StrictResponses, func(it *Item) {
+			m.Hits++
+			cb(it)
+		})
+		m.Misses = len(keys) - m.Hits
+		return err
 	})
 }
 
@@ -444,8 +1404,35 @@ func (c *Client) getFromAddr(addr net.Addr, keys []string, cb func(*Item)) error
 // cache misses. Each key must be at most 250 bytes in length.
 // If no error is returned, the returned map will also be non-nil.
 func (c *Client) GetMulti(keys []string) (map[string]*Item, error) {
+	if len(c.Interceptors) > 0 {
+		// Interceptors may rewrite op.Keys in place.
+		keys = append([]string(nil), keys...)
+	}
+	op := &Op{Name: "getmulti", Keys: keys}
+	err := c.intercept(op, func(ctx context.Context, op *Op) (err error) {
+		cached, keys := c.L1.getMulti(op.Keys)
+		gen := c.L1.generation()
+		op.Items, err = c.getMulti(keys)
+		if derr := c.finishReads(op.Items); err == nil {
+			err = derr
+		}
+		if op.Items == nil {
+			return err
+		}
+		for _, it := range op.Items {
+			c.L1.add(it, gen)
+		}
+		for key, it := range cached {
+			op.Items[key] = it
+		}
+		return err
+	})
+	return op.Items, err
+}
+
+func (c *Client) getMulti(keys []string) (map[string]*Item, error) {
 	var lk sync.Mutex
-	m := make(map[string]*Item)
+	m := make(map[string]*Item, len(keys))
 	addItemToMap := func(it *Item) {
 		lk.Lock()
 		defer lk.Unlock()
@@ -454,25 +1441,56 @@ func (c *Client) GetMulti(keys []string) (map[string]*Item, error) {
 
 	keyMap := make(map[net.Addr][]string)
 	for _, key := range keys {
-		if !legalKey(key) {
+		if !c.validKey(key) {
 			return nil, ErrMalformedKey
 		}
-		addr, err := c.selector.PickServer(key)
+		addr, err := c.pickServer(key)
 		if err != nil {
 			return nil, err
 		}
 		keyMap[addr] = append(keyMap[addr], key)
 	}
 
-	ch := make(chan error, buffered)
+	type request struct {
+		addr net.Addr
+		keys []string
+	}
+	var reqs []request
 	for addr, keys := range keyMap {
-		go func(addr net.Addr, keys []string) {
-			ch <- c.getFromAddr(addr, keys, addItemToMap)
-		}(addr, keys)
+		for len(keys) > 0 {
+			n := len(keys)
+			if c.MaxKeysPerGet > 0 && n > c.MaxKeysPerGet {
+				n = c.MaxKeysPerGet
+			}
+			reqs = append(reqs, request{addr, keys[:n]})
+			keys = keys[n:]
+		}
+	}
+
+	workers := len(reqs)
+	if c.GetMultiParallelism > 0 && workers > c.GetMultiParallelism {
+		workers = c.GetMultiParallelism
+	}
+	next := make(chan request, len(reqs))
+	for _, r := range reqs {
+		next <- r
+	}
+	close(next)
+	ch := make(chan error, buffered)
+	for i := 0; i < workers; i++ {
+		go func() {
+			var err error
+			for r := range next {
+				if ge := c.getFromAddr(r.addr, r.keys, addItemToMap); ge != nil {
+					err = ge
+				}
+			}
+			ch <- err
+		}()
 	}
 
 	var err error
-	for _ = range keyMap {
+	for i := 0; i < workers; i++ {
 		if ge := <-ch; ge != nil {
 			err = ge
 		}
@@ -481,53 +1499,227 @@ func (c *Client) GetMulti(keys []string) (map[string]*Item, error) {
 }
 
 // parseGetResponse reads a GET response from r and calls cb for each
-// read and allocated Item
-func parseGetResponse(r *bufio.Reader, cb func(*Item)) error {
+// read and allocated Item. keys are the requested keys; the returned
+// items share their key strings rather than copying them from the
+// response, and are allocated together, one slice per response. If
+// strict is set, the response is validated against keys:
+// items must be for the requested keys, in order, and every line must
+// be terminated by CRLF.
+func parseGetResponse(r *bufio.Reader, lim responseLimits, keys []string, strict bool, cb func(*Item)) error {
+	next := 0
+	var slab []Item
+	var arena valueArena
+	tooLarge := false
 	for {
-		line, err := r.ReadSlice('\n')
+		line, err := lim.readLine(r)
 		if err != nil {
 			return err
 		}
+		if strict && !bytes.HasSuffix(line, crlf) {
+			return fmt.Errorf("%w: get response line not terminated by CRLF: %q", ErrProtocol, line)
+		}
 		if bytes.Equal(line, resultEnd) {
+			if tooLarge {
+				return ErrValueTooLarge
+			}
 			return nil
 		}
-		it := new(Item)
-		size, err := scanGetResponseLine(line, it)
-		if err != nil {
+		if err := checkServerError(line); err != nil {
 			return err
 		}
-		it.Value, err = ioutil.ReadAll(io.LimitReader(r, int64(size)+2))
+		if len(slab) == 0 {
+			slab = make([]Item, len(keys)-next+1)
+		}
+		it := &slab[0]
+		slab = slab[1:]
+		key, size, err := scanValueLine(line, it)
 		if err != nil {
 			return err
 		}
-		if !bytes.HasSuffix(it.Value, crlf) {
-			return fmt.Errorf("memcache: corrupt get result read")
+		// Servers answer in request order, so the key is usually next.
+		match := next
+		for match < len(keys) && keys[match] != string(key) {
+			match++
+		}
+		if match < len(keys) {
+			it.Key = keys[match]
+			next = match + 1
+		} else if strict {
+			return fmt.Errorf("%w: unexpected key in get response", ErrProtocol)
+		} else {
+			it.Key = string(key)
+		}
+		if err := lim.checkSize(r, size); err == ErrValueTooLarge {
+			tooLarge = true
+			continue
+		} else if err != nil {
+			return err
+		}
+		if lim.lazy && size <= maxArenaValue {
+			v, err := appendValue(r, arena.alloc(size+2), size, strict)
+			if err != nil {
+				return err
+			}
+			it.lazy = v[:size:size]
+		} else if it.Value, it.buf, err = lim.readValue(r, size, strict); err != nil {
+			return err
 		}
-		it.Value = it.Value[:size]
 		cb(it)
 	}
-	panic("unreached")
 }
 
 // scanGetResponseLine populates it and returns the declared size of the item.
 // It does not read the bytes of the item.
 func scanGetResponseLine(line []byte, it *Item) (size int, err error) {
-	pattern := "VALUE %s %d %d %d\r\n"
-	dest := []interface{}{&it.Key, &it.Flags, &size, &it.casid}
-	if bytes.Count(line, space) == 3 {
-		pattern = "VALUE %s %d %d\r\n"
-		dest = dest[:3]
-	}
-	n, err := fmt.Sscanf(string(line), pattern, dest...)
-	if err != nil || n != len(dest) {
-		return -1, fmt.Errorf("memcache: unexpected line in get response: %q", line)
+	key, size, err := scanValueLine(line, it)
+	if err != nil {
+		return -1, err
 	}
+	it.Key = string(key)
 	return size, nil
 }
 
+// scanValueLine parses a "VALUE <key> <flags> <bytes> [<cas>]" line,
+// setting it's flags and CAS ID. The returned key aliases line.
+func scanValueLine(line []byte, it *Item) (key []byte, size int, err error) {
+	var f [4][]byte
+	n := 0
+	ok := bytes.HasPrefix(line, valuePrefix)
+	if ok {
+		rest := bytes.TrimSuffix(bytes.TrimSuffix(line[len(valuePrefix):], []byte("\n")), []byte("\r"))
+		for {
+			i := bytes.IndexByte(rest, ' ')
+			if i < 0 || n == len(f)-1 {
+				f[n] = rest
+				n++
+				break
+			}
+			f[n], rest = rest[:i], rest[i+1:]
+			n++
+		}
+	}
+	var flags, sz uint64
+	ok = ok && n >= 3 && len(f[0]) > 0
+	if ok {
+		flags, ok = parseUint(f[1], 32)
+	}
+	if ok {
+		sz, ok = parseUint(f[2], 31)
+	}
+	if ok && n == 4 {
+		it.casid, ok = parseUint(f[3], 64)
+	}
+	if !ok {
+		return nil, -1, fmt.Errorf("memcache: unexpected line in get response: %q", line)
+	}
+	it.Flags = uint32(flags)
+	return f[0], int(sz), nil
+}
+
+// parseUint parses b as a decimal number of at most bits bits, without
+// allocating.
+func parseUint(b []byte, bits uint) (uint64, bool) {
+	if len(b) == 0 || len(b) > 20 {
+		return 0, false
+	}
+	var n uint64
+	for _, ch := range b {
+		if ch < '0' || ch > '9' {
+			return 0, false
+		}
+		d := uint64(ch - '0')
+		if n > (1<<64-1-d)/10 {
+			return 0, false
+		}
+		n = n*10 + d
+	}
+	if bits < 64 && n >= 1<<bits {
+		return 0, false
+	}
+	return n, true
+}
+
+// scratchPool holds buffers for composing command lines.
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// maxScratch is the largest buffer returned to scratchPool, so that an
+// occasional large command doesn't pin memory.
+const maxScratch = 64 << 10
+
+func getScratch() *[]byte {
+	return scratchPool.Get().(*[]byte)
+}
+
+// putScratch returns b, which was grown from *bp, to the pool.
+func putScratch(bp *[]byte, b []byte) {
+	if cap(b) > maxScratch {
+		return
+	}
+	*bp = b[:0]
+	scratchPool.Put(bp)
+}
+
+// responseLimits bounds what the response parsers will read.
+type responseLimits struct {
+	maxLine  int
+	maxValue int
+
+	// pooled makes readValue use pooled buffers.
+	pooled bool
+
+	// drain makes the parsers skip values larger than maxValue.
+	drain bool
+
+	// lazy makes parseGetResponse read values into a valueArena.
+	lazy bool
+}
+
+func (c *Client) limits() responseLimits {
+	lim := responseLimits{maxLine: c.MaxLineLength, maxValue: c.MaxValueSize, pooled: c.PoolValues, drain: c.DrainOversizedValues}
+	if lim.maxLine <= 0 {
+		lim.maxLine = DefaultMaxLineLength
+	}
+	return lim
+}
+
+// readLine reads a response line, failing with ErrProtocol if it is
+// longer than maxLine. The line may alias r's buffer and is only valid
+// until the next read.
+func (lim responseLimits) readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		frag, err := r.ReadSlice('\n')
+		if len(line)+len(frag) > lim.maxLine {
+			return nil, fmt.Errorf("%w: response line longer than %d bytes", ErrProtocol, lim.maxLine)
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			line = append(line, frag...)
+			continue
+		case err != nil:
+			return nil, err
+		case line == nil:
+			return frag, nil
+		}
+		return append(line, frag...), nil
+	}
+}
+
+func (lim responseLimits) readString(r *bufio.Reader) (string, error) {
+	line, err := lim.readLine(r)
+	return string(line), err
+}
+
 // Set writes the given item, unconditionally.
 func (c *Client) Set(item *Item) error {
-	return c.onItem(item, (*Client).set)
+	return c.intercept(&Op{Name: "set", Keys: []string{item.Key}, Item: item}, func(ctx context.Context, op *Op) error {
+		return c.store("set", op.Item, (*Client).set)
+	})
 }
 
 func (c *Client) set(rw *bufio.ReadWriter, item *Item) error {
@@ -537,7 +1729,9 @@ func (c *Client) set(rw *bufio.ReadWriter, item *Item) error {
 // Add writes the given item, if no value already exists for its
 // key. ErrNotStored is returned if that condition is not met.
 func (c *Client) Add(item *Item) error {
-	return c.onItem(item, (*Client).add)
+	return c.intercept(&Op{Name: "add", Keys: []string{item.Key}, Item: item}, func(ctx context.Context, op *Op) error {
+		return c.store("add", op.Item, (*Client).add)
+	})
 }
 
 func (c *Client) add(rw *bufio.ReadWriter, item *Item) error {
@@ -545,14 +1739,18 @@ func (c *Client) add(rw *bufio.ReadWriter, item *Item) error {
 }
 
 // CompareAndSwap writes the given item that was previously returned
-// by Get, if the value was neither modified or evicted between the
-// Get and the CompareAndSwap calls. The item's Key should not change
+// by Get, or carries the CasID of such an item set with SetCasID, if
+// the value was neither modified or evicted between the Get and the
+// CompareAndSwap calls. The item's Key should not change
 // between calls but all other item fields may differ. ErrCASConflict
 // is returned if the value was modified in between the
 // calls. ErrNotStored is returned if the value was evicted in between
-// the calls.
+// the calls. ErrReplicatedCAS is returned if Replicas is greater than
+// one.
 func (c *Client) CompareAndSwap(item *Item) error {
-	return c.onItem(item, (*Client).cas)
+	return c.intercept(&Op{Name: "cas", Keys: []string{item.Key}, Item: item}, func(ctx context.Context, op *Op) error {
+		return c.store("cas", op.Item, (*Client).cas)
+	})
 }
 
 func (c *Client) cas(rw *bufio.ReadWriter, item *Item) error {
@@ -560,30 +1758,59 @@ func (c *Client) cas(rw *bufio.ReadWriter, item *Item) error {
 }
 
 func (c *Client) populateOne(rw *bufio.ReadWriter, verb string, item *Item) error {
+	if c.binaryKey(item.Key) || c.returnsCAS() && legalKey(item.Key) {
+		return c.metaStore(rw, verb, item)
+	}
 	if !legalKey(item.Key) {
 		return ErrMalformedKey
 	}
-	var err error
+	if err := writeStore(rw.Writer, verb, item); err != nil {
+		return err
+	}
+	return readStoreResponse(rw, verb)
+}
+
+// writeStore writes the storage command verb for item to w, without
+// flushing it.
+func writeStore(w *bufio.Writer, verb string, item *Item) error {
+	bp := getScratch()
+	b := append(*bp, verb...)
+	b = append(b, ' ')
+	b = append(b, item.Key...)
+	b = append(b, ' ')
+	b = strconv.AppendUint(b, uint64(item.Flags), 10)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(item.Expiration), 10)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(len(item.Value)), 10)
 	if verb == "cas" {
-		_, err = fmt.Fprintf(rw, "%s %s %d %d %d %d\r\n",
-			verb, item.Key, item.Flags, item.Expiration, len(item.Value), item.casid)
-	} else {
-		_, err = fmt.Fprintf(rw, "%s %s %d %d %d\r\n",
-			verb, item.Key, item.Flags, item.Expiration, len(item.Value))
+		b = append(b, ' ')
+		b = strconv.AppendUint(b, item.casid, 10)
 	}
+	b = append(b, crlf...)
+	_, err := w.Write(b)
+	putScratch(bp, b)
 	if err != nil {
 		return err
 	}
-	if _, err = rw.Write(item.Value); err != nil {
-		return err
-	}
-	if _, err := rw.Write(crlf); err != nil {
+	if _, err = w.Write(item.Value); err != nil {
 		return err
 	}
+	_, err = w.Write(crlf)
+	return err
+}
+
+// readStoreResponse flushes a storage command and reads its response.
+func readStoreResponse(rw *bufio.ReadWriter, verb string) error {
 	if err := rw.Flush(); err != nil {
 		return err
 	}
-	line, err := rw.ReadSlice('\n')
+	return readStoreLine(rw.Reader, verb)
+}
+
+// readStoreLine reads the response to the storage command verb.
+func readStoreLine(r *bufio.Reader, verb string) error {
+	line, err := r.ReadSlice('\n')
 	if err != nil {
 		return err
 	}
@@ -597,6 +1824,9 @@ func (c *Client) populateOne(rw *bufio.ReadWriter, verb string, item *Item) erro
 	case bytes.Equal(line, resultNotFound):
 		return ErrCacheMiss
 	}
+	if err := checkServerError(line); err != nil {
+		return err
+	}
 	return fmt.Errorf("memcache: unexpected response line from %q: %q", verb, string(line))
 }
 
@@ -617,6 +1847,20 @@ func writeExpectf(rw *bufio.ReadWriter, expect []byte, format string, args ...in
 	if err != nil {
 		return err
 	}
+	return matchLine(line, expect)
+}
+
+// readExpect reads a response line from r and checks it is expect.
+func readExpect(r *bufio.Reader, expect []byte) error {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return err
+	}
+	return matchLine(line, expect)
+}
+
+// matchLine returns nil if line is expect, or the error it reports.
+func matchLine(line, expect []byte) error {
 	switch {
 	case bytes.Equal(line, expect):
 		return nil
@@ -627,24 +1871,83 @@ func writeExpectf(rw *bufio.ReadWriter, expect []byte, format string, args ...in
 	case bytes.Equal(line, resultNotFound):
 		return ErrCacheMiss
 	}
+	if err := checkServerError(line); err != nil {
+		return err
+	}
 	return fmt.Errorf("memcache: unexpected response line: %q", string(line))
 }
 
 // Delete deletes the item with the provided key. The error ErrCacheMiss is
 // returned if the item didn't already exist in the cache.
 func (c *Client) Delete(key string) error {
-	return c.withKeyRw(key, func(rw *bufio.ReadWriter) error {
-		return writeExpectf(rw, resultDeleted, "delete %s\r\n", key)
+	return c.intercept(&Op{Name: "delete", Keys: []string{key}}, func(ctx context.Context, op *Op) error {
+		err := c.delete(op.Keys[0])
+		if err == nil || errors.Is(err, ErrCacheMiss) {
+			c.publishInvalidation(InvalidationEvent{Key: op.Keys[0]})
+		}
+		return err
 	})
 }
 
+// DeleteExisting deletes the item with the provided key and reports
+// whether it existed. Unlike Delete, a missing key is not an error, so
+// callers can tell an effective invalidation from a no-op.
+func (c *Client) DeleteExisting(key string) (existed bool, err error) {
+	err = c.Delete(key)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrCacheMiss):
+		return false, nil
+	}
+	return false, err
+}
+
+func (c *Client) delete(key string) error {
+	err := c.withKeyWriteRw(key, "delete", func(rw *bufio.ReadWriter) error {
+		return c.deleteRw(rw, key)
+	})
+	c.journal(key, nil, err)
+	return err
+}
+
+func (c *Client) deleteRw(rw *bufio.ReadWriter, key string) error {
+	if c.binaryKey(key) {
+		return c.metaDelete(rw, key)
+	}
+	return writeExpectf(rw, resultDeleted, "delete %s\r\n", key)
+}
+
+// Touch updates the expiry for the given key. The seconds parameter is
+// either a Unix timestamp or, if seconds is less than 1 month, the
+// number of seconds into the future at which time the item will
+// expire. ErrCacheMiss is returned if the key is not in the cache.
+func (c *Client) Touch(key string, seconds int32) error {
+	return c.intercept(&Op{Name: "touch", Keys: []string{key}}, func(ctx context.Context, op *Op) error {
+		return c.withKeyWriteRw(op.Keys[0], "touch", func(rw *bufio.ReadWriter) error {
+			return c.touchRw(rw, op.Keys[0], seconds)
+		})
+	})
+}
+
+func (c *Client) touchRw(rw *bufio.ReadWriter, key string, seconds int32) error {
+	if c.binaryKey(key) {
+		return c.metaTouch(rw, key, seconds)
+	}
+	return writeExpectf(rw, resultTouched, "touch %s %d\r\n", key, seconds)
+}
+
 // Increment atomically increments key by delta. The return value is
 // the new value after being incremented or an error. If the value
 // didn't exist in memcached the error is ErrCacheMiss. The value in
 // memcached must be an decimal number, or an error will be returned.
-// On 64-bit overflow, the new value wraps around.
+// On 64-bit overflow, the new value wraps around; with ErrorOnOverflow
+// set, ErrOverflow is returned along with it.
+//
+// When replication is enabled, the value reported by the first
+// replica to respond is returned.
 func (c *Client) Increment(key string, delta uint64) (newValue uint64, err error) {
-	return c.incrDecr("incr", key, delta)
+	return c.interceptIncrDecr("incr", key, delta)
 }
 
 // Decrement atomically decrements key by delta. The return value is
@@ -652,12 +1955,27 @@ func (c *Client) Increment(key string, delta uint64) (newValue uint64, err error
 // didn't exist in memcached the error is ErrCacheMiss. The value in
 // memcached must be an decimal number, or an error will be returned.
 // On underflow, the new value is capped at zero and does not wrap
-// around.
+// around, unless ErrorOnUnderflow is set.
+//
+// When replication is enabled, the value reported by the first
+// replica to respond is returned.
 func (c *Client) Decrement(key string, delta uint64) (newValue uint64, err error) {
-	return c.incrDecr("decr", key, delta)
+	return c.interceptIncrDecr("decr", key, delta)
+}
+
+func (c *Client) interceptIncrDecr(verb, key string, delta uint64) (uint64, error) {
+	op := &Op{Name: verb, Keys: []string{key}, Delta: delta}
+	err := c.intercept(op, func(ctx context.Context, op *Op) (err error) {
+		op.Value, err = c.incrDecr(op.Name, op.Keys[0], op.Delta)
+		return err
+	})
+	return op.Value, err
 }
 
 func (c *Client) _incrDecr(rw *bufio.ReadWriter, verb, key string, delta uint64) (uint64, error) {
+	if c.binaryKey(key) {
+		return c.metaIncrDecr(rw, verb, key, delta)
+	}
 	var val uint64
 	line, err := writeReadLine(rw, "%s %s %d\r\n", verb, key, delta)
 	if err != nil {
@@ -670,6 +1988,9 @@ func (c *Client) _incrDecr(rw *bufio.ReadWriter, verb, key string, delta uint64)
 		errMsg := line[len(resultClientErrorPrefix) : len(line)-2]
 		return 0, errors.New("memcache: client error: " + string(errMsg))
 	}
+	if err := checkServerError(line); err != nil {
+		return 0, err
+	}
 	val, err = strconv.ParseUint(string(line[:len(line)-2]), 10, 64)
 	if err != nil {
 		return 0, err
@@ -678,12 +1999,28 @@ func (c *Client) _incrDecr(rw *bufio.ReadWriter, verb, key string, delta uint64)
 }
 
 func (c *Client) incrDecr(verb, key string, delta uint64) (uint64, error) {
-	var val uint64
-	var err error
-	err = c.withKeyRw(key, func(rw *bufio.ReadWriter) error {
-		var err error
-		val, err = c._incrDecr(rw, verb, key, delta)
-		return err
+	if verb == "decr" && c.ErrorOnUnderflow {
+		return c.checkedDecr(key, delta)
+	}
+	var (
+		mu  sync.Mutex
+		val uint64
+		got bool
+	)
+	err := c.withKeyWriteRw(key, verb, func(rw *bufio.ReadWriter) error {
+		v, err := c._incrDecr(rw, verb, key, delta)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !got {
+			val, got = v, true
+		}
+		return nil
 	})
+	if err == nil && verb == "incr" {
+		err = c.checkOverflow(val, delta)
+	}
 	return val, err
 }
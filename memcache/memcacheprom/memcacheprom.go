@@ -0,0 +1,140 @@
+// Package memcacheprom exports memcache client metrics to Prometheus.
+// Unlike package memcache, it depends on
+// github.com/prometheus/client_golang, which programs importing it must
+// require.
+//
+// Typical use:
+//
+//	mc := memcache.New("10.0.0.1:11211", "10.0.0.2:11211")
+//	prometheus.MustRegister(memcacheprom.New(mc, "myapp"))
+package memcacheprom
+
+import (
+	"errors"
+	"net"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector for a memcache.Client. It is fed
+// by the client through the memcache.MetricsRecorder interface.
+type Collector struct {
+	client *memcache.Client
+
+	ops      *prometheus.CounterVec
+	keys     *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	bytes    *prometheus.CounterVec
+	conns    *prometheus.CounterVec
+	idleDesc *prometheus.Desc
+}
+
+// New returns a Collector for c and installs it as c's MetricsRecorder,
+// replacing any recorder already set. Metric names are prefixed with
+// namespace, which may be empty.
+func New(c *memcache.Client, namespace string) *Collector {
+	col := &Collector{
+		client: c,
+		ops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "memcache",
+			Name:      "operations_total",
+			Help:      "Operations sent to memcache servers, by command and result.",
+		}, []string{"op", "result", "server"}),
+		keys: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "memcache",
+			Name:      "keys_total",
+			Help:      "Keys looked up, by whether they were found.",
+		}, []string{"result", "server"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "memcache",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of operations sent to memcache servers.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"op", "server"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "memcache",
+			Name:      "bytes_total",
+			Help:      "Bytes transferred to and from memcache servers.",
+		}, []string{"direction", "server"}),
+		conns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "memcache",
+			Name:      "connection_events_total",
+			Help:      "Connection lifecycle events, such as dials and discards, per server.",
+		}, []string{"event", "server"}),
+		idleDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "memcache", "idle_connections"),
+			"Idle pooled connections per server.",
+			[]string{"server"}, nil),
+	}
+	c.Metrics = col
+	return col
+}
+
+// Describe implements prometheus.Collector.
+func (col *Collector) Describe(ch chan<- *prometheus.Desc) {
+	col.ops.Describe(ch)
+	col.keys.Describe(ch)
+	col.latency.Describe(ch)
+	col.bytes.Describe(ch)
+	col.conns.Describe(ch)
+	ch <- col.idleDesc
+}
+
+// Collect implements prometheus.Collector.
+func (col *Collector) Collect(ch chan<- prometheus.Metric) {
+	col.ops.Collect(ch)
+	col.keys.Collect(ch)
+	col.latency.Collect(ch)
+	col.bytes.Collect(ch)
+	col.conns.Collect(ch)
+	for server, n := range col.client.IdleConns() {
+		ch <- prometheus.MustNewConstMetric(col.idleDesc, prometheus.GaugeValue, float64(n), server)
+	}
+}
+
+// OpStart implements memcache.MetricsRecorder.
+func (col *Collector) OpStart(op string, addr net.Addr) {}
+
+// OpEnd implements memcache.MetricsRecorder.
+func (col *Collector) OpEnd(m memcache.OpMetrics) {
+	server := m.Addr.String()
+	col.ops.WithLabelValues(m.Op, result(m), server).Inc()
+	col.latency.WithLabelValues(m.Op, server).Observe(m.Duration.Seconds())
+	col.bytes.WithLabelValues("in", server).Add(float64(m.BytesIn))
+	col.bytes.WithLabelValues("out", server).Add(float64(m.BytesOut))
+	if m.Hits > 0 {
+		col.keys.WithLabelValues("hit", server).Add(float64(m.Hits))
+	}
+	if m.Misses > 0 {
+		col.keys.WithLabelValues("miss", server).Add(float64(m.Misses))
+	}
+}
+
+// ConnEvent implements memcache.ConnEventRecorder.
+func (col *Collector) ConnEvent(addr net.Addr, ev memcache.ConnEvent) {
+	col.conns.WithLabelValues(string(ev), addr.String()).Inc()
+}
+
+// result returns the value of the result label for m.
+func result(m memcache.OpMetrics) string {
+	if m.ErrClass != memcache.ErrClassNone {
+		return string(m.ErrClass)
+	}
+	switch {
+	case m.Err == nil:
+		return "ok"
+	case errors.Is(m.Err, memcache.ErrCacheMiss):
+		return "miss"
+	case errors.Is(m.Err, memcache.ErrNotStored):
+		return "not_stored"
+	case errors.Is(m.Err, memcache.ErrCASConflict):
+		return "cas_conflict"
+	}
+	return "error"
+}
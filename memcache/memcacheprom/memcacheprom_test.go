@@ -0,0 +1,64 @@
+package memcacheprom
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	c := memcache.New()
+	col := New(c, "test")
+	if c.Metrics != col {
+		t.Fatal("New did not install the collector as the client's MetricsRecorder")
+	}
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11211}
+	server := addr.String()
+
+	col.OpEnd(memcache.OpMetrics{Op: "gets", Addr: addr, Duration: time.Millisecond, BytesIn: 10, BytesOut: 5, Hits: 1, Misses: 2})
+	col.OpEnd(memcache.OpMetrics{Op: "set", Addr: addr, Err: memcache.ErrNotStored})
+	col.ConnEvent(addr, memcache.ConnDialed)
+
+	for _, tc := range []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"gets ok", testutil.ToFloat64(col.ops.WithLabelValues("gets", "ok", server)), 1},
+		{"set not_stored", testutil.ToFloat64(col.ops.WithLabelValues("set", "not_stored", server)), 1},
+		{"hits", testutil.ToFloat64(col.keys.WithLabelValues("hit", server)), 1},
+		{"misses", testutil.ToFloat64(col.keys.WithLabelValues("miss", server)), 2},
+		{"bytes in", testutil.ToFloat64(col.bytes.WithLabelValues("in", server)), 10},
+		{"bytes out", testutil.ToFloat64(col.bytes.WithLabelValues("out", server)), 5},
+		{"dials", testutil.ToFloat64(col.conns.WithLabelValues("dial", server)), 1},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+	if n := testutil.CollectAndCount(col, "test_memcache_operations_total"); n != 2 {
+		t.Errorf("collected %d operation series, want 2", n)
+	}
+}
+
+func TestResult(t *testing.T) {
+	for _, tc := range []struct {
+		m    memcache.OpMetrics
+		want string
+	}{
+		{memcache.OpMetrics{}, "ok"},
+		{memcache.OpMetrics{Err: memcache.ErrCacheMiss}, "miss"},
+		{memcache.OpMetrics{Err: fmt.Errorf("wrapped: %w", memcache.ErrNotStored)}, "not_stored"},
+		{memcache.OpMetrics{Err: memcache.ErrCASConflict}, "cas_conflict"},
+		{memcache.OpMetrics{Err: memcache.ErrTimeout, ErrClass: memcache.ErrClassTimeout}, string(memcache.ErrClassTimeout)},
+		{memcache.OpMetrics{Err: fmt.Errorf("boom")}, "error"},
+	} {
+		if got := result(tc.m); got != tc.want {
+			t.Errorf("result(%v) = %q, want %q", tc.m.Err, got, tc.want)
+		}
+	}
+}
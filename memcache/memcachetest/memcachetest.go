@@ -0,0 +1,378 @@
+// Package memcachetest provides an in-memory memcache client for unit
+// tests of code using memcache.MemcacheClient, so that they don't need
+// a running memcached.
+//
+// Typical use:
+//
+//	mc := memcachetest.New()
+//	svc := NewService(mc) // takes a memcache.MemcacheClient
+//	...
+//	mc.Advance(time.Hour) // expire items stored for an hour
+//
+// For tests that need the real client's code path, or the wire
+// protocol itself, NewServer starts a fake memcached on a local port:
+//
+//	srv := memcachetest.NewServer()
+//	defer srv.Close()
+//	mc := memcache.New(srv.Addr())
+package memcachetest
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// maxKeyLength is the largest key memcached accepts.
+const maxKeyLength = 250
+
+// errNonNumeric is returned by Increment and Decrement for a value that
+// isn't a decimal number, as the real client does.
+var errNonNumeric = errors.New("memcache: client error: cannot increment or decrement non-numeric value")
+
+// Client is an in-memory memcache.MemcacheClient. It follows the
+// semantics of memcached: expirations, relative or absolute, CAS IDs
+// that change on every write, flags, the conditions of Add, Replace
+// and CompareAndSwap, and the limits of Increment and Decrement.
+// Items are never evicted.
+//
+// Time is the real time plus the offset set by Advance.
+//
+// A Client is safe for concurrent use.
+type Client struct {
+	mu     sync.Mutex
+	items  map[string]*entry
+	cas    uint64
+	offset time.Duration
+}
+
+type entry struct {
+	value   []byte
+	flags   uint32
+	cas     uint64
+	expires time.Time // zero if the item doesn't expire
+
+	// fetched and accessed record reads of the item.
+	fetched  bool
+	accessed time.Time
+
+	// stale is set by a meta delete with the invalidate flag, and won
+	// once a reader has been told to recompute the item. vivified is
+	// set for an empty item created by a meta get with the N flag.
+	stale, won, vivified bool
+}
+
+var _ memcache.MemcacheClient = (*Client)(nil)
+
+// New returns an empty Client.
+func New() *Client {
+	return &Client{items: make(map[string]*entry)}
+}
+
+// Advance moves the client's clock forward by d, expiring the items
+// whose expiration time it passes.
+func (c *Client) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset += d
+}
+
+// Len returns the number of unexpired items.
+func (c *Client) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key := range c.items {
+		if c.lookup(key) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// FlushAll removes all items.
+func (c *Client) FlushAll() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*entry)
+	return nil
+}
+
+func (c *Client) Get(key string) (*memcache.Item, error) {
+	if !legalKey(key) {
+		return nil, memcache.ErrMalformedKey
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.lookup(key)
+	if e == nil {
+		return nil, memcache.ErrCacheMiss
+	}
+	return c.read(key, e), nil
+}
+
+func (c *Client) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	for _, key := range keys {
+		if !legalKey(key) {
+			return nil, memcache.ErrMalformedKey
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := make(map[string]*memcache.Item)
+	for _, key := range keys {
+		if e := c.lookup(key); e != nil {
+			m[key] = c.read(key, e)
+		}
+	}
+	return m, nil
+}
+
+// GetAndTouch gets the item for key and sets its expiration, as
+// memcache.Client's GetAndTouch does.
+func (c *Client) GetAndTouch(key string, seconds int32) (*memcache.Item, error) {
+	if !legalKey(key) {
+		return nil, memcache.ErrMalformedKey
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.lookup(key)
+	if e == nil {
+		return nil, memcache.ErrCacheMiss
+	}
+	it := c.read(key, e)
+	c.touch(key, e, seconds)
+	return it, nil
+}
+
+func (c *Client) Set(item *memcache.Item) error {
+	return c.store(item, func(*entry) error { return nil })
+}
+
+func (c *Client) Add(item *memcache.Item) error {
+	return c.store(item, func(e *entry) error {
+		if e != nil {
+			return memcache.ErrNotStored
+		}
+		return nil
+	})
+}
+
+// Replace writes item only if a value already exists for its key.
+// ErrNotStored is returned otherwise.
+func (c *Client) Replace(item *memcache.Item) error {
+	return c.store(item, func(e *entry) error {
+		if e == nil {
+			return memcache.ErrNotStored
+		}
+		return nil
+	})
+}
+
+// CompareAndSwap writes item if the CAS ID of the stored value is still
+// item.CasID(). It returns ErrCASConflict if the value was modified
+// since, and ErrCacheMiss if it was deleted or expired, as memcached
+// answers NOT_FOUND.
+func (c *Client) CompareAndSwap(item *memcache.Item) error {
+	return c.store(item, func(e *entry) error {
+		switch {
+		case e == nil:
+			return memcache.ErrCacheMiss
+		case e.cas != item.CasID():
+			return memcache.ErrCASConflict
+		}
+		return nil
+	})
+}
+
+// store writes item if check, called with the current entry for its
+// key or nil, returns nil.
+func (c *Client) store(item *memcache.Item, check func(*entry) error) error {
+	if !legalKey(item.Key) {
+		return memcache.ErrMalformedKey
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := check(c.lookup(item.Key)); err != nil {
+		return err
+	}
+	c.put(item.Key, append([]byte(nil), item.Value...), item.Flags, item.Expiration)
+	return nil
+}
+
+// put stores value under key and returns the entry, or nil if exp is
+// already past. c.mu must be held.
+func (c *Client) put(key string, value []byte, flags uint32, exp int32) *entry {
+	expires, ok := c.expiry(exp)
+	if !ok {
+		delete(c.items, key)
+		return nil
+	}
+	c.cas++
+	e := &entry{value: value, flags: flags, cas: c.cas, expires: expires}
+	c.items[key] = e
+	return e
+}
+
+func (c *Client) Delete(key string) error {
+	if !legalKey(key) {
+		return memcache.ErrMalformedKey
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lookup(key) == nil {
+		return memcache.ErrCacheMiss
+	}
+	delete(c.items, key)
+	return nil
+}
+
+// Touch sets the expiration of the item for key.
+func (c *Client) Touch(key string, seconds int32) error {
+	if !legalKey(key) {
+		return memcache.ErrMalformedKey
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.lookup(key)
+	if e == nil {
+		return memcache.ErrCacheMiss
+	}
+	c.touch(key, e, seconds)
+	return nil
+}
+
+// Increment adds delta to the value of key, wrapping around on 64-bit
+// overflow.
+func (c *Client) Increment(key string, delta uint64) (uint64, error) {
+	return c.arith(key, false, delta)
+}
+
+// Decrement subtracts delta from the value of key, stopping at zero.
+func (c *Client) Decrement(key string, delta uint64) (uint64, error) {
+	return c.arith(key, true, delta)
+}
+
+func (c *Client) arith(key string, decr bool, delta uint64) (uint64, error) {
+	if !legalKey(key) {
+		return 0, memcache.ErrMalformedKey
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.lookup(key)
+	if e == nil {
+		return 0, memcache.ErrCacheMiss
+	}
+	n, ok := c.apply(e, decr, delta)
+	if !ok {
+		return 0, errNonNumeric
+	}
+	return n, nil
+}
+
+// apply increments or decrements the value of e by delta, reporting
+// false if it isn't a decimal number. c.mu must be held.
+func (c *Client) apply(e *entry, decr bool, delta uint64) (uint64, bool) {
+	n, err := strconv.ParseUint(string(e.value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case !decr:
+		n += delta
+	case delta > n:
+		n = 0
+	default:
+		n -= delta
+	}
+	c.cas++
+	e.value, e.cas = strconv.AppendUint(nil, n, 10), c.cas
+	return n, true
+}
+
+// Stats returns the number of items as curr_items of a single server,
+// named "memcachetest".
+func (c *Client) Stats() (map[net.Addr]map[string]string, error) {
+	n := c.Len()
+	return map[net.Addr]map[string]string{
+		addr{}: {"curr_items": strconv.Itoa(n)},
+	}, nil
+}
+
+// now returns the client's current time. c.mu must be held.
+func (c *Client) now() time.Time {
+	return time.Now().Add(c.offset)
+}
+
+// lookup returns the unexpired entry for key, or nil, removing it if
+// it expired. c.mu must be held.
+func (c *Client) lookup(key string) *entry {
+	e, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	if !e.expires.IsZero() && !c.now().Before(e.expires) {
+		delete(c.items, key)
+		return nil
+	}
+	return e
+}
+
+// touch sets the expiration of e, the entry for key. c.mu must be held.
+func (c *Client) touch(key string, e *entry, seconds int32) {
+	expires, ok := c.expiry(seconds)
+	if !ok {
+		delete(c.items, key)
+		return
+	}
+	e.expires = expires
+}
+
+// expiry converts an Item.Expiration to the time the item expires,
+// zero for none, and reports false if it is already expired. c.mu must
+// be held.
+func (c *Client) expiry(exp int32) (time.Time, bool) {
+	now := c.now()
+	switch {
+	case exp == 0:
+		return time.Time{}, true
+	case exp < 0:
+		return time.Time{}, false
+	case exp <= memcache.MaxRelativeExpiration:
+		return now.Add(time.Duration(exp) * time.Second), true
+	}
+	t := time.Unix(int64(exp), 0)
+	return t, now.Before(t)
+}
+
+// read returns the item held by e, the entry for key, recording the
+// access. c.mu must be held.
+func (c *Client) read(key string, e *entry) *memcache.Item {
+	e.fetched, e.accessed = true, c.now()
+	it := &memcache.Item{Key: key, Value: append([]byte(nil), e.value...), Flags: e.flags}
+	it.SetCasID(e.cas)
+	return it
+}
+
+// legalKey reports whether the real client accepts key.
+func legalKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// addr is the net.Addr of the client's single pretend server.
+type addr struct{}
+
+func (addr) Network() string { return "memcachetest" }
+func (addr) String() string  { return "memcachetest" }
@@ -0,0 +1,158 @@
+package memcachetest
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestGetSetDelete(t *testing.T) {
+	c := New()
+	if _, err := c.Get("foo"); err != memcache.ErrCacheMiss {
+		t.Errorf("Get(missing) = %v, want ErrCacheMiss", err)
+	}
+	value := []byte("fooval")
+	if err := c.Set(&memcache.Item{Key: "foo", Value: value, Flags: 7}); err != nil {
+		t.Fatal(err)
+	}
+	value[0] = 'X'
+	it, err := c.Get("foo")
+	if err != nil || string(it.Value) != "fooval" || it.Flags != 7 || it.CasID() == 0 {
+		t.Errorf("Get(foo) = %+v, %v", it, err)
+	}
+	items, err := c.GetMulti([]string{"foo", "bar"})
+	if err != nil || len(items) != 1 || string(items["foo"].Value) != "fooval" {
+		t.Errorf("GetMulti = %v, %v", items, err)
+	}
+	if err := c.Delete("foo"); err != nil {
+		t.Errorf("Delete(foo) = %v", err)
+	}
+	if err := c.Delete("foo"); err != memcache.ErrCacheMiss {
+		t.Errorf("second Delete(foo) = %v, want ErrCacheMiss", err)
+	}
+	if err := c.Set(&memcache.Item{Key: "bad key"}); err != memcache.ErrMalformedKey {
+		t.Errorf("Set(bad key) = %v, want ErrMalformedKey", err)
+	}
+}
+
+func TestAddReplace(t *testing.T) {
+	c := New()
+	if err := c.Replace(&memcache.Item{Key: "foo", Value: []byte("v")}); err != memcache.ErrNotStored {
+		t.Errorf("Replace(missing) = %v, want ErrNotStored", err)
+	}
+	if err := c.Add(&memcache.Item{Key: "foo", Value: []byte("v1")}); err != nil {
+		t.Errorf("Add(missing) = %v", err)
+	}
+	if err := c.Add(&memcache.Item{Key: "foo", Value: []byte("v2")}); err != memcache.ErrNotStored {
+		t.Errorf("Add(existing) = %v, want ErrNotStored", err)
+	}
+	if err := c.Replace(&memcache.Item{Key: "foo", Value: []byte("v3")}); err != nil {
+		t.Errorf("Replace(existing) = %v", err)
+	}
+	if it, _ := c.Get("foo"); string(it.Value) != "v3" {
+		t.Errorf("value = %q, want v3", it.Value)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	c := New()
+	c.Set(&memcache.Item{Key: "foo", Value: []byte("v1")})
+	it, _ := c.Get("foo")
+	other, _ := c.Get("foo")
+
+	it.Value = []byte("v2")
+	if err := c.CompareAndSwap(it); err != nil {
+		t.Fatalf("CompareAndSwap = %v", err)
+	}
+	other.Value = []byte("v3")
+	if err := c.CompareAndSwap(other); err != memcache.ErrCASConflict {
+		t.Errorf("CompareAndSwap with stale CAS ID = %v, want ErrCASConflict", err)
+	}
+	c.Delete("foo")
+	if err := c.CompareAndSwap(it); err != memcache.ErrCacheMiss {
+		t.Errorf("CompareAndSwap of deleted item = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	c := New()
+	c.Set(&memcache.Item{Key: "short", Value: []byte("v"), Expiration: 60})
+	c.Set(&memcache.Item{Key: "forever", Value: []byte("v")})
+	abs := int32(time.Now().Add(40 * 24 * time.Hour).Unix())
+	c.Set(&memcache.Item{Key: "abs", Value: []byte("v"), Expiration: abs})
+	c.Set(&memcache.Item{Key: "expired", Value: []byte("v"), Expiration: -1})
+
+	if _, err := c.Get("expired"); err != memcache.ErrCacheMiss {
+		t.Errorf("Get(expired) = %v, want ErrCacheMiss", err)
+	}
+	c.Advance(59 * time.Second)
+	if _, err := c.GetAndTouch("short", 120); err != nil {
+		t.Errorf("GetAndTouch(short) = %v", err)
+	}
+	c.Advance(119 * time.Second)
+	if _, err := c.Get("short"); err != nil {
+		t.Errorf("Get(short) after touch = %v", err)
+	}
+	c.Advance(time.Second)
+	if _, err := c.Get("short"); err != memcache.ErrCacheMiss {
+		t.Errorf("Get(short) after expiry = %v, want ErrCacheMiss", err)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+	c.Advance(40 * 24 * time.Hour)
+	if _, err := c.Get("abs"); err != memcache.ErrCacheMiss {
+		t.Errorf("Get(abs) after its Unix time = %v, want ErrCacheMiss", err)
+	}
+	if _, err := c.Get("forever"); err != nil {
+		t.Errorf("Get(forever) = %v", err)
+	}
+}
+
+func TestIncrDecr(t *testing.T) {
+	c := New()
+	if _, err := c.Increment("n", 1); err != memcache.ErrCacheMiss {
+		t.Errorf("Increment(missing) = %v, want ErrCacheMiss", err)
+	}
+	c.Set(&memcache.Item{Key: "n", Value: []byte("10"), Flags: 3})
+	before, _ := c.Get("n")
+	if v, err := c.Increment("n", 5); v != 15 || err != nil {
+		t.Errorf("Increment = %d, %v; want 15", v, err)
+	}
+	if v, err := c.Decrement("n", 20); v != 0 || err != nil {
+		t.Errorf("Decrement below zero = %d, %v; want 0", v, err)
+	}
+	it, _ := c.Get("n")
+	if it.Flags != 3 || it.CasID() == before.CasID() {
+		t.Errorf("after incr/decr: %+v, want flags kept and a new CAS ID", it)
+	}
+	c.Set(&memcache.Item{Key: "n", Value: []byte(strconv.FormatUint(math.MaxUint64, 10))})
+	if v, err := c.Increment("n", 2); v != 1 || err != nil {
+		t.Errorf("wrapping Increment = %d, %v; want 1", v, err)
+	}
+	c.Set(&memcache.Item{Key: "s", Value: []byte("abc")})
+	if _, err := c.Increment("s", 1); err == nil {
+		t.Error("Increment of non-numeric value succeeded")
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := New()
+	c.Set(&memcache.Item{Key: "foo", Value: []byte("v")})
+	stats, err := c.Stats()
+	if err != nil || len(stats) != 1 {
+		t.Fatalf("Stats = %v, %v", stats, err)
+	}
+	for _, s := range stats {
+		if s["curr_items"] != "1" {
+			t.Errorf("curr_items = %q, want 1", s["curr_items"])
+		}
+	}
+	c.FlushAll()
+	if c.Len() != 0 {
+		t.Errorf("Len after FlushAll = %d", c.Len())
+	}
+}
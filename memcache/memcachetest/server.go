@@ -0,0 +1,729 @@
+package memcachetest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxValueSize is the largest value the server accepts, memcached's
+// default item size limit.
+const maxValueSize = 1 << 20
+
+// Server is a memcached server on a local port, holding its items in
+// memory, for tests that exercise the real client, or other code
+// speaking the memcached protocol, without the memcached binary. It
+// speaks the text protocol's storage, retrieval, arithmetic, touch,
+// delete, flush_all, stats, version and lru_crawler metadump commands,
+// and the meta protocol's mg, ms, md, ma and mn commands with their
+// common flags, including stale items and opaque tokens.
+//
+// The embedded Client reads and writes the server's items directly,
+// for seeding and inspecting them in tests, and its Advance moves the
+// server's clock.
+type Server struct {
+	*Client
+
+	ln     net.Listener
+	mu     sync.Mutex
+	conns  map[net.Conn]bool
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewServer starts a Server listening on a local port. The caller
+// should call Close when finished, to shut it down. It panics if it
+// can't listen, as it is meant for tests.
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("memcachetest: failed to listen on a port: %v", err))
+	}
+	s := &Server{Client: New(), ln: ln, conns: make(map[net.Conn]bool)}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Addr returns the address the server listens on, for memcache.New.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close stops the server, closing its connections, and waits for their
+// goroutines to exit.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	err := s.ln.Close()
+	for nc := range s.conns {
+		nc.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			nc.Close()
+			return
+		}
+		s.conns[nc] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.handle(nc)
+	}
+}
+
+func (s *Server) handle(nc net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, nc)
+		s.mu.Unlock()
+		nc.Close()
+	}()
+	rw := bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			rw.WriteString("ERROR\r\n")
+		} else if !s.dispatch(rw, f) {
+			return
+		}
+		// Flush once the client has nothing more pipelined.
+		if rw.Reader.Buffered() == 0 {
+			if err := rw.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// dispatch runs the command f, reporting false if the connection must
+// be closed.
+func (s *Server) dispatch(rw *bufio.ReadWriter, f []string) bool {
+	switch f[0] {
+	case "get", "gets":
+		s.get(rw, f[0] == "gets", 0, false, f[1:])
+	case "gat", "gats":
+		if len(f) < 3 {
+			rw.WriteString("ERROR\r\n")
+			return true
+		}
+		exp, err := strconv.ParseInt(f[1], 10, 32)
+		if err != nil {
+			rw.WriteString("CLIENT_ERROR invalid exptime argument\r\n")
+			return true
+		}
+		s.get(rw, f[0] == "gats", int32(exp), true, f[2:])
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.store(rw, f)
+	case "delete":
+		if len(f) < 2 {
+			rw.WriteString("ERROR\r\n")
+			return true
+		}
+		s.reply(rw, f, s.delete(f[1]))
+	case "incr", "decr":
+		if len(f) < 3 {
+			rw.WriteString("ERROR\r\n")
+			return true
+		}
+		delta, err := strconv.ParseUint(f[2], 10, 64)
+		if err != nil {
+			rw.WriteString("CLIENT_ERROR invalid numeric delta argument\r\n")
+			return true
+		}
+		n, status := s.arith(f[1], f[0] == "decr", delta)
+		if status == "" {
+			status = strconv.FormatUint(n, 10)
+		}
+		s.reply(rw, f, status)
+	case "touch":
+		if len(f) < 3 {
+			rw.WriteString("ERROR\r\n")
+			return true
+		}
+		exp, err := strconv.ParseInt(f[2], 10, 32)
+		if err != nil {
+			rw.WriteString("CLIENT_ERROR invalid exptime argument\r\n")
+			return true
+		}
+		status := "NOT_FOUND"
+		if s.Touch(f[1], int32(exp)) == nil {
+			status = "TOUCHED"
+		}
+		s.reply(rw, f, status)
+	case "flush_all":
+		s.FlushAll()
+		s.reply(rw, f, "OK")
+	case "stats":
+		s.stats(rw)
+	case "version":
+		rw.WriteString("VERSION 1.6.0-memcachetest\r\n")
+	case "verbosity":
+		s.reply(rw, f, "OK")
+	case "lru_crawler":
+		if len(f) < 2 || f[1] != "metadump" {
+			rw.WriteString("ERROR\r\n")
+			return true
+		}
+		s.metaDump(rw)
+	case "mg":
+		s.metaGet(rw, f)
+	case "ms":
+		return s.metaSet(rw, f)
+	case "md":
+		s.metaDelete(rw, f)
+	case "ma":
+		s.metaArith(rw, f)
+	case "mn":
+		rw.WriteString("MN\r\n")
+	case "quit":
+		rw.Flush()
+		return false
+	default:
+		rw.WriteString("ERROR\r\n")
+	}
+	return true
+}
+
+// reply writes status, unless the text command f ends with noreply.
+func (s *Server) reply(rw *bufio.ReadWriter, f []string, status string) {
+	if f[len(f)-1] == "noreply" {
+		return
+	}
+	rw.WriteString(status + "\r\n")
+}
+
+// get answers get, gets, gat and gats for keys, touching the items
+// found with exp if touch is set.
+func (s *Server) get(rw *bufio.ReadWriter, withCAS bool, exp int32, touch bool, keys []string) {
+	c := s.Client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		e := c.lookup(key)
+		if e == nil {
+			continue
+		}
+		c.read(key, e)
+		if touch {
+			c.touch(key, e, exp)
+		}
+		if withCAS {
+			fmt.Fprintf(rw, "VALUE %s %d %d %d\r\n", key, e.flags, len(e.value), e.cas)
+		} else {
+			fmt.Fprintf(rw, "VALUE %s %d %d\r\n", key, e.flags, len(e.value))
+		}
+		rw.Write(e.value)
+		rw.WriteString("\r\n")
+	}
+	rw.WriteString("END\r\n")
+}
+
+// store answers the storage commands: "verb key flags exptime bytes
+// [cas] [noreply]" followed by a data block.
+func (s *Server) store(rw *bufio.ReadWriter, f []string) bool {
+	n := 5
+	if f[0] == "cas" {
+		n = 6
+	}
+	if len(f) < n {
+		rw.WriteString("ERROR\r\n")
+		return true
+	}
+	flags, ferr := strconv.ParseUint(f[2], 10, 32)
+	exp, eerr := strconv.ParseInt(f[3], 10, 32)
+	size, serr := strconv.Atoi(f[4])
+	if ferr != nil || eerr != nil || serr != nil || size < 0 {
+		rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return false
+	}
+	value, ok := readData(rw, size)
+	if !ok {
+		rw.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return false
+	}
+	if size > maxValueSize {
+		s.reply(rw, f, "SERVER_ERROR object too large for cache")
+		return true
+	}
+	var cas uint64
+	if f[0] == "cas" {
+		if cas, ferr = strconv.ParseUint(f[5], 10, 64); ferr != nil {
+			rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return true
+		}
+	}
+	mode := map[string]string{"set": "S", "add": "E", "replace": "R", "append": "A", "prepend": "P", "cas": "S"}[f[0]]
+	status, _ := s.set(f[1], value, uint32(flags), int32(exp), mode, cas, f[0] == "cas")
+	s.reply(rw, f, map[string]string{"HD": "STORED", "NS": "NOT_STORED", "EX": "EXISTS", "NF": "NOT_FOUND"}[status])
+	return true
+}
+
+// readData reads a data block of size bytes and its CRLF terminator.
+func readData(r io.Reader, size int) ([]byte, bool) {
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(r, buf); err != nil || !bytes.HasSuffix(buf, []byte("\r\n")) {
+		return nil, false
+	}
+	return buf[:size], true
+}
+
+// set stores value under key in mode, one of the ms modes S, E, A, P
+// and R, checking cas first if checkCAS is set. It returns the meta
+// status, HD, NS, EX or NF, and the entry stored, if any.
+func (s *Server) set(key string, value []byte, flags uint32, exp int32, mode string, cas uint64, checkCAS bool) (string, *entry) {
+	c := s.Client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.lookup(key)
+	switch {
+	case checkCAS && e == nil:
+		return "NF", nil
+	case checkCAS && e.cas != cas:
+		return "EX", nil
+	case mode == "E" && e != nil:
+		return "NS", nil
+	case (mode == "R" || mode == "A" || mode == "P") && e == nil:
+		return "NS", nil
+	}
+	switch mode {
+	case "A":
+		value = append(append([]byte(nil), e.value...), value...)
+		flags, exp = e.flags, c.remaining(e)
+	case "P":
+		value = append(append([]byte(nil), value...), e.value...)
+		flags, exp = e.flags, c.remaining(e)
+	}
+	return "HD", c.put(key, value, flags, exp)
+}
+
+// remaining returns the expiration that keeps the expiry time of e.
+// c.mu must be held.
+func (c *Client) remaining(e *entry) int32 {
+	if e.expires.IsZero() {
+		return 0
+	}
+	return int32(e.expires.Unix())
+}
+
+// delete deletes key, returning the text protocol status.
+func (s *Server) delete(key string) string {
+	if s.Delete(key) != nil {
+		return "NOT_FOUND"
+	}
+	return "DELETED"
+}
+
+// arith applies an increment or decrement to key. It returns the new
+// value, or the text protocol status of a failure.
+func (s *Server) arith(key string, decr bool, delta uint64) (uint64, string) {
+	c := s.Client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.lookup(key)
+	if e == nil {
+		return 0, "NOT_FOUND"
+	}
+	n, ok := c.apply(e, decr, delta)
+	if !ok {
+		return 0, "CLIENT_ERROR cannot increment or decrement non-numeric value"
+	}
+	return n, ""
+}
+
+func (s *Server) stats(rw *bufio.ReadWriter) {
+	c := s.Client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var items, bytes int
+	for key := range c.items {
+		if e := c.lookup(key); e != nil {
+			items++
+			bytes += len(e.value)
+		}
+	}
+	fmt.Fprintf(rw, "STAT pid 1\r\nSTAT version 1.6.0-memcachetest\r\nSTAT curr_items %d\r\nSTAT bytes %d\r\nEND\r\n", items, bytes)
+}
+
+func (s *Server) metaDump(rw *bufio.ReadWriter) {
+	c := s.Client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.items {
+		e := c.lookup(key)
+		if e == nil {
+			continue
+		}
+		exp := int64(-1)
+		if !e.expires.IsZero() {
+			exp = e.expires.Unix()
+		}
+		fetch := "no"
+		if e.fetched {
+			fetch = "yes"
+		}
+		fmt.Fprintf(rw, "key=%s exp=%d la=%d cas=%d fetch=%s cls=1 size=%d\r\n",
+			url.QueryEscape(key), exp, e.accessed.Unix(), e.cas, fetch, len(e.value))
+	}
+	rw.WriteString("END\r\n")
+}
+
+// metaRequest is a parsed meta command.
+type metaRequest struct {
+	cmd    string
+	key    string
+	flags  []string
+	binary bool
+}
+
+// parseMeta parses the key and flags of the meta command f, starting
+// with the key at f[i], decoding a base64 key sent with the b flag.
+func parseMeta(f []string, i int) (*metaRequest, bool) {
+	if len(f) <= i {
+		return nil, false
+	}
+	mr := &metaRequest{cmd: f[0], key: f[i], flags: f[i+1:]}
+	if _, ok := mr.flag('b'); ok {
+		key, err := base64.StdEncoding.DecodeString(mr.key)
+		if err != nil {
+			return nil, false
+		}
+		mr.key, mr.binary = string(key), true
+	}
+	return mr, true
+}
+
+// flag returns the token of flag f, and whether it was sent.
+func (mr *metaRequest) flag(f byte) (string, bool) {
+	for _, fl := range mr.flags {
+		if fl[0] == f {
+			return fl[1:], true
+		}
+	}
+	return "", false
+}
+
+func (mr *metaRequest) has(f byte) bool {
+	_, ok := mr.flag(f)
+	return ok
+}
+
+// int32Flag returns the numeric token of flag f, or def if it wasn't
+// sent.
+func (mr *metaRequest) int32Flag(f byte, def int32) (int32, bool) {
+	tok, ok := mr.flag(f)
+	if !ok {
+		return def, true
+	}
+	n, err := strconv.ParseInt(tok, 10, 32)
+	return int32(n), err == nil
+}
+
+// echo returns the O and k flags of the response to mr.
+func (mr *metaRequest) echo() []string {
+	var ret []string
+	if tok, ok := mr.flag('O'); ok {
+		ret = append(ret, "O"+tok)
+	}
+	if mr.has('k') {
+		if mr.binary {
+			ret = append(ret, "k"+base64.StdEncoding.EncodeToString([]byte(mr.key)), "b")
+		} else {
+			ret = append(ret, "k"+mr.key)
+		}
+	}
+	return ret
+}
+
+// metaStatus writes a meta response line without a value, unless quiet
+// mode suppresses it: quiet mode hides EN, HD except for mg, and NF
+// except for ms.
+func metaStatus(rw *bufio.ReadWriter, mr *metaRequest, status string, ret []string) {
+	quiet := status == "EN" || status == "HD" && mr.cmd != "mg" || status == "NF" && mr.cmd != "ms"
+	if quiet && mr.has('q') {
+		return
+	}
+	if len(ret) == 0 {
+		rw.WriteString(status + "\r\n")
+		return
+	}
+	rw.WriteString(status + " " + strings.Join(ret, " ") + "\r\n")
+}
+
+// metaGet answers "mg key flags...".
+func (s *Server) metaGet(rw *bufio.ReadWriter, f []string) {
+	mr, ok := parseMeta(f, 1)
+	if !ok {
+		rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return
+	}
+	c := s.Client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.lookup(mr.key)
+	won := false
+	if e == nil {
+		vivify, ok := mr.int32Flag('N', 0)
+		if !ok {
+			rw.WriteString("CLIENT_ERROR bad token in command line format\r\n")
+			return
+		}
+		if !mr.has('N') {
+			metaStatus(rw, mr, "EN", mr.echo())
+			return
+		}
+		if e = c.put(mr.key, nil, 0, vivify); e == nil {
+			metaStatus(rw, mr, "EN", mr.echo())
+			return
+		}
+		e.vivified, won = true, true
+	}
+	now := c.now()
+	var ret []string
+	for _, fl := range mr.flags {
+		switch fl[0] {
+		case 'f':
+			ret = append(ret, "f"+strconv.FormatUint(uint64(e.flags), 10))
+		case 'c':
+			ret = append(ret, "c"+strconv.FormatUint(e.cas, 10))
+		case 's':
+			ret = append(ret, "s"+strconv.Itoa(len(e.value)))
+		case 't':
+			ret = append(ret, "t"+strconv.FormatInt(ttl(e, now), 10))
+		case 'h':
+			if e.fetched {
+				ret = append(ret, "h1")
+			} else {
+				ret = append(ret, "h0")
+			}
+		case 'l':
+			la := int64(0)
+			if !e.accessed.IsZero() {
+				la = int64(now.Sub(e.accessed) / time.Second)
+			}
+			ret = append(ret, "l"+strconv.FormatInt(la, 10))
+		}
+	}
+	ret = append(ret, mr.echo()...)
+	switch {
+	case e.stale && !e.won:
+		e.won = true
+		ret = append(ret, "W", "X")
+	case e.stale:
+		ret = append(ret, "Z", "X")
+	case won:
+		ret = append(ret, "W")
+	case e.vivified:
+		ret = append(ret, "Z")
+	}
+	if exp, ok := mr.int32Flag('T', 0); ok && mr.has('T') {
+		c.touch(mr.key, e, exp)
+	}
+	e.fetched, e.accessed = true, now
+	if !mr.has('v') {
+		metaStatus(rw, mr, "HD", ret)
+		return
+	}
+	head := "VA " + strconv.Itoa(len(e.value))
+	if len(ret) > 0 {
+		head += " " + strings.Join(ret, " ")
+	}
+	rw.WriteString(head + "\r\n")
+	rw.Write(e.value)
+	rw.WriteString("\r\n")
+}
+
+// ttl returns the remaining time to live of e in seconds, or -1 if it
+// doesn't expire.
+func ttl(e *entry, now time.Time) int64 {
+	if e.expires.IsZero() {
+		return -1
+	}
+	return int64((e.expires.Sub(now) + time.Second - 1) / time.Second)
+}
+
+// metaSet answers "ms key datalen flags..." and its data block.
+func (s *Server) metaSet(rw *bufio.ReadWriter, f []string) bool {
+	if len(f) < 3 {
+		rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return false
+	}
+	size, err := strconv.Atoi(f[2])
+	if err != nil || size < 0 {
+		rw.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return false
+	}
+	value, ok := readData(rw, size)
+	if !ok {
+		rw.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return false
+	}
+	mr, ok := parseMeta(append([]string{f[0], f[1]}, f[3:]...), 1)
+	if !ok {
+		rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return true
+	}
+	if size > maxValueSize {
+		rw.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return true
+	}
+	flags, fok := mr.flag('F')
+	itemFlags, ferr := strconv.ParseUint(flags, 10, 32)
+	exp, eok := mr.int32Flag('T', 0)
+	casTok, checkCAS := mr.flag('C')
+	cas, cerr := strconv.ParseUint(casTok, 10, 64)
+	if fok && ferr != nil || !eok || checkCAS && cerr != nil {
+		rw.WriteString("CLIENT_ERROR bad token in command line format\r\n")
+		return true
+	}
+	mode, _ := mr.flag('M')
+	switch mode {
+	case "", "s", "S":
+		mode = "S"
+	case "e", "E", "a", "A", "p", "P", "r", "R":
+		mode = strings.ToUpper(mode)
+	default:
+		rw.WriteString("CLIENT_ERROR invalid mode for ms\r\n")
+		return true
+	}
+	status, e := s.set(mr.key, value, uint32(itemFlags), exp, mode, cas, checkCAS)
+	ret := mr.echo()
+	if status == "HD" && e != nil && mr.has('c') {
+		ret = append([]string{"c" + strconv.FormatUint(e.cas, 10)}, ret...)
+	}
+	metaStatus(rw, mr, status, ret)
+	return true
+}
+
+// metaDelete answers "md key flags...". With the I flag, the item is
+// marked stale rather than removed, and its time to live is updated
+// by the T flag.
+func (s *Server) metaDelete(rw *bufio.ReadWriter, f []string) {
+	mr, ok := parseMeta(f, 1)
+	if !ok {
+		rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return
+	}
+	c := s.Client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.lookup(mr.key)
+	if e == nil {
+		metaStatus(rw, mr, "NF", mr.echo())
+		return
+	}
+	if tok, ok := mr.flag('C'); ok && tok != strconv.FormatUint(e.cas, 10) {
+		metaStatus(rw, mr, "EX", mr.echo())
+		return
+	}
+	if mr.has('I') {
+		c.cas++
+		e.stale, e.won, e.cas = true, false, c.cas
+		if exp, ok := mr.int32Flag('T', 0); ok && mr.has('T') {
+			c.touch(mr.key, e, exp)
+		}
+	} else {
+		delete(c.items, mr.key)
+	}
+	metaStatus(rw, mr, "HD", mr.echo())
+}
+
+// metaArith answers "ma key flags...".
+func (s *Server) metaArith(rw *bufio.ReadWriter, f []string) {
+	mr, ok := parseMeta(f, 1)
+	if !ok {
+		rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return
+	}
+	delta, initial := uint64(1), uint64(0)
+	var err error
+	if tok, ok := mr.flag('D'); ok {
+		delta, err = strconv.ParseUint(tok, 10, 64)
+	}
+	if tok, ok := mr.flag('J'); ok && err == nil {
+		initial, err = strconv.ParseUint(tok, 10, 64)
+	}
+	vivify, vok := mr.int32Flag('N', 0)
+	if err != nil || !vok {
+		rw.WriteString("CLIENT_ERROR bad token in command line format\r\n")
+		return
+	}
+	decr := false
+	switch mode, _ := mr.flag('M'); mode {
+	case "", "I", "i", "+":
+	case "D", "d", "-":
+		decr = true
+	default:
+		rw.WriteString("CLIENT_ERROR invalid mode for ma\r\n")
+		return
+	}
+	c := s.Client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.lookup(mr.key)
+	switch {
+	case e == nil && !mr.has('N'):
+		metaStatus(rw, mr, "NF", mr.echo())
+		return
+	case e == nil:
+		if e = c.put(mr.key, strconv.AppendUint(nil, initial, 10), 0, vivify); e == nil {
+			metaStatus(rw, mr, "NF", mr.echo())
+			return
+		}
+	default:
+		if tok, ok := mr.flag('C'); ok && tok != strconv.FormatUint(e.cas, 10) {
+			metaStatus(rw, mr, "EX", mr.echo())
+			return
+		}
+		if _, ok := c.apply(e, decr, delta); !ok {
+			rw.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return
+		}
+	}
+	var ret []string
+	for _, fl := range mr.flags {
+		switch fl[0] {
+		case 'c':
+			ret = append(ret, "c"+strconv.FormatUint(e.cas, 10))
+		case 't':
+			ret = append(ret, "t"+strconv.FormatInt(ttl(e, c.now()), 10))
+		}
+	}
+	ret = append(ret, mr.echo()...)
+	if !mr.has('v') {
+		metaStatus(rw, mr, "HD", ret)
+		return
+	}
+	head := "VA " + strconv.Itoa(len(e.value))
+	if len(ret) > 0 {
+		head += " " + strings.Join(ret, " ")
+	}
+	rw.WriteString(head + "\r\n")
+	rw.Write(e.value)
+	rw.WriteString("\r\n")
+}
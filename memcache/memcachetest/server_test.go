@@ -0,0 +1,158 @@
+package memcachetest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestServerClient(t *testing.T) {
+	for _, meta := range []bool{false, true} {
+		t.Run(fmt.Sprintf("MetaProtocol=%v", meta), func(t *testing.T) {
+			s := NewServer()
+			defer s.Close()
+			c := memcache.New(s.Addr())
+			c.MetaProtocol = meta
+
+			if err := c.Set(&memcache.Item{Key: "foo", Value: []byte("fooval"), Flags: 7}); err != nil {
+				t.Fatal(err)
+			}
+			it, err := c.Get("foo")
+			if err != nil || string(it.Value) != "fooval" || it.Flags != 7 {
+				t.Fatalf("Get(foo) = %+v, %v", it, err)
+			}
+			if _, err := c.Get("missing"); err != memcache.ErrCacheMiss {
+				t.Errorf("Get(missing) = %v, want ErrCacheMiss", err)
+			}
+			if err := c.Add(&memcache.Item{Key: "foo", Value: []byte("x")}); err != memcache.ErrNotStored {
+				t.Errorf("Add(foo) = %v, want ErrNotStored", err)
+			}
+			it.Value = []byte("swapped")
+			if err := c.CompareAndSwap(it); err != nil {
+				t.Errorf("CompareAndSwap = %v", err)
+			}
+			if err := c.CompareAndSwap(it); err != memcache.ErrCASConflict {
+				t.Errorf("stale CompareAndSwap = %v, want ErrCASConflict", err)
+			}
+			items, err := c.GetMulti([]string{"foo", "missing"})
+			if err != nil || len(items) != 1 || string(items["foo"].Value) != "swapped" {
+				t.Errorf("GetMulti = %v, %v", items, err)
+			}
+
+			c.Set(&memcache.Item{Key: "n", Value: []byte("5")})
+			if n, err := c.Increment("n", 10); err != nil || n != 15 {
+				t.Errorf("Increment = %d, %v, want 15", n, err)
+			}
+			if n, err := c.Decrement("n", 20); err != nil || n != 0 {
+				t.Errorf("Decrement = %d, %v, want 0", n, err)
+			}
+
+			if err := c.Touch("foo", 10); err != nil {
+				t.Errorf("Touch = %v", err)
+			}
+			s.Advance(11 * time.Second)
+			if _, err := c.Get("foo"); err != memcache.ErrCacheMiss {
+				t.Errorf("Get(foo) after expiry = %v, want ErrCacheMiss", err)
+			}
+			if err := c.Delete("n"); err != nil {
+				t.Errorf("Delete = %v", err)
+			}
+			if s.Len() != 0 {
+				t.Errorf("Len = %d, want 0", s.Len())
+			}
+		})
+	}
+}
+
+func TestServerSeeded(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Set(&memcache.Item{Key: "seeded", Value: []byte("v")})
+	c := memcache.New(s.Addr())
+	if it, err := c.Get("seeded"); err != nil || string(it.Value) != "v" {
+		t.Errorf("Get(seeded) = %+v, %v", it, err)
+	}
+	c.Set(&memcache.Item{Key: "written", Value: []byte("w")})
+	if it, err := s.Get("written"); err != nil || string(it.Value) != "w" {
+		t.Errorf("Server Get(written) = %+v, %v", it, err)
+	}
+}
+
+func TestServerMeta(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	nc, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	nc.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(nc)
+	tests := []struct {
+		cmd  string
+		want []string
+	}{
+		{"ms foo 3 F5 T0 c O1 k\r\nbar", []string{"HD c1 O1 kfoo"}},
+		{"mg foo v f c s t k", []string{"VA 3 f5 c1 s3 t-1 kfoo", "bar"}},
+		{"mg foo h", []string{"HD h1"}},
+		{"mg missing v q O7", nil},
+		{"mg missing v O7", []string{"EN O7"}},
+		{"ms foo 1 MA\r\nz", []string{"HD"}},
+		{"ms foo 1 C99\r\nz", []string{"EX"}},
+		{"ms nope 1 MR\r\nz", []string{"NS"}},
+		{"mg foo v", []string{"VA 4", "barz"}},
+		{"md foo I T30", []string{"HD"}},
+		{"mg foo c", []string{"HD c3 W X"}},
+		{"mg foo", []string{"HD Z X"}},
+		{"mg viv N30 v", []string{"VA 0 W", ""}},
+		{"mg viv v", []string{"VA 0 Z", ""}},
+		{"ma ctr N0 J10 v", []string{"VA 2", "10"}},
+		{"ma ctr MD D3 v", []string{"VA 1", "7"}},
+		{"ma none q", nil},
+		{"ms nope 1 MR q\r\nz", []string{"NS"}},
+		{"md foo q", nil},
+		{"md foo", []string{"NF"}},
+		{"mg Zm9v b v k", []string{"EN kZm9v b"}},
+		{"ms Zm9v 1 b k\r\nx", []string{"HD kZm9v b"}},
+		{"mn", []string{"MN"}},
+		{"bogus", []string{"ERROR"}},
+	}
+	for _, tt := range tests {
+		fmt.Fprintf(nc, "%s\r\n", tt.cmd)
+		for _, want := range tt.want {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("%q: %v", tt.cmd, err)
+			}
+			if got := strings.TrimSuffix(line, "\r\n"); got != want {
+				t.Errorf("%q: got %q, want %q", tt.cmd, got, want)
+			}
+		}
+	}
+	fmt.Fprintf(nc, "mn\r\n")
+	if line, _ := r.ReadString('\n'); line != "MN\r\n" {
+		t.Errorf("quiet commands wrote %q before MN", line)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	s := NewServer()
+	nc, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	fmt.Fprintf(nc, "version\r\n")
+	if line, err := bufio.NewReader(nc).ReadString('\n'); err != nil || !strings.HasPrefix(line, "VERSION ") {
+		t.Fatalf("version = %q, %v", line, err)
+	}
+	s.Close()
+	if _, err := nc.Read(make([]byte, 1)); err == nil {
+		t.Error("connection still open after Close")
+	}
+}
@@ -0,0 +1,60 @@
+package memcache_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/bradfitz/gomemcache/memcache/memcachetest"
+)
+
+// TestMemcachetestServer runs the client's meta protocol features
+// against memcachetest.Server, as downstream integration tests would.
+func TestMemcachetestServer(t *testing.T) {
+	s := memcachetest.NewServer()
+	defer s.Close()
+	c := memcache.New(s.Addr())
+	c.MetaProtocol = true
+	c.ReturnCAS = true
+
+	it := &memcache.Item{Key: "foo", Value: []byte("fooval"), Expiration: memcache.ExpiresIn(time.Minute)}
+	if err := c.Set(it); err != nil {
+		t.Fatal(err)
+	}
+	if it.CasID() == 0 {
+		t.Error("Set with ReturnCAS didn't set the CAS ID")
+	}
+	if ttl, err := c.GetTTL("foo"); err != nil || ttl != time.Minute {
+		t.Errorf("GetTTL = %v, %v, want 1m", ttl, err)
+	}
+	s.Advance(10 * time.Second)
+	got, err := c.GetWithOptions("foo", memcache.FetchOptions{TTL: true, Fetched: true, LastAccess: true, Size: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := memcache.ItemMeta{TTL: 50 * time.Second, LastAccess: 10 * time.Second, Fetched: true, Size: 6}
+	if *got.Meta != want {
+		t.Errorf("GetWithOptions Meta = %+v, want %+v", *got.Meta, want)
+	}
+
+	if err := c.Invalidate("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.IncrementWithInit("hits", 5, 10, time.Minute); err != nil || n != 10 {
+		t.Errorf("IncrementWithInit on a new key = %d, %v, want 10", n, err)
+	}
+	if n, err := c.IncrementWithInit("hits", 5, 10, time.Minute); err != nil || n != 15 {
+		t.Errorf("IncrementWithInit = %d, %v, want 15", n, err)
+	}
+
+	keys := map[string]bool{}
+	addr, _ := net.ResolveTCPAddr("tcp", s.Addr())
+	err = c.MetaDump(addr, func(ki memcache.KeyInfo) error {
+		keys[ki.Key] = true
+		return nil
+	})
+	if err != nil || len(keys) != 2 || !keys["foo"] || !keys["hits"] {
+		t.Errorf("MetaDump keys = %v, %v, want foo and hits", keys, err)
+	}
+}
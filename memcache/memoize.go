@@ -0,0 +1,56 @@
+//go:build go1.18
+
+package memcache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Memoize returns a function calling fn through c: its results are
+// encoded with c's Codec and cached for ttl, under a key made of prefix,
+// ':' and the SHA-1 of the JSON encoding of the argument, so that calls
+// with equal arguments, from any process, share a result. Functions of
+// several arguments take them as a struct.
+//
+// As with GetOrSet, which it builds on, concurrent calls for the same
+// argument share one call of fn, and errors from fn are returned
+// uncached unless NegativeTTL is set. fn should depend only on its
+// argument, and prefix should change along with fn's results.
+func Memoize[A, R any](c *Client, prefix string, ttl time.Duration, fn func(context.Context, A) (R, error)) func(context.Context, A) (R, error) {
+	return func(ctx context.Context, arg A) (R, error) {
+		var result R
+		key, err := memoKey(prefix, arg)
+		if err != nil {
+			return result, err
+		}
+		codec := c.codec()
+		data, err := c.GetOrSet(ctx, key, ttl, func() ([]byte, error) {
+			r, err := fn(ctx, arg)
+			if err != nil {
+				return nil, err
+			}
+			return codec.Marshal(r)
+		})
+		if err != nil {
+			return result, err
+		}
+		if err := codec.Unmarshal(data, &result); err != nil {
+			return result, err
+		}
+		return result, nil
+	}
+}
+
+// memoKey returns the key Memoize caches the result for arg under.
+func memoKey(prefix string, arg interface{}) (string, error) {
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(data)
+	return prefix + ":" + hex.EncodeToString(sum[:]), nil
+}
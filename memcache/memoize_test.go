@@ -0,0 +1,70 @@
+//go:build go1.18
+
+package memcache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	ctx := context.Background()
+
+	type args struct {
+		A, B int
+	}
+	type sum struct {
+		Total int
+	}
+	calls := 0
+	add := Memoize(c, "add", time.Minute, func(ctx context.Context, a args) (sum, error) {
+		calls++
+		return sum{a.A + a.B}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		got, err := add(ctx, args{1, 2})
+		if err != nil || got.Total != 3 {
+			t.Fatalf("add = %+v, %v", got, err)
+		}
+	}
+	if got, err := add(ctx, args{2, 2}); err != nil || got.Total != 4 {
+		t.Fatalf("add = %+v, %v", got, err)
+	}
+	if calls != 2 {
+		t.Errorf("fn called %d times, want 2", calls)
+	}
+
+	key, err := memoKey("add", args{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, "add:") || !legalKey(key) {
+		t.Errorf("key = %q", key)
+	}
+	if it, ok := s.get(key); !ok || string(it.value) != `{"Total":3}` {
+		t.Errorf("stored item = %+v, %v", it, ok)
+	}
+
+	errFn := errors.New("failed")
+	fail := Memoize(c, "fail", time.Minute, func(ctx context.Context, n int) (int, error) {
+		return 0, errFn
+	})
+	if _, err := fail(ctx, 1); err != errFn {
+		t.Errorf("error = %v, want %v", err, errFn)
+	}
+
+	bad := Memoize(c, "bad", time.Minute, func(ctx context.Context, f func()) (int, error) {
+		t.Error("fn called with an argument that can't be encoded")
+		return 0, nil
+	})
+	if _, err := bad(ctx, func() {}); err == nil {
+		t.Error("no error for an argument that can't be encoded")
+	}
+}
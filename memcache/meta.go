@@ -0,0 +1,293 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// metaResponse is a parsed response to a meta command, such as
+// "VA 5 f0 c12 W".
+type metaResponse struct {
+	// status is the response code: "VA", "HD", "EN", "NS", "EX", "NF"
+	// or "MN".
+	status string
+
+	// flags are the returned flags, each a flag character followed by
+	// its token, if any.
+	flags []string
+
+	// value is the data block of a VA response, and buf the pooled
+	// buffer holding it, if any.
+	value []byte
+	buf   *[]byte
+}
+
+// flag returns the token of the returned flag f, and whether it was
+// returned.
+func (mr *metaResponse) flag(f byte) (string, bool) {
+	for _, fl := range mr.flags {
+		if fl[0] == f {
+			return fl[1:], true
+		}
+	}
+	return "", false
+}
+
+// readMetaResponse reads one meta command response from r.
+func readMetaResponse(r *bufio.Reader, lim responseLimits, strict bool) (*metaResponse, error) {
+	line, err := lim.readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if strict && !bytes.HasSuffix(line, crlf) {
+		return nil, fmt.Errorf("%w: meta response line not terminated by CRLF: %q", ErrProtocol, line)
+	}
+	if err := checkServerError(line); err != nil {
+		return nil, err
+	}
+	// The line is copied once; the status and flags are substrings of it.
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: empty meta response line", ErrProtocol)
+	}
+	mr := &metaResponse{status: fields[0]}
+	size := -1
+	switch mr.status {
+	case "HD", "EN", "NS", "EX", "NF", "MN":
+		mr.flags = fields[1:]
+	case "VA":
+		var n uint64
+		ok := len(fields) >= 2
+		if ok {
+			n, ok = parseUint([]byte(fields[1]), 31)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed meta value line: %q", ErrProtocol, line)
+		}
+		size = int(n)
+		if err := lim.checkSize(r, size); err != nil {
+			return nil, err
+		}
+		mr.flags = fields[2:]
+	case "ERROR":
+		return nil, ErrMetaUnsupported
+	default:
+		return nil, fmt.Errorf("%w: unexpected meta response line: %q", ErrProtocol, line)
+	}
+	if size >= 0 {
+		if mr.value, mr.buf, err = lim.readValue(r, size, strict); err != nil {
+			return nil, err
+		}
+	}
+	return mr, nil
+}
+
+// readValue reads a data block of size bytes and its CRLF terminator.
+// If lim.pooled is set and the value is small enough to pool, it is
+// read into a pooled buffer, which is returned for the item holding the
+// value to release.
+func (lim responseLimits) readValue(r *bufio.Reader, size int, strict bool) ([]byte, *[]byte, error) {
+	var bp *[]byte
+	var dst []byte
+	if lim.pooled && size+2 <= maxPooledValue {
+		bp = getValueBuf(size + 2)
+		dst = *bp
+	}
+	value, err := appendValue(r, dst, size, strict)
+	if err != nil {
+		if bp != nil {
+			putValueBuf(bp)
+		}
+		return nil, nil, err
+	}
+	return value[:size:size], bp, nil
+}
+
+// checkSize checks the announced size of a value against maxValue. An
+// oversized value fails with ErrProtocol, or, if drain is set, is read
+// and discarded, returning ErrValueTooLarge.
+func (lim responseLimits) checkSize(r *bufio.Reader, size int) error {
+	if lim.maxValue <= 0 || size <= lim.maxValue {
+		return nil
+	}
+	if !lim.drain {
+		return fmt.Errorf("%w: value is %d bytes, larger than the maximum of %d", ErrProtocol, size, lim.maxValue)
+	}
+	if _, err := r.Discard(size); err != nil {
+		return err
+	}
+	var tail [2]byte
+	if _, err := io.ReadFull(r, tail[:]); err != nil {
+		return err
+	}
+	if !bytes.Equal(tail[:], crlf) {
+		return fmt.Errorf("%w: corrupt get result read", ErrProtocol)
+	}
+	return ErrValueTooLarge
+}
+
+// maxValueGrowth bounds how much appendValue grows its buffer ahead of
+// the data actually read, so that a bogus size announced by a server
+// cannot make the client allocate it all up front.
+const maxValueGrowth = 1 << 20
+
+// appendValue is like readValue but appends the value to dst.
+func appendValue(r *bufio.Reader, dst []byte, size int, strict bool) ([]byte, error) {
+	start := len(dst)
+	end := start + size + 2
+	for len(dst) < end {
+		if len(dst) == cap(dst) {
+			grow := end - len(dst)
+			if grow > maxValueGrowth {
+				grow = maxValueGrowth
+			}
+			dst = append(dst, make([]byte, grow)...)[:len(dst)]
+		}
+		limit := cap(dst)
+		if limit > end {
+			limit = end
+		}
+		n, err := io.ReadFull(r, dst[len(dst):limit])
+		dst = dst[:len(dst)+n]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if strict {
+				return nil, fmt.Errorf("%w: value is %d bytes, header announced %d", ErrProtocol, len(dst)-start-2, size)
+			}
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !bytes.HasSuffix(dst, crlf) {
+		return nil, fmt.Errorf("%w: corrupt get result read", ErrProtocol)
+	}
+	return dst[:start+size], nil
+}
+
+// opaque returns the index of the request mr answers, from its opaque
+// token, and marks it in seen. A missing, unknown or repeated token is
+// a protocol error.
+func (mr *metaResponse) opaque(seen []bool) (int, error) {
+	tok, ok := mr.flag('O')
+	if !ok {
+		return 0, fmt.Errorf("%w: no opaque token in meta response", ErrProtocol)
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || i >= len(seen) {
+		return 0, fmt.Errorf("%w: unknown opaque token %q in meta response", ErrProtocol, tok)
+	}
+	if seen[i] {
+		return 0, fmt.Errorf("%w: repeated response to meta request %d", ErrProtocol, i)
+	}
+	seen[i] = true
+	return i, nil
+}
+
+// item fills in an Item for key from a VA or HD response to mg.
+func (mr *metaResponse) item(key string) (*Item, error) {
+	it := &Item{Key: key, Value: mr.value, buf: mr.buf}
+	if tok, ok := mr.flag('k'); ok {
+		if _, b := mr.flag('b'); b {
+			key, err := base64.StdEncoding.DecodeString(tok)
+			if err != nil {
+				return nil, fmt.Errorf("%w: malformed base64 key in meta response", ErrProtocol)
+			}
+			tok = string(key)
+		}
+		it.Key = tok
+	}
+	if tok, ok := mr.flag('f'); ok {
+		flags, err := strconv.ParseUint(tok, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed flags %q in meta response", ErrProtocol, tok)
+		}
+		it.Flags = uint32(flags)
+	}
+	if tok, ok := mr.flag('c'); ok {
+		cas, err := strconv.ParseUint(tok, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed CAS %q in meta response", ErrProtocol, tok)
+		}
+		it.casid = cas
+	}
+	_, it.Win = mr.flag('W')
+	_, it.Stale = mr.flag('X')
+	meta, err := mr.itemMeta()
+	if err != nil {
+		return nil, err
+	}
+	it.Meta = meta
+	return it, nil
+}
+
+// metaGetFromAddr reads keys from the server at addr with one "mg"
+// command per key with the given flags, calling cb for each item found.
+// The commands are sent in quiet mode, in which the server answers
+// misses with nothing, followed by an "mn" whose MN response marks the
+// end of the batch. Each command carries the index of its key as opaque
+// token, which the server returns with the response, so that responses
+// are matched to keys by token rather than by order.
+func (c *Client) metaGetFromAddr(addr net.Addr, keys []string, flags string, cb func(*Item)) error {
+	return c.withAddrConn(addr, "mg", keys, func(cn *conn, m *OpMetrics) error {
+		rw := cn.rw
+		for i, key := range keys {
+			if _, err := fmt.Fprintf(rw, "mg %s %s q O%d\r\n", c.metaKey(key), flags, i); err != nil {
+				return err
+			}
+		}
+		if _, err := rw.WriteString("mn\r\n"); err != nil {
+			return err
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+		lim := c.limits()
+		var tooLarge error
+		seen := make([]bool, len(keys))
+		oversized := 0
+		for {
+			mr, err := readMetaResponse(rw.Reader, lim, c.StrictResponses)
+			if err == ErrValueTooLarge {
+				tooLarge = err
+				oversized++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if mr.status == "MN" {
+				break
+			}
+			i, err := mr.opaque(seen)
+			if err != nil {
+				return err
+			}
+			key := keys[i]
+			switch mr.status {
+			case "EN":
+				continue
+			case "VA", "HD":
+			default:
+				return fmt.Errorf("%w: unexpected %s response to mg", ErrProtocol, mr.status)
+			}
+			it, err := mr.item(key)
+			if err != nil {
+				return err
+			}
+			if c.StrictResponses && it.Key != key {
+				return fmt.Errorf("%w: unexpected key in mg response", ErrProtocol)
+			}
+			m.Hits++
+			cb(it)
+		}
+		m.Misses = len(keys) - m.Hits - oversized
+		return tooLarge
+	})
+}
@@ -0,0 +1,168 @@
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestMetaGet(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.MetaProtocol = true
+	c.StrictResponses = true
+	s.put("foo", []byte("fooval"), 7)
+	s.put("bar", []byte("barval"), 0)
+
+	it, err := c.Get("foo")
+	if err != nil {
+		t.Fatalf("Get(foo): %v", err)
+	}
+	if string(it.Value) != "fooval" || it.Flags != 7 || it.Stale || it.Win {
+		t.Errorf("Get(foo) = %+v", it)
+	}
+	if _, err := c.Get("missing"); err != ErrCacheMiss {
+		t.Errorf("Get(missing) = %v, want ErrCacheMiss", err)
+	}
+
+	// The CAS ID from mg must be usable by CompareAndSwap.
+	it.Value = []byte("new")
+	if err := c.CompareAndSwap(it); err != nil {
+		t.Errorf("CompareAndSwap after mg: %v", err)
+	}
+
+	items, err := c.GetMulti([]string{"foo", "missing", "bar"})
+	if err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if len(items) != 2 || string(items["foo"].Value) != "new" || string(items["bar"].Value) != "barval" {
+		t.Errorf("GetMulti = %v", items)
+	}
+}
+
+func TestMetaGetStaleWin(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.MetaProtocol = true
+	s.put("foo", []byte("old"), 0)
+	s.markStale("foo")
+
+	first, err := c.Get("foo")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !first.Stale || !first.Win {
+		t.Errorf("first Get of stale item: Stale=%v Win=%v, want both", first.Stale, first.Win)
+	}
+	second, err := c.Get("foo")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !second.Stale || second.Win {
+		t.Errorf("second Get of stale item: Stale=%v Win=%v, want stale without win", second.Stale, second.Win)
+	}
+	if string(second.Value) != "old" {
+		t.Errorf("stale value = %q, want old", second.Value)
+	}
+}
+
+// serveMetaOutOfOrder accepts one connection on ln, reads n mg commands
+// and the mn ending them, and answers them in reverse order with
+// respond, which is given the command's opaque flag, followed by MN.
+func serveMetaOutOfOrder(ln net.Listener, n int, respond func(opaque string) string) {
+	nc, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	var opaques []string
+	for len(opaques) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		opaques = append(opaques, f[len(f)-1])
+	}
+	if _, err := r.ReadString('\n'); err != nil {
+		return
+	}
+	for i := n - 1; i >= 0; i-- {
+		nc.Write([]byte(respond(opaques[i])))
+	}
+	nc.Write([]byte("MN\r\n"))
+}
+
+func TestMetaGetOpaque(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	values := map[string]string{"O0": "a", "O2": "c"}
+	go serveMetaOutOfOrder(ln, 3, func(opaque string) string {
+		v, ok := values[opaque]
+		if !ok {
+			// Misses are not answered in quiet mode.
+			return ""
+		}
+		return "VA 1 " + opaque + "\r\n" + v + "\r\n"
+	})
+	c := New(ln.Addr().String())
+	c.MetaProtocol = true
+	items, err := c.GetMulti([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if len(items) != 2 || string(items["a"].Value) != "a" || string(items["c"].Value) != "c" {
+		t.Errorf("GetMulti with reordered responses = %v", items)
+	}
+	if snap := c.Snapshot(); snap.Hits != 2 || snap.Misses != 1 {
+		t.Errorf("Snapshot = %+v, want 2 hits and 1 unanswered miss", snap)
+	}
+
+	go serveMetaOutOfOrder(ln, 2, func(string) string { return "EN O7\r\n" })
+	c = New(ln.Addr().String())
+	c.MetaProtocol = true
+	if _, err := c.GetMulti([]string{"a", "b"}); !errors.Is(err, ErrProtocol) {
+		t.Errorf("GetMulti with unknown opaque tokens = %v, want ErrProtocol", err)
+	}
+}
+
+func TestReadMetaResponseUnexpected(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("XX bogus\r\n"))
+	if _, err := readMetaResponse(r, New().limits(), false); !errors.Is(err, ErrProtocol) {
+		t.Errorf("readMetaResponse of unknown line = %v, want ErrProtocol", err)
+	}
+}
+
+func TestReadValueErrors(t *testing.T) {
+	tests := []struct {
+		name, data string
+		strict     bool
+		want       error
+	}{
+		{"truncated", "abc", false, io.ErrUnexpectedEOF},
+		{"truncated strict", "abc", true, ErrProtocol},
+		{"bad terminator", "abcde!!", false, ErrProtocol},
+		{"bad terminator strict", "abcde!!", true, ErrProtocol},
+	}
+	for _, tt := range tests {
+		r := bufio.NewReader(strings.NewReader(tt.data))
+		if _, err := appendValue(r, nil, 5, tt.strict); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	lim := responseLimits{maxValue: 2, drain: true}
+	r := bufio.NewReader(strings.NewReader("abcde!!"))
+	if err := lim.checkSize(r, 5); !errors.Is(err, ErrProtocol) {
+		t.Errorf("drained value with bad terminator: err = %v, want ErrProtocol", err)
+	}
+}
@@ -0,0 +1,192 @@
+package memcache
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsRecorder receives callbacks for every operation a Client sends
+// to a server, so that any metrics backend can be plugged in. Batch
+// operations such as GetMulti are reported once per server.
+//
+// Implementations must be safe for concurrent use.
+type MetricsRecorder interface {
+	// OpStart is called before op is sent to the server at addr,
+	// including before dialing a new connection.
+	OpStart(op string, addr net.Addr)
+
+	// OpEnd is called when the operation completes.
+	OpEnd(m OpMetrics)
+}
+
+// OpMetrics describes a completed operation against a single server.
+type OpMetrics struct {
+	// Op is the protocol command, such as "gets", "set" or "delete".
+	Op string
+
+	// Addr is the server the operation was sent to.
+	Addr net.Addr
+
+	// Keys are the operation's keys, as selected by the client's
+	// ErrorKeys mode.
+	Keys []string
+
+	// Duration is the time from OpStart until the response was read.
+	Duration time.Duration
+
+	// BytesIn and BytesOut are the number of bytes read from and
+	// written to the connection.
+	BytesIn, BytesOut int64
+
+	// Hits and Misses count the keys found and not found by a read, or
+	// by a write that reports a miss, such as Delete.
+	Hits, Misses int
+
+	// Err is the error the operation returned, if any, including
+	// cache-level errors such as ErrNotStored.
+	Err error
+
+	// ErrClass classifies Err. It is ErrClassNone for cache-level errors.
+	ErrClass ErrorClass
+}
+
+// ErrorClass is a coarse classification of operation failures.
+type ErrorClass string
+
+const (
+	// ErrClassNone means the operation succeeded or failed only with a
+	// cache-level error such as ErrCacheMiss or ErrNotStored.
+	ErrClassNone ErrorClass = ""
+
+	// ErrClassTimeout means a dial, read or write timed out.
+	ErrClassTimeout ErrorClass = "timeout"
+
+	// ErrClassNetwork means the connection failed or was closed.
+	ErrClassNetwork ErrorClass = "network"
+
+	// ErrClassServer means the server responded with SERVER_ERROR.
+	ErrClassServer ErrorClass = "server"
+
+	// ErrClassProtocol means the server sent an error or a response the
+	// client could not understand.
+	ErrClassProtocol ErrorClass = "protocol"
+)
+
+func classifyError(err error) ErrorClass {
+	if err == nil || resumableError(err) {
+		return ErrClassNone
+	}
+	var cte *ConnectTimeoutError
+	var ne net.Error
+	switch {
+	case errors.Is(err, ErrTimeout), errors.As(err, &cte):
+		return ErrClassTimeout
+	case errors.Is(err, ErrConnFailed):
+		return ErrClassNetwork
+	case errors.Is(err, ErrServerError):
+		return ErrClassServer
+	case errors.Is(err, ErrProtocol):
+		return ErrClassProtocol
+	case errors.As(err, &ne):
+		if ne.Timeout() {
+			return ErrClassTimeout
+		}
+		return ErrClassNetwork
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrClassNetwork
+	}
+	return ErrClassProtocol
+}
+
+// countingConn counts the bytes read from and written to a net.Conn,
+// and captures them while a wire trace is active. Like conn, it is only
+// used by one goroutine at a time.
+type countingConn struct {
+	net.Conn
+	nr, nw int64
+
+	tracing        bool
+	tracedRequest  []byte
+	tracedResponse []byte
+}
+
+func (cc *countingConn) Read(p []byte) (int, error) {
+	n, err := cc.Conn.Read(p)
+	cc.nr += int64(n)
+	if cc.tracing {
+		cc.tracedResponse = appendCapped(cc.tracedResponse, p[:n])
+	}
+	return n, err
+}
+
+func (cc *countingConn) Write(p []byte) (int, error) {
+	n, err := cc.Conn.Write(p)
+	cc.nw += int64(n)
+	if cc.tracing {
+		cc.tracedRequest = appendCapped(cc.tracedRequest, p[:n])
+	}
+	return n, err
+}
+
+// counters are the client's built-in operation counters.
+type counters struct {
+	ops, hits, misses                uint64
+	timeouts, networkErrs, protoErrs uint64
+}
+
+func (cs *counters) record(m *OpMetrics) {
+	atomic.AddUint64(&cs.ops, 1)
+	atomic.AddUint64(&cs.hits, uint64(m.Hits))
+	atomic.AddUint64(&cs.misses, uint64(m.Misses))
+	switch m.ErrClass {
+	case ErrClassTimeout:
+		atomic.AddUint64(&cs.timeouts, 1)
+	case ErrClassNetwork:
+		atomic.AddUint64(&cs.networkErrs, 1)
+	case ErrClassProtocol:
+		atomic.AddUint64(&cs.protoErrs, 1)
+	}
+}
+
+// Snapshot is a point-in-time copy of a client's built-in counters.
+type Snapshot struct {
+	// Ops is the number of operations sent to servers. Batch
+	// operations count once per server.
+	Ops uint64
+
+	// Hits and Misses count keys found and not found.
+	Hits, Misses uint64
+
+	// Errors counts failed operations by class. Cache-level errors
+	// such as ErrNotStored are not counted.
+	Errors map[ErrorClass]uint64
+}
+
+// HitRatio returns Hits / (Hits + Misses), or 0 if there were no
+// lookups.
+func (s Snapshot) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Snapshot returns the client's built-in counters. They are always
+// maintained, even without a MetricsRecorder, and are cheap enough to
+// read periodically for logging cache effectiveness.
+func (c *Client) Snapshot() Snapshot {
+	cs := &c.state.counters
+	return Snapshot{
+		Ops:    atomic.LoadUint64(&cs.ops),
+		Hits:   atomic.LoadUint64(&cs.hits),
+		Misses: atomic.LoadUint64(&cs.misses),
+		Errors: map[ErrorClass]uint64{
+			ErrClassTimeout:  atomic.LoadUint64(&cs.timeouts),
+			ErrClassNetwork:  atomic.LoadUint64(&cs.networkErrs),
+			ErrClassProtocol: atomic.LoadUint64(&cs.protoErrs),
+		},
+	}
+}
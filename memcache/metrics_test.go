@@ -0,0 +1,117 @@
+package memcache
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type recordingMetrics struct {
+	mu     sync.Mutex
+	starts int
+	ends   []OpMetrics
+}
+
+func (r *recordingMetrics) OpStart(op string, addr net.Addr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.starts++
+}
+
+func (r *recordingMetrics) OpEnd(m OpMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ends = append(r.ends, m)
+}
+
+func TestMetricsRecorder(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	rec := new(recordingMetrics)
+	c := New(s.Addr())
+	c.Metrics = rec
+
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("fooval")})
+	if _, err := c.GetMulti([]string{"foo", "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("bar"); err != ErrCacheMiss {
+		t.Fatalf("Get(bar): got %v, want ErrCacheMiss", err)
+	}
+
+	if rec.starts != 3 || len(rec.ends) != 3 {
+		t.Fatalf("got %d starts and %d ends, want 3 each", rec.starts, len(rec.ends))
+	}
+	set, multi, miss := rec.ends[0], rec.ends[1], rec.ends[2]
+	if set.Op != "set" || set.BytesOut == 0 || set.BytesIn != int64(len("STORED\r\n")) {
+		t.Errorf("set metrics = %+v", set)
+	}
+	if multi.Op != "gets" || multi.Hits != 1 || multi.Misses != 1 {
+		t.Errorf("GetMulti metrics = %+v, want 1 hit and 1 miss", multi)
+	}
+	if miss.Misses != 1 || miss.ErrClass != ErrClassNone {
+		t.Errorf("Get miss metrics = %+v", miss)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("fooval")})
+	c.GetMulti([]string{"foo", "bar", "baz"})
+
+	snap := c.Snapshot()
+	if snap.Ops != 2 || snap.Hits != 1 || snap.Misses != 2 {
+		t.Errorf("Snapshot = %+v, want 2 ops, 1 hit, 2 misses", snap)
+	}
+	if g, e := snap.HitRatio(), 1.0/3; g != e {
+		t.Errorf("HitRatio = %v, want %v", g, e)
+	}
+}
+
+type connEventRecorder struct {
+	recordingMetrics
+	events []ConnEvent
+}
+
+func (r *connEventRecorder) ConnEvent(addr net.Addr, ev ConnEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func TestConnStats(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	rec := new(connEventRecorder)
+	c.Metrics = MultiMetrics(rec)
+
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("fooval")})
+	c.Get("foo")
+	// The fake server answers unknown commands with ERROR, which the
+	// client treats as a protocol error.
+	if _, err := c.Increment("foo", 1); err == nil {
+		t.Fatal("Increment of non-numeric value succeeded")
+	}
+
+	got := c.ConnStats()[s.Addr()]
+	want := ConnStats{Dials: 1, ProtocolErrors: 1, Discarded: 1}
+	if got != want {
+		t.Errorf("ConnStats = %+v, want %+v", got, want)
+	}
+	wantEvents := []ConnEvent{ConnDialed, ConnProtocolError, ConnDiscarded}
+	if !reflect.DeepEqual(rec.events, wantEvents) {
+		t.Errorf("events = %v, want %v", rec.events, wantEvents)
+	}
+
+	dead := newFakeServer(t)
+	dead.Close()
+	dc := New(dead.Addr())
+	dc.Get("foo")
+	if got := dc.ConnStats()[dead.Addr()]; got.DialFailures != 1 {
+		t.Errorf("ConnStats of closed server = %+v, want 1 dial failure", got)
+	}
+}
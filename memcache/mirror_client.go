@@ -0,0 +1,266 @@
+package memcache
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync/atomic"
+)
+
+// DefaultMirrorMaxInFlight is the default maximum number of mirrored
+// operations outstanding against the shadow cluster.
+const DefaultMirrorMaxInFlight = 64
+
+// MirrorClient sends every operation to a primary client and
+// asynchronously duplicates a sample of them to a shadow client, for
+// validating a new cluster before cutover. Results always come from the
+// primary; the shadow's results are only compared against them and
+// counted in MirrorStats.
+type MirrorClient struct {
+	primary MemcacheClient
+	shadow  MemcacheClient
+
+	// Fraction is the fraction of keys, between 0 and 1, whose
+	// operations are mirrored to the shadow. Keys are sampled by hash
+	// rather than operations at random, so that the reads of a sampled
+	// key find the writes that were mirrored before them, and
+	// ShadowMisses counts real divergence.
+	Fraction float64
+
+	inflight chan struct{}
+	stats    mirrorCounters
+}
+
+// MirrorStats counts the outcome of mirrored operations.
+type MirrorStats struct {
+	// Mirrored is the number of operations sent to the shadow.
+	Mirrored uint64
+	// Dropped is the number of sampled operations that were not sent
+	// because too many mirrored operations were already in flight.
+	Dropped uint64
+	// Errors is the number of mirrored operations that failed on the
+	// shadow with an error other than a cache miss.
+	Errors uint64
+	// Mismatches is the number of reads for which both clusters had the
+	// key but with different values or flags.
+	Mismatches uint64
+	// ShadowMisses is the number of reads that hit the primary but
+	// missed the shadow.
+	ShadowMisses uint64
+	// ShadowOnlyHits is the number of reads that missed the primary but
+	// hit the shadow.
+	ShadowOnlyHits uint64
+}
+
+type mirrorCounters struct {
+	mirrored, dropped, errors, mismatches, shadowMisses, shadowOnlyHits uint64
+}
+
+// NewMirror returns a MirrorClient mirroring the operations on the given
+// fraction of keys from primary to shadow.
+func NewMirror(primary, shadow MemcacheClient, fraction float64) *MirrorClient {
+	return &MirrorClient{
+		primary:  primary,
+		shadow:   shadow,
+		Fraction: fraction,
+		inflight: make(chan struct{}, DefaultMirrorMaxInFlight),
+	}
+}
+
+// MirrorStats returns a snapshot of the mirroring counters.
+func (m *MirrorClient) MirrorStats() MirrorStats {
+	return MirrorStats{
+		Mirrored:       atomic.LoadUint64(&m.stats.mirrored),
+		Dropped:        atomic.LoadUint64(&m.stats.dropped),
+		Errors:         atomic.LoadUint64(&m.stats.errors),
+		Mismatches:     atomic.LoadUint64(&m.stats.mismatches),
+		ShadowMisses:   atomic.LoadUint64(&m.stats.shadowMisses),
+		ShadowOnlyHits: atomic.LoadUint64(&m.stats.shadowOnlyHits),
+	}
+}
+
+// sampled reports whether the operations on key are mirrored: whether
+// its hash falls in the first Fraction of the hash space.
+func (m *MirrorClient) sampled(key string) bool {
+	if m.Fraction <= 0 {
+		return false
+	}
+	if m.Fraction >= 1 {
+		return true
+	}
+	sum := sha1.Sum([]byte(key))
+	return float64(binary.BigEndian.Uint64(sum[:])) < m.Fraction*(1<<64)
+}
+
+// sample reports whether the current operation on key should be
+// mirrored, reserving a slot in the in-flight window if so. A successful
+// sample must be followed by a call to mirror.
+func (m *MirrorClient) sample(key string) bool {
+	return m.sampled(key) && m.reserve()
+}
+
+// reserve reserves a slot in the in-flight window, or counts the
+// operation as dropped if the window is full.
+func (m *MirrorClient) reserve() bool {
+	select {
+	case m.inflight <- struct{}{}:
+	default:
+		atomic.AddUint64(&m.stats.dropped, 1)
+		return false
+	}
+	atomic.AddUint64(&m.stats.mirrored, 1)
+	return true
+}
+
+// mirror runs fn against the shadow in the background.
+func (m *MirrorClient) mirror(fn func(shadow MemcacheClient)) {
+	go func() {
+		defer func() { <-m.inflight }()
+		fn(m.shadow)
+	}()
+}
+
+func (m *MirrorClient) countErr(err error) {
+	if err != nil && !resumableError(err) {
+		atomic.AddUint64(&m.stats.errors, 1)
+	}
+}
+
+// compare records the divergence between a primary and a shadow read of
+// the same key. A nil item means a miss.
+func (m *MirrorClient) compare(p, s *Item) {
+	switch {
+	case p != nil && s == nil:
+		atomic.AddUint64(&m.stats.shadowMisses, 1)
+	case p == nil && s != nil:
+		atomic.AddUint64(&m.stats.shadowOnlyHits, 1)
+	case p != nil && (p.Flags != s.Flags || !bytes.Equal(p.Bytes(), s.Bytes())):
+		atomic.AddUint64(&m.stats.mismatches, 1)
+	}
+}
+
+func (m *MirrorClient) Get(key string) (*Item, error) {
+	item, err := m.primary.Get(key)
+	if (err == nil || errors.Is(err, ErrCacheMiss)) && m.sample(key) {
+		item := copyItemOrNil(item)
+		m.mirror(func(shadow MemcacheClient) {
+			sitem, serr := shadow.Get(key)
+			m.countErr(serr)
+			if serr == nil || errors.Is(serr, ErrCacheMiss) {
+				m.compare(item, sitem)
+			}
+		})
+	}
+	return item, err
+}
+
+func (m *MirrorClient) GetMulti(keys []string) (map[string]*Item, error) {
+	items, err := m.primary.GetMulti(keys)
+	if err != nil {
+		return items, err
+	}
+	var skeys []string
+	for _, key := range keys {
+		if m.sampled(key) {
+			skeys = append(skeys, key)
+		}
+	}
+	if len(skeys) > 0 && m.reserve() {
+		pitems := make(map[string]*Item, len(skeys))
+		for _, key := range skeys {
+			if it, ok := items[key]; ok {
+				pitems[key] = copyItem(it)
+			}
+		}
+		m.mirror(func(shadow MemcacheClient) {
+			sitems, serr := shadow.GetMulti(skeys)
+			m.countErr(serr)
+			if serr != nil {
+				return
+			}
+			for _, key := range skeys {
+				m.compare(pitems[key], sitems[key])
+			}
+		})
+	}
+	return items, err
+}
+
+func (m *MirrorClient) Stats() (map[net.Addr]map[string]string, error) {
+	return m.primary.Stats()
+}
+
+// copyItem returns a copy of item that the shadow can use after the
+// caller has regained ownership of item. A lazy value is copied too.
+func copyItem(item *Item) *Item {
+	return item.clone()
+}
+
+func copyItemOrNil(item *Item) *Item {
+	if item == nil {
+		return nil
+	}
+	return copyItem(item)
+}
+
+func (m *MirrorClient) Set(item *Item) error {
+	err := m.primary.Set(item)
+	if m.sample(item.Key) {
+		it := copyItem(item)
+		m.mirror(func(shadow MemcacheClient) { m.countErr(shadow.Set(it)) })
+	}
+	return err
+}
+
+func (m *MirrorClient) Add(item *Item) error {
+	err := m.primary.Add(item)
+	if m.sample(item.Key) {
+		it := copyItem(item)
+		m.mirror(func(shadow MemcacheClient) { m.countErr(shadow.Add(it)) })
+	}
+	return err
+}
+
+// CompareAndSwap is not mirrored: the item's CAS ID belongs to the
+// primary and is meaningless on the shadow. The item is mirrored as a
+// Set instead if the swap succeeded.
+func (m *MirrorClient) CompareAndSwap(item *Item) error {
+	err := m.primary.CompareAndSwap(item)
+	if err == nil && m.sample(item.Key) {
+		it := copyItem(item)
+		m.mirror(func(shadow MemcacheClient) { m.countErr(shadow.Set(it)) })
+	}
+	return err
+}
+
+func (m *MirrorClient) Delete(key string) error {
+	err := m.primary.Delete(key)
+	if m.sample(key) {
+		m.mirror(func(shadow MemcacheClient) { m.countErr(shadow.Delete(key)) })
+	}
+	return err
+}
+
+func (m *MirrorClient) Increment(key string, delta uint64) (uint64, error) {
+	n, err := m.primary.Increment(key, delta)
+	if m.sample(key) {
+		m.mirror(func(shadow MemcacheClient) {
+			_, serr := shadow.Increment(key, delta)
+			m.countErr(serr)
+		})
+	}
+	return n, err
+}
+
+func (m *MirrorClient) Decrement(key string, delta uint64) (uint64, error) {
+	n, err := m.primary.Decrement(key, delta)
+	if m.sample(key) {
+		m.mirror(func(shadow MemcacheClient) {
+			_, serr := shadow.Decrement(key, delta)
+			m.countErr(serr)
+		})
+	}
+	return n, err
+}
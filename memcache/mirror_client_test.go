@@ -0,0 +1,109 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMirrorDivergence(t *testing.T) {
+	ps, ss := newFakeServer(t), newFakeServer(t)
+	defer ps.Close()
+	defer ss.Close()
+	m := NewMirror(New(ps.Addr()), New(ss.Addr()), 1)
+
+	mustSet(t, m, &Item{Key: "foo", Value: []byte("fooval")})
+	ps.put("bar", []byte("primary"), 0)
+	ss.put("bar", []byte("shadow"), 0)
+	ps.put("baz", []byte("primary"), 0)
+	ss.put("qux", []byte("shadow"), 0)
+
+	for _, key := range []string{"foo", "bar", "baz", "qux"} {
+		m.Get(key)
+	}
+	want := MirrorStats{Mirrored: 5, Mismatches: 1, ShadowMisses: 1, ShadowOnlyHits: 1}
+	var got MirrorStats
+	for i := 0; i < 100; i++ {
+		if got = m.MirrorStats(); got == want {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("MirrorStats = %+v, want %+v", got, want)
+}
+
+func TestMirrorGetMulti(t *testing.T) {
+	ps, ss := newFakeServer(t), newFakeServer(t)
+	defer ps.Close()
+	defer ss.Close()
+	primary, shadow := New(ps.Addr()), New(ss.Addr())
+	primary.LazyValues, shadow.LazyValues = true, true
+	m := NewMirror(primary, shadow, 1)
+	ps.put("foo", []byte("a"), 0)
+	ss.put("foo", []byte("b"), 0)
+	ps.put("bar", []byte("a"), 0)
+	if _, err := m.GetMulti([]string{"foo", "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	want := MirrorStats{Mirrored: 1, Mismatches: 1, ShadowMisses: 1}
+	var got MirrorStats
+	for i := 0; i < 100; i++ {
+		if got = m.MirrorStats(); got == want {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("MirrorStats = %+v, want %+v", got, want)
+}
+
+func TestMirrorSamplesKeys(t *testing.T) {
+	ps, ss := newFakeServer(t), newFakeServer(t)
+	defer ps.Close()
+	defer ss.Close()
+	m := NewMirror(New(ps.Addr()), New(ss.Addr()), 0.5)
+
+	var keys []string
+	sampled := 0
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key%d", i)
+		keys = append(keys, key)
+		if m.sampled(key) {
+			sampled++
+		}
+		if m.sampled(key) != m.sampled(key) {
+			t.Fatalf("sampling of %q is not deterministic", key)
+		}
+	}
+	if sampled < 60 || sampled > 140 {
+		t.Fatalf("sampled %d of %d keys with Fraction 0.5", sampled, len(keys))
+	}
+
+	wait := func(want MirrorStats) {
+		t.Helper()
+		var got MirrorStats
+		for i := 0; i < 200; i++ {
+			if got = m.MirrorStats(); got == want {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("MirrorStats = %+v, want %+v", got, want)
+	}
+	for _, key := range keys {
+		mustSet(t, m, &Item{Key: key, Value: []byte("val")})
+		wait(MirrorStats{Mirrored: m.MirrorStats().Mirrored})
+	}
+	for _, key := range keys {
+		_, inShadow := ss.get(key)
+		if inShadow != m.sampled(key) {
+			t.Errorf("key %q in shadow = %v, want %v", key, inShadow, m.sampled(key))
+		}
+	}
+	// Every mirrored read finds the mirrored write of the same key.
+	for _, key := range keys {
+		if _, err := m.Get(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait(MirrorStats{Mirrored: uint64(2 * sampled)})
+}
@@ -0,0 +1,186 @@
+package memcache
+
+import (
+	"errors"
+	"strings"
+)
+
+// Namespace is a subset of the keyspace that can be flushed as a whole
+// in a single operation, without touching other keys as flush_all
+// would. Keys in a namespace are stored under the namespace's name and
+// current epoch, which Flush advances: items stored in earlier epochs
+// are never read again and are left to expire or be evicted.
+//
+// Each operation reads the epoch first, at the cost of a round trip.
+type Namespace struct {
+	// SlidingExpiration, if positive, makes Get and GetMulti reset the
+	// expiration of the items they read to this many seconds, as Touch
+	// does, so that items such as sessions expire only once they go
+	// unread for that long. Get uses GetAndTouch, and GetMulti touches
+	// the items it found with TouchMulti.
+	SlidingExpiration int32
+
+	// DefaultExpiration and DefaultFlags, if non-zero, are the
+	// Expiration and Flags of the items stored in the namespace that
+	// leave them zero. They take precedence over the client's.
+	DefaultExpiration int32
+	DefaultFlags      uint32
+
+	c    *Client
+	name string
+}
+
+// Namespace returns the namespace with the given name, which must be a
+// legal key and must not contain ':'. The epoch is stored under "ns:"
+// followed by the name.
+func (c *Client) Namespace(name string) *Namespace {
+	return &Namespace{c: c, name: name}
+}
+
+// epochKey is the key holding the namespace's epoch.
+func (n *Namespace) epochKey() string {
+	return "ns:" + n.name
+}
+
+// prefix returns the prefix of the keys currently stored in the
+// namespace: its name and epoch, each followed by ':'.
+func (n *Namespace) prefix() (string, error) {
+	if strings.Contains(n.name, ":") {
+		return "", ErrMalformedKey
+	}
+	it, err := n.c.getRaw(n.epochKey())
+	if errors.Is(err, ErrCacheMiss) {
+		it, err = n.c.createVersion(n.epochKey())
+	}
+	if err != nil {
+		return "", err
+	}
+	return n.name + ":" + string(it.Bytes()) + ":", nil
+}
+
+// Key returns the key that key in the namespace is currently stored
+// under: the namespace's name, epoch and key, separated by ':'.
+func (n *Namespace) Key(key string) (string, error) {
+	prefix, err := n.prefix()
+	if err != nil {
+		return "", err
+	}
+	if !n.c.acceptsKey(prefix + key) {
+		return "", ErrMalformedKey
+	}
+	return prefix + key, nil
+}
+
+// Flush logically removes all items in the namespace.
+func (n *Namespace) Flush() error {
+	_, err := n.c.Increment(n.epochKey(), 1)
+	if errors.Is(err, ErrCacheMiss) {
+		// The epoch was evicted, and a new one must still differ from
+		// any earlier one.
+		return n.c.storeRaw("set", &Item{Key: n.epochKey(), Value: newVersion()})
+	}
+	return err
+}
+
+// Get gets the item for key in the namespace, as Client.Get does.
+func (n *Namespace) Get(key string) (*Item, error) {
+	nkey, err := n.Key(key)
+	if err != nil {
+		return nil, err
+	}
+	var it *Item
+	if n.SlidingExpiration > 0 {
+		it, err = n.c.GetAndTouch(nkey, n.SlidingExpiration)
+	} else {
+		it, err = n.c.Get(nkey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	it.Key = key
+	return it, nil
+}
+
+// GetMulti gets the items for keys in the namespace, as
+// Client.GetMulti does, reading the epoch once.
+func (n *Namespace) GetMulti(keys []string) (map[string]*Item, error) {
+	prefix, err := n.prefix()
+	if err != nil {
+		return nil, err
+	}
+	nkeys := make([]string, len(keys))
+	for i, key := range keys {
+		if !n.c.acceptsKey(prefix + key) {
+			return nil, ErrMalformedKey
+		}
+		nkeys[i] = prefix + key
+	}
+	items, err := n.c.GetMulti(nkeys)
+	if items == nil {
+		return nil, err
+	}
+	if n.SlidingExpiration > 0 && len(items) > 0 {
+		found := make([]string, 0, len(items))
+		for nkey := range items {
+			found = append(found, nkey)
+		}
+		for nkey, terr := range n.c.TouchMulti(found, n.SlidingExpiration) {
+			if terr != nil && !errors.Is(terr, ErrCacheMiss) {
+				n.c.logDebug("memcache: sliding expiration touch failed", "key", nkey, "err", terr)
+			}
+		}
+	}
+	m := make(map[string]*Item, len(items))
+	for nkey, it := range items {
+		it.Key = nkey[len(prefix):]
+		m[it.Key] = it
+	}
+	return m, err
+}
+
+// Set writes item in the namespace, as Client.Set does.
+func (n *Namespace) Set(item *Item) error {
+	return n.store(item, n.c.Set)
+}
+
+// Add writes item in the namespace if its key is not already there, as
+// Client.Add does.
+func (n *Namespace) Add(item *Item) error {
+	return n.store(item, n.c.Add)
+}
+
+// Delete deletes the item for key in the namespace, as Client.Delete
+// does.
+func (n *Namespace) Delete(key string) error {
+	nkey, err := n.Key(key)
+	if err != nil {
+		return err
+	}
+	return n.c.Delete(nkey)
+}
+
+func (n *Namespace) store(item *Item, fn func(*Item) error) error {
+	nkey, err := n.Key(item.Key)
+	if err != nil {
+		return err
+	}
+	it := *withDefaults(item, n.DefaultExpiration, n.DefaultFlags)
+	it.Key = nkey
+	return fn(&it)
+}
+
+// withDefaults returns item, or a copy of it with exp and flags in
+// place of its zero Expiration and Flags.
+func withDefaults(item *Item, exp int32, flags uint32) *Item {
+	if (item.Expiration != 0 || exp == 0) && (item.Flags != 0 || flags == 0) {
+		return item
+	}
+	it := *item
+	if it.Expiration == 0 {
+		it.Expiration = exp
+	}
+	if it.Flags == 0 {
+		it.Flags = flags
+	}
+	return &it
+}
@@ -0,0 +1,146 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNamespace(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	users, posts := c.Namespace("users"), c.Namespace("posts")
+
+	if err := users.Set(&Item{Key: "1", Value: []byte("alice")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Add(&Item{Key: "2", Value: []byte("bob")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Add(&Item{Key: "2", Value: []byte("carol")}); err != ErrNotStored {
+		t.Errorf("Add of existing key = %v, want ErrNotStored", err)
+	}
+	if err := posts.Set(&Item{Key: "1", Value: []byte("hello")}); err != nil {
+		t.Fatal(err)
+	}
+	if it, err := users.Get("1"); err != nil || it.Key != "1" || string(it.Value) != "alice" {
+		t.Fatalf("users.Get = %+v, %v", it, err)
+	}
+	m, err := users.GetMulti([]string{"1", "2", "3"})
+	if err != nil || len(m) != 2 || m["2"].Key != "2" || string(m["2"].Value) != "bob" {
+		t.Fatalf("users.GetMulti = %v, %v", m, err)
+	}
+	key, err := users.Key("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.get(key); !ok {
+		t.Errorf("item not stored under %q", key)
+	}
+
+	if err := users.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Get("1"); err != ErrCacheMiss {
+		t.Errorf("Get after Flush = %v, want ErrCacheMiss", err)
+	}
+	if it, err := posts.Get("1"); err != nil || string(it.Value) != "hello" {
+		t.Errorf("other namespace after Flush = %+v, %v", it, err)
+	}
+	if err := posts.Delete("1"); err != nil {
+		t.Errorf("Delete = %v", err)
+	}
+
+	if _, err := c.Namespace("a:b").Get("1"); err != ErrMalformedKey {
+		t.Errorf("namespace name with ':' = %v, want ErrMalformedKey", err)
+	}
+	if err := c.Namespace("fresh").Flush(); err != nil {
+		t.Errorf("Flush of unused namespace = %v", err)
+	}
+}
+
+func TestNamespaceSlidingExpiration(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	sessions := c.Namespace("sessions")
+	sessions.SlidingExpiration = 1800
+
+	for _, key := range []string{"a", "b"} {
+		if err := sessions.Set(&Item{Key: key, Value: []byte("data"), Expiration: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exptime := func(key string) string {
+		nkey, err := sessions.Key(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		it, _ := s.get(nkey)
+		return it.exptime
+	}
+	if _, err := sessions.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := exptime("a"); got != "1800" {
+		t.Errorf("expiration after Get = %s, want 1800", got)
+	}
+	if got := exptime("b"); got != "5" {
+		t.Errorf("expiration of unread item = %s, want 5", got)
+	}
+	if m, err := sessions.GetMulti([]string{"b", "missing"}); err != nil || len(m) != 1 {
+		t.Fatalf("GetMulti = %v, %v", m, err)
+	}
+	if got := exptime("b"); got != "1800" {
+		t.Errorf("expiration after GetMulti = %s, want 1800", got)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.DefaultExpiration = 300
+	c.DefaultFlags = 5
+
+	mustSet(t, c, &Item{Key: "a", Value: []byte("1")})
+	mustSet(t, c, &Item{Key: "b", Value: []byte("2"), Expiration: 60, Flags: 9})
+	if it, _ := s.get("a"); it.exptime != "300" || it.flags != 5 {
+		t.Errorf("item without settings stored with exptime %s, flags %d", it.exptime, it.flags)
+	}
+	if it, _ := s.get("b"); it.exptime != "60" || it.flags != 9 {
+		t.Errorf("item with settings stored with exptime %s, flags %d", it.exptime, it.flags)
+	}
+
+	ns := c.Namespace("sessions")
+	ns.DefaultExpiration = 1800
+	if err := ns.Set(&Item{Key: "s", Value: []byte("3")}); err != nil {
+		t.Fatal(err)
+	}
+	nkey, err := ns.Key("s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it, _ := s.get(nkey); it.exptime != "1800" || it.flags != 5 {
+		t.Errorf("namespace item stored with exptime %s, flags %d", it.exptime, it.flags)
+	}
+
+	item := &Item{Key: "c", Value: []byte("4")}
+	mustSet(t, c, item)
+	if item.Expiration != 0 || item.Flags != 0 {
+		t.Errorf("defaults written to the caller's item: %+v", item)
+	}
+
+	// The client's own metadata never expires.
+	if ok, err := c.NewLock("l", time.Minute).TryLock(); !ok || err != nil {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+	if err := c.SetTagged(&Item{Key: "t", Value: []byte("5")}, "tag"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"l:fence", "tag:tag", "ns:sessions"} {
+		if it, ok := s.get(key); !ok || it.exptime != "0" || it.flags != 0 {
+			t.Errorf("%s stored with %+v, want no expiration and no flags", key, it)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package memcache
+
+// NegativeFlag is the Item.Flags bit marking the entries NegativeTTL
+// stores for failed loads. Their value is the message of the loader's
+// error.
+const NegativeFlag uint32 = 1 << 23
+
+// NegativeCacheError is returned by GetOrSet and GetOrRevalidate for a
+// key whose load failed within the last NegativeTTL.
+type NegativeCacheError struct {
+	// Message is the message of the loader's error.
+	Message string
+}
+
+func (e *NegativeCacheError) Error() string {
+	return "memcache: cached load failure: " + e.Message
+}
+
+// negativeHit returns the NegativeCacheError recorded by it, or nil if
+// it isn't a negative entry.
+func negativeHit(it *Item) error {
+	if it.Flags&NegativeFlag == 0 {
+		return nil
+	}
+	return &NegativeCacheError{Message: string(it.Bytes())}
+}
+
+// cacheLoadError records that loading key failed with err, if
+// NegativeTTL is set. A failure to store the entry is logged.
+func (c *Client) cacheLoadError(key string, err error) {
+	if c.NegativeTTL <= 0 {
+		return
+	}
+	it := &Item{Key: key, Value: []byte(err.Error()), Flags: NegativeFlag, Expiration: expirationFor(c.NegativeTTL)}
+	if serr := c.Set(it); serr != nil {
+		c.logDebug("memcache: failed to cache load error", "key", key, "err", serr)
+	}
+}
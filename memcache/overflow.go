@@ -0,0 +1,64 @@
+package memcache
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	// ErrUnderflow is returned by Decrement, with ErrorOnUnderflow set,
+	// when delta exceeds the value of the key, which is left unchanged.
+	ErrUnderflow = errors.New("memcache: decrement below zero")
+
+	// ErrOverflow is returned by Increment, with ErrorOnOverflow set,
+	// along with the new value when the increment wrapped around. The
+	// wrapped value is stored.
+	ErrOverflow = errors.New("memcache: increment wrapped around")
+)
+
+// checkOverflow returns ErrOverflow if incrementing by delta gave
+// newValue by wrapping around and the client reports overflows.
+func (c *Client) checkOverflow(newValue, delta uint64) error {
+	if c.ErrorOnOverflow && newValue < delta {
+		return ErrOverflow
+	}
+	return nil
+}
+
+// checkedDecr decrements key by delta with a compare and swap, failing
+// with ErrUnderflow rather than stopping at zero. It retries when the
+// value changes between the read and the write. The value is read with
+// mg, for its remaining time to live, which the write keeps.
+func (c *Client) checkedDecr(key string, delta uint64) (uint64, error) {
+	if c.Replicas > 1 {
+		return 0, ErrReplicatedCAS
+	}
+	for {
+		it, err := c.metaFetch(key, "v f c t")
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.ParseUint(string(it.Bytes()), 10, 64)
+		if err != nil {
+			return 0, errors.New("memcache: client error: cannot increment or decrement non-numeric value")
+		}
+		if delta > n {
+			return 0, ErrUnderflow
+		}
+		n -= delta
+		next := &Item{Key: key, Value: strconv.AppendUint(nil, n, 10), Flags: it.Flags, casid: it.casid}
+		if it.Meta != nil && it.Meta.TTL > 0 {
+			next.Expiration = expirationFor(it.Meta.TTL)
+		}
+		switch err := c.onItem("cas", next, (*Client).cas); {
+		case err == nil:
+			return n, nil
+		case errors.Is(err, ErrCASConflict):
+			// Changed since the read: try again.
+		case errors.Is(err, ErrNotStored):
+			return 0, ErrCacheMiss
+		default:
+			return 0, err
+		}
+	}
+}
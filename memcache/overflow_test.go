@@ -0,0 +1,89 @@
+package memcache
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestErrorOnUnderflow(t *testing.T) {
+	for _, meta := range []bool{false, true} {
+		s := newFakeServer(t)
+		defer s.Close()
+		c := New(s.Addr())
+		c.MetaProtocol = meta
+		c.ErrorOnUnderflow = true
+		mustSet(t, c, &Item{Key: "n", Value: []byte("5"), Expiration: 60})
+
+		if v, err := c.Decrement("n", 2); v != 3 || err != nil {
+			t.Errorf("meta=%v: Decrement(2) = %d, %v; want 3", meta, v, err)
+		}
+		if _, err := c.Decrement("n", 4); err != ErrUnderflow {
+			t.Errorf("meta=%v: Decrement(4) = %v, want ErrUnderflow", meta, err)
+		}
+		it, _ := s.get("n")
+		if string(it.value) != "3" {
+			t.Errorf("meta=%v: value after underflow = %q, want 3", meta, it.value)
+		}
+		if it.exptime != "60" {
+			t.Errorf("meta=%v: expiration after Decrement = %q, want 60", meta, it.exptime)
+		}
+		if _, err := c.Decrement("missing", 1); err != ErrCacheMiss {
+			t.Errorf("meta=%v: Decrement(missing) = %v, want ErrCacheMiss", meta, err)
+		}
+
+		k := c.NewCounter("k", time.Minute)
+		if v, err := k.IncrBy(2); v != 2 || err != nil {
+			t.Errorf("meta=%v: IncrBy(2) = %d, %v", meta, v, err)
+		}
+		if _, err := k.DecrBy(3); err != ErrUnderflow {
+			t.Errorf("meta=%v: Counter.DecrBy(3) = %v, want ErrUnderflow", meta, err)
+		}
+	}
+
+	s := newFakeServer(t)
+	defer s.Close()
+	s.noMeta = true
+	c := New(s.Addr())
+	c.ErrorOnUnderflow = true
+	mustSet(t, c, &Item{Key: "n", Value: []byte("5")})
+	if _, err := c.Decrement("n", 1); !errors.Is(err, ErrMetaUnsupported) {
+		t.Errorf("Decrement without meta support = %v, want ErrMetaUnsupported", err)
+	}
+	c = New(s.Addr(), s.Addr())
+	c.ErrorOnUnderflow = true
+	c.Replicas = 2
+	if _, err := c.Decrement("n", 1); err != ErrReplicatedCAS {
+		t.Errorf("Decrement with replicas = %v, want ErrReplicatedCAS", err)
+	}
+}
+
+func TestErrorOnOverflow(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	mustSet(t, c, &Item{Key: "n", Value: []byte(strconv.FormatUint(math.MaxUint64-1, 10))})
+
+	if v, err := c.Increment("n", 1); v != math.MaxUint64 || err != nil {
+		t.Errorf("Increment to MaxUint64 = %d, %v", v, err)
+	}
+	if v, err := c.Increment("n", 2); v != 1 || err != nil {
+		t.Errorf("wrapping Increment without ErrorOnOverflow = %d, %v; want 1, nil", v, err)
+	}
+	c.ErrorOnOverflow = true
+	mustSet(t, c, &Item{Key: "n", Value: []byte(strconv.FormatUint(math.MaxUint64, 10))})
+	if v, err := c.Increment("n", 3); v != 2 || err != ErrOverflow {
+		t.Errorf("wrapping Increment = %d, %v; want 2, ErrOverflow", v, err)
+	}
+
+	c.MetaProtocol = true
+	if v, err := c.IncrementWithInit("new", 5, 1, time.Minute); v != 1 || err != nil {
+		t.Errorf("IncrementWithInit of a new key = %d, %v; want 1, nil", v, err)
+	}
+	mustSet(t, c, &Item{Key: "n", Value: []byte(strconv.FormatUint(math.MaxUint64, 10))})
+	if v, err := c.IncrementWithInit("n", 3, 0, time.Minute); v != 2 || err != ErrOverflow {
+		t.Errorf("wrapping IncrementWithInit = %d, %v; want 2, ErrOverflow", v, err)
+	}
+}
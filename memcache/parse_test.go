@@ -0,0 +1,97 @@
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseGetResponseStrict(t *testing.T) {
+	tests := []struct {
+		name, resp string
+		ok         bool
+	}{
+		{"valid", "VALUE a 0 1\r\nx\r\nVALUE c 0 2\r\nyz\r\nEND\r\n", true},
+		{"unrequested key", "VALUE z 0 1\r\nx\r\nEND\r\n", false},
+		{"out of order", "VALUE c 0 1\r\nx\r\nVALUE a 0 1\r\nx\r\nEND\r\n", false},
+		{"bare LF", "VALUE a 0 1\nx\r\nEND\r\n", false},
+		{"short value", "VALUE a 0 5\r\nx\r\n", false},
+	}
+	for _, tt := range tests {
+		r := bufio.NewReader(strings.NewReader(tt.resp))
+		err := parseGetResponse(r, New().limits(), []string{"a", "b", "c"}, true, func(*Item) {})
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestScanGetResponseLine(t *testing.T) {
+	tests := []struct {
+		line        string
+		key         string
+		flags, size int
+		cas         uint64
+		ok          bool
+	}{
+		{"VALUE foo 3 10\r\n", "foo", 3, 10, 0, true},
+		{"VALUE foo 3 10 99\r\n", "foo", 3, 10, 99, true},
+		{"VALUE foo 4294967295 0\r\n", "foo", 1<<32 - 1, 0, 0, true},
+		{"VALUE foo 4294967296 0\r\n", "", 0, 0, 0, false},
+		{"VALUE foo 3\r\n", "", 0, 0, 0, false},
+		{"VALUE foo 3 x\r\n", "", 0, 0, 0, false},
+		{"VALUE foo 3 -1\r\n", "", 0, 0, 0, false},
+		{"VALUE foo 3 10 99 1\r\n", "", 0, 0, 0, false},
+		{"VALUE foo  3 10\r\n", "", 0, 0, 0, false},
+		{"VALUES foo 3 10\r\n", "", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		var it Item
+		size, err := scanGetResponseLine([]byte(tt.line), &it)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%q: err = %v, want ok %v", tt.line, err, tt.ok)
+			continue
+		}
+		if tt.ok && (it.Key != tt.key || it.Flags != uint32(tt.flags) || size != tt.size || it.casid != tt.cas) {
+			t.Errorf("%q = %q %d %d %d", tt.line, it.Key, it.Flags, size, it.casid)
+		}
+	}
+}
+
+func TestParseGetResponseAllocs(t *testing.T) {
+	resp := "VALUE key 0 5 1\r\nhello\r\nEND\r\n"
+	sr := strings.NewReader(resp)
+	r := bufio.NewReader(sr)
+	keys := []string{"key"}
+	lim := New().limits()
+	n := testing.AllocsPerRun(100, func() {
+		sr.Reset(resp)
+		r.Reset(sr)
+		if err := parseGetResponse(r, lim, keys, false, func(*Item) {}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// One for the Item and one for its value.
+	if n > 2 {
+		t.Errorf("parseGetResponse allocations = %v, want at most 2", n)
+	}
+}
+
+func BenchmarkGetMulti(b *testing.B) {
+	s := newFakeServer(b)
+	defer s.Close()
+	c := New(s.Addr())
+	keys := make([]string, 500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		s.put(keys[i], []byte("value"), 0)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if m, err := c.GetMulti(keys); err != nil || len(m) != len(keys) {
+			b.Fatalf("GetMulti = %d items, %v", len(m), err)
+		}
+	}
+}
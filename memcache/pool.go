@@ -0,0 +1,129 @@
+package memcache
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// minPooledShift and maxPooledShift bound the sizes of pooled value
+// buffers, from 64 bytes to 1MB. Smaller buffers are cheap to allocate,
+// and larger ones would pin too much memory in the pool.
+const (
+	minPooledShift = 6
+	maxPooledShift = 20
+
+	minPooledValue = 1 << minPooledShift
+	maxPooledValue = 1 << maxPooledShift
+)
+
+// valuePools pools value buffers by power-of-two capacity, from
+// minPooledValue to maxPooledValue.
+var valuePools [maxPooledShift - minPooledShift + 1]sync.Pool
+
+// valuePoolClass returns the index in valuePools of buffers that can
+// hold n bytes, or -1 if n is too large to pool.
+func valuePoolClass(n int) int {
+	if n > maxPooledValue {
+		return -1
+	}
+	if n <= minPooledValue {
+		return 0
+	}
+	return bits.Len(uint(n-1)) - minPooledShift
+}
+
+// getValueBuf returns a buffer of length zero and capacity at least n,
+// from the pool if n is small enough.
+func getValueBuf(n int) *[]byte {
+	class := valuePoolClass(n)
+	if class < 0 {
+		b := make([]byte, 0, n)
+		return &b
+	}
+	if bp, ok := valuePools[class].Get().(*[]byte); ok {
+		return bp
+	}
+	b := make([]byte, 0, minPooledValue<<class)
+	return &b
+}
+
+// putValueBuf returns a buffer obtained from getValueBuf to the pool.
+func putValueBuf(bp *[]byte) {
+	c := cap(*bp)
+	class := valuePoolClass(c)
+	if class < 0 || minPooledValue<<class != c {
+		return
+	}
+	*bp = (*bp)[:0]
+	valuePools[class].Put(bp)
+}
+
+// arenaSize is the size of the buffers of a valueArena, and
+// maxArenaValue the largest value, with its CRLF, placed in one.
+const (
+	arenaSize     = 64 << 10
+	maxArenaValue = arenaSize/4 - 2
+)
+
+// valueArena hands out slices of shared buffers to hold the values of a
+// response read with LazyValues.
+type valueArena struct {
+	buf []byte
+}
+
+// alloc returns a slice of length zero and capacity n, for n at most
+// maxArenaValue+2.
+func (a *valueArena) alloc(n int) []byte {
+	if cap(a.buf)-len(a.buf) < n {
+		a.buf = make([]byte, 0, arenaSize)
+	}
+	start := len(a.buf)
+	a.buf = a.buf[:start+n]
+	return a.buf[start : start : start+n]
+}
+
+// Bytes returns the item's value. For an item read with LazyValues,
+// the first call copies the value out of the shared response buffer and
+// stores it in Value.
+func (it *Item) Bytes() []byte {
+	if it.lazy != nil {
+		it.Value = append([]byte(nil), it.lazy...)
+		it.lazy = nil
+	}
+	return it.Value
+}
+
+// value returns the item's value without copying a lazy value.
+func (it *Item) value() []byte {
+	if it.lazy != nil {
+		return it.lazy
+	}
+	return it.Value
+}
+
+// clone returns a copy of it with its own copy of the value.
+func (it *Item) clone() *Item {
+	cp := *it
+	cp.Value = append([]byte(nil), it.value()...)
+	cp.lazy, cp.buf = nil, nil
+	return &cp
+}
+
+// Reset clears it for reuse, keeping its value buffer: Value is
+// truncated to length zero rather than released.
+func (it *Item) Reset() {
+	*it = Item{Value: it.Value[:0], buf: it.buf}
+}
+
+// Release returns the item's value buffer to the pool when the item was
+// read by a client with PoolValues set, and is a no-op otherwise. The
+// item's Value, and any slice of it, must not be used after Release;
+// Release sets Value to nil.
+func (it *Item) Release() {
+	if it.buf != nil {
+		putValueBuf(it.buf)
+		it.buf = nil
+	}
+	it.Value = nil
+	it.lazy = nil
+}
@@ -0,0 +1,152 @@
+package memcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValuePoolClass(t *testing.T) {
+	tests := []struct{ n, cap int }{
+		{0, 64}, {64, 64}, {65, 128}, {1000, 1024}, {1 << 20, 1 << 20}, {1<<20 + 1, -1},
+	}
+	for _, tt := range tests {
+		class := valuePoolClass(tt.n)
+		got := -1
+		if class >= 0 {
+			got = minPooledValue << class
+		}
+		if got != tt.cap {
+			t.Errorf("buffer capacity for %d bytes = %d, want %d", tt.n, got, tt.cap)
+		}
+	}
+}
+
+func TestPoolValues(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.PoolValues = true
+	s.put("a", []byte("alpha"), 0)
+	s.put("b", []byte(strings.Repeat("b", 3000)), 0)
+
+	it, err := c.Get("a")
+	if err != nil || string(it.Value) != "alpha" {
+		t.Fatalf("Get = %v, %v", it, err)
+	}
+	if it.buf == nil || cap(*it.buf) != minPooledValue {
+		t.Fatalf("value buffer not pooled")
+	}
+	it.Release()
+	if it.Value != nil || it.buf != nil {
+		t.Errorf("Release left Value %q", it.Value)
+	}
+	it.Release()
+
+	m, err := c.GetMulti([]string{"a", "b"})
+	if err != nil || string(m["a"].Value) != "alpha" || len(m["b"].Value) != 3000 {
+		t.Fatalf("GetMulti = %v, %v", m, err)
+	}
+	for _, it := range m {
+		it.Release()
+	}
+
+	c.MetaProtocol = true
+	it, err = c.Get("b")
+	if err != nil || len(it.Value) != 3000 || it.buf == nil {
+		t.Fatalf("meta Get = %v, %v", it, err)
+	}
+	it.Release()
+
+	// Items read without pooling can be released too.
+	c.PoolValues = false
+	it, err = c.Get("a")
+	if err != nil || it.buf != nil {
+		t.Fatalf("Get = %v, %v", it, err)
+	}
+	it.Release()
+}
+
+func TestItemReuse(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	s.put("a", []byte("alpha"), 3)
+	s.put("b", []byte("be"), 0)
+
+	it := &Item{Value: make([]byte, 0, 16)}
+	if err := c.GetInto("a", it); err != nil || string(it.Value) != "alpha" || it.Flags != 3 || it.Key != "a" {
+		t.Fatalf("GetInto(a) = %+v, %v", it, err)
+	}
+	backing := it.Value[:1]
+	it.Reset()
+	if it.Key != "" || it.Flags != 0 || len(it.Value) != 0 || cap(it.Value) != 16 {
+		t.Errorf("Reset left %+v", it)
+	}
+	if err := c.GetInto("b", it); err != nil || string(it.Value) != "be" || it.Flags != 0 {
+		t.Fatalf("GetInto(b) = %+v, %v", it, err)
+	}
+	if !sameArray(it.Value, backing) {
+		t.Error("GetInto did not reuse the value buffer")
+	}
+	if err := c.GetInto("missing", it); err != ErrCacheMiss || string(it.Value) != "be" {
+		t.Errorf("GetInto(missing) = %v, left %q", err, it.Value)
+	}
+
+	// Pooled buffers stay owned by the item across reuse.
+	c.PoolValues = true
+	pooled, err := c.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp := pooled.buf
+	pooled.Reset()
+	if err := c.GetInto("b", pooled); err != nil || pooled.buf != bp {
+		t.Errorf("GetInto into pooled item = %v, buf kept %v", err, pooled.buf == bp)
+	}
+	pooled.Release()
+}
+
+func TestLazyValues(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.LazyValues = true
+	big := strings.Repeat("b", 100000)
+	s.put("a", []byte("alpha"), 0)
+	s.put("b", []byte("beta"), 0)
+	s.put("big", []byte(big), 0)
+
+	m, err := c.GetMulti([]string{"a", "b", "big", "missing"})
+	if err != nil || len(m) != 3 {
+		t.Fatalf("GetMulti = %v, %v", m, err)
+	}
+	a := m["a"]
+	if a.Value != nil {
+		t.Errorf("lazy value materialized early: %q", a.Value)
+	}
+	if string(a.Bytes()) != "alpha" || string(a.Value) != "alpha" || a.lazy != nil {
+		t.Errorf("Bytes = %q, Value %q", a.Bytes(), a.Value)
+	}
+	if string(m["b"].Bytes()) != "beta" {
+		t.Errorf("Bytes(b) = %q", m["b"].Bytes())
+	}
+	// Large values are read eagerly.
+	if string(m["big"].Value) != big || string(m["big"].Bytes()) != big {
+		t.Errorf("big value = %d bytes", len(m["big"].Value))
+	}
+
+	// Single-key reads are unaffected.
+	it, err := c.Get("a")
+	if err != nil || string(it.Value) != "alpha" {
+		t.Errorf("Get = %v, %v", it, err)
+	}
+
+	// Values needing decoding are materialized.
+	c.Transcoders = []Transcoder{&ChecksumTranscoder{}}
+	mustSet(t, c, &Item{Key: "c", Value: []byte("checked")})
+	mustSet(t, c, &Item{Key: "d", Value: []byte("summed")})
+	m, err = c.GetMulti([]string{"c", "d"})
+	if err != nil || string(m["c"].Value) != "checked" || string(m["d"].Value) != "summed" {
+		t.Errorf("GetMulti with transcoder = %v, %v", m, err)
+	}
+}
@@ -0,0 +1,39 @@
+package memcache
+
+import "context"
+
+// Prefetch reads keys in the background and discards the results, for
+// callers that know ahead of time which keys they will need: the
+// connections to the keys' servers are established and pooled, ready
+// for the reads that follow, and the items read are added to the
+// client's L1 cache, if any. Prefetch returns immediately. Malformed
+// keys are ignored, and nothing is sent if ctx is done by the time the
+// background read starts.
+func (c *Client) Prefetch(ctx context.Context, keys []string) {
+	valid := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key = c.storageKey(key); legalKey(key) {
+			valid = append(valid, key)
+		}
+	}
+	if len(valid) == 0 {
+		return
+	}
+	go func() {
+		if ctx.Err() != nil {
+			return
+		}
+		gen := c.L1.generation()
+		items, err := c.getMulti(valid)
+		if err != nil {
+			c.logDebug("memcache: prefetch failed", "err", err)
+		}
+		if c.L1 != nil {
+			c.finishReads(items)
+		}
+		for _, it := range items {
+			c.L1.add(it, gen)
+			it.Release()
+		}
+	}()
+}
@@ -0,0 +1,37 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPrefetch(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	s.put("a", []byte("alpha"), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Prefetch(ctx, []string{"a"})
+	c.Prefetch(context.Background(), []string{"bad key"})
+	time.Sleep(10 * time.Millisecond)
+	if st := c.ConnStats()[s.Addr()]; st.Dials != 0 {
+		t.Errorf("canceled prefetch dialed %d times", st.Dials)
+	}
+
+	c.Prefetch(context.Background(), []string{"a", "b", "bad key"})
+	deadline := time.Now().Add(time.Second)
+	for c.ConnStats()[s.Addr()].Dials == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// Let the prefetch return its connection, then reuse it.
+	time.Sleep(10 * time.Millisecond)
+	if it, err := c.Get("a"); err != nil || string(it.Value) != "alpha" {
+		t.Fatalf("Get = %v, %v", it, err)
+	}
+	if st := c.ConnStats()[s.Addr()]; st.Dials != 1 {
+		t.Errorf("dials = %d, want 1 shared by prefetch and Get", st.Dials)
+	}
+}
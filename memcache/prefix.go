@@ -0,0 +1,139 @@
+package memcache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+)
+
+// hashedKeyMarker starts the part of a storage key replacing a key
+// hashed under HashLongKeys.
+const hashedKeyMarker = "#"
+
+// mapsKeys reports whether the client stores keys under other names.
+func (c *Client) mapsKeys() bool {
+	return c.KeyPrefix != "" || c.HashLongKeys || c.EscapeKeys || c.KeyMapper != nil
+}
+
+// storageKey returns the key stored on the servers for key: key,
+// mapped by KeyMapper and escaped with EscapeKeys, with the client's
+// KeyPrefix, hashed with HashLongKeys if that isn't a legal key.
+func (c *Client) storageKey(key string) string {
+	if c.KeyMapper != nil {
+		key = c.KeyMapper(key)
+	}
+	sk := c.KeyPrefix + key
+	if c.EscapeKeys {
+		sk = c.KeyPrefix + EscapeKey(key)
+	}
+	if c.HashLongKeys && !c.validKey(sk) {
+		sum := sha1.Sum([]byte(key))
+		sk = c.KeyPrefix + hashedKeyMarker + base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return sk
+}
+
+// acceptsKey reports whether key can be stored: whether its storage key
+// is legal.
+func (c *Client) acceptsKey(key string) bool {
+	return c.validKey(c.storageKey(key))
+}
+
+// storageKeys returns the keys stored on the servers for keys.
+func (c *Client) storageKeys(keys []string) []string {
+	if !c.mapsKeys() {
+		return keys
+	}
+	sk := make([]string, len(keys))
+	for i, key := range keys {
+		sk[i] = c.storageKey(key)
+	}
+	return sk
+}
+
+// restoreItem sets the key of it, read from the servers, back to key.
+func (c *Client) restoreItem(it *Item, key string) {
+	if it != nil {
+		it.Key = key
+	}
+}
+
+// restoreItems returns items, read from the servers for keys, keyed,
+// and with keys, as in keys.
+func (c *Client) restoreItems(items map[string]*Item, keys []string) map[string]*Item {
+	if !c.mapsKeys() || items == nil {
+		return items
+	}
+	m := make(map[string]*Item, len(items))
+	restored := make(map[*Item]bool, len(items))
+	for _, key := range keys {
+		it, ok := items[c.storageKey(key)]
+		if !ok {
+			continue
+		}
+		if restored[it] {
+			// Another key, such as one differing only in case under a
+			// lowercasing KeyMapper, maps to the same item.
+			if m[key] != nil {
+				continue
+			}
+			it = it.clone()
+		}
+		restored[it] = true
+		it.Key = key
+		m[key] = it
+	}
+	return m
+}
+
+// restoreResult returns res, the result of a batch for keys, keyed as
+// in keys.
+func (c *Client) restoreResult(res BatchResult, keys []string) BatchResult {
+	if !c.mapsKeys() {
+		return res
+	}
+	m := make(BatchResult, len(res))
+	for _, key := range keys {
+		if err, ok := res[c.storageKey(key)]; ok {
+			m[key] = err
+		}
+	}
+	return m
+}
+
+// withStorageKeys wraps fn to run with the storage keys of op's keys
+// and of the items it stores, and to give the items and results it
+// returns their keys back. Interceptors, which run outside fn, see the
+// keys of the caller.
+func (c *Client) withStorageKeys(fn OpFunc) OpFunc {
+	return func(ctx context.Context, op *Op) error {
+		keys, item, batch := op.Keys, op.Item, op.Batch
+		op.Keys = c.storageKeys(keys)
+		if item != nil {
+			it := *item
+			it.Key = c.storageKey(it.Key)
+			op.Item = &it
+		}
+		if batch != nil {
+			op.Batch = make([]*Item, len(batch))
+			for i, item := range batch {
+				it := *item
+				it.Key = c.storageKey(it.Key)
+				op.Batch[i] = &it
+			}
+		}
+		err := fn(ctx, op)
+		op.Keys, op.Batch = keys, batch
+		if op.Result != nil {
+			op.Result = c.restoreResult(op.Result, keys)
+		}
+		if item != nil {
+			item.casid = op.Item.casid
+			op.Item = item
+		} else if len(keys) == 1 {
+			c.restoreItem(op.Item, keys[0])
+		}
+		op.Items = c.restoreItems(op.Items, keys)
+		return err
+	}
+}
@@ -0,0 +1,151 @@
+package memcache
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestKeyPrefix(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.KeyPrefix = "app:"
+	var seen []string
+	c.Interceptors = []Interceptor{func(ctx context.Context, op *Op, next OpFunc) error {
+		seen = append(seen, op.Keys...)
+		return next(ctx, op)
+	}}
+
+	item := &Item{Key: "a", Value: []byte("1")}
+	mustSet(t, c, item)
+	if item.Key != "a" {
+		t.Errorf("Set changed the item's key to %q", item.Key)
+	}
+	if _, ok := s.get("app:a"); !ok {
+		t.Fatal("item not stored under the prefixed key")
+	}
+	if _, ok := s.get("a"); ok {
+		t.Fatal("item stored under the unprefixed key")
+	}
+	if len(seen) != 1 || seen[0] != "a" {
+		t.Errorf("interceptor saw keys %q, want [a]", seen)
+	}
+
+	it, err := c.Get("a")
+	if err != nil || it.Key != "a" || string(it.Value) != "1" {
+		t.Fatalf("Get = %+v, %v", it, err)
+	}
+	if err := c.CompareAndSwap(it); err != nil {
+		t.Errorf("CompareAndSwap of a read item = %v", err)
+	}
+
+	if res := c.SetMulti([]*Item{{Key: "b", Value: []byte("2")}}); res["b"] != nil || len(res) != 1 {
+		t.Errorf("SetMulti = %v", res)
+	}
+	items, err := c.GetMulti([]string{"a", "b"})
+	if err != nil || len(items) != 2 || items["a"].Key != "a" || items["b"].Key != "b" {
+		t.Fatalf("GetMulti = %v, %v", items, err)
+	}
+
+	if it, err := c.GetAsync("b").Wait(); err != nil || it.Key != "b" {
+		t.Errorf("GetAsync = %+v, %v", it, err)
+	}
+
+	if _, err := c.Increment("n", 1); err != ErrCacheMiss {
+		t.Errorf("Increment of missing counter = %v", err)
+	}
+	c.MetaProtocol = true
+	if n, err := c.NewCounter("n", 0).IncrBy(2); err != nil || n != 2 {
+		t.Errorf("counter = %d, %v", n, err)
+	}
+	if it, ok := s.get("app:n"); !ok || string(it.value) != "2" {
+		t.Errorf("counter stored as %+v, %v", it, ok)
+	}
+	c.MetaProtocol = false
+
+	if res := c.DeleteMulti([]string{"a", "b"}); res.Err() != nil || len(res) != 2 || res["a"] != nil {
+		t.Errorf("DeleteMulti = %v", res)
+	}
+	if _, ok := s.get("app:a"); ok {
+		t.Error("DeleteMulti left app:a")
+	}
+
+	// Other clients of the cluster don't see the prefixed keys.
+	other := New(s.Addr())
+	mustSet(t, other, &Item{Key: "c", Value: []byte("3")})
+	if _, err := c.Get("c"); err != ErrCacheMiss {
+		t.Errorf("Get of other client's key = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestHashLongKeys(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	long := strings.Repeat("x", 300)
+	if err := c.Set(&Item{Key: long, Value: []byte("1")}); err != ErrMalformedKey {
+		t.Fatalf("Set of long key without HashLongKeys = %v, want ErrMalformedKey", err)
+	}
+
+	c.HashLongKeys = true
+	c.KeyPrefix = "app:"
+	spaced := "https://example.com/a page"
+	for _, key := range []string{long, spaced} {
+		mustSet(t, c, &Item{Key: key, Value: []byte(key)})
+		sk := c.storageKey(key)
+		if !legalKey(sk) || !strings.HasPrefix(sk, "app:#") {
+			t.Errorf("storage key for %q = %q", key, sk)
+		}
+		if it, ok := s.get(sk); !ok || string(it.value) != key {
+			t.Errorf("stored item = %+v, %v", it, ok)
+		}
+		it, err := c.Get(key)
+		if err != nil || it.Key != key || string(it.Value) != key {
+			t.Errorf("Get = %+v, %v", it, err)
+		}
+	}
+	mustSet(t, c, &Item{Key: "short", Value: []byte("s")})
+	if _, ok := s.get("app:short"); !ok {
+		t.Error("legal key was hashed")
+	}
+
+	items, err := c.GetMulti([]string{long, spaced, "short"})
+	if err != nil || len(items) != 3 || items[long].Key != long || items[spaced].Key != spaced {
+		t.Fatalf("GetMulti = %v, %v", items, err)
+	}
+	v, err := c.GetOrSet(context.Background(), long+"!", 0, func() ([]byte, error) { return []byte("loaded"), nil })
+	if err != nil || string(v) != "loaded" {
+		t.Errorf("GetOrSet = %q, %v", v, err)
+	}
+	if res := c.DeleteMulti([]string{long, spaced}); res.Err() != nil || len(res) != 2 {
+		t.Errorf("DeleteMulti = %v", res)
+	}
+}
+
+func TestKeyMapper(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.KeyMapper = strings.ToLower
+	c.KeyPrefix = "tenant1:"
+
+	mustSet(t, c, &Item{Key: "User:42", Value: []byte("v")})
+	if _, ok := s.get("tenant1:user:42"); !ok {
+		t.Fatal("item not stored under the mapped key")
+	}
+	it, err := c.Get("USER:42")
+	if err != nil || it.Key != "USER:42" {
+		t.Fatalf("Get = %+v, %v", it, err)
+	}
+	items, err := c.GetMulti([]string{"user:42", "User:42", "user:42"})
+	if err != nil || len(items) != 2 || items["user:42"].Key != "user:42" || items["User:42"].Key != "User:42" {
+		t.Fatalf("GetMulti = %v, %v", items, err)
+	}
+	if items["user:42"] == items["User:42"] {
+		t.Error("GetMulti returned one item for two keys")
+	}
+	if res := c.DeleteMulti([]string{"USER:42"}); res["USER:42"] != nil {
+		t.Errorf("DeleteMulti = %v", res)
+	}
+}
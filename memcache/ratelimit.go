@@ -0,0 +1,67 @@
+package memcache
+
+import (
+	"strconv"
+	"time"
+)
+
+// RateLimiter limits the rate of events per key, such as requests per
+// user, with counters in memcached, so that the instances of a service
+// sharing a cluster enforce a limit together. Each window of time has
+// its own counter under the key followed by ":" and the window's
+// number.
+//
+// Every call of Allow counts, whether it is allowed or not, and a
+// counter lost to eviction or a server failure resets its window, so
+// the limit is approximate.
+type RateLimiter struct {
+	c *Client
+
+	// Sliding makes Allow estimate the events of the last window length
+	// of time, rather than count those of the current fixed window,
+	// which lets up to twice the limit through around the boundary of
+	// two windows. The estimate weighs the count of the previous window
+	// by the part of it still within the last window length.
+	Sliding bool
+
+	// now returns the current time; tests replace it.
+	now func() time.Time
+}
+
+// NewRateLimiter returns a RateLimiter keeping its counters with c.
+func NewRateLimiter(c *Client) *RateLimiter {
+	return &RateLimiter{c: c, now: time.Now}
+}
+
+// Allow counts an event for key and reports whether it is within limit
+// events per window.
+func (r *RateLimiter) Allow(key string, limit int64, window time.Duration) (bool, error) {
+	if window <= 0 {
+		return false, nil
+	}
+	now := r.now().UnixNano()
+	n := now / int64(window)
+	// Counters outlive their window by one more window when the
+	// previous one is needed, and by a second for rounding.
+	ttl := window + time.Second
+	if r.Sliding {
+		ttl += window
+	}
+	count, err := r.c.NewCounter(windowKey(key, n), ttl).IncrBy(1)
+	if err != nil {
+		return false, err
+	}
+	if !r.Sliding {
+		return count <= uint64(limit), nil
+	}
+	prev, err := r.c.NewCounter(windowKey(key, n-1), ttl).Value()
+	if err != nil {
+		return false, err
+	}
+	elapsed := float64(now%int64(window)) / float64(window)
+	return float64(prev)*(1-elapsed)+float64(count) <= float64(limit), nil
+}
+
+func windowKey(key string, n int64) string {
+	return key + ":" + strconv.FormatInt(n, 10)
+}
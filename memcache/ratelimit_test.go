@@ -0,0 +1,55 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	now := time.Unix(1000, 0)
+	allow := func(r *RateLimiter, n int) int {
+		t.Helper()
+		allowed := 0
+		for i := 0; i < n; i++ {
+			ok, err := r.Allow("user", 5, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok {
+				allowed++
+			}
+		}
+		return allowed
+	}
+
+	fixed := NewRateLimiter(c)
+	fixed.now = func() time.Time { return now }
+	if got := allow(fixed, 8); got != 5 {
+		t.Errorf("fixed window allowed %d of 8, want 5", got)
+	}
+	// The next window starts afresh.
+	now = now.Add(time.Minute)
+	if got := allow(fixed, 8); got != 5 {
+		t.Errorf("next fixed window allowed %d of 8, want 5", got)
+	}
+
+	s2 := newFakeServer(t)
+	defer s2.Close()
+	sliding := NewRateLimiter(New(s2.Addr()))
+	sliding.Sliding = true
+	now = time.Unix(60*100, 0)
+	sliding.now = func() time.Time { return now }
+	if got := allow(sliding, 4); got != 4 {
+		t.Errorf("sliding window allowed %d of 4, want 4", got)
+	}
+	// A quarter into the next window, three quarters of the previous
+	// window's 4 events still count.
+	now = now.Add(time.Minute + 15*time.Second)
+	if got := allow(sliding, 5); got != 2 {
+		t.Errorf("sliding window allowed %d of 5, want 2", got)
+	}
+}
@@ -0,0 +1,60 @@
+package memcache
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrReadOnly is returned by a ReadOnlyClient for any operation that
+// would modify the cache.
+var ErrReadOnly = errors.New("memcache: client is read-only")
+
+// ReadOnlyClient wraps a MemcacheClient and rejects all mutating
+// operations with ErrReadOnly. Reads are passed through unchanged.
+// It is intended for canary and replay environments that must never
+// write to a shared cache. It only covers the methods of MemcacheClient;
+// to make every write of a Client fail, set Client.ReadOnly.
+type ReadOnlyClient struct {
+	c MemcacheClient
+}
+
+// NewReadOnly returns a ReadOnlyClient that reads through c.
+func NewReadOnly(c MemcacheClient) *ReadOnlyClient {
+	return &ReadOnlyClient{c: c}
+}
+
+func (r *ReadOnlyClient) Get(key string) (*Item, error) {
+	return r.c.Get(key)
+}
+
+func (r *ReadOnlyClient) GetMulti(keys []string) (map[string]*Item, error) {
+	return r.c.GetMulti(keys)
+}
+
+func (r *ReadOnlyClient) Stats() (map[net.Addr]map[string]string, error) {
+	return r.c.Stats()
+}
+
+func (r *ReadOnlyClient) Set(item *Item) error {
+	return ErrReadOnly
+}
+
+func (r *ReadOnlyClient) Add(item *Item) error {
+	return ErrReadOnly
+}
+
+func (r *ReadOnlyClient) CompareAndSwap(item *Item) error {
+	return ErrReadOnly
+}
+
+func (r *ReadOnlyClient) Delete(key string) error {
+	return ErrReadOnly
+}
+
+func (r *ReadOnlyClient) Increment(key string, delta uint64) (uint64, error) {
+	return 0, ErrReadOnly
+}
+
+func (r *ReadOnlyClient) Decrement(key string, delta uint64) (uint64, error) {
+	return 0, ErrReadOnly
+}
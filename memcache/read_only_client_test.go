@@ -0,0 +1,106 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	var c MemcacheClient = NewReadOnly(New())
+	it := &Item{Key: "foo", Value: []byte("fooval")}
+	if err := c.Set(it); err != ErrReadOnly {
+		t.Errorf("Set: got %v, want ErrReadOnly", err)
+	}
+	if err := c.Add(it); err != ErrReadOnly {
+		t.Errorf("Add: got %v, want ErrReadOnly", err)
+	}
+	if err := c.CompareAndSwap(it); err != ErrReadOnly {
+		t.Errorf("CompareAndSwap: got %v, want ErrReadOnly", err)
+	}
+	if err := c.Delete("foo"); err != ErrReadOnly {
+		t.Errorf("Delete: got %v, want ErrReadOnly", err)
+	}
+	if _, err := c.Increment("foo", 1); err != ErrReadOnly {
+		t.Errorf("Increment: got %v, want ErrReadOnly", err)
+	}
+	if _, err := c.Decrement("foo", 1); err != ErrReadOnly {
+		t.Errorf("Decrement: got %v, want ErrReadOnly", err)
+	}
+	if _, err := c.Get("foo"); err != ErrNoServers {
+		t.Errorf("Get: got %v, want ErrNoServers from the wrapped client", err)
+	}
+}
+
+func TestClientReadOnly(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	s.put("foo", []byte("1"), 0)
+	c := New(s.Addr())
+	c.ReadOnly = true
+	c.Tombstones = true
+
+	ctx := context.Background()
+	it := func() *Item { return &Item{Key: "foo", Value: []byte("2")} }
+	update := func(old []byte) ([]byte, error) { return []byte("2"), nil }
+	for _, tt := range []struct {
+		name string
+		fn   func() error
+	}{
+		{"Set", func() error { return c.Set(it()) }},
+		{"Add", func() error { return c.Add(&Item{Key: "new", Value: []byte("2")}) }},
+		{"CompareAndSwap", func() error { return c.CompareAndSwap(it()) }},
+		{"Delete", func() error { return c.Delete("foo") }},
+		{"Touch", func() error { return c.Touch("foo", 60) }},
+		{"Increment", func() error { _, err := c.Increment("foo", 1); return err }},
+		{"Decrement", func() error { _, err := c.Decrement("foo", 1); return err }},
+		{"IncrementWithInit", func() error { _, err := c.IncrementWithInit("foo", 1, 0, 0); return err }},
+		{"GetAndTouch", func() error { _, err := c.GetAndTouch("foo", 60); return err }},
+		{"SetMulti", func() error { return c.SetMulti([]*Item{it()}).Err() }},
+		{"DeleteMulti", func() error { return c.DeleteMulti([]string{"foo"}).Err() }},
+		{"TouchMulti", func() error { return c.TouchMulti([]string{"foo"}, 60).Err() }},
+		{"SetAsync", func() error { _, err := c.SetAsync(it()).Wait(); return err }},
+		{"SetReader", func() error { return c.SetReader("foo", strings.NewReader("2"), 1, 0, 0) }},
+		{"SetObject", func() error { return c.SetObject(it()) }},
+		{"SetTagged", func() error { return c.SetTagged(it(), "tag") }},
+		{"InvalidateTag", func() error { return c.InvalidateTag("tag") }},
+		{"Invalidate", func() error { return c.Invalidate("foo") }},
+		{"DeleteSoft", func() error { return c.DeleteSoft("foo", time.Minute) }},
+		{"Update", func() error { return c.Update(ctx, "foo", 0, update) }},
+		{"GetLease", func() error { _, _, err := c.GetLease(ctx, "new", time.Second); return err }},
+		{"Lock", func() error { _, err := c.NewLock("lock", time.Second).TryLock(); return err }},
+		{"Counter.IncrBy", func() error { _, err := c.NewCounter("foo", 0).IncrBy(1); return err }},
+		{"Counter.DecrBy", func() error { _, err := c.NewCounter("foo", 0).DecrBy(1); return err }},
+		{"Counter.Reset", func() error { return c.NewCounter("foo", 0).Reset() }},
+	} {
+		if err := tt.fn(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s: got %v, want ErrReadOnly", tt.name, err)
+		}
+	}
+
+	c.MetaProtocol = true
+	if _, _, err := c.GetLease(ctx, "new", time.Second); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("meta GetLease: got %v, want ErrReadOnly", err)
+	}
+	c.MetaProtocol = false
+
+	r := NewWithRedundancy(s.Addr())
+	r.ReadOnly = true
+	if err := r.Set(it()); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("RedundantWriteClient.Set: got %v, want ErrReadOnly", err)
+	}
+
+	if it, ok := s.get("foo"); !ok || string(it.value) != "1" {
+		t.Errorf("foo modified: %q, %v", it.value, ok)
+	}
+	for _, key := range []string{"new", "lock"} {
+		if _, ok := s.get(key); ok {
+			t.Errorf("%s created", key)
+		}
+	}
+	if it, err := c.Get("foo"); err != nil || string(it.Value) != "1" {
+		t.Errorf("Get = %v, %v; want 1", it, err)
+	}
+}
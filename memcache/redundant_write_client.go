@@ -24,7 +24,7 @@ func NewWithRedundancy(server ...string) *RedundantWriteClient {
 }
 
 func NewRedundantClientFromSelector(ss ServerSelector) *RedundantWriteClient {
-	return &RedundantWriteClient{Client{selector: ss}}
+	return &RedundantWriteClient{Client{selector: ss, state: new(clientState)}}
 }
 
 func (c *RedundantWriteClient) Set(item *Item) error {
@@ -40,6 +40,9 @@ func (c *RedundantWriteClient) CompareAndSwap(item *Item) error {
 }
 
 func (c *RedundantWriteClient) onItem(item *Item, fn memcacheOpFunc) error {
+	if c.ReadOnly {
+		return ErrReadOnly
+	}
 	ss := c.selector.(*ServerList)
 	ss.lk.RLock()
 	defer ss.lk.RUnlock()
@@ -77,7 +80,7 @@ func (c *RedundantWriteClient) Delete(key string) error {
 	var failCount = 0
 	var err error
 	for _, addr := range ss.addrs {
-		err = c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+		err = c.withAddrRw(addr, "delete", []string{key}, func(rw *bufio.ReadWriter) error {
 			return writeExpectf(rw, resultDeleted, "delete %s\r\n", key)
 		})
 		if err != nil {
@@ -93,7 +96,7 @@ func (c *RedundantWriteClient) Delete(key string) error {
 
 // just like Client.onItem except with a specified address instead of using selector.PickServer
 func (c *RedundantWriteClient) onAddrItem(addr net.Addr, item *Item, fn memcacheOpFunc) error {
-	cn, err := c.getConn(addr)
+	cn, err := c.getConn(addr, opWrite)
 	if err != nil {
 		return err
 	}
@@ -109,7 +112,7 @@ func (c *RedundantWriteClient) incrDecr(verb, key string, delta uint64) (uint64,
 	var err error
 	ss := c.selector.(*ServerList)
 	for _, addr := range ss.addrs {
-		err = c.withAddrRw(addr, func(rw *bufio.ReadWriter) error {
+		err = c.withAddrRw(addr, verb, []string{key}, func(rw *bufio.ReadWriter) error {
 			var err error
 			val, err = c._incrDecr(rw, verb, key, delta)
 			return err
@@ -0,0 +1,99 @@
+package memcache
+
+import (
+	"sync"
+	"time"
+)
+
+// LoadFunc computes the value of key for a Refresher.
+type LoadFunc func(key string) ([]byte, error)
+
+// Refresher keeps registered keys fresh in the cache: it recomputes
+// each key with its loader at the key's refresh interval and stores the
+// result, so that readers find the value in the cache instead of
+// recomputing it when it expires.
+type Refresher struct {
+	c *Client
+
+	// OnError, if non-nil, is called when loading or storing a key
+	// fails. The key's previous value stays in the cache until it
+	// expires, and the key is retried at its next interval.
+	OnError func(key string, err error)
+
+	mu      sync.Mutex
+	entries map[string]chan struct{}
+	wg      sync.WaitGroup
+	closed  bool
+}
+
+// NewRefresher returns a Refresher storing keys with c.
+func NewRefresher(c *Client) *Refresher {
+	return &Refresher{c: c, entries: make(map[string]chan struct{})}
+}
+
+// Register starts refreshing key: load is called, and its result
+// stored with the given expiration, at once and then every interval.
+// expiration should exceed interval, so that the value doesn't expire
+// between refreshes. Registering a key again replaces its loader and
+// interval.
+func (r *Refresher) Register(key string, interval time.Duration, expiration int32, load LoadFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	if stop, ok := r.entries[key]; ok {
+		close(stop)
+	}
+	stop := make(chan struct{})
+	r.entries[key] = stop
+	r.wg.Add(1)
+	go r.run(key, interval, expiration, load, stop)
+}
+
+// Unregister stops refreshing key. Its value stays in the cache until
+// it expires.
+func (r *Refresher) Unregister(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stop, ok := r.entries[key]; ok {
+		close(stop)
+		delete(r.entries, key)
+	}
+}
+
+// Close stops refreshing all keys and waits for refreshes in progress.
+func (r *Refresher) Close() {
+	r.mu.Lock()
+	r.closed = true
+	for key, stop := range r.entries {
+		close(stop)
+		delete(r.entries, key)
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+func (r *Refresher) run(key string, interval time.Duration, expiration int32, load LoadFunc, stop chan struct{}) {
+	defer r.wg.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		r.refresh(key, expiration, load)
+		select {
+		case <-t.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (r *Refresher) refresh(key string, expiration int32, load LoadFunc) {
+	value, err := load(key)
+	if err == nil {
+		err = r.c.Set(&Item{Key: key, Value: value, Expiration: expiration})
+	}
+	if err != nil && r.OnError != nil {
+		r.OnError(key, err)
+	}
+}
@@ -0,0 +1,55 @@
+package memcache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefresher(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	r := NewRefresher(c)
+	var mu sync.Mutex
+	var failed []string
+	r.OnError = func(key string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, key)
+	}
+
+	var loads int32
+	r.Register("k", 5*time.Millisecond, 60, func(key string) ([]byte, error) {
+		n := atomic.AddInt32(&loads, 1)
+		return []byte(fmt.Sprintf("%s-%d", key, n)), nil
+	})
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&loads) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	r.Unregister("k")
+	n := atomic.LoadInt32(&loads)
+	if n < 3 {
+		t.Fatalf("loader called %d times, want refreshes", n)
+	}
+	it, err := c.Get("k")
+	if err != nil || len(it.Value) == 0 {
+		t.Fatalf("Get = %v, %v", it, err)
+	}
+
+	r.Register("bad", time.Hour, 60, func(string) ([]byte, error) { return nil, errors.New("boom") })
+	r.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 1 || failed[0] != "bad" {
+		t.Errorf("OnError keys = %v, want [bad]", failed)
+	}
+	r.Register("late", time.Hour, 60, func(string) ([]byte, error) { return nil, nil })
+	if _, err := c.Get("late"); err != ErrCacheMiss {
+		t.Errorf("Register after Close stored a value: %v", err)
+	}
+}
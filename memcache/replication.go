@@ -0,0 +1,328 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+)
+
+// DefaultReadRepairExpiration is the default expiration, in seconds,
+// of items written back to replicas by read repair. The original
+// expiration of an item is not known to the client, so repaired
+// copies are kept short-lived.
+const DefaultReadRepairExpiration = 300
+
+// ReplicaSelector is a ServerSelector that can choose several distinct
+// servers for a key, for use with Client.Replicas.
+//
+// All ReplicaSelector implementations must be threadsafe.
+type ReplicaSelector interface {
+	ServerSelector
+
+	// PickServers returns up to n distinct server addresses that the
+	// given key should be replicated onto. The first address must be
+	// the one PickServer returns.
+	PickServers(key string, n int) ([]net.Addr, error)
+}
+
+// ReplicaPolicy decides whether a replicated write succeeded.
+type ReplicaPolicy int
+
+const (
+	// WriteAll requires every replica to accept the write.
+	WriteAll ReplicaPolicy = iota
+
+	// WriteQuorum requires a majority of replicas to accept the write.
+	WriteQuorum
+
+	// WriteAny requires at least one replica to accept the write.
+	WriteAny
+)
+
+func (p ReplicaPolicy) satisfied(ok, n int) bool {
+	switch p {
+	case WriteQuorum:
+		return ok > n/2
+	case WriteAny:
+		return ok > 0
+	}
+	return ok == n
+}
+
+// ReplicaResult is the outcome of a write on a single replica.
+type ReplicaResult struct {
+	Addr net.Addr
+	Err  error
+}
+
+// ReplicaError is returned when a replicated write did not satisfy the
+// client's ReplicaPolicy, or when a quorum read could not reach enough
+// replicas. Results holds the outcome for each replica, primary first.
+type ReplicaError struct {
+	// Key is the operation's key, as selected by the client's ErrorKeys
+	// mode: empty under KeysOmitted.
+	Key     string
+	Results []ReplicaResult
+}
+
+func (e *ReplicaError) Error() string {
+	failed := 0
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	return fmt.Sprintf("memcache: operation failed on %d of %d replicas", failed, len(e.Results))
+}
+
+// Unwrap returns the errors of the replicas that failed.
+func (e *ReplicaError) Unwrap() []error {
+	var errs []error
+	for _, r := range e.Results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errs
+}
+
+func (c *Client) pickReplicas(key string) ([]net.Addr, error) {
+	rs, ok := c.selector.(ReplicaSelector)
+	if !ok {
+		addr, err := c.pickServer(key)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Addr{addr}, nil
+	}
+	return rs.PickServers(c.routingKey(key), c.Replicas)
+}
+
+// onReplicas calls fn concurrently for each replica of key and applies
+// the client's ReplicaPolicy to the results. If the policy is not
+// satisfied and the primary failed with a cache-level error such as
+// ErrNotStored, that error is returned as is so that the semantics of
+// conditional writes are preserved.
+func (c *Client) onReplicas(key string, fn func(net.Addr) error) error {
+	addrs, err := c.pickReplicas(key)
+	if err != nil {
+		return err
+	}
+	results := make([]ReplicaResult, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, addr net.Addr) {
+			defer wg.Done()
+			results[i] = ReplicaResult{Addr: addr, Err: fn(addr)}
+		}(i, addr)
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, r := range results {
+		if r.Err == nil {
+			ok++
+		}
+	}
+	for _, r := range results {
+		if r.Err != nil && !resumableError(r.Err) {
+			c.logWarn("memcache: replica write failed", "key", key, "addr", r.Addr, "err", r.Err)
+		}
+	}
+	if c.ReplicaPolicy.satisfied(ok, len(results)) {
+		return nil
+	}
+	if err := results[0].Err; resumableError(err) {
+		return err
+	}
+	return &ReplicaError{Key: c.reportedKey(key), Results: results}
+}
+
+// withKeyWriteRw is like withKeyRw for write operations, but applies fn
+// to every replica of key when replication is enabled. fn may be called
+// concurrently.
+func (c *Client) withKeyWriteRw(key string, op string, fn func(*bufio.ReadWriter) error) error {
+	if c.Replicas <= 1 {
+		return c.withKeyRw(key, op, fn)
+	}
+	if !c.validKey(key) {
+		return ErrMalformedKey
+	}
+	return c.onReplicas(key, func(addr net.Addr) error {
+		return c.withAddrRw(addr, op, []string{key}, fn)
+	})
+}
+
+// preferLocalZone reorders addrs in place so that servers in the
+// client's LocalZone come first, keeping the relative order otherwise.
+func (c *Client) preferLocalZone(addrs []net.Addr) {
+	zs, ok := c.selector.(ZoneSelector)
+	if !ok || c.LocalZone == "" {
+		return
+	}
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return zs.Zone(addrs[i]) == c.LocalZone && zs.Zone(addrs[j]) != c.LocalZone
+	})
+}
+
+// getReplicated reads key from its replicas in order, moving on to the
+// next replica on a server error, or on a miss if ReadFallbackOnMiss is
+// set. Replicas in the client's LocalZone are tried first. Replicas
+// that were skipped are repaired in the background.
+func (c *Client) getReplicated(key string) (*Item, error) {
+	if !c.validKey(key) {
+		return nil, ErrMalformedKey
+	}
+	addrs, err := c.pickReplicas(key)
+	if err != nil {
+		return nil, err
+	}
+	c.preferLocalZone(addrs)
+	for i, addr := range addrs {
+		var item *Item
+		err = c.getFromAddr(addr, []string{key}, func(it *Item) { item = it })
+		if err == nil && item == nil {
+			err = ErrCacheMiss
+		}
+		if err == nil {
+			if i > 0 {
+				c.readRepair(addrs[:i], item)
+			}
+			return item, nil
+		}
+		if errors.Is(err, ErrCacheMiss) && !c.ReadFallbackOnMiss {
+			break
+		}
+		if i+1 < len(addrs) {
+			c.logDebug("memcache: falling back to next replica", "key", key, "addr", addr, "err", err)
+		}
+	}
+	return nil, err
+}
+
+// readRepair asynchronously adds a copy of item to addrs. Add rather
+// than Set is used so that a newer value written in the meantime is not
+// clobbered.
+func (c *Client) readRepair(addrs []net.Addr, item *Item) {
+	exp := c.ReadRepairExpiration
+	if exp < 0 {
+		return
+	}
+	if exp == 0 {
+		exp = DefaultReadRepairExpiration
+	}
+	it := &Item{
+		Key:        item.Key,
+		Value:      append([]byte(nil), item.Value...),
+		Flags:      item.Flags,
+		Expiration: exp,
+	}
+	for _, addr := range addrs {
+		go func(addr net.Addr) {
+			err := c.onItemAtAddr(addr, "add", it, (*Client).add)
+			if err != nil && !errors.Is(err, ErrNotStored) {
+				c.logWarn("memcache: read repair failed", "key", it.Key, "addr", addr, "err", err)
+			}
+		}(addr)
+	}
+}
+
+// GetQuorum reads key from all of its replicas and waits for a majority
+// of them to answer, either with the item or with a cache miss. Of the
+// items returned, the one with the highest version is chosen, which
+// tolerates a replica that missed a recent write. ErrCacheMiss is
+// returned if every answering replica missed.
+//
+// Versions are taken from ItemVersion if set. Otherwise the items' CAS
+// IDs are compared, which is only meaningful if the servers assign CAS
+// IDs in step, so applications relying on GetQuorum should embed a
+// version in their values and set ItemVersion.
+//
+// The item is decoded as by Get. It is never served from the L1 cache,
+// whose copy may be older than the replicas', but refreshes it. With
+// Replicas of one or less, GetQuorum is a Get.
+func (c *Client) GetQuorum(key string) (*Item, error) {
+	if c.Replicas <= 1 {
+		return c.Get(key)
+	}
+	op := &Op{Name: "getquorum", Keys: []string{key}}
+	err := c.intercept(op, func(ctx context.Context, op *Op) (err error) {
+		gen := c.L1.generation()
+		op.Item, err = c.getQuorum(key, op.Keys[0])
+		if err == nil {
+			err = c.finishRead(op.Item)
+		}
+		if err == nil {
+			c.L1.add(op.Item, gen)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return op.Item, nil
+}
+
+// getQuorum reads the item stored under the storage key sk from a
+// quorum of its replicas. key is the key the caller asked for.
+func (c *Client) getQuorum(key, sk string) (*Item, error) {
+	if !c.validKey(sk) {
+		return nil, ErrMalformedKey
+	}
+	addrs, err := c.pickReplicas(sk)
+	if err != nil {
+		return nil, err
+	}
+	type result struct {
+		i    int
+		item *Item
+		err  error
+	}
+	ch := make(chan result, len(addrs))
+	for i, addr := range addrs {
+		go func(i int, addr net.Addr) {
+			var item *Item
+			err := c.getFromAddr(addr, []string{sk}, func(it *Item) { item = it })
+			ch <- result{i, item, err}
+		}(i, addr)
+	}
+
+	quorum := len(addrs)/2 + 1
+	results := make([]ReplicaResult, len(addrs))
+	for i, addr := range addrs {
+		results[i].Addr = addr
+	}
+	var best *Item
+	answered, failed := 0, 0
+	for answered < quorum && failed <= len(addrs)-quorum {
+		r := <-ch
+		if r.err != nil {
+			results[r.i].Err = r.err
+			failed++
+			continue
+		}
+		answered++
+		if r.item != nil && (best == nil || c.itemVersion(r.item) > c.itemVersion(best)) {
+			best = r.item
+		}
+	}
+	if answered < quorum {
+		return nil, &ReplicaError{Key: c.reportedKey(key), Results: results}
+	}
+	if best == nil {
+		return nil, ErrCacheMiss
+	}
+	return best, nil
+}
+
+func (c *Client) itemVersion(it *Item) uint64 {
+	if c.ItemVersion != nil {
+		return c.ItemVersion(it)
+	}
+	return it.casid
+}
@@ -0,0 +1,216 @@
+package memcache
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPickServers(t *testing.T) {
+	ss := new(ServerList)
+	if err := ss.SetServers("127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11211", "127.0.0.1:11213"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"foo", "bar", "baz"} {
+		primary, err := ss.PickServer(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs, err := ss.PickServers(key, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(addrs) != 3 {
+			t.Errorf("PickServers(%q) returned %d servers, want 3 distinct", key, len(addrs))
+		}
+		if addrs[0].String() != primary.String() {
+			t.Errorf("PickServers(%q)[0] = %v, want primary %v", key, addrs[0], primary)
+		}
+		seen := map[string]bool{}
+		for _, a := range addrs {
+			if seen[a.String()] {
+				t.Errorf("PickServers(%q) returned %v twice", key, a)
+			}
+			seen[a.String()] = true
+		}
+	}
+}
+
+func TestReplicaPolicy(t *testing.T) {
+	tests := []struct {
+		p     ReplicaPolicy
+		ok, n int
+		want  bool
+	}{
+		{WriteAll, 3, 3, true},
+		{WriteAll, 2, 3, false},
+		{WriteQuorum, 2, 3, true},
+		{WriteQuorum, 1, 2, false},
+		{WriteAny, 1, 3, true},
+		{WriteAny, 0, 3, false},
+	}
+	for _, tt := range tests {
+		if got := tt.p.satisfied(tt.ok, tt.n); got != tt.want {
+			t.Errorf("policy %d satisfied(%d, %d) = %v, want %v", tt.p, tt.ok, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestReplicatedWriteAndFallback(t *testing.T) {
+	s1, s2 := newFakeServer(t), newFakeServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	byAddr := map[string]*fakeServer{s1.Addr(): s1, s2.Addr(): s2}
+
+	c := New(s1.Addr(), s2.Addr())
+	c.Replicas = 2
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("fooval")})
+	for addr, s := range byAddr {
+		if it, ok := s.get("foo"); !ok || string(it.value) != "fooval" {
+			t.Errorf("replica %s: foo not written", addr)
+		}
+	}
+
+	addrs, err := c.pickReplicas("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary := byAddr[addrs[0].String()]
+	primary.del("foo")
+
+	if _, err := c.Get("foo"); err != ErrCacheMiss {
+		t.Fatalf("Get without ReadFallbackOnMiss: got %v, want ErrCacheMiss", err)
+	}
+	c.ReadFallbackOnMiss = true
+	it, err := c.Get("foo")
+	if err != nil {
+		t.Fatalf("Get with ReadFallbackOnMiss: %v", err)
+	}
+	if string(it.Value) != "fooval" {
+		t.Errorf("Get = %q, want fooval", it.Value)
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := primary.get("foo"); ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("primary was not repaired")
+}
+
+func TestGetQuorum(t *testing.T) {
+	servers := []*fakeServer{newFakeServer(t), newFakeServer(t), newFakeServer(t)}
+	var addrs []string
+	for _, s := range servers {
+		defer s.Close()
+		addrs = append(addrs, s.Addr())
+	}
+	c := New(addrs...)
+	c.Replicas = 3
+	c.ItemVersion = func(it *Item) uint64 { return uint64(it.Flags) }
+
+	if _, err := c.GetQuorum("foo"); err != ErrCacheMiss {
+		t.Fatalf("GetQuorum on empty replicas: got %v, want ErrCacheMiss", err)
+	}
+	for i, s := range servers {
+		s.put("foo", []byte("v"+strconv.Itoa(i)), uint32(i))
+	}
+	// Any majority includes at least one of the two newest versions.
+	it, err := c.GetQuorum("foo")
+	if err != nil {
+		t.Fatalf("GetQuorum: %v", err)
+	}
+	if it.Flags == 0 {
+		t.Errorf("GetQuorum returned the oldest version %q", it.Value)
+	}
+}
+
+func TestGetQuorumTranscoded(t *testing.T) {
+	servers := []*fakeServer{newFakeServer(t), newFakeServer(t), newFakeServer(t)}
+	var addrs []string
+	for _, s := range servers {
+		defer s.Close()
+		addrs = append(addrs, s.Addr())
+	}
+	c := New(addrs...)
+	c.Replicas = 3
+	c.KeyPrefix = "app:"
+	c.Transcoders = []Transcoder{&CompressionTranscoder{MinSize: 1}}
+	var ops []string
+	c.Interceptors = []Interceptor{func(ctx context.Context, op *Op, next OpFunc) error {
+		ops = append(ops, op.Name)
+		return next(ctx, op)
+	}}
+
+	value := bytes.Repeat([]byte("0123456789"), 200)
+	mustSet(t, c, &Item{Key: "foo", Value: value})
+	if raw, _ := servers[0].get("app:foo"); raw.flags != DefaultCompressionFlag {
+		t.Fatalf("stored flags = %#x, want the value compressed", raw.flags)
+	}
+	it, err := c.GetQuorum("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.Key != "foo" || it.Flags != 0 || !bytes.Equal(it.Value, value) {
+		t.Errorf("GetQuorum = key %q, flags %#x, %d bytes; want the decoded item", it.Key, it.Flags, len(it.Value))
+	}
+	if got := strings.Join(ops, ","); got != "set,getquorum" {
+		t.Errorf("intercepted ops = %s, want set,getquorum", got)
+	}
+}
+
+func TestGetQuorumUnreplicated(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	if _, err := c.GetQuorum("foo"); err != ErrCacheMiss {
+		t.Errorf("GetQuorum of missing key = %v, want ErrCacheMiss", err)
+	}
+	s.put("foo", []byte("v"), 0)
+	if it, err := c.GetQuorum("foo"); err != nil || string(it.Value) != "v" {
+		t.Errorf("GetQuorum = %+v, %v", it, err)
+	}
+}
+
+func TestPreferLocalZone(t *testing.T) {
+	ss := new(ServerList)
+	servers := []string{"127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213"}
+	if err := ss.SetServers(servers...); err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.SetZones(map[string]string{servers[0]: "a", servers[1]: "b", servers[2]: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	c := NewFromSelector(ss)
+	c.Replicas = 3
+	c.LocalZone = "b"
+	for _, key := range []string{"foo", "bar", "baz"} {
+		addrs, err := c.pickReplicas(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.preferLocalZone(addrs)
+		if ss.Zone(addrs[0]) != "b" || ss.Zone(addrs[1]) != "b" || ss.Zone(addrs[2]) != "a" {
+			t.Errorf("key %q: replicas %v not ordered by local zone", key, addrs)
+		}
+	}
+}
+
+func TestReplicatedCAS(t *testing.T) {
+	s1, s2 := newFakeServer(t), newFakeServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	c := New(s1.Addr(), s2.Addr())
+	c.Replicas = 2
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("v")})
+	it, err := c.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CompareAndSwap(it); err != ErrReplicatedCAS {
+		t.Errorf("CompareAndSwap with replicas = %v, want ErrReplicatedCAS", err)
+	}
+}
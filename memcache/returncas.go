@@ -0,0 +1,39 @@
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+)
+
+// returnsCAS reports whether storage commands set the CAS ID of the
+// value stored on the item.
+func (c *Client) returnsCAS() bool {
+	return c.ReturnCAS && c.MetaProtocol && c.Replicas <= 1
+}
+
+// readStoredCAS flushes an "ms" command sent with the c flag, maps its
+// status to an error and sets the returned CAS ID on item.
+func (c *Client) readStoredCAS(rw *bufio.ReadWriter, item *Item) error {
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	mr, err := readMetaResponse(rw.Reader, c.limits(), c.StrictResponses)
+	if err != nil {
+		return err
+	}
+	if mr.status != "HD" {
+		if err := metaStatusError(mr.status); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: unexpected %s response to ms", ErrProtocol, mr.status)
+	}
+	tok, ok := mr.flag('c')
+	if !ok {
+		return fmt.Errorf("%w: no CAS in ms response", ErrProtocol)
+	}
+	if item.casid, err = strconv.ParseUint(tok, 10, 64); err != nil {
+		return fmt.Errorf("%w: malformed CAS %q in meta response", ErrProtocol, tok)
+	}
+	return nil
+}
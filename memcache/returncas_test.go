@@ -0,0 +1,93 @@
+package memcache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReturnCAS(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.MetaProtocol = true
+	c.ReturnCAS = true
+	c.KeyPrefix = "app:"
+
+	it := &Item{Key: "foo", Value: []byte("v1")}
+	if err := c.Set(it); err != nil {
+		t.Fatal(err)
+	}
+	fi, _ := s.get("app:foo")
+	if it.CasID() == 0 || it.CasID() != fi.cas {
+		t.Errorf("CasID after Set = %d, want %d", it.CasID(), fi.cas)
+	}
+	it.Value = []byte("v2")
+	if err := c.CompareAndSwap(it); err != nil {
+		t.Fatalf("CompareAndSwap with CasID from Set: %v", err)
+	}
+	it.Value = []byte("v3")
+	if err := c.CompareAndSwap(it); err != nil {
+		t.Errorf("CompareAndSwap with CasID from CompareAndSwap: %v", err)
+	}
+
+	if err := c.Add(it); err != ErrNotStored {
+		t.Errorf("Add of existing key = %v, want ErrNotStored", err)
+	}
+	added := &Item{Key: "bar", Value: []byte("v")}
+	if err := c.Add(added); err != nil || added.CasID() == 0 {
+		t.Errorf("Add = %v with CasID %d, want a CasID", err, added.CasID())
+	}
+}
+
+func TestReturnCASWithoutMeta(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.ReturnCAS = true
+	if err := c.Set(&Item{Key: "foo", Value: []byte("v")}); !errors.Is(err, ErrReturnCASWithoutMeta) {
+		t.Errorf("Set = %v, want ErrReturnCASWithoutMeta", err)
+	}
+	if _, ok := s.get("foo"); ok {
+		t.Error("Set stored the item")
+	}
+}
+
+func TestReturnCASDisabled(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	it := &Item{Key: "foo", Value: []byte("v1")}
+	mustSet(t, c, it)
+	if it.CasID() != 0 {
+		t.Errorf("CasID without ReturnCAS = %d, want 0", it.CasID())
+	}
+}
+
+func TestCasID(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	for _, meta := range []bool{false, true} {
+		c := New(s.Addr())
+		c.MetaProtocol = meta
+		mustSet(t, c, &Item{Key: "foo", Value: []byte("v1")})
+		items, err := c.GetMulti([]string{"foo"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := items["foo"].CasID()
+		fi, _ := s.get("foo")
+		if id == 0 || id != fi.cas {
+			t.Fatalf("meta=%v: CasID = %d, want %d", meta, id, fi.cas)
+		}
+
+		it := &Item{Key: "foo", Value: []byte("v2")}
+		it.SetCasID(id)
+		if err := c.CompareAndSwap(it); err != nil {
+			t.Errorf("meta=%v: CompareAndSwap with held CasID: %v", meta, err)
+		}
+		it.Value = []byte("v3")
+		if err := c.CompareAndSwap(it); err != ErrCASConflict {
+			t.Errorf("meta=%v: CompareAndSwap with stale CasID = %v, want ErrCASConflict", meta, err)
+		}
+	}
+}
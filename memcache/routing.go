@@ -0,0 +1,102 @@
+package memcache
+
+import (
+	"net"
+	"strings"
+)
+
+// routingKey returns the key servers are picked for key by: the one
+// returned by the client's RoutingKey function, if any.
+func (c *Client) routingKey(key string) string {
+	if c.RoutingKey != nil {
+		if rk := c.RoutingKey(key); rk != "" {
+			return rk
+		}
+	}
+	return key
+}
+
+// pickServer returns the server for key.
+func (c *Client) pickServer(key string) (net.Addr, error) {
+	return c.selector.PickServer(c.routingKey(key))
+}
+
+// HashTag is a RoutingKey function routing keys containing a hash tag,
+// a part between '{' and the next '}', such as "{user:42}:profile", by
+// the tag, so that all the keys with a tag are stored on one server.
+// Other keys, and keys with an empty tag, are routed by the whole key.
+func HashTag(key string) string {
+	i := strings.IndexByte(key, '{')
+	if i < 0 {
+		return ""
+	}
+	j := strings.IndexByte(key[i+1:], '}')
+	if j <= 0 {
+		return ""
+	}
+	return key[i+1 : i+1+j]
+}
+
+// routeSelector overrides the server choice of an underlying selector.
+type routeSelector struct {
+	base ServerSelector
+
+	// addr, if non-nil, is the server every key is sent to. Otherwise
+	// every key is routed as if it were key.
+	addr net.Addr
+	key  string
+}
+
+func (rs *routeSelector) PickServer(key string) (net.Addr, error) {
+	if rs.addr != nil {
+		return rs.addr, nil
+	}
+	return rs.base.PickServer(rs.key)
+}
+
+func (rs *routeSelector) PickServers(key string, n int) ([]net.Addr, error) {
+	if rs.addr != nil {
+		return []net.Addr{rs.addr}, nil
+	}
+	if rep, ok := rs.base.(ReplicaSelector); ok {
+		return rep.PickServers(rs.key, n)
+	}
+	addr, err := rs.base.PickServer(rs.key)
+	if err != nil {
+		return nil, err
+	}
+	return []net.Addr{addr}, nil
+}
+
+// view returns a copy of c that shares its connections and counters but
+// routes keys with rs.
+func (c *Client) view(rs *routeSelector) *Client {
+	nc := *c
+	if base, ok := c.selector.(*routeSelector); ok {
+		rs.base = base.base
+	} else {
+		rs.base = c.selector
+	}
+	nc.selector = rs
+	return &nc
+}
+
+// OnServer returns a client that sends every operation to server,
+// bypassing the selector, for debugging a specific node. The server
+// name is given in the same form as to ServerList.SetServers. The
+// returned client shares c's connections and copies its settings.
+func (c *Client) OnServer(server string) (*Client, error) {
+	addr, err := resolveServer(server)
+	if err != nil {
+		return nil, err
+	}
+	return c.view(&routeSelector{addr: addr}), nil
+}
+
+// WithRoutingKey returns a client that picks servers for every
+// operation as if the key were routingKey, so that related keys can be
+// co-located on one server. The returned client shares c's connections
+// and copies its settings.
+func (c *Client) WithRoutingKey(routingKey string) *Client {
+	return c.view(&routeSelector{key: routingKey})
+}
@@ -0,0 +1,93 @@
+package memcache
+
+import (
+	"testing"
+)
+
+func TestRoutingViews(t *testing.T) {
+	s1, s2 := newFakeServer(t), newFakeServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	c := New(s1.Addr(), s2.Addr())
+
+	on2, err := c.OnServer(s2.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		mustSet(t, on2, &Item{Key: key, Value: []byte(key)})
+		if _, ok := s2.get(key); !ok {
+			t.Errorf("OnServer: key %q not written to pinned server", key)
+		}
+	}
+
+	routed := c.WithRoutingKey("user:1")
+	want, err := c.selector.PickServer("user:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"user:1:name", "user:1:email", "user:1:prefs"} {
+		addr, err := routed.selector.PickServer(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr.String() != want.String() {
+			t.Errorf("WithRoutingKey: %q routed to %v, want %v", key, addr, want)
+		}
+	}
+	if _, err := routed.Stats(); err != nil {
+		t.Errorf("Stats on routed view: %v", err)
+	}
+}
+
+func TestRoutingKeyFunc(t *testing.T) {
+	s1, s2 := newFakeServer(t), newFakeServer(t)
+	defer s1.Close()
+	defer s2.Close()
+	c := New(s1.Addr(), s2.Addr())
+	c.RoutingKey = HashTag
+	c.KeyPrefix = "app:"
+
+	keys := []string{"{user:1}:name", "{user:1}:email", "{user:1}:prefs", "{user:1}:cart"}
+	for _, key := range keys {
+		mustSet(t, c, &Item{Key: key, Value: []byte(key)})
+	}
+	want, err := c.selector.PickServer("user:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n1, n2 int
+	for _, key := range keys {
+		if addr, _ := c.pickServer("app:" + key); addr.String() != want.String() {
+			t.Errorf("%q routed to %v, want %v", key, addr, want)
+		}
+		if _, ok := s1.get("app:" + key); ok {
+			n1++
+		}
+		if _, ok := s2.get("app:" + key); ok {
+			n2++
+		}
+	}
+	if n1 != len(keys) && n2 != len(keys) {
+		t.Errorf("keys split across servers: %d and %d", n1, n2)
+	}
+	items, err := c.GetMulti(keys)
+	if err != nil || len(items) != len(keys) {
+		t.Errorf("GetMulti = %v, %v", items, err)
+	}
+}
+
+func TestHashTag(t *testing.T) {
+	for key, want := range map[string]string{
+		"{user:1}:name": "user:1",
+		"a:{b}:c":       "b",
+		"plain":         "",
+		"{}:empty":      "",
+		"{unclosed":     "",
+		"x}{y}":         "y",
+	} {
+		if got := HashTag(key); got != want {
+			t.Errorf("HashTag(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
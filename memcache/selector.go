@@ -17,7 +17,6 @@ limitations under the License.
 package memcache
 
 import (
-	"hash/crc32"
 	"net"
 	"strings"
 	"sync"
@@ -33,10 +32,19 @@ type ServerSelector interface {
 	PickServer(key string) (net.Addr, error)
 }
 
+// ZoneSelector is implemented by selectors that know the zone (such as
+// an availability zone) each server is located in. A replicated client
+// with LocalZone set prefers same-zone replicas for reads.
+type ZoneSelector interface {
+	// Zone returns the zone of addr, or "" if unknown.
+	Zone(addr net.Addr) string
+}
+
 // ServerList is a simple ServerSelector. Its zero value is usable.
 type ServerList struct {
 	lk    sync.RWMutex
 	addrs []net.Addr
+	zones map[string]string
 }
 
 // staticAddr caches the Network() and String() values from any net.Addr.
@@ -66,19 +74,11 @@ func (s *staticAddr) String() string  { return s.str }
 func (ss *ServerList) SetServers(servers ...string) error {
 	naddr := make([]net.Addr, len(servers))
 	for i, server := range servers {
-		if strings.Contains(server, "/") {
-			addr, err := net.ResolveUnixAddr("unix", server)
-			if err != nil {
-				return err
-			}
-			naddr[i] = newStaticAddr(addr)
-		} else {
-			tcpaddr, err := net.ResolveTCPAddr("tcp", server)
-			if err != nil {
-				return err
-			}
-			naddr[i] = newStaticAddr(tcpaddr)
+		addr, err := resolveServer(server)
+		if err != nil {
+			return err
 		}
+		naddr[i] = addr
 	}
 
 	ss.lk.Lock()
@@ -87,13 +87,83 @@ func (ss *ServerList) SetServers(servers ...string) error {
 	return nil
 }
 
+func resolveServer(server string) (net.Addr, error) {
+	if strings.Contains(server, "/") {
+		addr, err := net.ResolveUnixAddr("unix", server)
+		if err != nil {
+			return nil, err
+		}
+		return newStaticAddr(addr), nil
+	}
+	tcpaddr, err := net.ResolveTCPAddr("tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	return newStaticAddr(tcpaddr), nil
+}
+
+// SetZones tags servers with zone labels, given as a map from server
+// name, in the same form as passed to SetServers, to zone. It replaces
+// any previous zone labels. As with SetServers, no changes are made if
+// any name fails to resolve.
+func (ss *ServerList) SetZones(zones map[string]string) error {
+	nzones := make(map[string]string, len(zones))
+	for server, zone := range zones {
+		addr, err := resolveServer(server)
+		if err != nil {
+			return err
+		}
+		nzones[addr.String()] = zone
+	}
+
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+	ss.zones = nzones
+	return nil
+}
+
+// Zone returns the zone label set for addr with SetZones, if any.
+func (ss *ServerList) Zone(addr net.Addr) string {
+	ss.lk.RLock()
+	defer ss.lk.RUnlock()
+	return ss.zones[addr.String()]
+}
+
 func (ss *ServerList) PickServer(key string) (net.Addr, error) {
 	ss.lk.RLock()
 	defer ss.lk.RUnlock()
 	if len(ss.addrs) == 0 {
 		return nil, ErrNoServers
 	}
-	// TODO-GO: remove this copy
-	cs := crc32.ChecksumIEEE([]byte(key))
+	cs := keyHash(key)
 	return ss.addrs[cs%uint32(len(ss.addrs))], nil
 }
+
+// PickServers returns up to n distinct servers for key, starting with
+// the one PickServer would return and continuing in list order.
+func (ss *ServerList) PickServers(key string, n int) ([]net.Addr, error) {
+	ss.lk.RLock()
+	defer ss.lk.RUnlock()
+	if len(ss.addrs) == 0 {
+		return nil, ErrNoServers
+	}
+	cs := keyHash(key)
+	start := int(cs % uint32(len(ss.addrs)))
+	addrs := make([]net.Addr, 0, n)
+	for i := 0; i < len(ss.addrs) && len(addrs) < n; i++ {
+		addr := ss.addrs[(start+i)%len(ss.addrs)]
+		if !containsAddr(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
+
+func containsAddr(addrs []net.Addr, addr net.Addr) bool {
+	for _, a := range addrs {
+		if a.String() == addr.String() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+//go:build go1.20
+
+package memcache
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestPickServerAllocs needs the allocation-free keyHash of Go 1.20;
+// before that, keyHash copies the key.
+func TestPickServerAllocs(t *testing.T) {
+	var ss ServerList
+	if err := ss.SetServers("127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213"); err != nil {
+		t.Fatal(err)
+	}
+	key := strings.Repeat("k", 250)
+	n := testing.AllocsPerRun(100, func() {
+		if !legalKey(key) {
+			t.Fatal("key is not legal")
+		}
+		if _, err := ss.PickServer(key); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if n != 0 {
+		t.Errorf("legalKey and PickServer allocations = %v, want 0", n)
+	}
+}
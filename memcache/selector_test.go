@@ -0,0 +1,15 @@
+package memcache
+
+import (
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+func TestKeyHash(t *testing.T) {
+	for _, key := range []string{"", "a", "foo", "user:12345:profile", strings.Repeat("k", 250)} {
+		if got, want := keyHash(key), crc32.ChecksumIEEE([]byte(key)); got != want {
+			t.Errorf("keyHash(%q) = %#x, want %#x", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Package sessions stores web sessions in memcache.
+//
+// Typical use:
+//
+//	mc := memcache.New("10.0.0.1:11211", "10.0.0.2:11211")
+//	store := sessions.NewStore(mc, 24*time.Hour)
+//
+//	sess, err := store.Get(cookie.Value) // a new session if unknown
+//	sess.Values["user"] = "gopher"
+//	err = store.Save(sess)
+//	http.SetCookie(w, &http.Cookie{Name: "session", Value: sess.ID})
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultKeyPrefix is the default Store.KeyPrefix.
+const DefaultKeyPrefix = "session:"
+
+// idBytes is the number of random bytes in a session ID.
+const idBytes = 32
+
+// Client is the subset of *memcache.Client used by a Store.
+type Client interface {
+	Get(key string) (*memcache.Item, error)
+	GetAndTouch(key string, seconds int32) (*memcache.Item, error)
+	Set(item *memcache.Item) error
+	Delete(key string) error
+}
+
+var _ Client = (*memcache.Client)(nil)
+
+// Session is a set of values kept across the requests of a client,
+// identified by a random ID.
+type Session struct {
+	// ID identifies the session, typically in a cookie. It is made of
+	// URL-safe base64 characters.
+	ID string
+
+	// Values holds the session's data. The values must be supported by
+	// the Store's Codec; with the Gob codec, their types must be
+	// registered with gob.Register.
+	Values map[string]interface{}
+
+	// IsNew reports whether the session was created rather than loaded
+	// from the store.
+	IsNew bool
+}
+
+// Store keeps sessions in memcache, each under the key KeyPrefix+ID,
+// expiring TTL after it was last saved or, with Sliding, read.
+type Store struct {
+	Client Client
+
+	// TTL is how long a session is kept after it was last saved, or
+	// read with Sliding. It must be positive.
+	TTL time.Duration
+
+	// Sliding makes Get reset the session's expiration, so that only
+	// sessions left unused for TTL expire.
+	Sliding bool
+
+	// KeyPrefix is prepended to session IDs to make keys. If empty,
+	// DefaultKeyPrefix is used.
+	KeyPrefix string
+
+	// Codec encodes the sessions' values. If nil, memcache.JSON is
+	// used.
+	Codec memcache.Codec
+}
+
+// NewStore returns a Store keeping sessions in c for ttl.
+func NewStore(c Client, ttl time.Duration) *Store {
+	return &Store{Client: c, TTL: ttl}
+}
+
+// New returns a new, empty session with a fresh ID. It is only stored
+// once saved.
+func (s *Store) New() (*Session, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &Session{
+		ID:     base64.RawURLEncoding.EncodeToString(b),
+		Values: make(map[string]interface{}),
+		IsNew:  true,
+	}, nil
+}
+
+// Get returns the session with the given ID. If there is none, because
+// id is empty, malformed, unknown or expired, Get returns a new session
+// with a fresh ID instead, so that clients can't choose their ID.
+// Errors talking to memcache are returned as is.
+func (s *Store) Get(id string) (*Session, error) {
+	if !validID(id) {
+		return s.New()
+	}
+	var it *memcache.Item
+	var err error
+	if s.Sliding {
+		it, err = s.Client.GetAndTouch(s.key(id), memcache.ExpiresIn(s.TTL))
+	} else {
+		it, err = s.Client.Get(s.key(id))
+	}
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return s.New()
+	}
+	if err != nil {
+		return nil, err
+	}
+	codec := s.codec()
+	if it.Flags&codec.Flags() != codec.Flags() {
+		return nil, memcache.ErrCodecMismatch
+	}
+	sess := &Session{ID: id}
+	if err := codec.Unmarshal(it.Value, &sess.Values); err != nil {
+		return nil, err
+	}
+	if sess.Values == nil {
+		sess.Values = make(map[string]interface{})
+	}
+	return sess, nil
+}
+
+// Save stores sess, resetting its expiration.
+func (s *Store) Save(sess *Session) error {
+	codec := s.codec()
+	data, err := codec.Marshal(sess.Values)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(&memcache.Item{
+		Key:        s.key(sess.ID),
+		Value:      data,
+		Flags:      codec.Flags(),
+		Expiration: memcache.ExpiresIn(s.TTL),
+	})
+}
+
+// Delete removes the session with the given ID, as on logout. Deleting
+// an unknown session is not an error.
+func (s *Store) Delete(id string) error {
+	if !validID(id) {
+		return nil
+	}
+	err := s.Client.Delete(s.key(id))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		err = nil
+	}
+	return err
+}
+
+func (s *Store) key(id string) string {
+	if s.KeyPrefix == "" {
+		return DefaultKeyPrefix + id
+	}
+	return s.KeyPrefix + id
+}
+
+func (s *Store) codec() memcache.Codec {
+	if s.Codec != nil {
+		return s.Codec
+	}
+	return memcache.JSON
+}
+
+// validID reports whether id could have been returned by New.
+func validID(id string) bool {
+	if len(id) != base64.RawURLEncoding.EncodedLen(idBytes) {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package sessions
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// mapClient is a Client backed by a map, recording the expirations set.
+type mapClient struct {
+	mu      sync.Mutex
+	items   map[string]memcache.Item
+	touches int
+}
+
+func newMapClient() *mapClient { return &mapClient{items: make(map[string]memcache.Item)} }
+
+func (c *mapClient) Get(key string) (*memcache.Item, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	it, ok := c.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	return &it, nil
+}
+
+func (c *mapClient) GetAndTouch(key string, seconds int32) (*memcache.Item, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	it, ok := c.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	it.Expiration = seconds
+	c.items[key] = it
+	c.touches++
+	return &it, nil
+}
+
+func (c *mapClient) Set(it *memcache.Item) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[it.Key] = *it
+	return nil
+}
+
+func (c *mapClient) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[key]; !ok {
+		return memcache.ErrCacheMiss
+	}
+	delete(c.items, key)
+	return nil
+}
+
+func TestStore(t *testing.T) {
+	mc := newMapClient()
+	s := NewStore(mc, time.Hour)
+
+	sess, err := s.Get("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sess.IsNew || !validID(sess.ID) {
+		t.Fatalf("Get(\"\") = %+v, want a new session", sess)
+	}
+	sess.Values["user"] = "gopher"
+	if err := s.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	it := mc.items[DefaultKeyPrefix+sess.ID]
+	if it.Expiration != 3600 || it.Flags != memcache.JSONCodecFlag {
+		t.Errorf("stored item = %+v", it)
+	}
+
+	got, err := s.Get(sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IsNew || got.ID != sess.ID || got.Values["user"] != "gopher" {
+		t.Errorf("Get = %+v", got)
+	}
+	if mc.touches != 0 {
+		t.Errorf("Get touched the session without Sliding")
+	}
+
+	if err := s.Delete(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(sess.ID); err != nil {
+		t.Errorf("second Delete = %v", err)
+	}
+	got, err = s.Get(sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IsNew || got.ID == sess.ID {
+		t.Errorf("Get after Delete = %+v, want a new session with a fresh ID", got)
+	}
+}
+
+func TestStoreSliding(t *testing.T) {
+	mc := newMapClient()
+	s := NewStore(mc, time.Minute)
+	s.Sliding = true
+
+	sess, _ := s.New()
+	if err := s.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if mc.touches != 1 {
+		t.Errorf("touches = %d, want 1", mc.touches)
+	}
+}
+
+func TestStoreMalformedID(t *testing.T) {
+	mc := newMapClient()
+	s := NewStore(mc, time.Minute)
+	for _, id := range []string{"short", "has space and is long enough to pass the length check", "../../../../../../../../../../../../etc"} {
+		sess, err := s.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sess.IsNew || sess.ID == id {
+			t.Errorf("Get(%q) = %+v, want a new session", id, sess)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package memcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"time"
+)
+
+// SlowOp describes an operation that took longer than the client's
+// SlowOpThreshold.
+type SlowOp struct {
+	Op       string
+	Addr     net.Addr
+	Duration time.Duration
+	Err      error
+
+	// Keys are the operation's keys, or their hashes if HashSlowOpKeys
+	// is set. Administrative commands have no keys.
+	Keys []string
+}
+
+// HashKey returns a short, stable hash of key suitable for logging in
+// place of the key itself.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c *Client) reportSlowOp(m *OpMetrics, keys []string) {
+	if c.HashSlowOpKeys {
+		hashed := make([]string, len(keys))
+		for i, key := range keys {
+			hashed[i] = HashKey(key)
+		}
+		keys = hashed
+	}
+	op := SlowOp{Op: m.Op, Addr: m.Addr, Duration: m.Duration, Err: m.Err, Keys: keys}
+	if c.SlowOpHook != nil {
+		c.SlowOpHook(op)
+	}
+	c.logWarn("memcache: slow operation", "op", op.Op, "addr", op.Addr, "duration", op.Duration, "keys", op.Keys)
+}
@@ -0,0 +1,30 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowOpHook(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.SlowOpThreshold = time.Nanosecond
+	c.HashSlowOpKeys = true
+	var got []SlowOp
+	c.SlowOpHook = func(op SlowOp) { got = append(got, op) }
+
+	c.Get("foo")
+	if len(got) != 1 {
+		t.Fatalf("got %d slow ops, want 1", len(got))
+	}
+	if op := got[0]; op.Op != "gets" || len(op.Keys) != 1 || op.Keys[0] != HashKey("foo") {
+		t.Errorf("slow op = %+v, want gets with hashed key", op)
+	}
+
+	c.SlowOpThreshold = time.Hour
+	c.Get("foo")
+	if len(got) != 1 {
+		t.Errorf("fast op reported as slow")
+	}
+}
@@ -0,0 +1,184 @@
+package memcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+)
+
+// streamChunk is the amount of data streamed between deadline
+// extensions.
+const streamChunk = 64 << 10
+
+// ErrStreamReplicated is returned by SetReader on a client with
+// replication enabled, since a stream can only be sent once.
+var ErrStreamReplicated = errors.New("memcache: streaming writes are not supported with replication")
+
+// SetReader unconditionally writes length bytes read from r as the value
+// of key, streaming them to the server without buffering the whole
+// value in memory. The connection deadline is extended as data is sent,
+// so the client's write timeout bounds each 64KB rather than the whole
+// value.
+//
+// The value bypasses the client's Transcoders and ChunkSize. If r
+// returns fewer than length bytes, the connection is discarded and
+// nothing is stored.
+func (c *Client) SetReader(key string, r io.Reader, length int, flags uint32, expiration int32) error {
+	if c.Replicas > 1 {
+		return ErrStreamReplicated
+	}
+	return c.intercept(&Op{Name: "setreader", Keys: []string{key}}, func(ctx context.Context, op *Op) error {
+		if !legalKey(op.Keys[0]) {
+			return ErrMalformedKey
+		}
+		return c.withKeyAddr(op.Keys[0], func(addr net.Addr) error {
+			return c.withAddrConn(addr, "set", op.Keys, func(cn *conn, _ *OpMetrics) error {
+				return streamSet(cn, op.Keys[0], r, length, flags, expiration)
+			})
+		})
+	})
+}
+
+func streamSet(cn *conn, key string, r io.Reader, length int, flags uint32, expiration int32) error {
+	rw := cn.rw
+	if _, err := fmt.Fprintf(rw, "set %s %d %d %d\r\n", key, flags, expiration, length); err != nil {
+		return err
+	}
+	for sent := int64(0); sent < int64(length); {
+		n := int64(length) - sent
+		if n > streamChunk {
+			n = streamChunk
+		}
+		m, err := io.CopyN(rw, r, n)
+		sent += m
+		if err == io.EOF {
+			return fmt.Errorf("memcache: SetReader: reader returned %d bytes, want %d: %w", sent, length, io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return err
+		}
+		cn.extendDeadline(opWrite)
+	}
+	if _, err := rw.Write(crlf); err != nil {
+		return err
+	}
+	return readStoreResponse(rw, "set")
+}
+
+// GetReader gets the value of key as a stream read directly from the
+// connection, for decoding large values without an intermediate copy.
+// It returns the value's size and flags and a ReadCloser over the
+// value, which must be closed; the connection is returned to the pool
+// on Close if the value was read to its end or nearly so, and closed
+// otherwise. ErrCacheMiss is returned if key is not in the cache.
+//
+// Each Read extends the connection deadline by the client's read
+// timeout. The value bypasses the client's Transcoders, and chunked
+// values are returned as their manifest. Reads go to the key's primary
+// server only.
+func (c *Client) GetReader(key string) (size int, flags uint32, rc io.ReadCloser, err error) {
+	err = c.intercept(&Op{Name: "getreader", Keys: []string{key}}, func(ctx context.Context, op *Op) error {
+		if !legalKey(op.Keys[0]) {
+			return ErrMalformedKey
+		}
+		return c.withKeyAddr(op.Keys[0], func(addr net.Addr) error {
+			return c.withAddrConn(addr, "get", op.Keys, func(cn *conn, m *OpMetrics) error {
+				var it Item
+				var err error
+				size, err = streamGet(cn, op.Keys[0], c.limits(), &it)
+				if err != nil {
+					return err
+				}
+				m.Hits = 1
+				flags = it.Flags
+				cn.detached = true
+				rc = &valueReader{cn: cn, r: io.LimitReader(cn.rw, int64(size))}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return size, flags, rc, nil
+}
+
+// streamGet sends a gets for key and reads the response up to the start
+// of the value, returning its size.
+func streamGet(cn *conn, key string, lim responseLimits, it *Item) (int, error) {
+	bp := getScratch()
+	b := append(append(append(*bp, "gets "...), key...), crlf...)
+	_, err := cn.rw.Write(b)
+	putScratch(bp, b)
+	if err != nil {
+		return 0, err
+	}
+	if err := cn.rw.Flush(); err != nil {
+		return 0, err
+	}
+	line, err := lim.readLine(cn.rw.Reader)
+	if err != nil {
+		return 0, err
+	}
+	if bytes.Equal(line, resultEnd) {
+		return 0, ErrCacheMiss
+	}
+	if err := checkServerError(line); err != nil {
+		return 0, err
+	}
+	size, err := scanGetResponseLine(line, it)
+	if err != nil {
+		return 0, err
+	}
+	if it.Key != key {
+		return 0, fmt.Errorf("%w: unexpected key in get response", ErrProtocol)
+	}
+	return size, nil
+}
+
+// valueReader streams a value from a detached connection.
+type valueReader struct {
+	cn     *conn
+	r      io.Reader
+	closed bool
+}
+
+func (vr *valueReader) Read(p []byte) (int, error) {
+	if vr.closed {
+		return 0, errors.New("memcache: read of closed value reader")
+	}
+	vr.cn.extendDeadline(opRead)
+	return vr.r.Read(p)
+}
+
+// Close drains what is left of the value, up to 64KB, and returns the
+// connection to the pool if the response ends as expected.
+func (vr *valueReader) Close() error {
+	if vr.closed {
+		return nil
+	}
+	vr.closed = true
+	cn := vr.cn
+	cn.extendDeadline(opRead)
+	n, err := io.Copy(ioutil.Discard, io.LimitReader(vr.r, streamChunk))
+	if err == nil && n == streamChunk {
+		// Too much left to be worth draining.
+		return cn.nc.Close()
+	}
+	if err == nil {
+		var tail [len("\r\nEND\r\n")]byte
+		if _, err = io.ReadFull(cn.rw, tail[:]); err == nil && string(tail[:]) != "\r\nEND\r\n" {
+			err = fmt.Errorf("%w: corrupt get result read", ErrProtocol)
+		}
+	}
+	if err != nil {
+		cn.nc.Close()
+		return err
+	}
+	cn.release()
+	return nil
+}
@@ -0,0 +1,101 @@
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSetReader(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	val := strings.Repeat("streamed", 20000)
+	if err := c.SetReader("big", strings.NewReader(val), len(val), 9, 0); err != nil {
+		t.Fatalf("SetReader: %v", err)
+	}
+	it, err := c.Get("big")
+	if err != nil || string(it.Value) != val || it.Flags != 9 {
+		t.Fatalf("Get after SetReader = %d bytes, %v", len(it.Value), err)
+	}
+
+	err = c.SetReader("short", bytes.NewReader([]byte("abc")), 10, 0, 0)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("SetReader with short reader = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, ok := s.get("short"); ok {
+		t.Error("short value was stored")
+	}
+	// The client must still work after discarding the connection.
+	if _, err := c.Get("big"); err != nil {
+		t.Errorf("Get after failed SetReader: %v", err)
+	}
+}
+
+func TestGetReader(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.MaxIdleConns = 1
+
+	val := strings.Repeat("0123456789", 30000)
+	s.put("big", []byte(val), 7)
+	size, flags, rc, err := c.GetReader("big")
+	if err != nil {
+		t.Fatalf("GetReader: %v", err)
+	}
+	if size != len(val) || flags != 7 {
+		t.Errorf("GetReader size, flags = %d, %d; want %d, 7", size, flags, len(val))
+	}
+	got, err := ioutil.ReadAll(rc)
+	if err != nil || string(got) != val {
+		t.Fatalf("read %d bytes, %v", len(got), err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if st := c.ConnStats()[s.Addr()]; st.Dials != 1 {
+		t.Errorf("dials = %d, want 1", st.Dials)
+	}
+
+	// A partially read value is drained and the connection reused.
+	s.put("small", []byte("hello world"), 0)
+	_, _, rc, err = c.GetReader("small")
+	if err != nil {
+		t.Fatalf("GetReader: %v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(rc, buf); err != nil || string(buf) != "hello" {
+		t.Fatalf("read %q, %v", buf, err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := rc.Read(buf); err == nil {
+		t.Error("Read after Close succeeded")
+	}
+	if it, err := c.Get("small"); err != nil || string(it.Value) != "hello world" {
+		t.Errorf("Get after GetReader = %v, %v", it, err)
+	}
+	if st := c.ConnStats()[s.Addr()]; st.Dials != 1 {
+		t.Errorf("dials = %d, want 1", st.Dials)
+	}
+
+	// Closing early on a large value discards the connection.
+	_, _, rc, err = c.GetReader("big")
+	if err != nil {
+		t.Fatalf("GetReader: %v", err)
+	}
+	rc.Close()
+	if _, err := c.Get("small"); err != nil {
+		t.Errorf("Get after early Close: %v", err)
+	}
+
+	if _, _, _, err := c.GetReader("missing"); err != ErrCacheMiss {
+		t.Errorf("GetReader of missing key = %v, want ErrCacheMiss", err)
+	}
+}
@@ -0,0 +1,134 @@
+package memcache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// SoftTTLFlag is the Item.Flags bit marking values stored by
+// GetOrRevalidate, or by GetOrSet with EarlyRefreshBeta, which are
+// prefixed with their soft expiration time and the time their load
+// took.
+const SoftTTLFlag uint32 = 1 << 30
+
+// softTTLHeaderLen is the length of the soft expiration prefix: the
+// time as big-endian Unix nanoseconds, zero for none, followed by the
+// load time in nanoseconds.
+const softTTLHeaderLen = 16
+
+// wrapSoftTTL returns value prefixed with the soft expiration time t
+// and the load time delta.
+func wrapSoftTTL(value []byte, t time.Time, delta time.Duration) []byte {
+	b := make([]byte, softTTLHeaderLen+len(value))
+	if !t.IsZero() {
+		binary.BigEndian.PutUint64(b, uint64(t.UnixNano()))
+	}
+	binary.BigEndian.PutUint64(b[8:], uint64(delta))
+	copy(b[softTTLHeaderLen:], value)
+	return b
+}
+
+// unwrapSoftTTL splits the value of an item stored with wrapSoftTTL
+// into the value stored, its soft expiration time and its load time.
+// Items without SoftTTLFlag, or too short to hold the prefix, are
+// never soft-expired.
+func unwrapSoftTTL(it *Item) ([]byte, time.Time, time.Duration) {
+	v := it.Bytes()
+	if it.Flags&SoftTTLFlag == 0 || len(v) < softTTLHeaderLen {
+		return v, time.Time{}, 0
+	}
+	var t time.Time
+	if ns := int64(binary.BigEndian.Uint64(v)); ns != 0 {
+		t = time.Unix(0, ns)
+	}
+	return v[softTTLHeaderLen:], t, time.Duration(binary.BigEndian.Uint64(v[8:]))
+}
+
+// refreshDue reports whether a value expiring at expiry, whose load
+// took delta, should be reloaded now: once expiry has passed or, with
+// EarlyRefreshBeta, at random ahead of it as in the XFetch algorithm,
+// the more likely the closer expiry is and the longer the load takes.
+// A zero expiry is never due.
+func (c *Client) refreshDue(expiry time.Time, delta time.Duration) bool {
+	if expiry.IsZero() {
+		return false
+	}
+	left := float64(time.Until(expiry))
+	if c.EarlyRefreshBeta > 0 && delta > 0 {
+		return left <= -float64(delta)*c.EarlyRefreshBeta*math.Log(1-rand.Float64())
+	}
+	return left <= 0
+}
+
+// GetOrRevalidate is like GetOrSet, but serves stale values while they
+// are refreshed. Values are stored for hardTTL, with a soft expiration
+// softTTL from now recorded in the value. A hit past its soft
+// expiration is returned at once, and loader is called in the
+// background to replace it; a client runs at most one such refresh
+// per key at a time. Refresh failures are logged, and the stale value
+// kept; NegativeTTL only applies to loads on a miss.
+//
+// With MetaProtocol, items the server reports as stale are refreshed
+// only by the client that won them, and a soft-expired item another
+// client has won is served without a refresh.
+func (c *Client) GetOrRevalidate(ctx context.Context, key string, softTTL, hardTTL time.Duration, loader func() ([]byte, error)) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !c.acceptsKey(key) {
+		return nil, ErrMalformedKey
+	}
+	refresh := func() (*Item, error) {
+		start := time.Now()
+		value, err := loader()
+		if err != nil {
+			return nil, err
+		}
+		it := &Item{
+			Key:        key,
+			Value:      wrapSoftTTL(value, start.Add(softTTL), time.Since(start)),
+			Flags:      SoftTTLFlag,
+			Expiration: expirationFor(hardTTL),
+		}
+		if err := c.Set(it); err != nil {
+			c.logDebug("memcache: GetOrRevalidate failed to store loaded value", "key", key, "err", err)
+		}
+		return &Item{Key: key, Value: value}, nil
+	}
+
+	it, err := c.Get(key)
+	if errors.Is(err, ErrCacheMiss) {
+		it, err = c.state.refresh.do(key, func() (*Item, error) {
+			it, err := refresh()
+			if err != nil {
+				c.cacheLoadError(key, err)
+			}
+			return it, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return it.Value, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := negativeHit(it); err != nil {
+		return nil, err
+	}
+	value, soft, delta := unwrapSoftTTL(it)
+	if it.Win || (!it.Stale && c.refreshDue(soft, delta)) {
+		c.state.refresh.goOnce(key, func() (*Item, error) {
+			it, err := refresh()
+			if err != nil {
+				c.logDebug("memcache: GetOrRevalidate refresh failed", "key", key, "err", err)
+			}
+			return it, err
+		})
+	}
+	return value, nil
+}
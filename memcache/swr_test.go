@@ -0,0 +1,109 @@
+package memcache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrRevalidate(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	ctx := context.Background()
+
+	var loads int32
+	loader := func() ([]byte, error) {
+		n := atomic.AddInt32(&loads, 1)
+		return []byte{'v', '0' + byte(n)}, nil
+	}
+	waitStored := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if it, err := c.Get("k"); err == nil {
+				if v, _, _ := unwrapSoftTTL(it); string(v) == want {
+					return
+				}
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("value %q never stored", want)
+	}
+
+	// A miss loads synchronously.
+	v, err := c.GetOrRevalidate(ctx, "k", time.Hour, time.Hour, loader)
+	if err != nil || string(v) != "v1" {
+		t.Fatalf("miss = %q, %v", v, err)
+	}
+	it, ok := s.get("k")
+	if !ok || it.flags&SoftTTLFlag == 0 {
+		t.Fatalf("stored item = %+v, %v; want SoftTTLFlag", it, ok)
+	}
+
+	// A fresh hit doesn't call the loader.
+	if v, err := c.GetOrRevalidate(ctx, "k", time.Hour, time.Hour, loader); err != nil || string(v) != "v1" {
+		t.Errorf("fresh hit = %q, %v", v, err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+
+	// A soft-expired hit is served stale and refreshed in the background.
+	mustSet(t, c, &Item{Key: "k", Value: wrapSoftTTL([]byte("old"), time.Now().Add(-time.Second), 0), Flags: SoftTTLFlag})
+	if v, err := c.GetOrRevalidate(ctx, "k", time.Hour, time.Hour, loader); err != nil || string(v) != "old" {
+		t.Errorf("stale hit = %q, %v", v, err)
+	}
+	waitStored("v2")
+
+	// Values not stored by GetOrRevalidate are served as they are.
+	mustSet(t, c, &Item{Key: "k", Value: []byte("plain")})
+	if v, err := c.GetOrRevalidate(ctx, "k", time.Hour, time.Hour, loader); err != nil || string(v) != "plain" {
+		t.Errorf("plain hit = %q, %v", v, err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 2 {
+		t.Errorf("loader called %d times, want 2", n)
+	}
+}
+
+func TestGetOrRevalidateMeta(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.MetaProtocol = true
+	ctx := context.Background()
+
+	var loads int32
+	release := make(chan struct{})
+	loader := func() ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []byte("new"), nil
+	}
+	mustSet(t, c, &Item{Key: "k", Value: wrapSoftTTL([]byte("old"), time.Now().Add(time.Hour), 0), Flags: SoftTTLFlag})
+	s.markStale("k")
+
+	// The first reader wins the stale item and refreshes it; the second
+	// is served the stale value without a refresh.
+	for i := 0; i < 2; i++ {
+		if v, err := c.GetOrRevalidate(ctx, "k", time.Hour, time.Hour, loader); err != nil || string(v) != "old" {
+			t.Errorf("read %d = %q, %v", i, v, err)
+		}
+	}
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		it, ok := s.get("k")
+		if ok && !it.stale {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale item never replaced")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+}
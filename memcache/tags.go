@@ -0,0 +1,120 @@
+package memcache
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// tagKeyPrefix is prepended to a tag to form the key holding its
+// version.
+const tagKeyPrefix = "tag:"
+
+// SetTagged writes item, unconditionally, tagged with tags, so that
+// invalidating any of the tags with InvalidateTag hides it. The item
+// must be read with GetTagged and the same tags, in any order.
+//
+// Tags are implemented by folding the current version of each tag into
+// the key the item is stored under, which is why reads need the tags
+// too: invalidating a tag changes its version, and with it the keys of
+// all items tagged with it. Items stored under old versions are never
+// read again and are left to expire or be evicted.
+func (c *Client) SetTagged(item *Item, tags ...string) error {
+	key, err := c.taggedKey(item.Key, tags)
+	if err != nil {
+		return err
+	}
+	it := *item
+	it.Key = key
+	return c.Set(&it)
+}
+
+// GetTagged gets the item stored for key by SetTagged with tags. It
+// returns ErrCacheMiss if there is none, or if any of the tags was
+// invalidated since.
+func (c *Client) GetTagged(key string, tags ...string) (*Item, error) {
+	tkey, err := c.taggedKey(key, tags)
+	if err != nil {
+		return nil, err
+	}
+	it, err := c.Get(tkey)
+	if err != nil {
+		return nil, err
+	}
+	it.Key = key
+	return it, nil
+}
+
+// InvalidateTag hides all items stored with tag by SetTagged.
+func (c *Client) InvalidateTag(tag string) error {
+	err := c.invalidateTag(tag)
+	if err == nil {
+		c.publishInvalidation(InvalidationEvent{Tag: tag})
+	}
+	return err
+}
+
+func (c *Client) invalidateTag(tag string) error {
+	_, err := c.Increment(tagKeyPrefix+tag, 1)
+	if errors.Is(err, ErrCacheMiss) {
+		// Nothing was stored since the version was evicted, but a new
+		// version must still differ from any earlier one.
+		return c.storeRaw("set", &Item{Key: tagKeyPrefix + tag, Value: newVersion()})
+	}
+	return err
+}
+
+// newVersion returns the initial version of a tag or namespace epoch.
+// It is based on the time rather than zero, so that a version that was
+// evicted doesn't return to a value items were stored under.
+func newVersion() []byte {
+	return strconv.AppendInt(nil, time.Now().UnixNano(), 10)
+}
+
+// taggedKey returns the key an item for key with tags is stored under:
+// key followed by "#" and a hash of the tags and their versions.
+func (c *Client) taggedKey(key string, tags []string) (string, error) {
+	if !c.acceptsKey(key) {
+		return "", ErrMalformedKey
+	}
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	vkeys := make([]string, len(sorted))
+	for i, tag := range sorted {
+		vkeys[i] = tagKeyPrefix + tag
+	}
+	versions, err := c.getMultiRaw(vkeys)
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	for i, vkey := range vkeys {
+		it, ok := versions[vkey]
+		if !ok {
+			if it, err = c.createVersion(vkey); err != nil {
+				return "", err
+			}
+		}
+		h.Write([]byte(sorted[i]))
+		h.Write([]byte{0})
+		h.Write(it.Bytes())
+		h.Write([]byte{0})
+	}
+	tkey := key + "#" + strconv.FormatUint(h.Sum64(), 16)
+	if !c.acceptsKey(tkey) {
+		return "", ErrMalformedKey
+	}
+	return tkey, nil
+}
+
+// createVersion creates the version of a tag or namespace epoch under
+// vkey, unless another client did first, and returns it.
+func (c *Client) createVersion(vkey string) (*Item, error) {
+	err := c.storeRaw("add", &Item{Key: vkey, Value: newVersion()})
+	if err != nil && !errors.Is(err, ErrNotStored) {
+		return nil, err
+	}
+	return c.getRaw(vkey)
+}
@@ -0,0 +1,47 @@
+package memcache
+
+import "testing"
+
+func TestTags(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	if err := c.SetTagged(&Item{Key: "a", Value: []byte("1")}, "user:1", "list"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetTagged(&Item{Key: "b", Value: []byte("2")}, "user:2"); err != nil {
+		t.Fatal(err)
+	}
+	it, err := c.GetTagged("a", "list", "user:1")
+	if err != nil || it.Key != "a" || string(it.Value) != "1" {
+		t.Fatalf("GetTagged = %+v, %v", it, err)
+	}
+	if _, err := c.GetTagged("a", "list"); err != ErrCacheMiss {
+		t.Errorf("GetTagged with other tags = %v, want ErrCacheMiss", err)
+	}
+
+	if err := c.InvalidateTag("list"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTagged("a", "user:1", "list"); err != ErrCacheMiss {
+		t.Errorf("GetTagged after invalidation = %v, want ErrCacheMiss", err)
+	}
+	if it, err := c.GetTagged("b", "user:2"); err != nil || string(it.Value) != "2" {
+		t.Errorf("item with other tag = %+v, %v", it, err)
+	}
+
+	// An evicted version doesn't bring old items back.
+	if err := c.SetTagged(&Item{Key: "a", Value: []byte("3")}, "list"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(tagKeyPrefix + "list"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTagged("a", "list"); err != ErrCacheMiss {
+		t.Errorf("GetTagged after version eviction = %v, want ErrCacheMiss", err)
+	}
+	if err := c.InvalidateTag("never-used"); err != nil {
+		t.Errorf("InvalidateTag of unused tag = %v", err)
+	}
+}
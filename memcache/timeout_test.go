@@ -0,0 +1,27 @@
+package memcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOpTimeout(t *testing.T) {
+	tests := []struct {
+		c     *Client
+		class opClass
+		want  time.Duration
+	}{
+		{&Client{}, opRead, DefaultTimeout},
+		{&Client{}, opAdmin, DefaultAdminTimeout},
+		{&Client{Timeout: time.Second}, opWrite, time.Second},
+		{&Client{Timeout: time.Second}, opAdmin, time.Second},
+		{&Client{Timeout: time.Second, ReadTimeout: 5 * time.Millisecond}, opRead, 5 * time.Millisecond},
+		{&Client{Timeout: time.Second, ReadTimeout: 5 * time.Millisecond}, opWrite, time.Second},
+		{&Client{AdminTimeout: 3 * time.Second}, opAdmin, 3 * time.Second},
+	}
+	for i, tt := range tests {
+		if got := tt.c.opTimeout(tt.class); got != tt.want {
+			t.Errorf("%d. opTimeout(%d) = %v, want %v", i, tt.class, got, tt.want)
+		}
+	}
+}
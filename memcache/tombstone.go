@@ -0,0 +1,41 @@
+package memcache
+
+import (
+	"errors"
+	"time"
+)
+
+// TombstoneFlag is the Item.Flags bit marking the tombstones left by
+// DeleteSoft. Clients only interpret it if Tombstones is set.
+const TombstoneFlag uint32 = 1 << 21
+
+// errTombstonesDisabled is returned by DeleteSoft if the client's
+// Tombstones is not set, as its reads wouldn't honour the tombstone.
+var errTombstonesDisabled = errors.New("memcache: DeleteSoft requires Client.Tombstones")
+
+// ErrTombstoned is returned by Get and similar reads for a key deleted
+// with DeleteSoft whose tombstone hasn't expired yet. GetMulti omits
+// such keys, as it does misses.
+var ErrTombstoned = errors.New("memcache: item deleted")
+
+// DeleteSoft deletes the item for key by replacing it with an empty
+// tombstone for ttl. Until the tombstone expires, reads of key fail
+// with ErrTombstoned, and writers filling the cache with Add, as
+// GetOrSet and other read-through code should, cannot store a value
+// they read from the source of truth before the deletion. Set still
+// overwrites the tombstone. The client's Tombstones must be set.
+func (c *Client) DeleteSoft(key string, ttl time.Duration) error {
+	if !c.Tombstones {
+		return errTombstonesDisabled
+	}
+	err := c.Set(&Item{Key: key, Flags: TombstoneFlag, Expiration: expirationFor(ttl)})
+	if err == nil {
+		c.publishInvalidation(InvalidationEvent{Key: c.storageKey(key)})
+	}
+	return err
+}
+
+// tombstone reports whether it is a tombstone the client honours.
+func (c *Client) tombstone(it *Item) bool {
+	return c.Tombstones && it.Flags&TombstoneFlag != 0
+}
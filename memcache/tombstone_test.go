@@ -0,0 +1,65 @@
+package memcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeleteSoft(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.L1 = NewL1Cache(0, 0, time.Minute)
+	c.Tombstones = true
+
+	mustSet(t, c, &Item{Key: "k", Value: []byte("v")})
+	mustSet(t, c, &Item{Key: "other", Value: []byte("o")})
+	if err := c.DeleteSoft("k", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("k"); err != ErrTombstoned {
+		t.Errorf("Get of tombstoned key = %v, want ErrTombstoned", err)
+	}
+	m, err := c.GetMulti([]string{"k", "other"})
+	if err != nil || len(m) != 1 || m["other"] == nil {
+		t.Errorf("GetMulti = %v, %v; want only the live key", m, err)
+	}
+
+	// A racing Add can't resurrect the key, and read-through serves the
+	// loaded value without storing it.
+	if err := c.Add(&Item{Key: "k", Value: []byte("stale")}); err != ErrNotStored {
+		t.Errorf("Add over tombstone = %v, want ErrNotStored", err)
+	}
+	v, err := c.GetOrSet(context.Background(), "k", time.Minute, func() ([]byte, error) { return []byte("fresh"), nil })
+	if err != nil || string(v) != "fresh" {
+		t.Errorf("GetOrSet of tombstoned key = %q, %v", v, err)
+	}
+	if _, err := c.Get("k"); err != ErrTombstoned {
+		t.Errorf("Get after GetOrSet = %v, want ErrTombstoned", err)
+	}
+
+	// Set replaces the tombstone.
+	mustSet(t, c, &Item{Key: "k", Value: []byte("new")})
+	if it, err := c.Get("k"); err != nil || string(it.Value) != "new" {
+		t.Errorf("Get after Set = %+v, %v", it, err)
+	}
+}
+
+func TestTombstonesDisabled(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+
+	if err := c.DeleteSoft("k", time.Minute); err != errTombstonesDisabled {
+		t.Errorf("DeleteSoft without Tombstones = %v, want errTombstonesDisabled", err)
+	}
+	// The flag bit belongs to the application.
+	mustSet(t, c, &Item{Key: "k", Value: []byte("v"), Flags: TombstoneFlag})
+	if it, err := c.Get("k"); err != nil || string(it.Value) != "v" {
+		t.Errorf("Get = %+v, %v; want the item", it, err)
+	}
+	if m, err := c.GetMulti([]string{"k"}); err != nil || len(m) != 1 {
+		t.Errorf("GetMulti = %v, %v; want the item", m, err)
+	}
+}
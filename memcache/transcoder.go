@@ -0,0 +1,97 @@
+package memcache
+
+import "errors"
+
+// Transcoder transforms item values on their way to and from the
+// servers, for example to compress or encrypt them. A Transcoder
+// typically marks the values it transformed with a bit of Item.Flags.
+//
+// Implementations must be safe for concurrent use.
+type Transcoder interface {
+	// Encode transforms item before it is stored. item is a copy owned
+	// by the client, but its Value is shared with the caller and must
+	// be replaced rather than modified in place.
+	Encode(item *Item) error
+
+	// Decode reverses Encode on an item read from a server.
+	Decode(item *Item) error
+}
+
+// encode returns item transformed by the client's Transcoders, in
+// order. item itself is not modified.
+func (c *Client) encode(item *Item) (*Item, error) {
+	if len(c.Transcoders) == 0 {
+		return item, nil
+	}
+	it := *item
+	for _, t := range c.Transcoders {
+		if err := t.Encode(&it); err != nil {
+			return nil, err
+		}
+	}
+	return &it, nil
+}
+
+// decode reverses encode on item in place, applying the client's
+// Transcoders in reverse order.
+func (c *Client) decode(item *Item) error {
+	for i := len(c.Transcoders) - 1; i >= 0; i-- {
+		if err := c.Transcoders[i].Decode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareStore applies the client's defaults to item, encodes it,
+// applies ExpirationJitter and, if it is still larger than the client's
+// ChunkSize, writes it as chunks, returning the item to store.
+func (c *Client) prepareStore(item *Item) (*Item, error) {
+	item = withDefaults(item, c.DefaultExpiration, c.DefaultFlags)
+	it, err := c.encode(item)
+	if err != nil {
+		return nil, err
+	}
+	if exp := c.jitterExpiration(it.Expiration); exp != it.Expiration {
+		if it == item {
+			cp := *item
+			it = &cp
+		}
+		it.Expiration = exp
+	}
+	if c.ChunkSize > 0 && len(it.Value) > c.ChunkSize {
+		return c.chunk(it)
+	}
+	return it, nil
+}
+
+// finishRead reverses prepareStore on an item read from a server. It
+// returns ErrTombstoned for a tombstone left by DeleteSoft.
+func (c *Client) finishRead(item *Item) error {
+	if c.tombstone(item) {
+		return ErrTombstoned
+	}
+	if item.lazy != nil && (len(c.Transcoders) > 0 || c.chunked(item)) {
+		item.Bytes()
+	}
+	if err := c.unchunk(item); err != nil {
+		return err
+	}
+	return c.decode(item)
+}
+
+// finishReads applies finishRead to each of items, removing those that
+// fail and returning the last error other than ErrCacheMiss and
+// ErrTombstoned.
+func (c *Client) finishReads(items map[string]*Item) error {
+	var err error
+	for key, it := range items {
+		if derr := c.finishRead(it); derr != nil {
+			delete(items, key)
+			if !errors.Is(derr, ErrCacheMiss) && !errors.Is(derr, ErrTombstoned) {
+				err = derr
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,32 @@
+//go:build go1.18
+
+package memcache
+
+import (
+	"context"
+)
+
+// Get gets the value of key from c and decodes it into a T with c's
+// Codec. It returns ErrCacheMiss if key is not in the cache, and
+// ctx.Err() without contacting the server if ctx is already done.
+func Get[T any](ctx context.Context, c *Client, key string) (T, error) {
+	var v T
+	if err := ctx.Err(); err != nil {
+		return v, err
+	}
+	if _, err := c.GetObject(key, &v); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// Set encodes v with c's Codec and stores it under key with the given
+// expiration, in seconds, as Client.Set does. It returns ctx.Err()
+// without contacting the server if ctx is already done.
+func Set[T any](ctx context.Context, c *Client, key string, v T, expiration int32) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.SetObject(&Item{Key: key, Object: v, Expiration: expiration})
+}
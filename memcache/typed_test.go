@@ -0,0 +1,37 @@
+//go:build go1.18
+
+package memcache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTypedGetSet(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	ctx := context.Background()
+
+	want := []codecTestUser{{Name: "ann", Age: 42}, {Name: "bob"}}
+	if err := Set(ctx, c, "users", want, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	got, err := Get[[]codecTestUser](ctx, c, "users")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Get = %+v, want %+v", got, want)
+	}
+	if _, err := Get[int](ctx, c, "missing"); err != ErrCacheMiss {
+		t.Errorf("Get(missing) = %v, want ErrCacheMiss", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Get[int](canceled, c, "users"); err != context.Canceled {
+		t.Errorf("Get with canceled context = %v, want context.Canceled", err)
+	}
+}
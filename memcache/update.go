@@ -0,0 +1,92 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// DefaultUpdateAttempts is the number of attempts Update makes when the
+// client's UpdateAttempts is zero.
+const DefaultUpdateAttempts = 10
+
+// Bounds of the randomized backoff between the attempts of Update and
+// other retry loops, which doubles after each attempt.
+const (
+	minRetryBackoff = time.Millisecond
+	maxRetryBackoff = 100 * time.Millisecond
+)
+
+// waitBackoff sleeps for a random duration between half and all of
+// *backoff, then doubles *backoff up to maxRetryBackoff. It returns
+// ctx.Err() if ctx is done first.
+func waitBackoff(ctx context.Context, backoff *time.Duration) error {
+	t := time.NewTimer(*backoff/2 + time.Duration(rand.Int63n(int64(*backoff/2)+1)))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	if *backoff *= 2; *backoff > maxRetryBackoff {
+		*backoff = maxRetryBackoff
+	}
+	return nil
+}
+
+func (c *Client) updateAttempts() int {
+	if c.UpdateAttempts > 0 {
+		return c.UpdateAttempts
+	}
+	return DefaultUpdateAttempts
+}
+
+// Update atomically replaces the value of key by fn's result, creating
+// the item if it is not in the cache: old is the current value, nil if
+// there is none. The item is stored with the given expiration, as
+// memcached doesn't report the expiration of the item read, and keeps
+// its Flags.
+//
+// If another client changes, deletes or creates the item in between,
+// Update calls fn again on the new value, after a short randomized
+// backoff, up to UpdateAttempts times in all; once they are exhausted it
+// returns ErrCASConflict or ErrNotStored. An error from fn aborts the
+// update and is returned as is. Update returns ctx.Err() if ctx is done
+// before an attempt or during a backoff.
+func (c *Client) Update(ctx context.Context, key string, expiration int32, fn func(old []byte) ([]byte, error)) error {
+	backoff := minRetryBackoff
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		it, err := c.Get(key)
+		var old []byte
+		switch {
+		case errors.Is(err, ErrCacheMiss):
+			it = nil
+		case err != nil:
+			return err
+		default:
+			if old = it.Bytes(); old == nil {
+				old = []byte{}
+			}
+		}
+		value, err := fn(old)
+		if err != nil {
+			return err
+		}
+		if it == nil {
+			err = c.Add(&Item{Key: key, Value: value, Expiration: expiration})
+		} else {
+			it.Value, it.Expiration = value, expiration
+			err = c.CompareAndSwap(it)
+		}
+		if !errors.Is(err, ErrCASConflict) && !errors.Is(err, ErrNotStored) || attempt >= c.updateAttempts() {
+			return err
+		}
+		if err := waitBackoff(ctx, &backoff); err != nil {
+			return err
+		}
+	}
+}
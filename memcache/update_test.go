@@ -0,0 +1,91 @@
+package memcache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	c.UpdateAttempts = 1000
+	ctx := context.Background()
+
+	incr := func(old []byte) ([]byte, error) {
+		n := 0
+		if old != nil {
+			var err error
+			if n, err = strconv.Atoi(string(old)); err != nil {
+				return nil, err
+			}
+		}
+		return []byte(strconv.Itoa(n + 1)), nil
+	}
+
+	// Concurrent updates of a missing key each apply exactly once.
+	const workers, updates = 8, 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < updates; j++ {
+				if err := c.Update(ctx, "n", 0, incr); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	it, err := c.Get("n")
+	if err != nil || string(it.Value) != strconv.Itoa(workers*updates) {
+		t.Fatalf("after updates, Get = %+v, %v; want %d", it, err, workers*updates)
+	}
+
+	// Flags are kept.
+	mustSet(t, c, &Item{Key: "f", Value: []byte("1"), Flags: 7})
+	if err := c.Update(ctx, "f", 0, incr); err != nil {
+		t.Fatal(err)
+	}
+	if it, err := c.Get("f"); err != nil || it.Flags != 7 || string(it.Value) != "2" {
+		t.Errorf("updated item = %+v, %v", it, err)
+	}
+
+	// An existing empty value is not reported as missing.
+	mustSet(t, c, &Item{Key: "e", Value: []byte{}})
+	c.Update(ctx, "e", 0, func(old []byte) ([]byte, error) {
+		if old == nil {
+			t.Error("empty value passed as nil")
+		}
+		return old, nil
+	})
+
+	// Errors from fn abort the update.
+	errAbort := errors.New("abort")
+	if err := c.Update(ctx, "n", 0, func([]byte) ([]byte, error) { return nil, errAbort }); err != errAbort {
+		t.Errorf("aborted Update = %v, want %v", err, errAbort)
+	}
+
+	// A conflict on every attempt gives up after UpdateAttempts.
+	c.UpdateAttempts = 3
+	calls := 0
+	err = c.Update(ctx, "n", 0, func(old []byte) ([]byte, error) {
+		calls++
+		mustSet(t, c, &Item{Key: "n", Value: []byte("0")})
+		return old, nil
+	})
+	if err != ErrCASConflict || calls != 3 {
+		t.Errorf("always-conflicting Update = %v after %d calls, want %v after 3", err, calls, ErrCASConflict)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := c.Update(cctx, "n", 0, incr); err != context.Canceled {
+		t.Errorf("canceled Update = %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package memcache
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// WarmCopyOptions configures WarmCopy.
+type WarmCopyOptions struct {
+	// ItemsPerSecond limits the rate at which items are copied, to at
+	// most one per nanosecond. If zero, items are copied as fast as
+	// possible.
+	ItemsPerSecond int
+
+	// Overwrite makes WarmCopy replace items already present in the
+	// destination. By default items are only added, so entries written
+	// to the destination since it went live are kept.
+	Overwrite bool
+}
+
+// WarmCopyStats reports the outcome of a WarmCopy.
+type WarmCopyStats struct {
+	// Listed is the number of keys listed on the source.
+	Listed int
+	// Copied is the number of items written to the destination.
+	Copied int
+	// Skipped is the number of listed keys that had expired, were gone
+	// by the time they were read, or already existed in the destination.
+	Skipped int
+	// Errors is the number of items that failed to copy.
+	Errors int
+}
+
+// WarmCopy copies the live items of every server of src into dst, for
+// pre-warming a new fleet. Keys are listed with MetaDump, so src must
+// use a *ServerList and run a memcached with the LRU crawler enabled.
+// Items are copied as stored, with the same keys, flags, values and
+// absolute expiration times: dst's KeyPrefix, KeyMapper, Transcoders,
+// defaults, jitter and chunking are not applied to them.
+//
+// WarmCopy stops at the first error listing keys; errors copying
+// individual items are only counted.
+func WarmCopy(src, dst *Client, opts WarmCopyOptions) (WarmCopyStats, error) {
+	var stats WarmCopyStats
+	var tick <-chan time.Time
+	if opts.ItemsPerSecond > 0 {
+		interval := time.Second / time.Duration(opts.ItemsPerSecond)
+		if interval <= 0 {
+			interval = time.Nanosecond
+		}
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	verb := "add"
+	if opts.Overwrite {
+		verb = "set"
+	}
+	for _, addr := range src.servers() {
+		// Keys are collected before copying so that the dump connection
+		// isn't held open while waiting on the rate limit.
+		var keys []KeyInfo
+		err := src.MetaDump(addr, func(ki KeyInfo) error {
+			keys = append(keys, ki)
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+		stats.Listed += len(keys)
+		for _, ki := range keys {
+			if tick != nil {
+				<-tick
+			}
+			copyOne(src, dst, addr, ki, verb, &stats)
+		}
+	}
+	return stats, nil
+}
+
+func copyOne(src, dst *Client, addr net.Addr, ki KeyInfo, verb string, stats *WarmCopyStats) {
+	if ki.Expiration >= 0 && ki.Expiration <= time.Now().Unix() || !legalKey(ki.Key) {
+		stats.Skipped++
+		return
+	}
+	var item *Item
+	if err := src.getFromAddr(addr, []string{ki.Key}, func(it *Item) { item = it }); err != nil {
+		stats.Errors++
+		return
+	}
+	if item == nil {
+		stats.Skipped++
+		return
+	}
+	if ki.Expiration > 0 {
+		item.Expiration = int32(ki.Expiration)
+	}
+	switch err := dst.storeAsStored(verb, item); {
+	case err == nil:
+		stats.Copied++
+	case errors.Is(err, ErrNotStored):
+		stats.Skipped++
+	default:
+		stats.Errors++
+	}
+}
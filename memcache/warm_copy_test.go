@@ -0,0 +1,68 @@
+package memcache
+
+import (
+	"testing"
+)
+
+func TestParseMetaDumpLine(t *testing.T) {
+	ki, err := parseMetaDumpLine("key=foo%2Fbar exp=1700000000 la=1690000000 cas=12 fetch=yes cls=1 size=70\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := KeyInfo{Key: "foo/bar", Expiration: 1700000000, LastAccess: 1690000000, Size: 70}
+	if ki != want {
+		t.Errorf("parseMetaDumpLine = %+v, want %+v", ki, want)
+	}
+	if _, err := parseMetaDumpLine("exp=-1 size=3\r\n"); err == nil {
+		t.Errorf("parseMetaDumpLine without key: want error")
+	}
+}
+
+func TestWarmCopy(t *testing.T) {
+	src, dst := newFakeServer(t), newFakeServer(t)
+	defer src.Close()
+	defer dst.Close()
+	src.put("foo", []byte("fooval"), 7)
+	src.put("bar", []byte("barval"), 0)
+	dst.put("bar", []byte("newer"), 0)
+
+	stats, err := WarmCopy(New(src.Addr()), New(dst.Addr()), WarmCopyOptions{ItemsPerSecond: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (WarmCopyStats{Listed: 2, Copied: 1, Skipped: 1}); stats != want {
+		t.Errorf("WarmCopy stats = %+v, want %+v", stats, want)
+	}
+	if it, ok := dst.get("foo"); !ok || string(it.value) != "fooval" || it.flags != 7 {
+		t.Errorf("foo not copied")
+	}
+	if it, _ := dst.get("bar"); string(it.value) != "newer" {
+		t.Errorf("bar overwritten without Overwrite")
+	}
+}
+
+func TestWarmCopyAsStored(t *testing.T) {
+	src, dst := newFakeServer(t), newFakeServer(t)
+	defer src.Close()
+	defer dst.Close()
+	src.put("app:foo", []byte("compressed"), DefaultCompressionFlag)
+
+	// The destination client's key mapping and Transcoders must not be
+	// applied again to items copied as stored.
+	dc := New(dst.Addr())
+	dc.KeyPrefix = "app:"
+	dc.Transcoders = []Transcoder{&CompressionTranscoder{MinSize: 1}}
+	stats, err := WarmCopy(New(src.Addr()), dc, WarmCopyOptions{ItemsPerSecond: 2e9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (WarmCopyStats{Listed: 1, Copied: 1}); stats != want {
+		t.Errorf("WarmCopy stats = %+v, want %+v", stats, want)
+	}
+	if it, ok := dst.get("app:foo"); !ok || string(it.value) != "compressed" || it.flags != DefaultCompressionFlag {
+		t.Errorf("app:foo not copied as stored: %+v", it)
+	}
+	if _, ok := dst.get("app:app:foo"); ok {
+		t.Errorf("key prefixed twice")
+	}
+}
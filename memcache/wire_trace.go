@@ -0,0 +1,126 @@
+package memcache
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// maxTraceBytes bounds the number of bytes captured in each direction
+// for a wire trace.
+const maxTraceBytes = 64 << 10
+
+// WireTrace holds the protocol lines exchanged for one operation.
+// Value bodies are replaced by a "<N bytes>" placeholder, and capture
+// stops after 64KB in each direction.
+type WireTrace struct {
+	Op       string
+	Addr     net.Addr
+	Request  []string
+	Response []string
+	Err      error
+
+	// Keys are the operation's keys, as selected by the client's
+	// ErrorKeys mode.
+	Keys []string
+}
+
+func appendCapped(buf, p []byte) []byte {
+	if room := maxTraceBytes - len(buf); len(p) > room {
+		p = p[:room]
+	}
+	return append(buf, p...)
+}
+
+func (cc *countingConn) startTrace() {
+	cc.tracing = true
+	cc.tracedRequest, cc.tracedResponse = nil, nil
+}
+
+func (cc *countingConn) stopTrace(op string, addr net.Addr, keys []string, err error) WireTrace {
+	cc.tracing = false
+	tr := WireTrace{
+		Op:       op,
+		Addr:     addr,
+		Keys:     keys,
+		Request:  sanitizeWire(cc.tracedRequest, requestValueLen),
+		Response: sanitizeWire(cc.tracedResponse, responseValueLen),
+		Err:      err,
+	}
+	cc.tracedRequest, cc.tracedResponse = nil, nil
+	return tr
+}
+
+// sanitizeWire splits captured protocol bytes into lines, replacing
+// the value body that follows any line for which valueLen reports a
+// length.
+func sanitizeWire(b []byte, valueLen func(fields [][]byte) int) []string {
+	var lines []string
+	for len(b) > 0 {
+		i := bytes.Index(b, crlf)
+		if i < 0 {
+			lines = append(lines, string(b)+" <truncated>")
+			break
+		}
+		line := b[:i]
+		b = b[i+2:]
+		lines = append(lines, string(line))
+		n := valueLen(bytes.Fields(line))
+		if n < 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("<%d bytes>", n))
+		if n+2 > len(b) {
+			break
+		}
+		b = b[n+2:]
+	}
+	return lines
+}
+
+// requestValueLen returns the length of the value following a request
+// line, or -1 if the command carries no value.
+func requestValueLen(f [][]byte) int {
+	if len(f) == 0 {
+		return -1
+	}
+	switch string(f[0]) {
+	case "set", "add", "replace", "append", "prepend", "cas":
+		if len(f) >= 5 {
+			return atoiOr(f[4], -1)
+		}
+	case "ms":
+		if len(f) >= 3 {
+			return atoiOr(f[2], -1)
+		}
+	}
+	return -1
+}
+
+// responseValueLen returns the length of the value following a
+// response line, or -1 if the line carries no value.
+func responseValueLen(f [][]byte) int {
+	if len(f) == 0 {
+		return -1
+	}
+	switch string(f[0]) {
+	case "VALUE":
+		if len(f) >= 4 {
+			return atoiOr(f[3], -1)
+		}
+	case "VA":
+		if len(f) >= 2 {
+			return atoiOr(f[1], -1)
+		}
+	}
+	return -1
+}
+
+func atoiOr(b []byte, def int) int {
+	n, err := strconv.Atoi(string(b))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,33 @@
+package memcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWireTrace(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	var traces []WireTrace
+	c.WireTraceHook = func(tr WireTrace) { traces = append(traces, tr) }
+	c.WireTraceFraction = 1
+
+	mustSet(t, c, &Item{Key: "foo", Value: []byte("secret value")})
+	if _, err := c.Get("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("got %d traces, want 2", len(traces))
+	}
+	set, get := traces[0], traces[1]
+	if want := []string{"set foo 0 0 12", "<12 bytes>"}; !reflect.DeepEqual(set.Request, want) {
+		t.Errorf("set request = %q, want %q", set.Request, want)
+	}
+	if want := []string{"STORED"}; !reflect.DeepEqual(set.Response, want) {
+		t.Errorf("set response = %q, want %q", set.Response, want)
+	}
+	if want := []string{"VALUE foo 0 12 1", "<12 bytes>", "END"}; !reflect.DeepEqual(get.Response, want) {
+		t.Errorf("get response = %q, want %q", get.Response, want)
+	}
+}
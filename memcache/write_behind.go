@@ -0,0 +1,152 @@
+package memcache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy selects what a WriteBehind does with a write when its
+// queue is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest rejects the new write with ErrQueueFull.
+	DropNewest OverflowPolicy = iota
+
+	// DropOldest discards the oldest queued write to make room.
+	DropOldest
+
+	// Block waits for room in the queue.
+	Block
+)
+
+var (
+	// ErrQueueFull is returned by WriteBehind.Set when the queue is full
+	// and the policy is DropNewest.
+	ErrQueueFull = errors.New("memcache: write-behind queue full")
+
+	// ErrQueueClosed is returned by WriteBehind.Set after Close.
+	ErrQueueClosed = errors.New("memcache: write-behind queue closed")
+)
+
+// WriteBehind queues Sets and writes them to the cache from background
+// workers, so that callers don't wait for the servers. Writes are
+// best-effort: failures are reported to OnError only.
+type WriteBehind struct {
+	c      *Client
+	policy OverflowPolicy
+	queue  chan *Item
+
+	// OnError, if non-nil, is called by the workers with each item that
+	// failed to be written and its error.
+	OnError func(item *Item, err error)
+
+	dropped uint64
+
+	// mu guards closed against sends on a closed queue; Set holds it
+	// for reading while sending.
+	mu     sync.RWMutex
+	closed bool
+
+	pendingMu sync.Mutex
+	pending   int
+	idle      *sync.Cond
+	wg        sync.WaitGroup
+}
+
+// NewWriteBehind returns a WriteBehind writing with c from the given
+// number of workers, queueing at most queueSize items.
+func NewWriteBehind(c *Client, queueSize, workers int, policy OverflowPolicy) *WriteBehind {
+	if workers < 1 {
+		workers = 1
+	}
+	w := &WriteBehind{c: c, policy: policy, queue: make(chan *Item, queueSize)}
+	w.idle = sync.NewCond(&w.pendingMu)
+	w.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go w.work()
+	}
+	return w
+}
+
+// Set queues item to be written, unconditionally, as Client.Set does.
+// The item is copied, but its Value must not be modified afterwards.
+func (w *WriteBehind) Set(item *Item) error {
+	it := *item
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrQueueClosed
+	}
+	w.addPending(1)
+	for {
+		select {
+		case w.queue <- &it:
+			return nil
+		default:
+		}
+		switch w.policy {
+		case DropOldest:
+			select {
+			case <-w.queue:
+				atomic.AddUint64(&w.dropped, 1)
+				w.addPending(-1)
+			default:
+			}
+		case Block:
+			w.queue <- &it
+			return nil
+		default:
+			atomic.AddUint64(&w.dropped, 1)
+			w.addPending(-1)
+			return ErrQueueFull
+		}
+	}
+}
+
+// Dropped returns the number of writes discarded because the queue was
+// full.
+func (w *WriteBehind) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Flush waits until every queued write has been attempted.
+func (w *WriteBehind) Flush() {
+	w.pendingMu.Lock()
+	defer w.pendingMu.Unlock()
+	for w.pending > 0 {
+		w.idle.Wait()
+	}
+}
+
+// Close stops accepting writes, writes those already queued and stops
+// the workers.
+func (w *WriteBehind) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+}
+
+func (w *WriteBehind) addPending(n int) {
+	w.pendingMu.Lock()
+	defer w.pendingMu.Unlock()
+	w.pending += n
+	if w.pending == 0 {
+		w.idle.Broadcast()
+	}
+}
+
+func (w *WriteBehind) work() {
+	defer w.wg.Done()
+	for it := range w.queue {
+		if err := w.c.Set(it); err != nil && w.OnError != nil {
+			w.OnError(it, err)
+		}
+		w.addPending(-1)
+	}
+}
@@ -0,0 +1,66 @@
+package memcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestWriteBehind(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.Close()
+	c := New(s.Addr())
+	w := NewWriteBehind(c, 16, 2, Block)
+	for i := 0; i < 100; i++ {
+		if err := w.Set(&Item{Key: fmt.Sprintf("k%d", i), Value: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	w.Flush()
+	for i := 0; i < 100; i++ {
+		if it, ok := s.get(fmt.Sprintf("k%d", i)); !ok || string(it.value) != fmt.Sprint(i) {
+			t.Fatalf("k%d not written after Flush", i)
+		}
+	}
+	w.Close()
+	if err := w.Set(&Item{Key: "late"}); err != ErrQueueClosed {
+		t.Errorf("Set after Close = %v, want ErrQueueClosed", err)
+	}
+	if w.Dropped() != 0 {
+		t.Errorf("Dropped = %d with Block policy", w.Dropped())
+	}
+}
+
+func TestWriteBehindOverflow(t *testing.T) {
+	// No server: writes fail, slowly enough for the queue to fill.
+	c := New("127.0.0.1:1")
+	for _, policy := range []OverflowPolicy{DropNewest, DropOldest} {
+		w := NewWriteBehind(c, 1, 1, policy)
+		var mu sync.Mutex
+		var failed int
+		w.OnError = func(*Item, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed++
+		}
+		full := 0
+		for i := 0; i < 50; i++ {
+			if err := w.Set(&Item{Key: "k", Value: []byte("v")}); err == ErrQueueFull {
+				full++
+			}
+		}
+		w.Close()
+		mu.Lock()
+		attempted := failed
+		mu.Unlock()
+		if attempted+int(w.Dropped()) != 50 {
+			t.Errorf("policy %d: %d attempted + %d dropped, want 50", policy, attempted, w.Dropped())
+		}
+		if policy == DropNewest && full != int(w.Dropped()) {
+			t.Errorf("DropNewest: %d ErrQueueFull, %d dropped", full, w.Dropped())
+		}
+		if policy == DropOldest && full != 0 {
+			t.Errorf("DropOldest returned ErrQueueFull %d times", full)
+		}
+	}
+}